@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/rulesync"
+	"riskmatrix/pkg/api"
+	"riskmatrix/pkg/database"
+)
+
+func main() {
+	dbPath := flag.String("db", "data/riskmatrix.db", "Path to SQLite database file")
+	dir := flag.String("dir", "detections", "Directory of detection rule files")
+	dryRun := flag.Bool("dry-run", false, "Show the changes without applying them")
+	jsonOutput := flag.Bool("json", false, "Print the plan as JSON")
+	flag.Parse()
+
+	defs, err := rulesync.LoadDir(*dir)
+	if err != nil {
+		log.Fatalf("Error loading rule files: %v", err)
+	}
+
+	db, err := database.New(*dbPath)
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	// Status changes follow the lifecycle configured for the server
+	repo := rulesync.NewRepository(db)
+	repo.SetLifecycle(detection.NewLifecycle(detection.NewRepository(db), api.LoadLifecycleConfig()))
+
+	var plan *rulesync.Plan
+	if *dryRun {
+		plan, err = repo.Plan(defs)
+	} else {
+		plan, err = repo.Apply(defs)
+	}
+	if err != nil {
+		log.Fatalf("Error syncing detections:\n%v", err)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(plan); err != nil {
+			log.Fatalf("Error writing plan: %v", err)
+		}
+		return
+	}
+
+	printPlan(os.Stdout, plan)
+	verb := "Synced"
+	if *dryRun {
+		verb = "Would sync"
+	}
+	fmt.Printf("%s %d rule files: %d created, %d updated, %d retired, %d unchanged\n",
+		verb, len(defs), plan.Created, plan.Updated, plan.Retired, plan.Unchanged)
+}
+
+// printPlan writes the plan as a diff: + for created detections, ~ for
+// updated ones with each changed field, and - for retired ones
+func printPlan(w io.Writer, plan *rulesync.Plan) {
+	for _, action := range plan.Actions {
+		switch action.Action {
+		case rulesync.ActionCreate:
+			fmt.Fprintf(w, "+ %s %s (%s)\n", action.ExternalID, action.Name, action.File)
+		case rulesync.ActionUpdate:
+			fmt.Fprintf(w, "~ %s %s (%s)\n", action.ExternalID, action.Name, action.File)
+			for _, change := range action.Changes {
+				fmt.Fprintf(w, "    %s: %q -> %q\n", change.Field, change.Old, change.New)
+			}
+		case rulesync.ActionRetire:
+			fmt.Fprintf(w, "- %s %s\n", action.ExternalID, action.Name)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -19,6 +20,11 @@ func main() {
 	// Parse command line flags
 	dbPath := flag.String("db", "data/riskmatrix.db", "Path to SQLite database file")
 	csvPath := flag.String("csv", "data/mitre.csv", "Path to MITRE CSV file")
+	stixPaths := flag.String("stix", "", "Comma-separated paths to MITRE ATT&CK STIX 2.1 bundles, imported instead of the CSV file")
+	jsonOutput := flag.Bool("json", false, "Print the STIX import report as JSON")
+	version := flag.String("version", "", "ATT&CK version of the imported data (default: read from the STIX bundles)")
+	remap := flag.Bool("remap", false, "After a STIX import, move detections mapped to revoked techniques onto their replacements")
+	groupsCSV := flag.String("groups-csv", "", "Path to a CSV of threat group technique uses, imported instead of the CSV file")
 	flag.Parse()
 
 	// Ensure data directory exists
@@ -26,6 +32,16 @@ func main() {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
+	if *stixPaths != "" {
+		importSTIX(*dbPath, splitAndTrim(*stixPaths, ","), *version, *remap, *jsonOutput)
+	}
+	if *groupsCSV != "" {
+		importGroupsCSV(*dbPath, *groupsCSV)
+	}
+	if *stixPaths != "" || *groupsCSV != "" {
+		return
+	}
+
 	// Open the CSV file
 	file, err := os.Open(*csvPath)
 	if err != nil {
@@ -75,10 +91,11 @@ func main() {
 
 		// Create a new MITRE technique
 		technique := &models.MitreTechnique{
-			ID:          row[columnMap["ID"]],
-			Name:        row[columnMap["name"]],
-			Description: row[columnMap["description"]],
-			Tactic:      extractPrimaryTactic(row[columnMap["tactics"]]),
+			ID:            row[columnMap["ID"]],
+			Name:          row[columnMap["name"]],
+			Description:   row[columnMap["description"]],
+			Tactic:        extractPrimaryTactic(row[columnMap["tactics"]]),
+			AttackVersion: *version,
 		}
 
 		// Set additional fields
@@ -133,6 +150,151 @@ func main() {
 	fmt.Printf("Successfully imported %d MITRE techniques\n", count)
 }
 
+// importSTIX upserts the techniques of STIX bundles in one transaction and
+// prints what changed, optionally remapping detections off revoked
+// techniques afterwards. Techniques are stamped with the version of their
+// bundle unless a version is given.
+func importSTIX(dbPath string, paths []string, version string, remap, jsonOutput bool) {
+	techniques := make([]*models.MitreTechnique, 0)
+	groups := make([]*models.ThreatGroup, 0)
+	versions := make(map[string]bool)
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Error opening STIX bundle: %v", err)
+		}
+		bundle, err := mitre.ParseBundle(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", path, err)
+		}
+		techniques = append(techniques, bundle.Techniques...)
+		groups = append(groups, bundle.Groups...)
+		versions[bundle.Version] = true
+	}
+	if version == "" && len(versions) == 1 {
+		for v := range versions {
+			version = v
+		}
+	}
+
+	db, err := database.New(dbPath)
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	repo := mitre.NewRepository(db)
+	report, err := repo.ImportTechniques(techniques, version)
+	if err != nil {
+		log.Fatalf("Error importing techniques: %v", err)
+	}
+	if len(groups) > 0 {
+		report.Groups, err = repo.ImportGroups(groups, version)
+		if err != nil {
+			log.Fatalf("Error importing threat groups: %v", err)
+		}
+	}
+
+	if remap {
+		report.Remapped, err = repo.ApplyRemaps(nil, "import-mitre")
+		if err != nil {
+			log.Fatalf("Error remapping detections: %v", err)
+		}
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Error writing report: %v", err)
+		}
+		return
+	}
+
+	printReport(os.Stdout, report)
+
+	proposals, err := repo.ProposeRemaps()
+	if err != nil {
+		log.Fatalf("Error listing remaps: %v", err)
+	}
+	for _, proposal := range proposals {
+		if proposal.ReplacementID != "" {
+			fmt.Printf("! detection %d (%s) is mapped to %s, %s by %s; rerun with -remap to move it\n",
+				proposal.DetectionID, proposal.DetectionName, proposal.TechniqueID, proposal.Reason, proposal.ReplacementID)
+		} else {
+			fmt.Printf("! detection %d (%s) is mapped to %s %s, which has no replacement\n",
+				proposal.DetectionID, proposal.DetectionName, proposal.Reason, proposal.TechniqueID)
+		}
+	}
+
+	if report.Version != "" {
+		fmt.Printf("ATT&CK version %s\n", report.Version)
+	}
+	if report.Groups != nil {
+		printGroupReport(report.Groups)
+	}
+	fmt.Printf("Imported %d techniques from %d bundles: %d added, %d changed, %d revoked, %d deprecated, %d unchanged, %d skipped\n",
+		len(techniques), len(paths), len(report.Added), len(report.Changed), len(report.Revoked), len(report.Deprecated), report.Unchanged, report.Skipped)
+}
+
+// printReport writes the import report as a diff: + for added techniques,
+// ~ for changed ones with the fields that changed, - for revoked or
+// deprecated ones, and > for remapped detections
+func printReport(w io.Writer, report *mitre.ImportReport) {
+	for _, id := range report.Added {
+		fmt.Fprintf(w, "+ %s\n", id)
+	}
+	for _, change := range report.Changed {
+		fmt.Fprintf(w, "~ %s (%s)\n", change.ID, strings.Join(change.Fields, ", "))
+	}
+	for _, id := range report.Revoked {
+		fmt.Fprintf(w, "- %s revoked\n", id)
+	}
+	for _, id := range report.Deprecated {
+		fmt.Fprintf(w, "- %s deprecated\n", id)
+	}
+	for _, remap := range report.Remapped {
+		fmt.Fprintf(w, "> detection %d remapped from %s to %s\n", remap.DetectionID, remap.OldTechniqueID, remap.NewTechniqueID)
+	}
+}
+
+// importGroupsCSV imports threat groups and the techniques they use from a
+// CSV file
+func importGroupsCSV(dbPath, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Error opening groups CSV: %v", err)
+	}
+	defer file.Close()
+
+	groups, err := mitre.ParseGroupsCSV(file)
+	if err != nil {
+		log.Fatalf("Error reading %s: %v", path, err)
+	}
+
+	db, err := database.New(dbPath)
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	report, err := mitre.NewRepository(db).ImportGroups(groups, "")
+	if err != nil {
+		log.Fatalf("Error importing threat groups: %v", err)
+	}
+	printGroupReport(report)
+}
+
+// printGroupReport prints a summary of a threat group import, warning about
+// techniques missing from the catalog
+func printGroupReport(report *mitre.GroupImportReport) {
+	if len(report.Unknown) > 0 {
+		fmt.Printf("! %d techniques used by groups are not in the catalog: %s\n", len(report.Unknown), strings.Join(report.Unknown, ", "))
+	}
+	fmt.Printf("Imported %d threat groups with %d technique uses\n", report.Groups, report.Uses)
+}
+
 // extractPrimaryTactic extracts the first tactic from a comma-separated list
 func extractPrimaryTactic(tactics string) string {
 	parts := splitAndTrim(tactics, ",")
@@ -33,10 +33,22 @@ func main() {
 	// Create and start server
 	server := api.NewServer(db)
 
+	// Start event ingestion; queued events are processed before the
+	// database is closed
+	stopIngestion, err := server.StartIngestion()
+	if err != nil {
+		log.Fatalf("Failed to start event ingestion: %v", err)
+	}
+	defer stopIngestion()
+
 	// Start risk decay process
 	stopDecay := server.StartRiskDecayProcess()
 	defer close(stopDecay)
 
+	// Start checking pending emulation tests
+	stopEmulationChecks := server.StartEmulationCheckProcess()
+	defer close(stopEmulationChecks)
+
 	// Handle graceful shutdown
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
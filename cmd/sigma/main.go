@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/sigma"
+	"riskmatrix/pkg/api"
+	"riskmatrix/pkg/database"
+)
+
+const usage = `Usage:
+  sigma import [-db path] <rule file or directory>...
+  sigma export [-db path] [-id detection_id] [-out directory]`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "import":
+		runImport(os.Args[2:])
+	case "export":
+		runExport(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// runImport imports Sigma rules from files, or from .yml and .yaml files
+// under directories
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dbPath := flags.String("db", "data/riskmatrix.db", "Path to SQLite database file")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		log.Fatalf("No rule files given\n%s", usage)
+	}
+
+	paths := make([]string, 0)
+	for _, root := range flags.Args() {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			// Files named on the command line are read whatever their extension
+			ext := strings.ToLower(filepath.Ext(path))
+			if path == root || ext == ".yml" || ext == ".yaml" {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", root, err)
+		}
+	}
+
+	db, err := database.New(*dbPath)
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	repo := newRepository(db)
+
+	counts := make(map[string]int)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Warning: Error reading %s: %v", path, err)
+			counts[sigma.ImportFailed]++
+			continue
+		}
+
+		rules, err := sigma.Parse(data)
+		if err != nil {
+			log.Printf("Warning: Error parsing %s: %v", path, err)
+			counts[sigma.ImportFailed]++
+			continue
+		}
+
+		for _, result := range repo.Import(rules) {
+			counts[result.Action]++
+			for _, warning := range result.Warnings {
+				log.Printf("Warning: %s: %s: %s", path, result.Title, warning)
+			}
+			if result.Error != "" {
+				log.Printf("Warning: %s: %s: %s", path, result.Title, result.Error)
+			}
+		}
+	}
+
+	fmt.Printf("Imported Sigma rules: %d created, %d updated, %d failed\n",
+		counts[sigma.ImportCreated], counts[sigma.ImportUpdated], counts[sigma.ImportFailed])
+}
+
+// runExport writes one detection to stdout, or detections to a directory as
+// one rule file each
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := flags.String("db", "data/riskmatrix.db", "Path to SQLite database file")
+	id := flags.Int64("id", 0, "Detection to export; all detections when not set")
+	outDir := flags.String("out", "", "Directory to write rule files to; stdout when not set")
+	flags.Parse(args)
+
+	if *id == 0 && *outDir == "" {
+		log.Fatalf("Exporting all detections needs -out\n%s", usage)
+	}
+
+	db, err := database.New(*dbPath)
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	repo := newRepository(db)
+
+	ids := []int64{*id}
+	if *id == 0 {
+		detections, err := detection.NewRepository(db).ListDetections()
+		if err != nil {
+			log.Fatalf("Error listing detections: %v", err)
+		}
+		ids = make([]int64, 0, len(detections))
+		for _, det := range detections {
+			ids = append(ids, det.ID)
+		}
+	}
+
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0755); err != nil {
+			log.Fatalf("Error creating %s: %v", *outDir, err)
+		}
+	}
+
+	written := make(map[string]bool)
+	for _, detectionID := range ids {
+		rule, err := repo.Export(detectionID)
+		if err != nil {
+			log.Fatalf("Error exporting detection %d: %v", detectionID, err)
+		}
+		data, err := rule.Render()
+		if err != nil {
+			log.Fatalf("Error exporting detection %d: %v", detectionID, err)
+		}
+
+		if *outDir == "" {
+			os.Stdout.Write(data)
+			continue
+		}
+
+		// Detections may share a name, so later ones get their ID appended
+		name := ruleFileName(rule.Title)
+		if written[name] {
+			name = fmt.Sprintf("%s_%d", name, detectionID)
+		}
+		written[name] = true
+
+		path := filepath.Join(*outDir, name+".yml")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			log.Fatalf("Error writing %s: %v", path, err)
+		}
+	}
+
+	if *outDir != "" {
+		fmt.Printf("Exported %d Sigma rules to %s\n", len(ids), *outDir)
+	}
+}
+
+// newRepository creates a Sigma repository that follows the detection
+// lifecycle configured for the server
+func newRepository(db *database.DB) *sigma.Repository {
+	repo := sigma.NewRepository(db)
+	repo.SetLifecycle(detection.NewLifecycle(detection.NewRepository(db), api.LoadLifecycleConfig()))
+	return repo
+}
+
+// nonAlphanumeric matches runs of characters not used in rule file names
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ruleFileName returns a file name for a rule from its title
+func ruleFileName(title string) string {
+	name := strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(title), "_"), "_")
+	if name == "" {
+		return "rule"
+	}
+	return name
+}
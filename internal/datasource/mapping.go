@@ -0,0 +1,368 @@
+package datasource
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+// DefaultEntityPriority is the order entity types are tried in for an event's
+// risk object when a mapping does not set one
+var DefaultEntityPriority = []models.EntityType{
+	models.EntityTypeUser,
+	models.EntityTypeHost,
+	models.EntityTypeIP,
+}
+
+// Record holds the values a mapping profile extracts from a raw record
+type Record struct {
+	User          string     `json:"user,omitempty"`
+	Host          string     `json:"host,omitempty"`
+	IP            string     `json:"ip,omitempty"`
+	Timestamp     *time.Time `json:"timestamp,omitempty"`
+	DetectionName string     `json:"detection_name,omitempty"`
+}
+
+// parseMapping decodes a stored mapping profile, ignoring missing or
+// malformed values
+func parseMapping(mapping sql.NullString) *models.DataSourceMapping {
+	if !mapping.Valid || mapping.String == "" {
+		return nil
+	}
+
+	var parsed models.DataSourceMapping
+	if err := json.Unmarshal([]byte(mapping.String), &parsed); err != nil {
+		return nil
+	}
+	return &parsed
+}
+
+// formatMapping encodes a mapping profile for storage
+func formatMapping(mapping *models.DataSourceMapping) (sql.NullString, error) {
+	if mapping == nil {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// ValidateMapping checks that a mapping profile's paths, patterns and entity
+// types are usable
+func ValidateMapping(mapping *models.DataSourceMapping) error {
+	for name, extractor := range mappingExtractors(mapping) {
+		if extractor.Path == "" && extractor.Regex == "" {
+			return fmt.Errorf("%s extractor needs a path or regex", name)
+		}
+		if extractor.Path != "" {
+			if _, err := parseJSONPath(extractor.Path); err != nil {
+				return fmt.Errorf("invalid %s path: %w", name, err)
+			}
+		}
+		if extractor.Regex != "" {
+			if _, err := regexp.Compile(extractor.Regex); err != nil {
+				return fmt.Errorf("invalid %s regex: %w", name, err)
+			}
+		}
+	}
+
+	for _, entityType := range mapping.EntityPriority {
+		switch entityType {
+		case models.EntityTypeUser, models.EntityTypeHost, models.EntityTypeIP:
+		default:
+			return fmt.Errorf("invalid entity type in entity priority: %s", entityType)
+		}
+	}
+
+	return nil
+}
+
+// mappingExtractors returns a mapping's configured extractors by field name
+func mappingExtractors(mapping *models.DataSourceMapping) map[string]*models.FieldExtractor {
+	extractors := make(map[string]*models.FieldExtractor)
+	for name, extractor := range map[string]*models.FieldExtractor{
+		"user":           mapping.User,
+		"host":           mapping.Host,
+		"ip":             mapping.IP,
+		"timestamp":      mapping.Timestamp,
+		"detection_name": mapping.DetectionName,
+	} {
+		if extractor != nil {
+			extractors[name] = extractor
+		}
+	}
+	return extractors
+}
+
+// Normalize extracts the fields of a raw record with a mapping profile.
+// Fields whose extractor finds nothing are left empty.
+func Normalize(mapping *models.DataSourceMapping, raw string) (*Record, error) {
+	if err := ValidateMapping(mapping); err != nil {
+		return nil, err
+	}
+
+	// Records that are not JSON can still be read with regexes
+	var doc interface{}
+	isJSON := json.Unmarshal([]byte(raw), &doc) == nil
+
+	extract := func(extractor *models.FieldExtractor) (string, error) {
+		if extractor == nil {
+			return "", nil
+		}
+
+		value := raw
+		if extractor.Path != "" {
+			if !isJSON {
+				return "", fmt.Errorf("record is not JSON")
+			}
+			path, _ := parseJSONPath(extractor.Path)
+			value = path.first(doc)
+		}
+
+		if extractor.Regex != "" && value != "" {
+			value = extractRegex(regexp.MustCompile(extractor.Regex), value)
+		}
+		return strings.TrimSpace(value), nil
+	}
+
+	record := &Record{}
+	var err error
+	if record.User, err = extract(mapping.User); err != nil {
+		return nil, err
+	}
+	if record.Host, err = extract(mapping.Host); err != nil {
+		return nil, err
+	}
+	if record.IP, err = extract(mapping.IP); err != nil {
+		return nil, err
+	}
+	if record.DetectionName, err = extract(mapping.DetectionName); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := extract(mapping.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if timestamp != "" {
+		parsed, err := ParseTimestamp(timestamp, mapping.TimestampFormat)
+		if err != nil {
+			return nil, err
+		}
+		record.Timestamp = &parsed
+	}
+
+	return record, nil
+}
+
+// RiskObject returns the entity a record's risk is attributed to: the first
+// entity type in priority order that the record has a value for, or nil
+func (r *Record) RiskObject(priority []models.EntityType) *models.RiskObject {
+	if len(priority) == 0 {
+		priority = DefaultEntityPriority
+	}
+
+	values := map[models.EntityType]string{
+		models.EntityTypeUser: r.User,
+		models.EntityTypeHost: r.Host,
+		models.EntityTypeIP:   r.IP,
+	}
+	for _, entityType := range priority {
+		if value := values[entityType]; value != "" {
+			return &models.RiskObject{EntityType: entityType, EntityValue: value}
+		}
+	}
+	return nil
+}
+
+// extractRegex returns the "value" named group of the first match, or else
+// its first group, or else the whole match
+func extractRegex(re *regexp.Regexp, value string) string {
+	match := re.FindStringSubmatch(value)
+	if match == nil {
+		return ""
+	}
+	if i := re.SubexpIndex("value"); i > 0 {
+		return match[i]
+	}
+	if len(match) > 1 {
+		return match[1]
+	}
+	return match[0]
+}
+
+// timestampLayouts are the text formats accepted for event times without a
+// layout, including the CEF receipt time format
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"Jan 02 2006 15:04:05.000",
+	"Jan 02 2006 15:04:05",
+}
+
+// ParseTimestamp parses an event time with layout, or when layout is empty
+// in one of the accepted text formats or as Unix seconds or milliseconds.
+// Mapping profiles and the event listeners share it so that both read times
+// the same way.
+func ParseTimestamp(value, layout string) (time.Time, error) {
+	if layout != "" {
+		timestamp, err := time.Parse(layout, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp: %s", value)
+		}
+		return timestamp, nil
+	}
+
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		// Values this large are milliseconds
+		if number > 1e11 {
+			number /= 1000
+		}
+		seconds := int64(number)
+		return time.Unix(seconds, int64((number-float64(seconds))*1e9)).UTC(), nil
+	}
+
+	for _, layout := range timestampLayouts {
+		if timestamp, err := time.Parse(layout, value); err == nil {
+			return timestamp, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp: %s", value)
+}
+
+// jsonPath is a parsed JSONPath: a sequence of object keys, array indexes and
+// wildcards
+type jsonPath []pathSegment
+
+// pathSegment is one step of a JSONPath. An index of -1 with an empty key is
+// a wildcard.
+type pathSegment struct {
+	key   string
+	index int
+}
+
+// parseJSONPath parses the JSONPath subset used by mapping profiles: $,
+// .key, ['key'], [n], [*] and .*
+func parseJSONPath(path string) (jsonPath, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		// Allow a bare key as the first segment
+		rest = "." + rest
+	}
+
+	segments := make(jsonPath, 0)
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			if key == "" {
+				return nil, fmt.Errorf("empty key in %s", path)
+			}
+			if key == "*" {
+				segments = append(segments, pathSegment{index: -1})
+			} else {
+				segments = append(segments, pathSegment{key: key})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated bracket in %s", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			switch {
+			case inner == "*":
+				segments = append(segments, pathSegment{index: -1})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid index %s in %s", inner, path)
+				}
+				segments = append(segments, pathSegment{index: index})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in %s", rest[0], path)
+		}
+	}
+
+	return segments, nil
+}
+
+// first returns the first non-empty value the path selects in a decoded
+// JSON document, as a string
+func (p jsonPath) first(doc interface{}) string {
+	if len(p) == 0 {
+		return FieldValue(doc)
+	}
+
+	segment, rest := p[0], p[1:]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if segment.key != "" {
+			if child, ok := node[segment.key]; ok {
+				return rest.first(child)
+			}
+			return ""
+		}
+		if segment.index == -1 {
+			for _, child := range node {
+				if value := rest.first(child); value != "" {
+					return value
+				}
+			}
+		}
+	case []interface{}:
+		if segment.key != "" {
+			return ""
+		}
+		if segment.index == -1 {
+			for _, child := range node {
+				if value := rest.first(child); value != "" {
+					return value
+				}
+			}
+			return ""
+		}
+		if segment.index < len(node) {
+			return rest.first(node[segment.index])
+		}
+	}
+	return ""
+}
+
+// FieldValue returns a decoded JSON value as a field string; objects and
+// arrays are re-encoded
+func FieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,146 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestNormalize_CloudTrail(t *testing.T) {
+	mapping := &models.DataSourceMapping{
+		User:          &models.FieldExtractor{Path: "$.userIdentity.userName"},
+		IP:            &models.FieldExtractor{Path: "$.sourceIPAddress"},
+		Timestamp:     &models.FieldExtractor{Path: "$.eventTime"},
+		DetectionName: &models.FieldExtractor{Path: "$.resources[*].ARN", Regex: `:role/(?P<value>[^/]+)$`},
+	}
+	raw := `{"eventTime": "2026-10-16T09:30:00Z", "sourceIPAddress": "10.0.0.5",
+		"userIdentity": {"userName": "alice"},
+		"resources": [{"type": "bucket"}, {"ARN": "arn:aws:iam::1:role/AssumeAdmin"}]}`
+
+	record, err := Normalize(mapping, raw)
+	if err != nil {
+		t.Fatalf("Failed to normalize record: %v", err)
+	}
+
+	if record.User != "alice" || record.IP != "10.0.0.5" || record.Host != "" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	if record.DetectionName != "AssumeAdmin" {
+		t.Errorf("Expected detection name 'AssumeAdmin', got '%s'", record.DetectionName)
+	}
+	if record.Timestamp == nil || !record.Timestamp.Equal(time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected timestamp %v", record.Timestamp)
+	}
+
+	// The user comes first by default, then the entity priority decides
+	if obj := record.RiskObject(nil); obj.EntityType != models.EntityTypeUser || obj.EntityValue != "alice" {
+		t.Errorf("Expected user alice, got %+v", obj)
+	}
+	if obj := record.RiskObject([]models.EntityType{models.EntityTypeHost, models.EntityTypeIP}); obj.EntityType != models.EntityTypeIP {
+		t.Errorf("Expected ip, got %+v", obj)
+	}
+}
+
+func TestNormalize_Regex(t *testing.T) {
+	mapping := &models.DataSourceMapping{
+		Host:            &models.FieldExtractor{Regex: `host=(\S+)`},
+		User:            &models.FieldExtractor{Regex: `user=(\S+)`},
+		DetectionName:   &models.FieldExtractor{Regex: `rule="([^"]+)"`},
+		Timestamp:       &models.FieldExtractor{Regex: `^(\S+ \S+)`},
+		TimestampFormat: "2006-01-02 15:04:05",
+	}
+
+	record, err := Normalize(mapping, `2026-10-16 09:30:00 host=ws-1 rule="Credential Dump"`)
+	if err != nil {
+		t.Fatalf("Failed to normalize record: %v", err)
+	}
+	if record.Host != "ws-1" || record.User != "" || record.DetectionName != "Credential Dump" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	if record.Timestamp == nil || record.Timestamp.Hour() != 9 {
+		t.Errorf("Unexpected timestamp %v", record.Timestamp)
+	}
+
+	// A path cannot be read from a record that is not JSON
+	if _, err := Normalize(&models.DataSourceMapping{Host: &models.FieldExtractor{Path: "$.host"}}, "host=ws-1"); err == nil {
+		t.Error("Expected error for a path into a non-JSON record")
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	expected := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		value, layout string
+	}{
+		{"2026-10-16T09:30:00Z", ""},
+		{"1792143000", ""},
+		{"1792143000000", ""},
+		{"Oct 16 2026 09:30:00", ""}, // CEF receipt time
+		{"16/10/2026 09:30", "02/01/2006 15:04"},
+	} {
+		timestamp, err := ParseTimestamp(tt.value, tt.layout)
+		if err != nil || !timestamp.Equal(expected) {
+			t.Errorf("ParseTimestamp(%q, %q) = %v, %v", tt.value, tt.layout, timestamp, err)
+		}
+	}
+
+	if _, err := ParseTimestamp("yesterday", ""); err == nil {
+		t.Error("Expected error for an unreadable timestamp")
+	}
+}
+
+func TestValidateMapping(t *testing.T) {
+	valid := &models.DataSourceMapping{
+		User:           &models.FieldExtractor{Path: "$['user name'][0]"},
+		Host:           &models.FieldExtractor{Path: "host", Regex: `(?P<value>\w+)`},
+		EntityPriority: []models.EntityType{models.EntityTypeHost},
+	}
+	if err := ValidateMapping(valid); err != nil {
+		t.Errorf("Expected mapping to be valid: %v", err)
+	}
+
+	for name, mapping := range map[string]*models.DataSourceMapping{
+		"empty extractor": {User: &models.FieldExtractor{}},
+		"bad path":        {User: &models.FieldExtractor{Path: "$.user[x]"}},
+		"unclosed path":   {User: &models.FieldExtractor{Path: "$.user[0"}},
+		"bad regex":       {User: &models.FieldExtractor{Regex: "("}},
+		"bad entity type": {EntityPriority: []models.EntityType{"printer"}},
+	} {
+		if err := ValidateMapping(mapping); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+func TestRepository_Mapping(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	dataSource := &models.DataSource{
+		Name: "cloudtrail",
+		Mapping: &models.DataSourceMapping{
+			User:           &models.FieldExtractor{Path: "$.userIdentity.userName"},
+			EntityPriority: []models.EntityType{models.EntityTypeUser},
+		},
+	}
+	if err := repo.CreateDataSource(dataSource); err != nil {
+		t.Fatalf("Failed to create data source: %v", err)
+	}
+
+	saved, err := repo.GetDataSource(dataSource.ID)
+	if err != nil {
+		t.Fatalf("Failed to get data source: %v", err)
+	}
+	if saved.Mapping == nil || saved.Mapping.User == nil || saved.Mapping.User.Path != "$.userIdentity.userName" {
+		t.Fatalf("Expected mapping to be saved, got %+v", saved.Mapping)
+	}
+
+	saved.Mapping = nil
+	if err := repo.UpdateDataSource(saved); err != nil {
+		t.Fatalf("Failed to update data source: %v", err)
+	}
+	if updated, _ := repo.GetDataSourceByName("cloudtrail"); updated.Mapping != nil {
+		t.Errorf("Expected mapping to be cleared, got %+v", updated.Mapping)
+	}
+}
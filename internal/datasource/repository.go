@@ -20,17 +20,19 @@ func NewRepository(db *database.DB) *Repository {
 
 // GetDataSource retrieves a data source by ID
 func (r *Repository) GetDataSource(id int64) (*models.DataSource, error) {
-	query := `SELECT id, name, description, log_format FROM data_sources WHERE id = ?`
+	query := `SELECT id, name, description, log_format, mapping FROM data_sources WHERE id = ?`
 
 	row := r.db.QueryRow(query, id)
 
 	var dataSource models.DataSource
+	var mapping sql.NullString
 
 	err := row.Scan(
 		&dataSource.ID,
 		&dataSource.Name,
 		&dataSource.Description,
 		&dataSource.LogFormat,
+		&mapping,
 	)
 
 	if err != nil {
@@ -39,23 +41,26 @@ func (r *Repository) GetDataSource(id int64) (*models.DataSource, error) {
 		}
 		return nil, fmt.Errorf("error scanning data source: %w", err)
 	}
+	dataSource.Mapping = parseMapping(mapping)
 
 	return &dataSource, nil
 }
 
 // GetDataSourceByName retrieves a data source by name
 func (r *Repository) GetDataSourceByName(name string) (*models.DataSource, error) {
-	query := `SELECT id, name, description, log_format FROM data_sources WHERE name = ?`
+	query := `SELECT id, name, description, log_format, mapping FROM data_sources WHERE name = ?`
 
 	row := r.db.QueryRow(query, name)
 
 	var dataSource models.DataSource
+	var mapping sql.NullString
 
 	err := row.Scan(
 		&dataSource.ID,
 		&dataSource.Name,
 		&dataSource.Description,
 		&dataSource.LogFormat,
+		&mapping,
 	)
 
 	if err != nil {
@@ -64,13 +69,14 @@ func (r *Repository) GetDataSourceByName(name string) (*models.DataSource, error
 		}
 		return nil, fmt.Errorf("error scanning data source: %w", err)
 	}
+	dataSource.Mapping = parseMapping(mapping)
 
 	return &dataSource, nil
 }
 
 // ListDataSources retrieves all data sources
 func (r *Repository) ListDataSources() ([]*models.DataSource, error) {
-	query := `SELECT id, name, description, log_format FROM data_sources ORDER BY name`
+	query := `SELECT id, name, description, log_format, mapping FROM data_sources ORDER BY name`
 
 	rows, err := r.db.Query(query)
 	if err != nil {
@@ -82,17 +88,20 @@ func (r *Repository) ListDataSources() ([]*models.DataSource, error) {
 
 	for rows.Next() {
 		var dataSource models.DataSource
+		var mapping sql.NullString
 
 		err := rows.Scan(
 			&dataSource.ID,
 			&dataSource.Name,
 			&dataSource.Description,
 			&dataSource.LogFormat,
+			&mapping,
 		)
 
 		if err != nil {
 			return nil, fmt.Errorf("error scanning data source row: %w", err)
 		}
+		dataSource.Mapping = parseMapping(mapping)
 
 		dataSources = append(dataSources, &dataSource)
 	}
@@ -102,18 +111,34 @@ func (r *Repository) ListDataSources() ([]*models.DataSource, error) {
 
 // CreateDataSource creates a new data source
 func (r *Repository) CreateDataSource(dataSource *models.DataSource) error {
+	return createDataSource(r.db, dataSource)
+}
+
+// CreateDataSourceTx creates a new data source within a transaction
+func (r *Repository) CreateDataSourceTx(tx *sql.Tx, dataSource *models.DataSource) error {
+	return createDataSource(tx, dataSource)
+}
+
+// createDataSource creates a new data source with db
+func createDataSource(db database.Execer, dataSource *models.DataSource) error {
 	// Validate required fields
 	if dataSource.Name == "" {
 		return fmt.Errorf("data source name cannot be empty")
 	}
 
-	query := `INSERT INTO data_sources (name, description, log_format) VALUES (?, ?, ?)`
+	mapping, err := formatMapping(dataSource.Mapping)
+	if err != nil {
+		return fmt.Errorf("error encoding mapping: %w", err)
+	}
 
-	result, err := r.db.Exec(
+	query := `INSERT INTO data_sources (name, description, log_format, mapping) VALUES (?, ?, ?, ?)`
+
+	result, err := db.Exec(
 		query,
 		dataSource.Name,
 		dataSource.Description,
 		dataSource.LogFormat,
+		mapping,
 	)
 
 	if err != nil {
@@ -131,13 +156,19 @@ func (r *Repository) CreateDataSource(dataSource *models.DataSource) error {
 
 // UpdateDataSource updates an existing data source
 func (r *Repository) UpdateDataSource(dataSource *models.DataSource) error {
-	query := `UPDATE data_sources SET name = ?, description = ?, log_format = ? WHERE id = ?`
+	mapping, err := formatMapping(dataSource.Mapping)
+	if err != nil {
+		return fmt.Errorf("error encoding mapping: %w", err)
+	}
+
+	query := `UPDATE data_sources SET name = ?, description = ?, log_format = ?, mapping = ? WHERE id = ?`
 
 	result, err := r.db.Exec(
 		query,
 		dataSource.Name,
 		dataSource.Description,
 		dataSource.LogFormat,
+		mapping,
 		dataSource.ID,
 	)
 
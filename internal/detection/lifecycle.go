@@ -0,0 +1,368 @@
+package detection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"riskmatrix/pkg/models"
+)
+
+var (
+	// ErrTransitionNotAllowed is returned for a status change the lifecycle
+	// does not allow
+	ErrTransitionNotAllowed = errors.New("status transition not allowed")
+
+	// ErrApprovalRequired is returned for a status change that can only be
+	// made by approving a request for it
+	ErrApprovalRequired = errors.New("status transition needs a reviewer's approval")
+
+	// ErrRequirementsNotMet is returned for a detection missing fields its
+	// status requires
+	ErrRequirementsNotMet = errors.New("detection does not meet the requirements of its status")
+
+	// ErrApprovalNotPending is returned when reviewing an approval that was
+	// already reviewed
+	ErrApprovalNotPending = errors.New("approval is not pending")
+
+	// ErrSelfApproval is returned when the requester reviews their own
+	// approval request
+	ErrSelfApproval = errors.New("approval must be reviewed by someone other than the requester")
+)
+
+// LifecycleConfig holds configuration for the detection lifecycle
+type LifecycleConfig struct {
+	// Statuses each status may move to
+	Transitions map[models.DetectionStatus][]models.DetectionStatus `json:"transitions"`
+
+	// Fields a detection needs at each status; see requirementChecks. The
+	// passing_tests requirement needs the latest test run to have passed.
+	Requirements map[models.DetectionStatus][]string `json:"requirements"`
+
+	// Statuses a detection only moves to once a reviewer approves it
+	ApprovalRequired []models.DetectionStatus `json:"approval_required"`
+}
+
+// DefaultLifecycleConfig returns the default lifecycle: detections move one
+// stage at a time, production needs a complete detection and a reviewer's
+// approval, and any stage can be retired
+func DefaultLifecycleConfig() LifecycleConfig {
+	return LifecycleConfig{
+		Transitions: map[models.DetectionStatus][]models.DetectionStatus{
+			models.StatusIdea:       {models.StatusDraft, models.StatusRetired},
+			models.StatusDraft:      {models.StatusIdea, models.StatusTest, models.StatusRetired},
+			models.StatusTest:       {models.StatusDraft, models.StatusProduction, models.StatusRetired},
+			models.StatusProduction: {models.StatusTest, models.StatusRetired},
+			models.StatusRetired:    {models.StatusDraft},
+		},
+		Requirements: map[models.DetectionStatus][]string{
+			models.StatusProduction: {"query", "owner", "playbook_link", "mitre_techniques", "testing_description"},
+		},
+		ApprovalRequired: []models.DetectionStatus{models.StatusProduction},
+	}
+}
+
+// requirementChecks report whether a detection has each field a status can
+// require
+var requirementChecks = map[string]func(*models.Detection) bool{
+	"description":         func(d *models.Detection) bool { return strings.TrimSpace(d.Description) != "" },
+	"query":               func(d *models.Detection) bool { return strings.TrimSpace(d.Query) != "" },
+	"owner":               func(d *models.Detection) bool { return d.Owner != "" },
+	"playbook_link":       func(d *models.Detection) bool { return d.PlaybookLink != "" },
+	"risk_object":         func(d *models.Detection) bool { return d.RiskObject != "" },
+	"testing_description": func(d *models.Detection) bool { return strings.TrimSpace(d.TestingDescription) != "" },
+	"mitre_techniques":    func(d *models.Detection) bool { return len(d.MitreTechniques) > 0 },
+	"data_sources":        func(d *models.Detection) bool { return len(d.DataSources) > 0 },
+}
+
+// requirementPassingTests is the requirement that the latest test run of a
+// detection, against its current query, passed
+const requirementPassingTests = "passing_tests"
+
+// lifecycleStatuses are the statuses a lifecycle can refer to
+var lifecycleStatuses = map[models.DetectionStatus]bool{
+	models.StatusIdea:       true,
+	models.StatusDraft:      true,
+	models.StatusTest:       true,
+	models.StatusProduction: true,
+	models.StatusRetired:    true,
+}
+
+// Validate checks that the configuration only refers to known statuses and
+// fields
+func (c LifecycleConfig) Validate() error {
+	checkStatus := func(status models.DetectionStatus) error {
+		if !lifecycleStatuses[status] {
+			return fmt.Errorf("unknown status: %s", status)
+		}
+		return nil
+	}
+
+	for from, targets := range c.Transitions {
+		if err := checkStatus(from); err != nil {
+			return err
+		}
+		for _, to := range targets {
+			if err := checkStatus(to); err != nil {
+				return err
+			}
+		}
+	}
+	for status, fields := range c.Requirements {
+		if err := checkStatus(status); err != nil {
+			return err
+		}
+		for _, field := range fields {
+			if _, ok := requirementChecks[field]; !ok && field != requirementPassingTests {
+				return fmt.Errorf("unknown required field: %s", field)
+			}
+		}
+	}
+	for _, status := range c.ApprovalRequired {
+		if err := checkStatus(status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lifecycle enforces the allowed status transitions of detections, the
+// fields each status requires, and approval of promotions
+type Lifecycle struct {
+	repo   *Repository
+	config LifecycleConfig
+}
+
+// NewLifecycle creates a new detection lifecycle
+func NewLifecycle(repo *Repository, config LifecycleConfig) *Lifecycle {
+	return &Lifecycle{repo: repo, config: config}
+}
+
+// Config returns the lifecycle configuration
+func (l *Lifecycle) Config() LifecycleConfig {
+	return l.config
+}
+
+// NeedsApproval reports whether moving to status needs a reviewer's approval
+func (l *Lifecycle) NeedsApproval(status models.DetectionStatus) bool {
+	for _, s := range l.config.ApprovalRequired {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether the lifecycle has a transition from one status to
+// another
+func (l *Lifecycle) allows(from, to models.DetectionStatus) bool {
+	for _, target := range l.config.Transitions[from] {
+		if target == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTransition checks that a detection may move from one status to
+// another without approval. An empty from status is a new detection, which
+// may start at any status that does not need approval.
+func (l *Lifecycle) CheckTransition(from, to models.DetectionStatus) error {
+	if from == to {
+		return nil
+	}
+
+	if from != "" && !l.allows(from, to) {
+		return fmt.Errorf("%w: %s to %s", ErrTransitionNotAllowed, from, to)
+	}
+
+	if l.NeedsApproval(to) {
+		return fmt.Errorf("%w: %s", ErrApprovalRequired, to)
+	}
+	return nil
+}
+
+// CheckRequirements checks that a detection has the fields its status
+// requires
+func (l *Lifecycle) CheckRequirements(detection *models.Detection) error {
+	missing := make([]string, 0)
+	for _, field := range l.config.Requirements[detection.Status] {
+		if field == requirementPassingTests {
+			passed, err := l.testsPassed(detection)
+			if err != nil {
+				return err
+			}
+			if !passed {
+				missing = append(missing, field)
+			}
+			continue
+		}
+		if check, ok := requirementChecks[field]; ok && !check(detection) {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: %s detection needs %s", ErrRequirementsNotMet, detection.Status, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// testsPassed reports whether the latest test run of a detection passed and
+// was run against its current query
+func (l *Lifecycle) testsPassed(detection *models.Detection) (bool, error) {
+	var status, query string
+	err := l.repo.db.QueryRow(`SELECT status, query FROM detection_test_runs WHERE detection_id = ? ORDER BY id DESC LIMIT 1`,
+		detection.ID).Scan(&status, &query)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error querying detection test runs: %w", err)
+	}
+	return status == string(models.TestRunPassed) && query == detection.Query, nil
+}
+
+// CheckUpdate checks that an update of current is allowed: its status
+// change, if any, needs no approval and updated meets the requirements of its
+// status. A nil current checks a new detection.
+func (l *Lifecycle) CheckUpdate(current, updated *models.Detection) error {
+	var from models.DetectionStatus
+	if current != nil {
+		from = current.Status
+	}
+	if err := l.CheckTransition(from, updated.Status); err != nil {
+		return err
+	}
+	return l.CheckRequirements(updated)
+}
+
+// Transition moves a detection to a status that needs no approval
+func (l *Lifecycle) Transition(detectionID int64, to models.DetectionStatus, actor, comment string) (*models.Detection, error) {
+	detection, err := l.repo.GetDetection(detectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := *detection
+	detection.Status = to
+	if err := l.CheckUpdate(&current, detection); err != nil {
+		return nil, err
+	}
+
+	if err := l.repo.UpdateDetectionAs(detection, actor, comment); err != nil {
+		return nil, fmt.Errorf("error updating detection: %w", err)
+	}
+	return l.repo.GetDetection(detectionID)
+}
+
+// Revert reverts a detection to a revision, as long as the lifecycle allows
+// the detection to move back to that revision's status
+func (l *Lifecycle) Revert(detectionID int64, revision int, changedBy, reason string) (*models.Detection, error) {
+	target, err := l.repo.GetRevision(detectionID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	detection, err := l.repo.GetDetection(detectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := *detection
+	applyRevision(&reverted, target)
+	if err := l.CheckUpdate(detection, &reverted); err != nil {
+		return nil, err
+	}
+
+	return l.repo.RevertDetection(detectionID, revision, changedBy, reason)
+}
+
+// RequestApproval requests moving a detection to a status that needs a
+// reviewer's approval. The detection must already meet the requirements of
+// that status.
+func (l *Lifecycle) RequestApproval(detectionID int64, to models.DetectionStatus, requestedBy, comment string) (*models.DetectionApproval, error) {
+	detection, err := l.repo.GetDetection(detectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.checkApproval(detection, to); err != nil {
+		return nil, err
+	}
+
+	approval := &models.DetectionApproval{
+		DetectionID: detectionID,
+		FromStatus:  detection.Status,
+		ToStatus:    to,
+		RequestedBy: requestedBy,
+		Comment:     comment,
+		Status:      models.ApprovalPending,
+	}
+	if err := l.repo.CreateApproval(approval); err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// Review approves or rejects a pending approval request. Approving it moves
+// the detection to the requested status, as long as it is still at the
+// status it was requested from and meets the requirements of the new one.
+func (l *Lifecycle) Review(approvalID int64, reviewer string, approve bool, comment string) (*models.DetectionApproval, error) {
+	approval, err := l.repo.GetApproval(approvalID)
+	if err != nil {
+		return nil, err
+	}
+	if approval.Status != models.ApprovalPending {
+		return nil, fmt.Errorf("%w: approval %d is %s", ErrApprovalNotPending, approvalID, approval.Status)
+	}
+	if strings.EqualFold(reviewer, approval.RequestedBy) {
+		return nil, ErrSelfApproval
+	}
+
+	approval.Reviewer = reviewer
+	approval.ReviewComment = comment
+	if !approve {
+		approval.Status = models.ApprovalRejected
+		if err := l.repo.ReviewApproval(approval, nil); err != nil {
+			return nil, err
+		}
+		return approval, nil
+	}
+
+	detection, err := l.repo.GetDetection(approval.DetectionID)
+	if err != nil {
+		return nil, err
+	}
+	if detection.Status != approval.FromStatus {
+		return nil, fmt.Errorf("%w: detection moved to %s since approval was requested", ErrTransitionNotAllowed, detection.Status)
+	}
+	if err := l.checkApproval(detection, approval.ToStatus); err != nil {
+		return nil, err
+	}
+
+	detection.Status = approval.ToStatus
+	approval.Status = models.ApprovalApproved
+	if err := l.repo.ReviewApproval(approval, detection); err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// checkApproval checks that a detection may move to a status through an
+// approval request
+func (l *Lifecycle) checkApproval(detection *models.Detection, to models.DetectionStatus) error {
+	if !l.NeedsApproval(to) {
+		return fmt.Errorf("%w: %s needs no approval", ErrTransitionNotAllowed, to)
+	}
+
+	if !l.allows(detection.Status, to) {
+		return fmt.Errorf("%w: %s to %s", ErrTransitionNotAllowed, detection.Status, to)
+	}
+
+	promoted := *detection
+	promoted.Status = to
+	return l.CheckRequirements(&promoted)
+}
@@ -0,0 +1,218 @@
+package detection
+
+import (
+	"errors"
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestLifecycleConfig_Validate(t *testing.T) {
+	if err := DefaultLifecycleConfig().Validate(); err != nil {
+		t.Errorf("Expected default config to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		config LifecycleConfig
+	}{
+		{"unknown transition status", LifecycleConfig{Transitions: map[models.DetectionStatus][]models.DetectionStatus{models.StatusDraft: {"live"}}}},
+		{"unknown required field", LifecycleConfig{Requirements: map[models.DetectionStatus][]string{models.StatusTest: {"runbook"}}}},
+		{"unknown approval status", LifecycleConfig{ApprovalRequired: []models.DetectionStatus{"live"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+func TestLifecycle_CheckTransition(t *testing.T) {
+	lifecycle := NewLifecycle(nil, DefaultLifecycleConfig())
+
+	tests := []struct {
+		from     models.DetectionStatus
+		to       models.DetectionStatus
+		expected error
+	}{
+		{"", models.StatusDraft, nil},
+		{"", models.StatusProduction, ErrApprovalRequired},
+		{models.StatusDraft, models.StatusTest, nil},
+		{models.StatusDraft, models.StatusProduction, ErrTransitionNotAllowed},
+		{models.StatusTest, models.StatusProduction, ErrApprovalRequired},
+		{models.StatusProduction, models.StatusProduction, nil},
+		{models.StatusRetired, models.StatusTest, ErrTransitionNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			err := lifecycle.CheckTransition(tt.from, tt.to)
+			if !errors.Is(err, tt.expected) || (tt.expected == nil && err != nil) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestLifecycle_Promotion(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	lifecycle := NewLifecycle(repo, DefaultLifecycleConfig())
+	detection := createTestDetection(t, repo)
+	detection.Query = "index=win EventCode=4625"
+	if err := repo.UpdateDetection(detection); err != nil {
+		t.Fatalf("Failed to update detection: %v", err)
+	}
+
+	if _, err := lifecycle.Transition(detection.ID, models.StatusTest, "alice", "Ready for testing"); err != nil {
+		t.Fatalf("Failed to move detection to test: %v", err)
+	}
+
+	// Production needs a MITRE mapping and a reviewer's approval
+	if _, err := lifecycle.Transition(detection.ID, models.StatusProduction, "alice", ""); !errors.Is(err, ErrApprovalRequired) {
+		t.Errorf("Expected approval required error, got %v", err)
+	}
+	if _, err := lifecycle.RequestApproval(detection.ID, models.StatusProduction, "alice", ""); !errors.Is(err, ErrRequirementsNotMet) {
+		t.Errorf("Expected requirements error, got %v", err)
+	}
+
+	if _, err := db.Exec("INSERT INTO mitre_techniques (id, name, description, tactic) VALUES (?, ?, ?, ?)",
+		"T1110", "Brute Force", "Brute force", "Credential Access"); err != nil {
+		t.Fatalf("Failed to create test technique: %v", err)
+	}
+	if err := repo.AddMitreTechnique(detection.ID, "T1110"); err != nil {
+		t.Fatalf("Failed to add technique: %v", err)
+	}
+
+	approval, err := lifecycle.RequestApproval(detection.ID, models.StatusProduction, "alice", "Tested in lab")
+	if err != nil {
+		t.Fatalf("Failed to request approval: %v", err)
+	}
+	if _, err := lifecycle.RequestApproval(detection.ID, models.StatusProduction, "alice", ""); !errors.Is(err, ErrTransitionNotAllowed) {
+		t.Errorf("Expected a second pending request to be refused, got %v", err)
+	}
+
+	if _, err := lifecycle.Review(approval.ID, "Alice", true, ""); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("Expected self approval error, got %v", err)
+	}
+	if _, err := lifecycle.Review(approval.ID, "bob", true, "Looks good"); err != nil {
+		t.Fatalf("Failed to approve: %v", err)
+	}
+	if _, err := lifecycle.Review(approval.ID, "carol", false, ""); !errors.Is(err, ErrApprovalNotPending) {
+		t.Errorf("Expected approval not pending error, got %v", err)
+	}
+
+	promoted, err := repo.GetDetection(detection.ID)
+	if err != nil {
+		t.Fatalf("Failed to get detection: %v", err)
+	}
+	if promoted.Status != models.StatusProduction {
+		t.Errorf("Expected detection in production, got %s", promoted.Status)
+	}
+
+	transitions, err := repo.ListTransitions(detection.ID)
+	if err != nil {
+		t.Fatalf("Failed to list transitions: %v", err)
+	}
+	if len(transitions) != 3 {
+		t.Fatalf("Expected 3 transitions, got %+v", transitions)
+	}
+	if transitions[0].FromStatus != "" || transitions[0].ToStatus != models.StatusDraft {
+		t.Errorf("Unexpected creation transition %+v", transitions[0])
+	}
+	last := transitions[2]
+	if last.FromStatus != models.StatusTest || last.ToStatus != models.StatusProduction || last.Actor != "alice" ||
+		last.ApprovedBy != "bob" || last.ApprovalID == nil || *last.ApprovalID != approval.ID || last.CreatedAt.IsZero() {
+		t.Errorf("Unexpected promotion transition %+v", last)
+	}
+}
+
+func TestLifecycle_Review_Rejected(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	// Without requirements the detection can be put up for production as it is
+	config := DefaultLifecycleConfig()
+	config.Requirements = nil
+	lifecycle := NewLifecycle(repo, config)
+
+	detection := createTestDetection(t, repo)
+	if _, err := lifecycle.Transition(detection.ID, models.StatusTest, "alice", ""); err != nil {
+		t.Fatalf("Failed to move detection to test: %v", err)
+	}
+	approval, err := lifecycle.RequestApproval(detection.ID, models.StatusProduction, "alice", "")
+	if err != nil {
+		t.Fatalf("Failed to request approval: %v", err)
+	}
+
+	rejected, err := lifecycle.Review(approval.ID, "bob", false, "Too noisy")
+	if err != nil {
+		t.Fatalf("Failed to reject: %v", err)
+	}
+	if rejected.Status != models.ApprovalRejected || rejected.ReviewedAt == nil {
+		t.Errorf("Unexpected rejected approval %+v", rejected)
+	}
+
+	if current, _ := repo.GetDetection(detection.ID); current.Status != models.StatusTest {
+		t.Errorf("Expected detection to stay in test, got %s", current.Status)
+	}
+
+	approvals, err := repo.ListApprovals(detection.ID, models.ApprovalRejected)
+	if err != nil {
+		t.Fatalf("Failed to list approvals: %v", err)
+	}
+	if len(approvals) != 1 || approvals[0].Reviewer != "bob" || approvals[0].ReviewComment != "Too noisy" {
+		t.Errorf("Unexpected approvals %+v", approvals)
+	}
+}
+
+func TestLifecycle_CheckRequirements_PassingTests(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	config := DefaultLifecycleConfig()
+	config.Requirements = map[models.DetectionStatus][]string{models.StatusTest: {"passing_tests"}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected config to be valid, got %v", err)
+	}
+	lifecycle := NewLifecycle(repo, config)
+
+	detection := createTestDetection(t, repo)
+	detection.Query = "EventCode=4625"
+	detection.Status = models.StatusTest
+
+	addRun := func(query string, status models.TestRunStatus) {
+		if _, err := db.Exec(`INSERT INTO detection_test_runs (detection_id, query, status) VALUES (?, ?, ?)`,
+			detection.ID, query, status); err != nil {
+			t.Fatalf("Failed to create test run: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status models.TestRunStatus
+		passed bool
+	}{
+		{"no runs", "", "", false},
+		{"failed run", "EventCode=4625", models.TestRunFailed, false},
+		{"run of an older query", "EventCode=4624", models.TestRunPassed, false},
+		{"passed run", "EventCode=4625", models.TestRunPassed, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.status != "" {
+				addRun(tt.query, tt.status)
+			}
+			err := lifecycle.CheckRequirements(detection)
+			if tt.passed && err != nil {
+				t.Errorf("Expected requirements to be met, got %v", err)
+			}
+			if !tt.passed && !errors.Is(err, ErrRequirementsNotMet) {
+				t.Errorf("Expected requirements error, got %v", err)
+			}
+		})
+	}
+}
@@ -22,7 +22,7 @@ func NewRepository(db *database.DB) *Repository {
 
 // GetDetection retrieves a detection by ID
 func (r *Repository) GetDetection(id int64) (*models.Detection, error) {
-	query := `SELECT id, name, description, query, status, severity, risk_points, playbook_link, owner, risk_object, testing_description, event_count_last_30_days, false_positives_last_30_days, class_id, created_at, updated_at 
+	query := `SELECT id, name, description, query, status, severity, risk_points, playbook_link, owner, risk_object, testing_description, event_count_last_30_days, false_positives_last_30_days, class_id, external_id, created_at, updated_at 
               FROM detections WHERE id = ?`
 
 	row := r.db.QueryRow(query, id)
@@ -33,6 +33,7 @@ func (r *Repository) GetDetection(id int64) (*models.Detection, error) {
 	var playbookLink, owner, riskObject, testingDescription, queryField sql.NullString
 	var description sql.NullString
 	var classID sql.NullInt64
+	var externalID sql.NullString
 
 	err := row.Scan(
 		&detection.ID,
@@ -49,6 +50,7 @@ func (r *Repository) GetDetection(id int64) (*models.Detection, error) {
 		&detection.EventCountLast30Days,
 		&detection.FalsePositivesLast30Days,
 		&classID,
+		&externalID,
 		&createdAt,
 		&updatedAt,
 	)
@@ -72,6 +74,9 @@ func (r *Repository) GetDetection(id int64) (*models.Detection, error) {
 	if testingDescription.Valid {
 		detection.TestingDescription = testingDescription.String
 	}
+	if externalID.Valid {
+		detection.ExternalID = externalID.String
+	}
 	if classID.Valid {
 		detection.ClassID = &classID.Int64
 		// Load the class information
@@ -113,9 +118,24 @@ func (r *Repository) GetDetection(id int64) (*models.Detection, error) {
 	return &detection, nil
 }
 
+// GetDetectionByName retrieves a detection by name, case-insensitively. If
+// several detections share the name, the oldest is returned.
+func (r *Repository) GetDetectionByName(name string) (*models.Detection, error) {
+	var id int64
+	err := r.db.QueryRow(`SELECT id FROM detections WHERE name = ? COLLATE NOCASE ORDER BY id LIMIT 1`, name).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("detection not found: %s", name)
+		}
+		return nil, fmt.Errorf("error querying detection: %w", err)
+	}
+
+	return r.GetDetection(id)
+}
+
 // ListDetections retrieves all detections
 func (r *Repository) ListDetections() ([]*models.Detection, error) {
-	query := `SELECT id, name, description, query, status, severity, risk_points, playbook_link, owner, risk_object, testing_description, event_count_last_30_days, false_positives_last_30_days, class_id, created_at, updated_at 
+	query := `SELECT id, name, description, query, status, severity, risk_points, playbook_link, owner, risk_object, testing_description, event_count_last_30_days, false_positives_last_30_days, class_id, external_id, created_at, updated_at 
               FROM detections ORDER BY name`
 
 	rows, err := r.db.Query(query)
@@ -132,6 +152,7 @@ func (r *Repository) ListDetections() ([]*models.Detection, error) {
 		var playbookLink, owner, riskObject, testingDescription, queryField sql.NullString
 		var description sql.NullString
 		var classID sql.NullInt64
+		var externalID sql.NullString
 
 		err := rows.Scan(
 			&detection.ID,
@@ -148,6 +169,7 @@ func (r *Repository) ListDetections() ([]*models.Detection, error) {
 			&detection.EventCountLast30Days,
 			&detection.FalsePositivesLast30Days,
 			&classID,
+			&externalID,
 			&createdAt,
 			&updatedAt,
 		)
@@ -175,6 +197,9 @@ func (r *Repository) ListDetections() ([]*models.Detection, error) {
 		if testingDescription.Valid {
 			detection.TestingDescription = testingDescription.String
 		}
+		if externalID.Valid {
+			detection.ExternalID = externalID.String
+		}
 		if classID.Valid {
 			detection.ClassID = &classID.Int64
 		}
@@ -219,7 +244,7 @@ func (r *Repository) ListDetections() ([]*models.Detection, error) {
 
 // ListDetectionsByStatus retrieves detections by status
 func (r *Repository) ListDetectionsByStatus(status models.DetectionStatus) ([]*models.Detection, error) {
-	query := `SELECT id, name, description, query, status, severity, risk_points, playbook_link, owner, risk_object, testing_description, event_count_last_30_days, false_positives_last_30_days, class_id, created_at, updated_at 
+	query := `SELECT id, name, description, query, status, severity, risk_points, playbook_link, owner, risk_object, testing_description, event_count_last_30_days, false_positives_last_30_days, class_id, external_id, created_at, updated_at 
               FROM detections WHERE status = ? ORDER BY name`
 
 	rows, err := r.db.Query(query, status)
@@ -236,6 +261,7 @@ func (r *Repository) ListDetectionsByStatus(status models.DetectionStatus) ([]*m
 		var playbookLink, owner, riskObject, testingDescription, queryField sql.NullString
 		var description sql.NullString
 		var classID sql.NullInt64
+		var externalID sql.NullString
 
 		err := rows.Scan(
 			&detection.ID,
@@ -252,6 +278,7 @@ func (r *Repository) ListDetectionsByStatus(status models.DetectionStatus) ([]*m
 			&detection.EventCountLast30Days,
 			&detection.FalsePositivesLast30Days,
 			&classID,
+			&externalID,
 			&createdAt,
 			&updatedAt,
 		)
@@ -279,6 +306,9 @@ func (r *Repository) ListDetectionsByStatus(status models.DetectionStatus) ([]*m
 		if testingDescription.Valid {
 			detection.TestingDescription = testingDescription.String
 		}
+		if externalID.Valid {
+			detection.ExternalID = externalID.String
+		}
 		if classID.Valid {
 			detection.ClassID = &classID.Int64
 		}
@@ -321,10 +351,33 @@ func (r *Repository) ListDetectionsByStatus(status models.DetectionStatus) ([]*m
 	return detections, nil
 }
 
-// CreateDetection creates a new detection
+// CreateDetection creates a new detection and records it as revision 1
 func (r *Repository) CreateDetection(detection *models.Detection) error {
-	query := `INSERT INTO detections (name, description, query, status, severity, risk_points, playbook_link, owner, risk_object, testing_description, event_count_last_30_days, false_positives_last_30_days, class_id, created_at, updated_at) 
-              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createDetection(tx, detection); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CreateDetectionTx creates a new detection within a transaction
+func (r *Repository) CreateDetectionTx(tx *sql.Tx, detection *models.Detection) error {
+	return createDetection(tx, detection)
+}
+
+// createDetection creates a new detection with db
+func createDetection(db database.Execer, detection *models.Detection) error {
+	query := `INSERT INTO detections (name, description, query, status, severity, risk_points, playbook_link, owner, risk_object, testing_description, event_count_last_30_days, false_positives_last_30_days, class_id, external_id, created_at, updated_at) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	now := time.Now()
 	detection.CreatedAt = now
@@ -340,8 +393,12 @@ func (r *Repository) CreateDetection(detection *models.Detection) error {
 	if detection.RiskObject != "" {
 		riskObject = sql.NullString{String: string(detection.RiskObject), Valid: true}
 	}
+	var externalID sql.NullString
+	if detection.ExternalID != "" {
+		externalID = sql.NullString{String: detection.ExternalID, Valid: true}
+	}
 
-	result, err := r.db.Exec(
+	result, err := db.Exec(
 		query,
 		detection.Name,
 		detection.Description,
@@ -356,6 +413,7 @@ func (r *Repository) CreateDetection(detection *models.Detection) error {
 		detection.EventCountLast30Days,
 		detection.FalsePositivesLast30Days,
 		classID,
+		externalID,
 		detection.CreatedAt.Format(time.RFC3339),
 		detection.UpdatedAt.Format(time.RFC3339),
 	)
@@ -370,17 +428,61 @@ func (r *Repository) CreateDetection(detection *models.Detection) error {
 	}
 
 	detection.ID = id
-	return nil
+	if err := recordCreation(db, detection); err != nil {
+		return err
+	}
+	return recordRevision(db, id, "", "", now)
 }
 
-// UpdateDetection updates an existing detection
+// UpdateDetection updates an existing detection. A detection without an
+// external ID keeps the one it has.
 func (r *Repository) UpdateDetection(detection *models.Detection) error {
+	return r.UpdateDetectionAs(detection, "", "")
+}
+
+// UpdateDetectionAs updates an existing detection, recording who changed it
+// and why in the new revision
+func (r *Repository) UpdateDetectionAs(detection *models.Detection, changedBy, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateDetection(tx, detection, changedBy, reason); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateDetectionTx updates an existing detection within a transaction,
+// recording who changed it and why in the new revision
+func (r *Repository) UpdateDetectionTx(tx *sql.Tx, detection *models.Detection, changedBy, reason string) error {
+	return updateDetection(tx, detection, changedBy, reason)
+}
+
+// updateDetection updates an existing detection with db and records the
+// result as a new revision
+func updateDetection(db database.Execer, detection *models.Detection, changedBy, reason string) error {
 	query := `UPDATE detections 
-              SET name = ?, description = ?, query = ?, status = ?, severity = ?, risk_points = ?, playbook_link = ?, owner = ?, risk_object = ?, testing_description = ?, event_count_last_30_days = ?, false_positives_last_30_days = ?, class_id = ?, updated_at = ? 
+              SET name = ?, description = ?, query = ?, status = ?, severity = ?, risk_points = ?, playbook_link = ?, owner = ?, risk_object = ?, testing_description = ?, event_count_last_30_days = ?, false_positives_last_30_days = ?, class_id = ?, external_id = COALESCE(?, external_id), updated_at = ? 
               WHERE id = ?`
 
 	detection.UpdatedAt = time.Now()
 
+	// A detection created before revisions were kept gets its current state
+	// as revision 1 first, so the change can be diffed against it
+	if err := recordBaselineRevision(db, detection.ID); err != nil {
+		return err
+	}
+	if err := recordTransition(db, detection, changedBy, reason); err != nil {
+		return err
+	}
+
 	var classID sql.NullInt64
 	if detection.ClassID != nil {
 		classID = sql.NullInt64{Int64: *detection.ClassID, Valid: true}
@@ -391,8 +493,12 @@ func (r *Repository) UpdateDetection(detection *models.Detection) error {
 	if detection.RiskObject != "" {
 		riskObject = sql.NullString{String: string(detection.RiskObject), Valid: true}
 	}
+	var externalID sql.NullString
+	if detection.ExternalID != "" {
+		externalID = sql.NullString{String: detection.ExternalID, Valid: true}
+	}
 
-	_, err := r.db.Exec(
+	_, err := db.Exec(
 		query,
 		detection.Name,
 		detection.Description,
@@ -407,11 +513,15 @@ func (r *Repository) UpdateDetection(detection *models.Detection) error {
 		detection.EventCountLast30Days,
 		detection.FalsePositivesLast30Days,
 		classID,
+		externalID,
 		detection.UpdatedAt.Format(time.RFC3339),
 		detection.ID,
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return recordRevision(db, detection.ID, changedBy, reason, detection.UpdatedAt)
 }
 
 // DeleteDetection deletes a detection by ID
@@ -441,6 +551,13 @@ func (r *Repository) AddMitreTechnique(detectionID int64, mitreID string) error
 	return err
 }
 
+// AddMitreTechniqueTx adds a MITRE technique to a detection within a transaction
+func (r *Repository) AddMitreTechniqueTx(tx *sql.Tx, detectionID int64, mitreID string) error {
+	query := `INSERT OR IGNORE INTO detection_mitre_map (detection_id, mitre_id) VALUES (?, ?)`
+	_, err := tx.Exec(query, detectionID, mitreID)
+	return err
+}
+
 // RemoveMitreTechnique removes a MITRE technique from a detection
 func (r *Repository) RemoveMitreTechnique(detectionID int64, mitreID string) error {
 	query := `DELETE FROM detection_mitre_map WHERE detection_id = ? AND mitre_id = ?`
@@ -448,6 +565,14 @@ func (r *Repository) RemoveMitreTechnique(detectionID int64, mitreID string) err
 	return err
 }
 
+// RemoveMitreTechniqueTx removes a MITRE technique from a detection within a
+// transaction
+func (r *Repository) RemoveMitreTechniqueTx(tx *sql.Tx, detectionID int64, mitreID string) error {
+	query := `DELETE FROM detection_mitre_map WHERE detection_id = ? AND mitre_id = ?`
+	_, err := tx.Exec(query, detectionID, mitreID)
+	return err
+}
+
 // AddDataSource adds a data source to a detection
 func (r *Repository) AddDataSource(detectionID int64, dataSourceID int64) error {
 	query := `INSERT OR IGNORE INTO detection_datasource (detection_id, datasource_id) VALUES (?, ?)`
@@ -455,6 +580,13 @@ func (r *Repository) AddDataSource(detectionID int64, dataSourceID int64) error
 	return err
 }
 
+// AddDataSourceTx adds a data source to a detection within a transaction
+func (r *Repository) AddDataSourceTx(tx *sql.Tx, detectionID int64, dataSourceID int64) error {
+	query := `INSERT OR IGNORE INTO detection_datasource (detection_id, datasource_id) VALUES (?, ?)`
+	_, err := tx.Exec(query, detectionID, dataSourceID)
+	return err
+}
+
 // RemoveDataSource removes a data source from a detection
 func (r *Repository) RemoveDataSource(detectionID int64, dataSourceID int64) error {
 	query := `DELETE FROM detection_datasource WHERE detection_id = ? AND datasource_id = ?`
@@ -462,6 +594,14 @@ func (r *Repository) RemoveDataSource(detectionID int64, dataSourceID int64) err
 	return err
 }
 
+// RemoveDataSourceTx removes a data source from a detection within a
+// transaction
+func (r *Repository) RemoveDataSourceTx(tx *sql.Tx, detectionID int64, dataSourceID int64) error {
+	query := `DELETE FROM detection_datasource WHERE detection_id = ? AND datasource_id = ?`
+	_, err := tx.Exec(query, detectionID, dataSourceID)
+	return err
+}
+
 // GetDetectionCount returns the total number of detections
 func (r *Repository) GetDetectionCount() (int, error) {
 	query := `SELECT COUNT(*) FROM detections`
@@ -873,7 +1013,7 @@ func (r *Repository) ListDetectionsByClass(classID int64) ([]*models.Detection,
 	query := `SELECT d.id, d.name, d.description, d.query, d.status, d.severity, d.risk_points, 
 	                 d.playbook_link, d.owner, d.risk_object, d.testing_description, 
 	                 d.event_count_last_30_days, d.false_positives_last_30_days, 
-	                 d.class_id, d.created_at, d.updated_at
+	                 d.class_id, d.external_id, d.created_at, d.updated_at
 	          FROM detections d
 	          WHERE d.class_id = ?
 	          ORDER BY d.name`
@@ -892,6 +1032,7 @@ func (r *Repository) ListDetectionsByClass(classID int64) ([]*models.Detection,
 		var playbookLink, owner, riskObject, testingDescription, queryField sql.NullString
 		var description sql.NullString
 		var classID sql.NullInt64
+		var externalID sql.NullString
 
 		err := rows.Scan(
 			&detection.ID,
@@ -908,6 +1049,7 @@ func (r *Repository) ListDetectionsByClass(classID int64) ([]*models.Detection,
 			&detection.EventCountLast30Days,
 			&detection.FalsePositivesLast30Days,
 			&classID,
+			&externalID,
 			&createdAt,
 			&updatedAt,
 		)
@@ -935,6 +1077,9 @@ func (r *Repository) ListDetectionsByClass(classID int64) ([]*models.Detection,
 		if testingDescription.Valid {
 			detection.TestingDescription = testingDescription.String
 		}
+		if externalID.Valid {
+			detection.ExternalID = externalID.String
+		}
 		if classID.Valid {
 			detection.ClassID = &classID.Int64
 		}
@@ -0,0 +1,206 @@
+package detection
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// revisionColumns are the detection columns each revision keeps
+const revisionColumns = `name, description, query, status, severity, risk_points, playbook_link, owner, risk_object, testing_description, class_id, external_id`
+
+// recordRevision records the current state of a detection as its next
+// revision
+func recordRevision(db database.Execer, detectionID int64, changedBy, reason string, createdAt time.Time) error {
+	query := `INSERT INTO detection_revisions (detection_id, revision, ` + revisionColumns + `, changed_by, reason, created_at)
+              SELECT id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM detection_revisions WHERE detection_id = ?), ` + revisionColumns + `, ?, ?, ?
+              FROM detections WHERE id = ?`
+
+	if _, err := db.Exec(query, detectionID, nullString(changedBy), nullString(reason), createdAt.Format(time.RFC3339), detectionID); err != nil {
+		return fmt.Errorf("error recording detection revision: %w", err)
+	}
+	return nil
+}
+
+// recordBaselineRevision records the current state of a detection as
+// revision 1 if it has no revisions yet
+func recordBaselineRevision(db database.Execer, detectionID int64) error {
+	query := `INSERT INTO detection_revisions (detection_id, revision, ` + revisionColumns + `, created_at)
+              SELECT id, 1, ` + revisionColumns + `, updated_at
+              FROM detections
+              WHERE id = ? AND NOT EXISTS (SELECT 1 FROM detection_revisions WHERE detection_id = ?)`
+
+	if _, err := db.Exec(query, detectionID, detectionID); err != nil {
+		return fmt.Errorf("error recording detection revision: %w", err)
+	}
+	return nil
+}
+
+// ListRevisions retrieves the revisions of a detection, newest first
+func (r *Repository) ListRevisions(detectionID int64) ([]*models.DetectionRevision, error) {
+	query := `SELECT id, detection_id, revision, ` + revisionColumns + `, changed_by, reason, created_at
+              FROM detection_revisions WHERE detection_id = ? ORDER BY revision DESC`
+
+	rows, err := r.db.Query(query, detectionID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying detection revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := make([]*models.DetectionRevision, 0)
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating detection revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// GetRevision retrieves one revision of a detection
+func (r *Repository) GetRevision(detectionID int64, revision int) (*models.DetectionRevision, error) {
+	query := `SELECT id, detection_id, revision, ` + revisionColumns + `, changed_by, reason, created_at
+              FROM detection_revisions WHERE detection_id = ? AND revision = ?`
+
+	result, err := scanRevision(r.db.QueryRow(query, detectionID, revision))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("revision %d of detection %d not found", revision, detectionID)
+	}
+	return result, err
+}
+
+// RevertDetection restores a detection to the state of one of its revisions,
+// which records a new revision rather than discarding the later ones. The
+// detection keeps its external ID.
+func (r *Repository) RevertDetection(detectionID int64, revision int, changedBy, reason string) (*models.Detection, error) {
+	target, err := r.GetRevision(detectionID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	detection, err := r.GetDetection(detectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	applyRevision(detection, target)
+
+	if reason == "" {
+		reason = fmt.Sprintf("Revert to revision %d", revision)
+	}
+	if err := r.UpdateDetectionAs(detection, changedBy, reason); err != nil {
+		return nil, fmt.Errorf("error reverting detection: %w", err)
+	}
+
+	return r.GetDetection(detectionID)
+}
+
+// applyRevision sets a detection's fields to those of a revision. The external
+// ID and links are left as they are.
+func applyRevision(detection *models.Detection, revision *models.DetectionRevision) {
+	detection.Name = revision.Name
+	detection.Description = revision.Description
+	detection.Query = revision.Query
+	detection.Status = revision.Status
+	detection.Severity = revision.Severity
+	detection.RiskPoints = revision.RiskPoints
+	detection.PlaybookLink = revision.PlaybookLink
+	detection.Owner = revision.Owner
+	detection.RiskObject = revision.RiskObject
+	detection.TestingDescription = revision.TestingDescription
+	detection.ClassID = revision.ClassID
+}
+
+// DiffRevisions lists the fields that differ between two revisions
+func DiffRevisions(from, to *models.DetectionRevision) []models.DetectionChange {
+	changes := make([]models.DetectionChange, 0)
+	field := func(name string, fromValue, toValue interface{}) {
+		if fromValue != toValue {
+			changes = append(changes, models.DetectionChange{Field: name, From: fromValue, To: toValue})
+		}
+	}
+
+	classID := func(id *int64) interface{} {
+		if id == nil {
+			return nil
+		}
+		return *id
+	}
+
+	field("name", from.Name, to.Name)
+	field("description", from.Description, to.Description)
+	field("query", from.Query, to.Query)
+	field("status", from.Status, to.Status)
+	field("severity", from.Severity, to.Severity)
+	field("risk_points", from.RiskPoints, to.RiskPoints)
+	field("playbook_link", from.PlaybookLink, to.PlaybookLink)
+	field("owner", from.Owner, to.Owner)
+	field("risk_object", from.RiskObject, to.RiskObject)
+	field("testing_description", from.TestingDescription, to.TestingDescription)
+	field("class_id", classID(from.ClassID), classID(to.ClassID))
+	field("external_id", from.ExternalID, to.ExternalID)
+
+	return changes
+}
+
+// scanRevision scans a detection revision from a row
+func scanRevision(row database.RowScanner) (*models.DetectionRevision, error) {
+	var revision models.DetectionRevision
+	var description, query, playbookLink, owner, riskObject, testingDescription sql.NullString
+	var externalID, changedBy, reason sql.NullString
+	var classID sql.NullInt64
+	var createdAt string
+
+	err := row.Scan(
+		&revision.ID,
+		&revision.DetectionID,
+		&revision.Revision,
+		&revision.Name,
+		&description,
+		&query,
+		&revision.Status,
+		&revision.Severity,
+		&revision.RiskPoints,
+		&playbookLink,
+		&owner,
+		&riskObject,
+		&testingDescription,
+		&classID,
+		&externalID,
+		&changedBy,
+		&reason,
+		&createdAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning detection revision: %w", err)
+	}
+
+	revision.Description = description.String
+	revision.Query = query.String
+	revision.PlaybookLink = playbookLink.String
+	revision.Owner = owner.String
+	revision.RiskObject = models.RiskObjectType(riskObject.String)
+	revision.TestingDescription = testingDescription.String
+	revision.ExternalID = externalID.String
+	revision.ChangedBy = changedBy.String
+	revision.Reason = reason.String
+	if classID.Valid {
+		revision.ClassID = &classID.Int64
+	}
+
+	revision.CreatedAt = parseTimestamp(createdAt)
+
+	return &revision, nil
+}
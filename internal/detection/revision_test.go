@@ -0,0 +1,91 @@
+package detection
+
+import (
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestRepository_Revisions(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	detection := createTestDetection(t, repo)
+
+	// Tune the detection twice
+	detection.Query = "index=win EventCode=4625"
+	detection.RiskPoints = 30
+	if err := repo.UpdateDetectionAs(detection, "alice", "Too noisy"); err != nil {
+		t.Fatalf("Failed to update detection: %v", err)
+	}
+	detection.Severity = models.SeverityHigh
+	if err := repo.UpdateDetection(detection); err != nil {
+		t.Fatalf("Failed to update detection: %v", err)
+	}
+
+	revisions, err := repo.ListRevisions(detection.ID)
+	if err != nil {
+		t.Fatalf("Failed to list revisions: %v", err)
+	}
+	if len(revisions) != 3 || revisions[0].Revision != 3 || revisions[2].Revision != 1 {
+		t.Fatalf("Expected revisions 3, 2 and 1, got %+v", revisions)
+	}
+
+	second := revisions[1]
+	if second.ChangedBy != "alice" || second.Reason != "Too noisy" || second.RiskPoints != 30 || second.CreatedAt.IsZero() {
+		t.Errorf("Unexpected revision %+v", second)
+	}
+
+	changes := DiffRevisions(revisions[2], second)
+	if len(changes) != 2 || changes[0].Field != "query" || changes[1].Field != "risk_points" {
+		t.Fatalf("Expected query and risk points changes, got %+v", changes)
+	}
+	if changes[1].From != 50 || changes[1].To != 30 {
+		t.Errorf("Expected risk points 50 -> 30, got %+v", changes[1])
+	}
+
+	// Reverting records a new revision with the old state
+	reverted, err := repo.RevertDetection(detection.ID, 1, "bob", "")
+	if err != nil {
+		t.Fatalf("Failed to revert detection: %v", err)
+	}
+	if reverted.Query != "" || reverted.RiskPoints != 50 || reverted.Severity != models.SeverityMedium {
+		t.Errorf("Expected detection as of revision 1, got %+v", reverted)
+	}
+
+	latest, err := repo.GetRevision(detection.ID, 4)
+	if err != nil {
+		t.Fatalf("Failed to get revision: %v", err)
+	}
+	if latest.ChangedBy != "bob" || latest.Reason != "Revert to revision 1" {
+		t.Errorf("Unexpected revert revision %+v", latest)
+	}
+
+	if _, err := repo.RevertDetection(detection.ID, 99, "", ""); err == nil {
+		t.Error("Expected error reverting to a missing revision")
+	}
+}
+
+func TestRepository_Revisions_Baseline(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	// A detection from before revisions were kept has none
+	detection := createTestDetection(t, repo)
+	if _, err := db.Exec(`DELETE FROM detection_revisions`); err != nil {
+		t.Fatalf("Failed to clear revisions: %v", err)
+	}
+
+	detection.Name = "Renamed Detection"
+	if err := repo.UpdateDetection(detection); err != nil {
+		t.Fatalf("Failed to update detection: %v", err)
+	}
+
+	revisions, err := repo.ListRevisions(detection.ID)
+	if err != nil {
+		t.Fatalf("Failed to list revisions: %v", err)
+	}
+	if len(revisions) != 2 || revisions[1].Name != "Test Detection" || revisions[0].Name != "Renamed Detection" {
+		t.Errorf("Expected the state before the update as revision 1, got %+v", revisions)
+	}
+}
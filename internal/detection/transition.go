@@ -0,0 +1,283 @@
+package detection
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// recordCreation records the status a new detection starts at as its first
+// transition
+func recordCreation(db database.Execer, detection *models.Detection) error {
+	query := `INSERT INTO detection_transitions (detection_id, to_status, created_at) VALUES (?, ?, ?)`
+	if _, err := db.Exec(query, detection.ID, detection.Status, detection.CreatedAt.Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("error recording detection transition: %w", err)
+	}
+	return nil
+}
+
+// recordTransition records a change of a detection's status, if its stored
+// status differs from the new one. It must run before the detection is
+// updated.
+func recordTransition(db database.Execer, detection *models.Detection, actor, comment string) error {
+	query := `INSERT INTO detection_transitions (detection_id, from_status, to_status, actor, comment, created_at)
+              SELECT id, status, ?, ?, ?, ? FROM detections WHERE id = ? AND status != ?`
+
+	_, err := db.Exec(
+		query,
+		detection.Status,
+		nullString(actor),
+		nullString(comment),
+		detection.UpdatedAt.Format(time.RFC3339),
+		detection.ID,
+		detection.Status,
+	)
+	if err != nil {
+		return fmt.Errorf("error recording detection transition: %w", err)
+	}
+	return nil
+}
+
+// ListTransitions retrieves the status transitions of a detection, oldest
+// first
+func (r *Repository) ListTransitions(detectionID int64) ([]*models.DetectionTransition, error) {
+	query := `SELECT t.id, t.detection_id, t.from_status, t.to_status, t.actor, t.comment, t.approval_id, a.reviewer, t.created_at
+              FROM detection_transitions t
+              LEFT JOIN detection_approvals a ON a.id = t.approval_id
+              WHERE t.detection_id = ? ORDER BY t.id`
+
+	rows, err := r.db.Query(query, detectionID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying detection transitions: %w", err)
+	}
+	defer rows.Close()
+
+	transitions := make([]*models.DetectionTransition, 0)
+	for rows.Next() {
+		var transition models.DetectionTransition
+		var fromStatus, actor, comment, approvedBy sql.NullString
+		var approvalID sql.NullInt64
+		var createdAt string
+
+		if err := rows.Scan(
+			&transition.ID,
+			&transition.DetectionID,
+			&fromStatus,
+			&transition.ToStatus,
+			&actor,
+			&comment,
+			&approvalID,
+			&approvedBy,
+			&createdAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning detection transition: %w", err)
+		}
+
+		transition.FromStatus = models.DetectionStatus(fromStatus.String)
+		transition.Actor = actor.String
+		transition.Comment = comment.String
+		transition.ApprovedBy = approvedBy.String
+		if approvalID.Valid {
+			transition.ApprovalID = &approvalID.Int64
+		}
+		transition.CreatedAt = parseTimestamp(createdAt)
+
+		transitions = append(transitions, &transition)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating detection transitions: %w", err)
+	}
+
+	return transitions, nil
+}
+
+// CreateApproval creates a pending approval request. A detection can only
+// have one pending request at a time.
+func (r *Repository) CreateApproval(approval *models.DetectionApproval) error {
+	var pending int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM detection_approvals WHERE detection_id = ? AND status = ?`,
+		approval.DetectionID, models.ApprovalPending).Scan(&pending); err != nil {
+		return fmt.Errorf("error querying detection approvals: %w", err)
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: detection %d already has a pending approval", ErrTransitionNotAllowed, approval.DetectionID)
+	}
+
+	query := `INSERT INTO detection_approvals (detection_id, from_status, to_status, requested_by, comment, status, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	approval.CreatedAt = time.Now()
+	result, err := r.db.Exec(
+		query,
+		approval.DetectionID,
+		approval.FromStatus,
+		approval.ToStatus,
+		approval.RequestedBy,
+		nullString(approval.Comment),
+		approval.Status,
+		approval.CreatedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating detection approval: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	approval.ID = id
+	return nil
+}
+
+// approvalColumns are the columns scanned by scanApproval
+const approvalColumns = `id, detection_id, from_status, to_status, requested_by, comment, status, reviewer, review_comment, created_at, reviewed_at`
+
+// GetApproval retrieves an approval request by ID
+func (r *Repository) GetApproval(id int64) (*models.DetectionApproval, error) {
+	query := `SELECT ` + approvalColumns + ` FROM detection_approvals WHERE id = ?`
+
+	approval, err := scanApproval(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("approval not found: %d", id)
+	}
+	return approval, err
+}
+
+// ListApprovals retrieves approval requests, newest first, optionally only
+// those of one detection (detectionID 0 for all) or with one status (empty
+// for all)
+func (r *Repository) ListApprovals(detectionID int64, status models.ApprovalStatus) ([]*models.DetectionApproval, error) {
+	query := `SELECT ` + approvalColumns + ` FROM detection_approvals
+              WHERE (? = 0 OR detection_id = ?) AND (? = '' OR status = ?)
+              ORDER BY id DESC`
+
+	rows, err := r.db.Query(query, detectionID, detectionID, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("error querying detection approvals: %w", err)
+	}
+	defer rows.Close()
+
+	approvals := make([]*models.DetectionApproval, 0)
+	for rows.Next() {
+		approval, err := scanApproval(rows)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, approval)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating detection approvals: %w", err)
+	}
+
+	return approvals, nil
+}
+
+// ReviewApproval records the review of a pending approval request. For an
+// approved request the detection, with its new status, is updated in the
+// same transaction and the transition is linked to the approval.
+func (r *Repository) ReviewApproval(approval *models.DetectionApproval, detection *models.Detection) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(
+		`UPDATE detection_approvals SET status = ?, reviewer = ?, review_comment = ?, reviewed_at = ? WHERE id = ? AND status = ?`,
+		approval.Status,
+		approval.Reviewer,
+		nullString(approval.ReviewComment),
+		now.Format(time.RFC3339),
+		approval.ID,
+		models.ApprovalPending,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating detection approval: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return fmt.Errorf("%w: approval %d", ErrApprovalNotPending, approval.ID)
+	}
+
+	if detection != nil {
+		if err := updateDetection(tx, detection, approval.RequestedBy, approval.Comment); err != nil {
+			return fmt.Errorf("error updating detection: %w", err)
+		}
+
+		query := `UPDATE detection_transitions SET approval_id = ?
+                  WHERE id = (SELECT MAX(id) FROM detection_transitions WHERE detection_id = ?)`
+		if _, err := tx.Exec(query, approval.ID, detection.ID); err != nil {
+			return fmt.Errorf("error linking detection transition: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	approval.ReviewedAt = &now
+	return nil
+}
+
+// scanApproval scans a detection approval from a row
+func scanApproval(row database.RowScanner) (*models.DetectionApproval, error) {
+	var approval models.DetectionApproval
+	var comment, reviewer, reviewComment, reviewedAt sql.NullString
+	var createdAt string
+
+	err := row.Scan(
+		&approval.ID,
+		&approval.DetectionID,
+		&approval.FromStatus,
+		&approval.ToStatus,
+		&approval.RequestedBy,
+		&comment,
+		&approval.Status,
+		&reviewer,
+		&reviewComment,
+		&createdAt,
+		&reviewedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning detection approval: %w", err)
+	}
+
+	approval.Comment = comment.String
+	approval.Reviewer = reviewer.String
+	approval.ReviewComment = reviewComment.String
+	approval.CreatedAt = parseTimestamp(createdAt)
+	if reviewedAt.Valid {
+		parsed := parseTimestamp(reviewedAt.String)
+		approval.ReviewedAt = &parsed
+	}
+
+	return &approval, nil
+}
+
+// nullString returns a NULL for an empty string
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// parseTimestamp parses a timestamp in SQLite or RFC3339 format, returning
+// the zero time if it is neither
+func parseTimestamp(value string) time.Time {
+	if parsedTime, err := time.Parse("2006-01-02 15:04:05", value); err == nil {
+		return parsedTime
+	}
+	if parsedTime, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsedTime
+	}
+	return time.Time{}
+}
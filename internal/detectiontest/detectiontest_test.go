@@ -0,0 +1,206 @@
+package detectiontest
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// setupTestRepo creates a detection test repository with a test database
+func setupTestRepo(t *testing.T) (*Repository, *database.DB) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	return NewRepository(db), db
+}
+
+// records builds test case records from JSON documents
+func records(docs ...string) []json.RawMessage {
+	raw := make([]json.RawMessage, len(docs))
+	for i, doc := range docs {
+		raw[i] = json.RawMessage(doc)
+	}
+	return raw
+}
+
+func TestParseDSL(t *testing.T) {
+	record := map[string]interface{}{
+		"index":     "win",
+		"EventCode": float64(4625),
+		"user":      "Administrator",
+		"process":   map[string]interface{}{"name": "powershell.exe"},
+		"tags":      []interface{}{"auth", "failure"},
+	}
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"index=win EventCode=4625", true},
+		{"index=win AND EventCode=4624", false},
+		{"user=admin*", true},
+		{`process.name="POWERSHELL.EXE"`, true},
+		{"EventCode>=4600 AND EventCode<4700", true},
+		{"tags=failure", true},
+		{"user!=Administrator OR (index=win NOT tags=success)", true},
+		{"missing!=x", false},
+		{"NOT missing=x", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			matcher, err := ParseDSL(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to parse query: %v", err)
+			}
+			if matched := matcher.Match(record); matched != tt.expected {
+				t.Errorf("Expected match %v, got %v", tt.expected, matched)
+			}
+		})
+	}
+
+	for _, invalid := range []string{
+		"index=win | stats count by user",
+		"powershell",
+		"user=",
+		"(user=admin",
+		"EventCode>high",
+		`user="admin`,
+	} {
+		if _, err := ParseDSL(invalid); err == nil {
+			t.Errorf("Expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		query    string
+		language string
+		err      error
+	}{
+		{"selection:\n    EventID: 4625\ncondition: selection", LanguageSigma, nil},
+		{"index=win EventCode=4625", LanguageDSL, nil},
+		{"index=win EventCode=4625 | stats count by user", "", ErrUnsupportedQuery},
+		{"", "", ErrUnsupportedQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, language, err := Compile(tt.query)
+			if language != tt.language || !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("Expected %q and %v, got %q and %v", tt.language, tt.err, language, err)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	det := &models.Detection{
+		ID:         1,
+		Query:      "EventCode=4625 user!=SYSTEM",
+		RiskObject: models.RiskObjectUser,
+	}
+	testCases := []*models.DetectionTestCase{
+		{
+			ID:             1,
+			Name:           "Failed logon",
+			Records:        records(`{"EventCode": 4624, "user": "alice"}`, `{"EventCode": 4625, "user": "alice", "host": "ws1"}`),
+			ExpectMatch:    true,
+			ExpectedEntity: &models.ExpectedEntity{EntityType: models.EntityTypeUser, EntityValue: "Alice"},
+		},
+		{
+			ID:          2,
+			Name:        "System logon",
+			Records:     records(`{"EventCode": 4625, "user": "SYSTEM"}`, `"a raw line"`),
+			ExpectMatch: false,
+		},
+		{
+			ID:             3,
+			Name:           "Wrong entity",
+			Records:        records(`{"EventCode": 4625, "user": "bob"}`),
+			ExpectMatch:    true,
+			ExpectedEntity: &models.ExpectedEntity{EntityType: models.EntityTypeUser, EntityValue: "alice"},
+		},
+	}
+
+	run := Evaluate(det, testCases, defaultMapping)
+	if run.Status != models.TestRunFailed || run.Language != LanguageDSL || run.Passed != 2 || run.Failed != 1 {
+		t.Fatalf("Unexpected run %+v", run)
+	}
+	if entity := run.Results[0].Entity; entity == nil || entity.EntityValue != "alice" {
+		t.Errorf("Expected entity alice, got %+v", entity)
+	}
+	if result := run.Results[2]; result.Passed || result.Message != "expected entity user alice, got user bob" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	// A query the evaluator cannot run fails the run
+	det.Query = "index=win | stats count"
+	if run := Evaluate(det, testCases, defaultMapping); run.Status != models.TestRunError || run.Error == "" {
+		t.Errorf("Expected error run, got %+v", run)
+	}
+}
+
+func TestRepository_Run(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	det := &models.Detection{
+		Name:     "Encoded PowerShell",
+		Query:    "selection:\n    CommandLine|contains: ' -enc '\ncondition: selection",
+		Status:   models.StatusDraft,
+		Severity: models.SeverityMedium,
+	}
+	if err := repo.detections.CreateDetection(det); err != nil {
+		t.Fatalf("Failed to create detection: %v", err)
+	}
+
+	if _, err := repo.Run(det.ID, ""); !errors.Is(err, ErrNoTestCases) {
+		t.Errorf("Expected no test cases error, got %v", err)
+	}
+
+	testCase := &models.DetectionTestCase{
+		DetectionID: det.ID,
+		Name:        "Encoded command",
+		Records:     records(`{"CommandLine": "powershell -enc SQBFAFgA", "host": "ws1"}`),
+		ExpectMatch: true,
+	}
+	if err := repo.CreateTestCase(testCase); err != nil {
+		t.Fatalf("Failed to create test case: %v", err)
+	}
+	if err := repo.CreateTestCase(&models.DetectionTestCase{DetectionID: det.ID, Name: "No records"}); err == nil {
+		t.Error("Expected error creating a test case without records")
+	}
+
+	run, err := repo.Run(det.ID, "alice")
+	if err != nil {
+		t.Fatalf("Failed to run tests: %v", err)
+	}
+	if run.Status != models.TestRunPassed || run.Language != LanguageSigma || run.Passed != 1 {
+		t.Errorf("Unexpected run %+v", run)
+	}
+
+	// Deleting a test case keeps its results in the history
+	if err := repo.DeleteTestCase(det.ID, testCase.ID); err != nil {
+		t.Fatalf("Failed to delete test case: %v", err)
+	}
+	stored, err := repo.GetRun(det.ID, run.ID)
+	if err != nil {
+		t.Fatalf("Failed to get test run: %v", err)
+	}
+	if stored.RunBy != "alice" || len(stored.Results) != 1 || stored.Results[0].TestCaseID != nil ||
+		stored.Results[0].TestCaseName != "Encoded command" || stored.Results[0].Entity == nil {
+		t.Errorf("Unexpected stored run %+v", stored)
+	}
+
+	runs, err := repo.ListRuns(det.ID)
+	if err != nil {
+		t.Fatalf("Failed to list test runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Status != models.TestRunPassed {
+		t.Errorf("Unexpected test runs %+v", runs)
+	}
+}
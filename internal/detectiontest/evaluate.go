@@ -0,0 +1,172 @@
+package detectiontest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"riskmatrix/internal/datasource"
+	"riskmatrix/pkg/models"
+)
+
+// ErrNoTestCases is returned when running the tests of a detection that has
+// none
+var ErrNoTestCases = errors.New("detection has no test cases")
+
+// defaultMapping extracts entities from records of detections without a data
+// source mapping profile: the user, host and ip fields
+var defaultMapping = &models.DataSourceMapping{
+	User: &models.FieldExtractor{Path: "$.user"},
+	Host: &models.FieldExtractor{Path: "$.host"},
+	IP:   &models.FieldExtractor{Path: "$.ip"},
+}
+
+// Run evaluates a detection's test cases against its current query and
+// stores the run
+func (r *Repository) Run(detectionID int64, runBy string) (*models.DetectionTestRun, error) {
+	det, err := r.detections.GetDetection(detectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	testCases, err := r.ListTestCases(detectionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(testCases) == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrNoTestCases, detectionID)
+	}
+
+	run := Evaluate(det, testCases, r.entityMapping(det))
+	run.RunBy = runBy
+	if err := r.createRun(run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// entityMapping returns the mapping profile entities are extracted with: that
+// of the detection's first data source with one, or else defaultMapping
+func (r *Repository) entityMapping(det *models.Detection) *models.DataSourceMapping {
+	for _, linked := range det.DataSources {
+		// Detections do not carry their data sources' mapping profiles
+		ds, err := r.dataSources.GetDataSource(linked.ID)
+		if err == nil && ds.Mapping != nil {
+			return ds.Mapping
+		}
+	}
+	return defaultMapping
+}
+
+// Evaluate runs test cases against a detection's query. A test case passes
+// when whether any of its records match is as expected and, for an expected
+// entity, the entity extracted from the first matching record with mapping
+// is that entity. A query that cannot be evaluated fails the run with an
+// error.
+func Evaluate(det *models.Detection, testCases []*models.DetectionTestCase, mapping *models.DataSourceMapping) *models.DetectionTestRun {
+	run := &models.DetectionTestRun{
+		DetectionID: det.ID,
+		Query:       det.Query,
+		CreatedAt:   time.Now(),
+		Results:     make([]*models.DetectionTestResult, 0, len(testCases)),
+	}
+
+	matcher, language, err := Compile(det.Query)
+	run.Language = language
+	if err != nil {
+		run.Status = models.TestRunError
+		run.Error = err.Error()
+		return run
+	}
+
+	// The detection's risk object decides which entity is extracted
+	priority := mapping.EntityPriority
+	if det.RiskObject != "" {
+		priority = []models.EntityType{models.EntityType(strings.ToLower(string(det.RiskObject)))}
+	}
+
+	for _, testCase := range testCases {
+		result := evaluateTestCase(matcher, testCase, mapping, priority)
+		if result.Passed {
+			run.Passed++
+		} else {
+			run.Failed++
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	run.Status = models.TestRunPassed
+	if run.Failed > 0 {
+		run.Status = models.TestRunFailed
+	}
+	return run
+}
+
+// evaluateTestCase runs one test case against a compiled query
+func evaluateTestCase(matcher Matcher, testCase *models.DetectionTestCase, mapping *models.DataSourceMapping, priority []models.EntityType) *models.DetectionTestResult {
+	testCaseID := testCase.ID
+	result := &models.DetectionTestResult{
+		TestCaseID:   &testCaseID,
+		TestCaseName: testCase.Name,
+	}
+
+	matchIndex := -1
+	for i, raw := range testCase.Records {
+		record, err := ParseRecord(raw)
+		if err != nil {
+			result.Message = fmt.Sprintf("record %d: %v", i+1, err)
+			return result
+		}
+		if matcher.Match(record) {
+			matchIndex = i
+			break
+		}
+	}
+	result.Matched = matchIndex >= 0
+
+	if result.Matched != testCase.ExpectMatch {
+		if testCase.ExpectMatch {
+			result.Message = "expected a record to match, but none did"
+		} else {
+			result.Message = fmt.Sprintf("expected no match, but record %d matched", matchIndex+1)
+		}
+		return result
+	}
+
+	if result.Matched {
+		raw := string(testCase.Records[matchIndex])
+		if record, err := ParseRecord(testCase.Records[matchIndex]); err == nil {
+			if line, ok := record["_raw"].(string); ok && len(record) == 1 {
+				raw = line
+			}
+		}
+
+		// Extraction errors only fail test cases that expect an entity
+		normalized, err := datasource.Normalize(mapping, raw)
+		if err != nil && testCase.ExpectedEntity != nil {
+			result.Message = fmt.Sprintf("error extracting entity: %v", err)
+			return result
+		}
+		if err == nil {
+			if object := normalized.RiskObject(priority); object != nil {
+				result.Entity = &models.ExpectedEntity{EntityType: object.EntityType, EntityValue: object.EntityValue}
+			}
+		}
+	}
+
+	if expected := testCase.ExpectedEntity; expected != nil {
+		actual := result.Entity
+		if actual == nil {
+			result.Message = fmt.Sprintf("expected entity %s %s, but none was extracted", expected.EntityType, expected.EntityValue)
+			return result
+		}
+		if actual.EntityType != expected.EntityType || !strings.EqualFold(actual.EntityValue, expected.EntityValue) {
+			result.Message = fmt.Sprintf("expected entity %s %s, got %s %s", expected.EntityType, expected.EntityValue, actual.EntityType, actual.EntityValue)
+			return result
+		}
+	}
+
+	result.Passed = true
+	return result
+}
@@ -0,0 +1,357 @@
+package detectiontest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"riskmatrix/internal/sigma"
+)
+
+// Query languages the evaluator supports
+const (
+	LanguageSigma = "sigma"
+	LanguageDSL   = "dsl"
+)
+
+// ErrUnsupportedQuery is returned for a query in a language the evaluator
+// cannot run, such as a search with pipes
+var ErrUnsupportedQuery = errors.New("query language not supported")
+
+// Record is a log record a query is evaluated against
+type Record map[string]interface{}
+
+// Matcher reports whether a log record matches a query
+type Matcher interface {
+	Match(record map[string]interface{}) bool
+}
+
+// Compile compiles a detection's query into a matcher, returning the
+// language it is written in. A Sigma detection block is Sigma; anything else
+// must be a field-match expression, as parsed by ParseDSL.
+func Compile(query string) (Matcher, string, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, "", fmt.Errorf("%w: detection has no query", ErrUnsupportedQuery)
+	}
+
+	if sigma.IsQuery(query) {
+		matcher, err := sigma.CompileQuery(query)
+		if err != nil {
+			return nil, LanguageSigma, err
+		}
+		return matcher, LanguageSigma, nil
+	}
+
+	matcher, err := ParseDSL(query)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupportedQuery, err)
+	}
+	return matcher, LanguageDSL, nil
+}
+
+// ParseRecord decodes a test record: a JSON object, or a JSON string holding
+// a raw log line, which is matched as the _raw field
+func ParseRecord(data json.RawMessage) (Record, error) {
+	var line string
+	if err := json.Unmarshal(data, &line); err == nil {
+		return Record{"_raw": line}, nil
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil || record == nil {
+		return nil, fmt.Errorf("record must be a JSON object or string")
+	}
+	return record, nil
+}
+
+// dslQuery is a parsed field-match expression
+type dslQuery struct {
+	root dslNode
+}
+
+// dslNode is a node of a field-match expression
+type dslNode func(fields map[string][]string) bool
+
+// ParseDSL parses a field-match expression: comparisons such as
+// user=admin, EventCode!=4624, "process.name"="power shell*" or
+// risk_score>=70, combined with AND, OR, NOT and parentheses. Comparisons
+// next to each other are ANDed, so a simple search such as
+// index=win EventCode=4625 is an expression too. = and != compare
+// case-insensitively and allow * wildcards; <, <=, > and >= compare numbers.
+// Nested fields are joined with dots.
+func ParseDSL(query string) (Matcher, error) {
+	tokens, err := tokenizeDSL(query)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &dslParser{tokens: tokens}
+	root, err := parser.or()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(tokens) {
+		return nil, fmt.Errorf("unexpected %q", tokens[parser.pos].text)
+	}
+	return &dslQuery{root: root}, nil
+}
+
+// Match reports whether a record matches the expression
+func (q *dslQuery) Match(record map[string]interface{}) bool {
+	fields := make(map[string][]string)
+	flattenRecord(fields, "", record)
+	return q.root(fields)
+}
+
+// dslToken is a token of a field-match expression. Quoted tokens are never
+// keywords or operators.
+type dslToken struct {
+	text   string
+	quoted bool
+}
+
+// dslOperators are the comparison operators, longest first
+var dslOperators = []string{"!=", "<=", ">=", "=", "<", ">"}
+
+// tokenizeDSL splits a field-match expression into words, quoted strings,
+// operators and parentheses
+func tokenizeDSL(query string) ([]dslToken, error) {
+	tokens := make([]dslToken, 0)
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, dslToken{text: string(c)})
+			i++
+		case c == '"':
+			var text strings.Builder
+			i++
+			for ; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' && i+1 < len(query) {
+					i++
+				}
+				text.WriteByte(query[i])
+			}
+			if i >= len(query) {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			tokens = append(tokens, dslToken{text: text.String(), quoted: true})
+			i++
+		case strings.IndexByte("!<>=", c) >= 0:
+			operator := ""
+			for _, op := range dslOperators {
+				if strings.HasPrefix(query[i:], op) {
+					operator = op
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected %q", c)
+			}
+			tokens = append(tokens, dslToken{text: operator})
+			i += len(operator)
+		default:
+			start := i
+			for i < len(query) && strings.IndexByte(" \t\n\r()\"!<>=", query[i]) < 0 {
+				i++
+			}
+			word := query[start:i]
+			if strings.ContainsAny(word, "|[]") {
+				return nil, fmt.Errorf("unexpected %q", word)
+			}
+			tokens = append(tokens, dslToken{text: word})
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	return tokens, nil
+}
+
+// dslParser is a recursive descent parser for field-match expressions
+type dslParser struct {
+	tokens []dslToken
+	pos    int
+}
+
+// keyword reports whether the next token is the given keyword
+func (p *dslParser) keyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+// or parses expressions joined with OR
+func (p *dslParser) or() (dslNode, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(fields map[string][]string) bool { return l(fields) || right(fields) }
+	}
+	return left, nil
+}
+
+// and parses expressions joined with AND, or simply next to each other
+func (p *dslParser) and() (dslNode, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.tokens) && !p.keyword("OR") && p.tokens[p.pos].text != ")" {
+		if p.keyword("AND") {
+			p.pos++
+		}
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(fields map[string][]string) bool { return l(fields) && right(fields) }
+	}
+	return left, nil
+}
+
+// not parses an expression that may be negated
+func (p *dslParser) not() (dslNode, error) {
+	if p.keyword("NOT") {
+		p.pos++
+		inner, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(fields map[string][]string) bool { return !inner(fields) }, nil
+	}
+	return p.primary()
+}
+
+// primary parses a parenthesized expression or a comparison
+func (p *dslParser) primary() (dslNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	if token := p.tokens[p.pos]; token.text == "(" && !token.quoted {
+		p.pos++
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].text != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	}
+
+	if p.pos+2 >= len(p.tokens) || !isOperator(p.tokens[p.pos+1]) {
+		return nil, fmt.Errorf("expected a comparison at %q", p.tokens[p.pos].text)
+	}
+	field, operator, value := p.tokens[p.pos].text, p.tokens[p.pos+1].text, p.tokens[p.pos+2]
+	if isOperator(value) || (!value.quoted && (value.text == "(" || value.text == ")")) {
+		return nil, fmt.Errorf("missing value for %s", field)
+	}
+	p.pos += 3
+
+	return comparison(field, operator, value.text)
+}
+
+// isOperator reports whether a token is a comparison operator
+func isOperator(token dslToken) bool {
+	if token.quoted {
+		return false
+	}
+	for _, op := range dslOperators {
+		if token.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// comparison builds a node comparing a field's values with a value. A field
+// with several values, from an array, matches when any value does; != needs
+// the field to be present.
+func comparison(field, operator, value string) (dslNode, error) {
+	var match func(string) bool
+	switch operator {
+	case "=", "!=":
+		pattern := wildcardRegexp(value)
+		match = pattern.MatchString
+	default:
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s needs a number, got %q", field, operator, value)
+		}
+		match = func(v string) bool {
+			number, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return false
+			}
+			switch operator {
+			case "<":
+				return number < limit
+			case "<=":
+				return number <= limit
+			case ">":
+				return number > limit
+			}
+			return number >= limit
+		}
+	}
+
+	return func(fields map[string][]string) bool {
+		values := fields[field]
+		for _, v := range values {
+			if match(v) {
+				return operator != "!="
+			}
+		}
+		return operator == "!=" && len(values) > 0
+	}, nil
+}
+
+// wildcardRegexp compiles a value with * wildcards into a case-insensitive
+// regex matching whole values
+func wildcardRegexp(value string) *regexp.Regexp {
+	parts := strings.Split(value, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("(?is)^" + strings.Join(parts, ".*") + "$")
+}
+
+// flattenRecord adds the values of a record to fields, joining nested keys
+// with dots. Each element of an array is a value of the array's field.
+func flattenRecord(fields map[string][]string, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flattenRecord(fields, key, nested)
+		}
+	case []interface{}:
+		for _, nested := range v {
+			flattenRecord(fields, prefix, nested)
+		}
+	case string:
+		fields[prefix] = append(fields[prefix], v)
+	case float64:
+		fields[prefix] = append(fields[prefix], strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		fields[prefix] = append(fields[prefix], strconv.FormatBool(v))
+	}
+}
@@ -0,0 +1,450 @@
+package detectiontest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"riskmatrix/internal/datasource"
+	"riskmatrix/internal/detection"
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// Repository stores detection test cases and the history of their runs
+type Repository struct {
+	db          *database.DB
+	detections  *detection.Repository
+	dataSources *datasource.Repository
+}
+
+// NewRepository creates a new detection test repository
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{
+		db:          db,
+		detections:  detection.NewRepository(db),
+		dataSources: datasource.NewRepository(db),
+	}
+}
+
+// ValidateTestCase checks that a test case has a name, records that are JSON
+// objects or strings, and a complete expected entity, if any
+func ValidateTestCase(testCase *models.DetectionTestCase) error {
+	testCase.Name = strings.TrimSpace(testCase.Name)
+	if testCase.Name == "" {
+		return fmt.Errorf("test case name is required")
+	}
+	if len(testCase.Name) > 255 {
+		return fmt.Errorf("test case name must be less than 255 characters")
+	}
+
+	if len(testCase.Records) == 0 {
+		return fmt.Errorf("test case needs at least one record")
+	}
+	for i, record := range testCase.Records {
+		if _, err := ParseRecord(record); err != nil {
+			return fmt.Errorf("record %d: %w", i+1, err)
+		}
+	}
+
+	if entity := testCase.ExpectedEntity; entity != nil {
+		if !testCase.ExpectMatch {
+			return fmt.Errorf("an expected entity needs expect_match")
+		}
+		switch entity.EntityType {
+		case models.EntityTypeUser, models.EntityTypeHost, models.EntityTypeIP:
+		default:
+			return fmt.Errorf("invalid expected entity type: %s", entity.EntityType)
+		}
+		if strings.TrimSpace(entity.EntityValue) == "" {
+			return fmt.Errorf("expected entity value is required")
+		}
+	}
+
+	return nil
+}
+
+// testCaseColumns are the columns scanned by scanTestCase
+const testCaseColumns = `id, detection_id, name, description, records, expect_match, expected_entity_type, expected_entity_value, created_at, updated_at`
+
+// testCaseArgs returns the column values of a test case from name to
+// expected_entity_value
+func testCaseArgs(testCase *models.DetectionTestCase) ([]interface{}, error) {
+	records, err := json.Marshal(testCase.Records)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding records: %w", err)
+	}
+
+	var entityType, entityValue sql.NullString
+	if testCase.ExpectedEntity != nil {
+		entityType = sql.NullString{String: string(testCase.ExpectedEntity.EntityType), Valid: true}
+		entityValue = sql.NullString{String: strings.TrimSpace(testCase.ExpectedEntity.EntityValue), Valid: true}
+	}
+
+	return []interface{}{
+		testCase.Name,
+		sql.NullString{String: testCase.Description, Valid: testCase.Description != ""},
+		string(records),
+		testCase.ExpectMatch,
+		entityType,
+		entityValue,
+	}, nil
+}
+
+// CreateTestCase creates a test case for a detection
+func (r *Repository) CreateTestCase(testCase *models.DetectionTestCase) error {
+	if err := ValidateTestCase(testCase); err != nil {
+		return err
+	}
+
+	args, err := testCaseArgs(testCase)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	query := `INSERT INTO detection_test_cases (detection_id, name, description, records, expect_match, expected_entity_type, expected_entity_value, created_at, updated_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	args = append([]interface{}{testCase.DetectionID}, args...)
+	args = append(args, now.Format(time.RFC3339), now.Format(time.RFC3339))
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error creating test case: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	testCase.ID = id
+	testCase.CreatedAt = now
+	testCase.UpdatedAt = now
+	return nil
+}
+
+// GetTestCase retrieves a detection's test case by ID
+func (r *Repository) GetTestCase(detectionID, id int64) (*models.DetectionTestCase, error) {
+	query := `SELECT ` + testCaseColumns + ` FROM detection_test_cases WHERE id = ? AND detection_id = ?`
+
+	testCase, err := scanTestCase(r.db.QueryRow(query, id, detectionID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("test case not found: %d", id)
+	}
+	return testCase, err
+}
+
+// ListTestCases retrieves a detection's test cases
+func (r *Repository) ListTestCases(detectionID int64) ([]*models.DetectionTestCase, error) {
+	query := `SELECT ` + testCaseColumns + ` FROM detection_test_cases WHERE detection_id = ? ORDER BY id`
+
+	rows, err := r.db.Query(query, detectionID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying test cases: %w", err)
+	}
+	defer rows.Close()
+
+	testCases := make([]*models.DetectionTestCase, 0)
+	for rows.Next() {
+		testCase, err := scanTestCase(rows)
+		if err != nil {
+			return nil, err
+		}
+		testCases = append(testCases, testCase)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating test cases: %w", err)
+	}
+
+	return testCases, nil
+}
+
+// UpdateTestCase updates a detection's test case
+func (r *Repository) UpdateTestCase(testCase *models.DetectionTestCase) error {
+	if err := ValidateTestCase(testCase); err != nil {
+		return err
+	}
+
+	args, err := testCaseArgs(testCase)
+	if err != nil {
+		return err
+	}
+
+	testCase.UpdatedAt = time.Now()
+	query := `UPDATE detection_test_cases SET name = ?, description = ?, records = ?, expect_match = ?,
+              expected_entity_type = ?, expected_entity_value = ?, updated_at = ?
+              WHERE id = ? AND detection_id = ?`
+
+	args = append(args, testCase.UpdatedAt.Format(time.RFC3339), testCase.ID, testCase.DetectionID)
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error updating test case: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("test case not found: %d", testCase.ID)
+	}
+
+	return nil
+}
+
+// DeleteTestCase deletes a detection's test case. Results of past runs are
+// kept.
+func (r *Repository) DeleteTestCase(detectionID, id int64) error {
+	result, err := r.db.Exec(`DELETE FROM detection_test_cases WHERE id = ? AND detection_id = ?`, id, detectionID)
+	if err != nil {
+		return fmt.Errorf("error deleting test case: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("test case not found: %d", id)
+	}
+
+	return nil
+}
+
+// scanTestCase scans a test case from a row
+func scanTestCase(row database.RowScanner) (*models.DetectionTestCase, error) {
+	var testCase models.DetectionTestCase
+	var description, entityType, entityValue sql.NullString
+	var records, createdAt, updatedAt string
+
+	err := row.Scan(
+		&testCase.ID,
+		&testCase.DetectionID,
+		&testCase.Name,
+		&description,
+		&records,
+		&testCase.ExpectMatch,
+		&entityType,
+		&entityValue,
+		&createdAt,
+		&updatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning test case: %w", err)
+	}
+
+	testCase.Description = description.String
+	if err := json.Unmarshal([]byte(records), &testCase.Records); err != nil {
+		return nil, fmt.Errorf("error decoding test case records: %w", err)
+	}
+	if entityType.Valid {
+		testCase.ExpectedEntity = &models.ExpectedEntity{
+			EntityType:  models.EntityType(entityType.String),
+			EntityValue: entityValue.String,
+		}
+	}
+	testCase.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	testCase.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+	return &testCase, nil
+}
+
+// createRun stores a test run with its results
+func (r *Repository) createRun(run *models.DetectionTestRun) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO detection_test_runs (detection_id, query, language, status, passed, failed, error, run_by, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := tx.Exec(
+		query,
+		run.DetectionID,
+		run.Query,
+		sql.NullString{String: run.Language, Valid: run.Language != ""},
+		run.Status,
+		run.Passed,
+		run.Failed,
+		sql.NullString{String: run.Error, Valid: run.Error != ""},
+		sql.NullString{String: run.RunBy, Valid: run.RunBy != ""},
+		run.CreatedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating test run: %w", err)
+	}
+
+	run.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	query = `INSERT INTO detection_test_results (run_id, test_case_id, test_case_name, passed, matched, entity_type, entity_value, message)
+             VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, testResult := range run.Results {
+		testResult.RunID = run.ID
+
+		var entityType, entityValue sql.NullString
+		if testResult.Entity != nil {
+			entityType = sql.NullString{String: string(testResult.Entity.EntityType), Valid: true}
+			entityValue = sql.NullString{String: testResult.Entity.EntityValue, Valid: true}
+		}
+
+		result, err := tx.Exec(
+			query,
+			run.ID,
+			testResult.TestCaseID,
+			testResult.TestCaseName,
+			testResult.Passed,
+			testResult.Matched,
+			entityType,
+			entityValue,
+			sql.NullString{String: testResult.Message, Valid: testResult.Message != ""},
+		)
+		if err != nil {
+			return fmt.Errorf("error creating test result: %w", err)
+		}
+		if testResult.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("error getting last insert ID: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// runColumns are the columns scanned by scanRun
+const runColumns = `id, detection_id, query, language, status, passed, failed, error, run_by, created_at`
+
+// GetRun retrieves a detection's test run by ID, with its results
+func (r *Repository) GetRun(detectionID, id int64) (*models.DetectionTestRun, error) {
+	query := `SELECT ` + runColumns + ` FROM detection_test_runs WHERE id = ? AND detection_id = ?`
+
+	run, err := scanRun(r.db.QueryRow(query, id, detectionID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("test run not found: %d", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query = `SELECT id, run_id, test_case_id, test_case_name, passed, matched, entity_type, entity_value, message
+             FROM detection_test_results WHERE run_id = ? ORDER BY id`
+
+	rows, err := r.db.Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying test results: %w", err)
+	}
+	defer rows.Close()
+
+	run.Results = make([]*models.DetectionTestResult, 0)
+	for rows.Next() {
+		var testResult models.DetectionTestResult
+		var testCaseID sql.NullInt64
+		var entityType, entityValue, message sql.NullString
+
+		if err := rows.Scan(
+			&testResult.ID,
+			&testResult.RunID,
+			&testCaseID,
+			&testResult.TestCaseName,
+			&testResult.Passed,
+			&testResult.Matched,
+			&entityType,
+			&entityValue,
+			&message,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning test result: %w", err)
+		}
+
+		if testCaseID.Valid {
+			testResult.TestCaseID = &testCaseID.Int64
+		}
+		if entityType.Valid {
+			testResult.Entity = &models.ExpectedEntity{
+				EntityType:  models.EntityType(entityType.String),
+				EntityValue: entityValue.String,
+			}
+		}
+		testResult.Message = message.String
+
+		run.Results = append(run.Results, &testResult)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating test results: %w", err)
+	}
+
+	return run, nil
+}
+
+// ListRuns retrieves a detection's test runs, newest first, without their
+// results
+func (r *Repository) ListRuns(detectionID int64) ([]*models.DetectionTestRun, error) {
+	query := `SELECT ` + runColumns + ` FROM detection_test_runs WHERE detection_id = ? ORDER BY id DESC`
+
+	rows, err := r.db.Query(query, detectionID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying test runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]*models.DetectionTestRun, 0)
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating test runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// scanRun scans a test run from a row
+func scanRun(row database.RowScanner) (*models.DetectionTestRun, error) {
+	var run models.DetectionTestRun
+	var language, runError, runBy sql.NullString
+	var createdAt string
+
+	err := row.Scan(
+		&run.ID,
+		&run.DetectionID,
+		&run.Query,
+		&language,
+		&run.Status,
+		&run.Passed,
+		&run.Failed,
+		&runError,
+		&runBy,
+		&createdAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning test run: %w", err)
+	}
+
+	run.Language = language.String
+	run.Error = runError.String
+	run.RunBy = runBy.String
+	run.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+
+	return &run, nil
+}
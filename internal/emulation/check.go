@@ -0,0 +1,284 @@
+package emulation
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"riskmatrix/internal/risk"
+	"riskmatrix/pkg/models"
+)
+
+// CheckTest checks an emulation test against the events its expected
+// detections produced, and stores the result. A completed test can be
+// checked again to pick up events that were ingested late.
+func (r *Repository) CheckTest(id int64) (*models.EmulationTest, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	test, err := r.getTest(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.checkTx(tx, test); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return test, nil
+}
+
+// CheckPending checks every emulation test whose detection window was still
+// open when it was last checked
+func (r *Repository) CheckPending() error {
+	rows, err := r.db.Query(`SELECT id FROM emulation_tests WHERE status = ?`, models.EmulationPending)
+	if err != nil {
+		return fmt.Errorf("error querying pending emulation tests: %w", err)
+	}
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning emulation test ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if _, err := r.CheckTest(id); err != nil {
+			return fmt.Errorf("error checking emulation test %d: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// StartCheckProcess starts a background process that checks pending tests
+// periodically, so that reading tests and coverage does not write
+func (r *Repository) StartCheckProcess(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.CheckPending(); err != nil {
+				log.Printf("Error checking pending emulation tests: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// checkTx looks for the first event each expected detection produced for the
+// target host between execution and the end of the window, then sets and
+// stores the test's status. The test stays pending while the window is open
+// and a detection has not fired yet.
+func (r *Repository) checkTx(tx *sql.Tx, test *models.EmulationTest) error {
+	detected := 0
+	for _, expected := range test.ExpectedDetections {
+		eventID, detectedAt, err := r.firstEventTx(tx, expected.DetectionID, test)
+		if err != nil {
+			return err
+		}
+
+		expected.Detected = eventID != 0
+		expected.EventID = nil
+		expected.DetectedAt = nil
+		eventArg := sql.NullInt64{}
+		detectedAtArg := sql.NullString{}
+		if expected.Detected {
+			detected++
+			expected.EventID = &eventID
+			expected.DetectedAt = &detectedAt
+			eventArg = sql.NullInt64{Int64: eventID, Valid: true}
+			detectedAtArg = sql.NullString{String: detectedAt.UTC().Format(time.RFC3339), Valid: true}
+		}
+
+		if _, err := tx.Exec(
+			`UPDATE emulation_test_detections SET detected = ?, event_id = ?, detected_at = ?
+             WHERE emulation_test_id = ? AND detection_id = ?`,
+			expected.Detected, eventArg, detectedAtArg, test.ID, expected.DetectionID,
+		); err != nil {
+			return fmt.Errorf("error updating expected detection: %w", err)
+		}
+	}
+
+	now := r.now()
+	switch {
+	case detected == len(test.ExpectedDetections):
+		test.Status = models.EmulationValidated
+	case now.Before(test.WindowEnd()):
+		test.Status = models.EmulationPending
+	case detected > 0:
+		test.Status = models.EmulationPartial
+	default:
+		test.Status = models.EmulationMissed
+	}
+	test.CheckedAt = &now
+
+	if _, err := tx.Exec(
+		`UPDATE emulation_tests SET status = ?, checked_at = ? WHERE id = ?`,
+		test.Status, now.Format(time.RFC3339), test.ID,
+	); err != nil {
+		return fmt.Errorf("error updating emulation test: %w", err)
+	}
+
+	return nil
+}
+
+// firstEventTx returns the first event a detection produced for a test's
+// target host within the test's window, or a zero ID if there was none. An
+// event is for the host when the host is its entity or is named by a host
+// field of its context, so detections keyed on users count too.
+func (r *Repository) firstEventTx(tx *sql.Tx, detectionID int64, test *models.EmulationTest) (int64, time.Time, error) {
+	rows, err := tx.Query(
+		`SELECT e.id, e.timestamp, ro.entity_type, ro.entity_value, e.context
+         FROM events e
+         JOIN risk_objects ro ON e.entity_id = ro.id
+         WHERE e.detection_id = ? AND datetime(e.timestamp) >= datetime(?) AND datetime(e.timestamp) <= datetime(?)
+         ORDER BY datetime(e.timestamp), e.id`,
+		detectionID,
+		test.ExecutedAt.UTC().Format(time.RFC3339),
+		test.WindowEnd().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("error querying events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var timestamp, entityType, entityValue string
+		var context sql.NullString
+
+		if err := rows.Scan(&id, &timestamp, &entityType, &entityValue, &context); err != nil {
+			return 0, time.Time{}, fmt.Errorf("error scanning event row: %w", err)
+		}
+
+		if eventOnHost(models.EntityType(entityType), entityValue, context.String, test.TargetHost) {
+			at, _ := time.Parse(time.RFC3339, timestamp)
+			return id, at, nil
+		}
+	}
+
+	return 0, time.Time{}, nil
+}
+
+// eventOnHost reports whether an event with the given entity and context
+// happened on host. Host names are compared ignoring case.
+func eventOnHost(entityType models.EntityType, entityValue, context, host string) bool {
+	if entityType == models.EntityTypeHost && strings.EqualFold(entityValue, host) {
+		return true
+	}
+	if context == "" {
+		return false
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(context), &values); err != nil {
+		return false
+	}
+	for key, fieldType := range risk.DefaultCorrelationFields {
+		if fieldType != models.EntityTypeHost {
+			continue
+		}
+		if value, ok := values[key].(string); ok && strings.EqualFold(strings.TrimSpace(value), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Coverage returns the validated coverage status of every MITRE technique:
+// validated when the latest completed emulation test of the technique
+// triggered all its expected detections, mapped-only when production
+// detections are mapped to it but it is not validated, and not covered
+// otherwise. Pending tests do not count until they have been checked.
+func (r *Repository) Coverage() ([]*models.TechniqueValidationCoverage, error) {
+	rows, err := r.db.Query(
+		`SELECT mt.id, mt.name, mt.tactic, COUNT(DISTINCT d.id)
+         FROM mitre_techniques mt
+         LEFT JOIN detection_mitre_map dmm ON mt.id = dmm.mitre_id
+         LEFT JOIN detections d ON dmm.detection_id = d.id AND d.status = 'production'
+         GROUP BY mt.id
+         ORDER BY mt.tactic, mt.id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying technique coverage: %w", err)
+	}
+
+	coverage := make([]*models.TechniqueValidationCoverage, 0)
+	for rows.Next() {
+		var technique models.TechniqueValidationCoverage
+		if err := rows.Scan(&technique.TechniqueID, &technique.Name, &technique.Tactic, &technique.Detections); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning technique coverage row: %w", err)
+		}
+		coverage = append(coverage, &technique)
+	}
+	rows.Close()
+
+	latest, err := r.latestCompletedTests()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, technique := range coverage {
+		test := latest[technique.TechniqueID]
+		if test != nil {
+			technique.LastTestID = &test.ID
+			technique.LastTestStatus = test.Status
+			technique.LastExecutedAt = &test.ExecutedAt
+		}
+
+		switch {
+		case technique.Detections == 0:
+			technique.Status = models.TechniqueNotCovered
+		case test != nil && test.Status == models.EmulationValidated:
+			technique.Status = models.TechniqueValidated
+		default:
+			technique.Status = models.TechniqueMappedOnly
+		}
+	}
+
+	return coverage, nil
+}
+
+// latestCompletedTests returns the most recently executed test of each
+// technique that is no longer pending, without expected detections
+func (r *Repository) latestCompletedTests() (map[string]*models.EmulationTest, error) {
+	rows, err := r.db.Query(
+		`SELECT `+testColumns+` FROM emulation_tests WHERE status != ? ORDER BY executed_at, id`,
+		models.EmulationPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying emulation tests: %w", err)
+	}
+	defer rows.Close()
+
+	latest := make(map[string]*models.EmulationTest)
+	for rows.Next() {
+		test, err := scanTest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning emulation test row: %w", err)
+		}
+		latest[test.TechniqueID] = test
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,277 @@
+package emulation
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// DefaultWindowMinutes is how long after execution events count towards a
+// test that does not set its own window
+const DefaultWindowMinutes = 60
+
+var (
+	// ErrNotFound is returned when an emulation test does not exist
+	ErrNotFound = errors.New("emulation test not found")
+
+	// ErrUnknownReference is returned when recording a test of a technique
+	// or expecting a detection that does not exist
+	ErrUnknownReference = errors.New("unknown technique or detection")
+)
+
+// Repository stores emulation test executions and checks them against the
+// events their expected detections produced
+type Repository struct {
+	db *database.DB
+
+	// now returns the current time; replaced in tests
+	now func() time.Time
+}
+
+// NewRepository creates a new emulation test repository
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db, now: time.Now}
+}
+
+const testColumns = `id, technique_id, test_name, target_host, executed_at, window_minutes, executed_by, notes, status, checked_at, created_at`
+
+// scanTest scans an emulation test row, without its expected detections
+func scanTest(row database.RowScanner) (*models.EmulationTest, error) {
+	var test models.EmulationTest
+	var executedBy, notes, checkedAt sql.NullString
+	var executedAt, createdAt string
+
+	err := row.Scan(
+		&test.ID,
+		&test.TechniqueID,
+		&test.TestName,
+		&test.TargetHost,
+		&executedAt,
+		&test.WindowMinutes,
+		&executedBy,
+		&notes,
+		&test.Status,
+		&checkedAt,
+		&createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle nullable fields
+	if executedBy.Valid {
+		test.ExecutedBy = executedBy.String
+	}
+	if notes.Valid {
+		test.Notes = notes.String
+	}
+	if checkedAt.Valid {
+		if t, err := time.Parse(time.RFC3339, checkedAt.String); err == nil {
+			test.CheckedAt = &t
+		}
+	}
+
+	// Parse timestamps
+	test.ExecutedAt, _ = time.Parse(time.RFC3339, executedAt)
+	test.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+
+	return &test, nil
+}
+
+// CreateTest records an emulation test execution and checks it straight
+// away, so that a test logged after the fact is validated immediately
+func (r *Repository) CreateTest(test *models.EmulationTest) error {
+	if test.WindowMinutes == 0 {
+		test.WindowMinutes = DefaultWindowMinutes
+	}
+	test.TargetHost = strings.TrimSpace(test.TargetHost)
+	test.Status = models.EmulationPending
+	test.CheckedAt = nil
+	test.CreatedAt = time.Now()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM mitre_techniques WHERE id = ?)`, test.TechniqueID).Scan(&exists); err != nil {
+		return fmt.Errorf("error checking MITRE technique: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: MITRE technique %s", ErrUnknownReference, test.TechniqueID)
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO emulation_tests (technique_id, test_name, target_host, executed_at, window_minutes, executed_by, notes, status, created_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		test.TechniqueID,
+		test.TestName,
+		test.TargetHost,
+		test.ExecutedAt.UTC().Format(time.RFC3339),
+		test.WindowMinutes,
+		sql.NullString{String: test.ExecutedBy, Valid: test.ExecutedBy != ""},
+		sql.NullString{String: test.Notes, Valid: test.Notes != ""},
+		test.Status,
+		test.CreatedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating emulation test: %w", err)
+	}
+
+	test.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	for _, expected := range test.ExpectedDetections {
+		if err := tx.QueryRow(`SELECT name FROM detections WHERE id = ?`, expected.DetectionID).Scan(&expected.DetectionName); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: detection %d", ErrUnknownReference, expected.DetectionID)
+			}
+			return fmt.Errorf("error checking detection: %w", err)
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO emulation_test_detections (emulation_test_id, detection_id) VALUES (?, ?)`,
+			test.ID, expected.DetectionID,
+		); err != nil {
+			return fmt.Errorf("error adding expected detection: %w", err)
+		}
+	}
+
+	if err := r.checkTx(tx, test); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetTest retrieves an emulation test with its expected detections
+func (r *Repository) GetTest(id int64) (*models.EmulationTest, error) {
+	return r.getTest(r.db, id)
+}
+
+// getTest retrieves an emulation test with its expected detections
+func (r *Repository) getTest(q database.Queryer, id int64) (*models.EmulationTest, error) {
+	test, err := scanTest(q.QueryRow(`SELECT `+testColumns+` FROM emulation_tests WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %d", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("error scanning emulation test: %w", err)
+	}
+
+	test.ExpectedDetections, err = r.listExpectedDetections(q, id)
+	if err != nil {
+		return nil, err
+	}
+	return test, nil
+}
+
+// listExpectedDetections lists the detections an emulation test is expected
+// to trigger, by detection name
+func (r *Repository) listExpectedDetections(q database.Queryer, testID int64) ([]*models.EmulationDetection, error) {
+	rows, err := q.Query(
+		`SELECT etd.detection_id, d.name, etd.detected, etd.event_id, etd.detected_at
+         FROM emulation_test_detections etd
+         JOIN detections d ON etd.detection_id = d.id
+         WHERE etd.emulation_test_id = ?
+         ORDER BY d.name`,
+		testID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying expected detections: %w", err)
+	}
+	defer rows.Close()
+
+	detections := make([]*models.EmulationDetection, 0)
+	for rows.Next() {
+		var expected models.EmulationDetection
+		var eventID sql.NullInt64
+		var detectedAt sql.NullString
+
+		if err := rows.Scan(&expected.DetectionID, &expected.DetectionName, &expected.Detected, &eventID, &detectedAt); err != nil {
+			return nil, fmt.Errorf("error scanning expected detection row: %w", err)
+		}
+
+		if eventID.Valid {
+			expected.EventID = &eventID.Int64
+		}
+		if detectedAt.Valid {
+			if t, err := time.Parse(time.RFC3339, detectedAt.String); err == nil {
+				expected.DetectedAt = &t
+			}
+		}
+
+		detections = append(detections, &expected)
+	}
+
+	return detections, nil
+}
+
+// ListTests lists emulation tests, newest execution first, optionally only
+// those of one technique
+func (r *Repository) ListTests(techniqueID string) ([]*models.EmulationTest, error) {
+	query := `SELECT ` + testColumns + ` FROM emulation_tests`
+	args := make([]interface{}, 0, 1)
+	if techniqueID != "" {
+		query += ` WHERE technique_id = ?`
+		args = append(args, techniqueID)
+	}
+	query += ` ORDER BY executed_at DESC, id DESC`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying emulation tests: %w", err)
+	}
+
+	tests := make([]*models.EmulationTest, 0)
+	for rows.Next() {
+		test, err := scanTest(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning emulation test row: %w", err)
+		}
+		tests = append(tests, test)
+	}
+	rows.Close()
+
+	for _, test := range tests {
+		test.ExpectedDetections, err = r.listExpectedDetections(r.db, test.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tests, nil
+}
+
+// DeleteTest deletes an emulation test
+func (r *Repository) DeleteTest(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM emulation_tests WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting emulation test: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package emulation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/mitre"
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// setupTestRepo creates an emulation test repository with a test database
+// holding a technique and two production detections mapped to it
+func setupTestRepo(t *testing.T) (*Repository, *database.DB, []*models.Detection) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+
+	technique := &models.MitreTechnique{ID: "T1059.001", Name: "PowerShell", Tactic: "Execution", IsSubTechnique: true, SubTechniqueOf: "T1059"}
+	if err := mitre.NewRepository(db).CreateMitreTechnique(technique); err != nil {
+		t.Fatalf("Failed to create technique: %v", err)
+	}
+	other := &models.MitreTechnique{ID: "T1003", Name: "OS Credential Dumping", Tactic: "Credential Access"}
+	if err := mitre.NewRepository(db).CreateMitreTechnique(other); err != nil {
+		t.Fatalf("Failed to create technique: %v", err)
+	}
+
+	detections := detection.NewRepository(db)
+	created := make([]*models.Detection, 0, 2)
+	for _, name := range []string{"Encoded PowerShell", "PowerShell Download Cradle"} {
+		det := &models.Detection{Name: name, Status: models.StatusProduction, Severity: models.SeverityHigh, RiskPoints: 40}
+		if err := detections.CreateDetection(det); err != nil {
+			t.Fatalf("Failed to create detection: %v", err)
+		}
+		if err := detections.AddMitreTechnique(det.ID, technique.ID); err != nil {
+			t.Fatalf("Failed to map technique: %v", err)
+		}
+		created = append(created, det)
+	}
+
+	return NewRepository(db), db, created
+}
+
+// addEvent records an event of a detection for an entity, with a context
+func addEvent(t *testing.T, db *database.DB, detectionID int64, entityType models.EntityType, entityValue, context string, at time.Time) {
+	_, err := db.Exec(`INSERT OR IGNORE INTO risk_objects (entity_type, entity_value) VALUES (?, ?)`, entityType, entityValue)
+	if err != nil {
+		t.Fatalf("Failed to create risk object: %v", err)
+	}
+	_, err = db.Exec(
+		`INSERT INTO events (detection_id, entity_id, timestamp, context, risk_points)
+         SELECT ?, id, ?, ?, 40 FROM risk_objects WHERE entity_type = ? AND entity_value = ?`,
+		detectionID, at.Format(time.RFC3339), context, entityType, entityValue,
+	)
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+}
+
+func TestRepository_CheckTest(t *testing.T) {
+	repo, db, detections := setupTestRepo(t)
+	defer db.Close()
+
+	executedAt := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	expected := func() []*models.EmulationDetection {
+		return []*models.EmulationDetection{{DetectionID: detections[0].ID}, {DetectionID: detections[1].ID}}
+	}
+
+	// Only the first detection fired on the host within the window: once for
+	// another host, and once after the window closed
+	addEvent(t, db, detections[0].ID, models.EntityTypeHost, "WS1", "", executedAt.Add(5*time.Minute))
+	addEvent(t, db, detections[1].ID, models.EntityTypeHost, "ws2", "", executedAt.Add(5*time.Minute))
+	addEvent(t, db, detections[1].ID, models.EntityTypeHost, "ws1", "", executedAt.Add(90*time.Minute))
+
+	test := &models.EmulationTest{
+		TechniqueID:        "T1059.001",
+		TestName:           "Atomic T1059.001-17 PowerShell EncodedCommand",
+		TargetHost:         "ws1",
+		ExecutedAt:         executedAt,
+		ExpectedDetections: expected(),
+	}
+	if err := repo.CreateTest(test); err != nil {
+		t.Fatalf("Failed to create emulation test: %v", err)
+	}
+	if test.Status != models.EmulationPartial || test.WindowMinutes != DefaultWindowMinutes {
+		t.Fatalf("Expected partial test with the default window, got %+v", test)
+	}
+	if first := test.ExpectedDetections[0]; !first.Detected || first.EventID == nil || !first.DetectedAt.Equal(executedAt.Add(5*time.Minute)) {
+		t.Errorf("Expected the first detection to be detected, got %+v", first)
+	}
+
+	// A user-keyed event naming the host in its context counts, once the
+	// test is checked again
+	addEvent(t, db, detections[1].ID, models.EntityTypeUser, "alice", `{"hostname": "WS1"}`, executedAt.Add(10*time.Minute))
+	checked, err := repo.CheckTest(test.ID)
+	if err != nil {
+		t.Fatalf("Failed to check emulation test: %v", err)
+	}
+	if checked.Status != models.EmulationValidated {
+		t.Errorf("Expected validated test, got %s", checked.Status)
+	}
+
+	// A test still inside its window stays pending until its detections fire
+	recent := &models.EmulationTest{
+		TechniqueID:        "T1059.001",
+		TestName:           "Atomic T1059.001-1",
+		TargetHost:         "ws3",
+		ExecutedAt:         time.Now().Add(-time.Minute),
+		ExpectedDetections: expected(),
+	}
+	if err := repo.CreateTest(recent); err != nil {
+		t.Fatalf("Failed to create emulation test: %v", err)
+	}
+	if recent.Status != models.EmulationPending {
+		t.Errorf("Expected pending test, got %s", recent.Status)
+	}
+	// Reading the test does not check it; the pending check does
+	repo.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	fetched, err := repo.GetTest(recent.ID)
+	if err != nil {
+		t.Fatalf("Failed to get emulation test: %v", err)
+	}
+	if fetched.Status != models.EmulationPending {
+		t.Errorf("Expected test to stay pending until checked, got %s", fetched.Status)
+	}
+	if err := repo.CheckPending(); err != nil {
+		t.Fatalf("Failed to check pending emulation tests: %v", err)
+	}
+	fetched, err = repo.GetTest(recent.ID)
+	if err != nil {
+		t.Fatalf("Failed to get emulation test: %v", err)
+	}
+	if fetched.Status != models.EmulationMissed || fetched.CheckedAt == nil {
+		t.Errorf("Expected missed test once the window closed, got %+v", fetched)
+	}
+
+	tests, err := repo.ListTests("T1059.001")
+	if err != nil {
+		t.Fatalf("Failed to list emulation tests: %v", err)
+	}
+	if len(tests) != 2 || tests[0].ID != recent.ID || len(tests[1].ExpectedDetections) != 2 {
+		t.Errorf("Unexpected emulation tests %+v", tests)
+	}
+
+	// Unknown techniques and detections are refused
+	test.ID = 0
+	test.TechniqueID = "T9999"
+	if err := repo.CreateTest(test); !errors.Is(err, ErrUnknownReference) {
+		t.Errorf("Expected unknown reference error, got %v", err)
+	}
+
+	if err := repo.DeleteTest(recent.ID); err != nil {
+		t.Fatalf("Failed to delete emulation test: %v", err)
+	}
+	if _, err := repo.GetTest(recent.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestRepository_Coverage(t *testing.T) {
+	repo, db, detections := setupTestRepo(t)
+	defer db.Close()
+
+	statuses := func() map[string]models.TechniqueValidation {
+		coverage, err := repo.Coverage()
+		if err != nil {
+			t.Fatalf("Failed to get coverage: %v", err)
+		}
+		result := make(map[string]models.TechniqueValidation)
+		for _, technique := range coverage {
+			result[technique.TechniqueID] = technique.Status
+		}
+		return result
+	}
+
+	if got := statuses(); got["T1059.001"] != models.TechniqueMappedOnly || got["T1003"] != models.TechniqueNotCovered {
+		t.Fatalf("Unexpected coverage before testing %v", got)
+	}
+
+	executedAt := time.Now().Add(-3 * time.Hour)
+	addEvent(t, db, detections[0].ID, models.EntityTypeHost, "ws1", "", executedAt.Add(time.Minute))
+	validated := &models.EmulationTest{
+		TechniqueID:        "T1059.001",
+		TestName:           "Atomic T1059.001-17",
+		TargetHost:         "ws1",
+		ExecutedAt:         executedAt,
+		ExpectedDetections: []*models.EmulationDetection{{DetectionID: detections[0].ID}},
+	}
+	if err := repo.CreateTest(validated); err != nil {
+		t.Fatalf("Failed to create emulation test: %v", err)
+	}
+	if got := statuses(); got["T1059.001"] != models.TechniqueValidated {
+		t.Errorf("Expected validated technique, got %v", got)
+	}
+
+	// A later test that misses a detection means the technique is no longer
+	// validated, while a test still in its window does not count yet
+	missed := &models.EmulationTest{
+		TechniqueID:        "T1059.001",
+		TestName:           "Atomic T1059.001-17",
+		TargetHost:         "ws1",
+		ExecutedAt:         executedAt.Add(time.Hour),
+		ExpectedDetections: []*models.EmulationDetection{{DetectionID: detections[1].ID}},
+	}
+	if err := repo.CreateTest(missed); err != nil {
+		t.Fatalf("Failed to create emulation test: %v", err)
+	}
+	pending := &models.EmulationTest{
+		TechniqueID:        "T1059.001",
+		TestName:           "Atomic T1059.001-17",
+		TargetHost:         "ws1",
+		ExecutedAt:         time.Now(),
+		ExpectedDetections: []*models.EmulationDetection{{DetectionID: detections[0].ID}},
+	}
+	if err := repo.CreateTest(pending); err != nil {
+		t.Fatalf("Failed to create emulation test: %v", err)
+	}
+
+	coverage, err := repo.Coverage()
+	if err != nil {
+		t.Fatalf("Failed to get coverage: %v", err)
+	}
+	for _, technique := range coverage {
+		if technique.TechniqueID != "T1059.001" {
+			continue
+		}
+		if technique.Status != models.TechniqueMappedOnly || technique.Detections != 2 ||
+			technique.LastTestID == nil || *technique.LastTestID != missed.ID || technique.LastTestStatus != models.EmulationMissed {
+			t.Errorf("Unexpected coverage %+v", technique)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// cefHeaderFields names the pipe-separated fields after "CEF:"
+var cefHeaderFields = []string{
+	"version",
+	"device_vendor",
+	"device_product",
+	"device_version",
+	"signature_id",
+	"name",
+	"severity",
+}
+
+// cefKey matches an extension key and the equals sign after it. Keys cannot
+// contain backslashes, so an escaped \= in a value never matches.
+var cefKey = regexp.MustCompile(`(?:^|\s)([A-Za-z0-9_.\[\]-]+)=`)
+
+// ParseCEF parses an ArcSight Common Event Format message into its header
+// fields and extension keys. Custom extension fields such as cs1 are also
+// added under the name given by their label, so cs1Label=detection_id cs1=42
+// sets detection_id to 42.
+func ParseCEF(message string) (map[string]string, error) {
+	start := strings.Index(message, "CEF:")
+	if start < 0 {
+		return nil, fmt.Errorf("not a CEF message")
+	}
+	message = message[start+len("CEF:"):]
+
+	fields := make(map[string]string)
+
+	// Header fields are separated by unescaped pipes
+	var value strings.Builder
+	field := 0
+	i := 0
+	for ; i < len(message) && field < len(cefHeaderFields); i++ {
+		switch c := message[i]; {
+		case c == '\\' && i+1 < len(message) && (message[i+1] == '|' || message[i+1] == '\\'):
+			value.WriteByte(message[i+1])
+			i++
+		case c == '|':
+			fields[cefHeaderFields[field]] = value.String()
+			value.Reset()
+			field++
+		default:
+			value.WriteByte(c)
+		}
+	}
+	if field < len(cefHeaderFields) {
+		return nil, fmt.Errorf("CEF header has %d of %d fields", field, len(cefHeaderFields))
+	}
+
+	// Each extension value runs until the next key
+	extension := message[i:]
+	matches := cefKey.FindAllStringSubmatchIndex(extension, -1)
+	for n, match := range matches {
+		key := extension[match[2]:match[3]]
+		end := len(extension)
+		if n+1 < len(matches) {
+			end = matches[n+1][0]
+		}
+		fields[key] = unescapeCEFValue(strings.TrimSpace(extension[match[1]:end]))
+	}
+
+	labelled := make(map[string]string)
+	for key, label := range fields {
+		if strings.HasSuffix(key, "Label") && label != "" {
+			if v, ok := fields[strings.TrimSuffix(key, "Label")]; ok {
+				labelled[label] = v
+			}
+		}
+	}
+	for label, v := range labelled {
+		fields[label] = v
+	}
+
+	return fields, nil
+}
+
+// unescapeCEFValue removes the escaping from an extension value
+func unescapeCEFValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(value[i])
+			}
+			continue
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,290 @@
+package ingest
+
+import (
+	"compress/gzip"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+const (
+	// maxHECBody is the largest HEC request body accepted, before and after
+	// decompression
+	maxHECBody = 10 * 1024 * 1024
+
+	// maxHECEvents is the most events accepted in one HEC request
+	maxHECEvents = 10000
+)
+
+// HECConfig holds the configuration for the HTTP Event Collector listener
+type HECConfig struct {
+	// Address to listen on
+	Addr string
+
+	// Tokens accepted in the "Authorization: Splunk <token>" header; with
+	// none configured any request is accepted
+	Tokens []string
+
+	// How event fields map onto events
+	Mapping FieldMapping
+}
+
+// hecResponse is the status payload returned by Splunk's HTTP Event Collector
+type hecResponse struct {
+	Text               string `json:"text"`
+	Code               int    `json:"code"`
+	InvalidEventNumber *int   `json:"invalid-event-number,omitempty"`
+}
+
+// HEC status codes, as returned by Splunk
+const (
+	hecSuccess          = 0
+	hecTokenRequired    = 2
+	hecInvalidAuth      = 3
+	hecInvalidToken     = 4
+	hecNoData           = 5
+	hecInvalidFormat    = 6
+	hecServerBusy       = 9
+	hecEventRequired    = 12
+	hecEventBlank       = 13
+	hecHealthy          = 17
+	hecUnsupportedRoute = 404
+)
+
+// HECListener accepts events in the Splunk HTTP Event Collector format and
+// submits the events they describe
+type HECListener struct {
+	pipeline *Pipeline
+	config   HECConfig
+	server   *http.Server
+	listener net.Listener
+}
+
+// NewHECListener creates a new HTTP Event Collector listener
+func NewHECListener(pipeline *Pipeline, config HECConfig) *HECListener {
+	l := &HECListener{
+		pipeline: pipeline,
+		config:   config,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /services/collector", l.handleEvents)
+	mux.HandleFunc("POST /services/collector/event", l.handleEvents)
+	mux.HandleFunc("POST /services/collector/event/1.0", l.handleEvents)
+	mux.HandleFunc("GET /services/collector/health", l.handleHealth)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeHEC(w, http.StatusNotFound, hecResponse{Text: "The requested URL was not found on this server.", Code: hecUnsupportedRoute})
+	})
+
+	l.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 60 * time.Second,
+	}
+	return l
+}
+
+// ServeHTTP handles an HTTP Event Collector request
+func (l *HECListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.server.Handler.ServeHTTP(w, r)
+}
+
+// Start starts listening on the configured address
+func (l *HECListener) Start() error {
+	listener, err := net.Listen("tcp", l.config.Addr)
+	if err != nil {
+		return fmt.Errorf("error listening for HEC on %s: %w", l.config.Addr, err)
+	}
+	l.listener = listener
+
+	go func() {
+		if err := l.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HEC listener error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Stop stops the listener
+func (l *HECListener) Stop() {
+	l.server.Close()
+}
+
+// Addr returns the address the listener is bound to, or nil
+func (l *HECListener) Addr() net.Addr {
+	if l.listener == nil {
+		return nil
+	}
+	return l.listener.Addr()
+}
+
+// handleHealth handles GET /services/collector/health
+func (l *HECListener) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHEC(w, http.StatusOK, hecResponse{Text: "HEC is healthy", Code: hecHealthy})
+}
+
+// handleEvents handles POST /services/collector/event
+func (l *HECListener) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if status, response := l.authorize(r); status != http.StatusOK {
+		writeHEC(w, status, response)
+		return
+	}
+
+	body := io.Reader(http.MaxBytesReader(w, r.Body, maxHECBody))
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data format", Code: hecInvalidFormat})
+			return
+		}
+		defer gz.Close()
+		body = http.MaxBytesReader(w, gz, maxHECBody)
+	}
+
+	// The body is one or more event objects, one after another
+	events := make([]*models.Event, 0)
+	decoder := json.NewDecoder(body)
+	for n := 0; ; n++ {
+		var raw json.RawMessage
+		var maxBytesErr *http.MaxBytesError
+		if err := decoder.Decode(&raw); err == io.EOF {
+			break
+		} else if errors.As(err, &maxBytesErr) {
+			writeHEC(w, http.StatusRequestEntityTooLarge, hecResponse{Text: "Content too large", Code: hecInvalidFormat, InvalidEventNumber: &n})
+			return
+		} else if err != nil {
+			writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data format", Code: hecInvalidFormat, InvalidEventNumber: &n})
+			return
+		}
+		if n >= maxHECEvents {
+			writeHEC(w, http.StatusRequestEntityTooLarge, hecResponse{Text: "Too many events", Code: hecInvalidFormat, InvalidEventNumber: &n})
+			return
+		}
+
+		event, code, err := l.event(raw)
+		if err != nil {
+			log.Printf("Rejecting HEC event %d: %v", n, err)
+			writeHEC(w, http.StatusBadRequest, hecResponse{Text: hecText(code), Code: code, InvalidEventNumber: &n})
+			return
+		}
+		events = append(events, event)
+	}
+
+	if len(events) == 0 {
+		writeHEC(w, http.StatusBadRequest, hecResponse{Text: "No data", Code: hecNoData})
+		return
+	}
+
+	for n, err := range l.pipeline.Submit(events) {
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrQueueFull), errors.Is(err, ErrStopped):
+			retryAfter := int(math.Ceil(l.pipeline.RetryAfter().Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeHEC(w, http.StatusServiceUnavailable, hecResponse{Text: "Server is busy", Code: hecServerBusy, InvalidEventNumber: &n})
+			return
+		default:
+			log.Printf("Error processing HEC event %d: %v", n, err)
+			writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data format", Code: hecInvalidFormat, InvalidEventNumber: &n})
+			return
+		}
+	}
+
+	writeHEC(w, http.StatusOK, hecResponse{Text: "Success", Code: hecSuccess})
+}
+
+// authorize checks the request's HEC token
+func (l *HECListener) authorize(r *http.Request) (int, hecResponse) {
+	if len(l.config.Tokens) == 0 {
+		return http.StatusOK, hecResponse{}
+	}
+
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return http.StatusUnauthorized, hecResponse{Text: "Token is required", Code: hecTokenRequired}
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Splunk") {
+		return http.StatusUnauthorized, hecResponse{Text: "Invalid authorization", Code: hecInvalidAuth}
+	}
+
+	for _, accepted := range l.config.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(accepted)) == 1 {
+			return http.StatusOK, hecResponse{}
+		}
+	}
+	return http.StatusForbidden, hecResponse{Text: "Invalid token", Code: hecInvalidToken}
+}
+
+// event builds an event from one HEC event object, returning the HEC status
+// code to report if it cannot
+func (l *HECListener) event(raw json.RawMessage) (*models.Event, int, error) {
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, hecInvalidFormat, err
+	}
+
+	value, ok := object["event"]
+	if !ok || value == nil {
+		return nil, hecEventRequired, fmt.Errorf("event field is required")
+	}
+
+	// A string event may itself be JSON or CEF
+	if text, ok := value.(string); ok {
+		if strings.TrimSpace(text) == "" {
+			return nil, hecEventBlank, fmt.Errorf("event field cannot be blank")
+		}
+
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+			object["event"] = decoded
+		} else if strings.Contains(text, "CEF:") {
+			if cef, err := ParseCEF(text); err == nil {
+				nested := make(map[string]interface{}, len(cef))
+				for key, v := range cef {
+					nested[key] = v
+				}
+				object["event"] = nested
+			}
+		}
+	}
+
+	fields := make(map[string]string)
+	flattenFields(fields, "", object)
+
+	event, err := l.config.Mapping.Event(fields, string(raw))
+	if err != nil {
+		return nil, hecInvalidFormat, err
+	}
+	return event, hecSuccess, nil
+}
+
+// hecText returns the message Splunk sends with a status code
+func hecText(code int) string {
+	switch code {
+	case hecEventRequired:
+		return "Event field is required"
+	case hecEventBlank:
+		return "Event field cannot be blank"
+	default:
+		return "Invalid data format"
+	}
+}
+
+// writeHEC writes an HEC status response
+func writeHEC(w http.ResponseWriter, status int, response hecResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,125 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postHEC(listener *HECListener, token, body string) (int, hecResponse) {
+	req := httptest.NewRequest("POST", "/services/collector/event", bytes.NewBufferString(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Splunk "+token)
+	}
+	w := httptest.NewRecorder()
+	listener.ServeHTTP(w, req)
+
+	var response hecResponse
+	_ = json.NewDecoder(w.Body).Decode(&response)
+	return w.Code, response
+}
+
+func TestHECListener(t *testing.T) {
+	processor := &testProcessor{fail: map[int]bool{99: true}}
+	pipeline := NewPipeline(processor, Config{Workers: 1, BatchWait: time.Millisecond})
+	pipeline.Start()
+	defer pipeline.Stop()
+
+	listener := NewHECListener(pipeline, HECConfig{Tokens: []string{"secret"}, Mapping: DefaultHECMapping()})
+
+	valid := `{"time": 1760607000.5, "host": "ws-1", "event": {"detection_id": 3, "risk_points": 20}}
+{"event": "{\"detection_id\": 4, \"user\": \"alice\"}"}`
+
+	if status, response := postHEC(listener, "", valid); status != http.StatusUnauthorized || response.Code != hecTokenRequired {
+		t.Errorf("Expected token required, got %d %+v", status, response)
+	}
+	if status, response := postHEC(listener, "wrong", valid); status != http.StatusForbidden || response.Code != hecInvalidToken {
+		t.Errorf("Expected invalid token, got %d %+v", status, response)
+	}
+
+	status, response := postHEC(listener, "secret", valid)
+	if status != http.StatusOK || response.Code != hecSuccess {
+		t.Fatalf("Expected success, got %d %+v", status, response)
+	}
+	if len(processor.seen) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(processor.seen))
+	}
+
+	first, second := processor.seen[0], processor.seen[1]
+	if first.DetectionID != 3 || first.RiskObject.EntityValue != "ws-1" || first.RiskPoints != 20 {
+		t.Errorf("Unexpected first event %+v", first)
+	}
+	if !first.Timestamp.Equal(time.Unix(1760607000, 5e8)) {
+		t.Errorf("Expected timestamp from HEC time, got %s", first.Timestamp)
+	}
+	if second.DetectionID != 4 || second.RiskObject.EntityValue != "alice" {
+		t.Errorf("Unexpected second event %+v", second)
+	}
+
+	// Errors name the event that could not be used
+	status, response = postHEC(listener, "secret", `{"event": {"detection_id": 3, "user": "bob"}} {"host": "ws-1"}`)
+	if status != http.StatusBadRequest || response.Code != hecEventRequired || response.InvalidEventNumber == nil || *response.InvalidEventNumber != 1 {
+		t.Errorf("Expected event 1 to be missing its event field, got %d %+v", status, response)
+	}
+
+	status, response = postHEC(listener, "secret", `{"event": {"detection_id": 3, "user": "bob", "risk_points": 99}}`)
+	if status != http.StatusBadRequest || response.InvalidEventNumber == nil || *response.InvalidEventNumber != 0 {
+		t.Errorf("Expected event 0 to fail processing, got %d %+v", status, response)
+	}
+
+	if status, response := postHEC(listener, "secret", ""); status != http.StatusBadRequest || response.Code != hecNoData {
+		t.Errorf("Expected no data, got %d %+v", status, response)
+	}
+}
+
+func TestHECListener_Busy(t *testing.T) {
+	// A pipeline that is not running cannot take events
+	pipeline := NewPipeline(&testProcessor{}, Config{RetryAfter: 2 * time.Second})
+	listener := NewHECListener(pipeline, HECConfig{Mapping: DefaultHECMapping()})
+
+	req := httptest.NewRequest("POST", "/services/collector", bytes.NewBufferString(`{"host": "ws-1", "event": {"detection_id": 3}}`))
+	w := httptest.NewRecorder()
+	listener.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("Expected Retry-After 2, got '%s'", retryAfter)
+	}
+}
+
+func TestHECListener_TooLarge(t *testing.T) {
+	pipeline := NewPipeline(&testProcessor{}, Config{Workers: 1, BatchWait: time.Millisecond})
+	pipeline.Start()
+	defer pipeline.Stop()
+
+	listener := NewHECListener(pipeline, HECConfig{Mapping: DefaultHECMapping()})
+
+	// A small gzip body that inflates past the body limit
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(`{"event": {"detection_id": 3, "host": "`))
+	_, _ = gz.Write(bytes.Repeat([]byte("a"), maxHECBody))
+	_, _ = gz.Write([]byte(`"}}`))
+	_ = gz.Close()
+
+	req := httptest.NewRequest("POST", "/services/collector/event", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	listener.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d for an inflated body, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+
+	events := strings.Repeat(`{"host": "ws-1", "event": {"detection_id": 3}}`, maxHECEvents+1)
+	status, response := postHEC(listener, "", events)
+	if status != http.StatusRequestEntityTooLarge || response.InvalidEventNumber == nil || *response.InvalidEventNumber != maxHECEvents {
+		t.Errorf("Expected too many events, got %d %+v", status, response)
+	}
+}
@@ -0,0 +1,162 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"riskmatrix/internal/datasource"
+	"riskmatrix/pkg/models"
+)
+
+// EntityField is a record field that names an entity of a fixed type
+type EntityField struct {
+	Field string
+	Type  models.EntityType
+}
+
+// FieldMapping maps the fields of a record received by a listener onto an
+// event. Each value names the field to read; nested fields are joined with
+// dots, e.g. "event.user".
+type FieldMapping struct {
+	// Field holding the numeric detection ID
+	DetectionID string
+
+	// Fields holding the entity type and value
+	EntityType  string
+	EntityValue string
+
+	// Fields tried in order when the record has no entity type and value;
+	// the first one present is the entity
+	Entities []EntityField
+
+	// Field holding the risk points; without it the detection's base points
+	// apply when risk modifiers are enabled
+	RiskPoints string
+
+	// Field holding the time of the event, in a format read by
+	// datasource.ParseTimestamp
+	Timestamp string
+
+	// Field holding the sender's ID for the event, used to drop replays
+	ExternalID string
+}
+
+// DefaultSyslogMapping returns the mapping for syslog messages, using the
+// standard CEF extension keys for entities
+func DefaultSyslogMapping() FieldMapping {
+	return FieldMapping{
+		DetectionID: "detection_id",
+		EntityType:  "entity_type",
+		EntityValue: "entity_value",
+		Entities: []EntityField{
+			{Field: "suser", Type: models.EntityTypeUser},
+			{Field: "duser", Type: models.EntityTypeUser},
+			{Field: "shost", Type: models.EntityTypeHost},
+			{Field: "dhost", Type: models.EntityTypeHost},
+			{Field: "src", Type: models.EntityTypeIP},
+			{Field: "dst", Type: models.EntityTypeIP},
+		},
+		RiskPoints: "risk_points",
+		Timestamp:  "rt",
+		ExternalID: "externalId",
+	}
+}
+
+// DefaultHECMapping returns the mapping for HTTP Event Collector events
+func DefaultHECMapping() FieldMapping {
+	return FieldMapping{
+		DetectionID: "event.detection_id",
+		EntityType:  "event.entity_type",
+		EntityValue: "event.entity_value",
+		Entities: []EntityField{
+			{Field: "event.user", Type: models.EntityTypeUser},
+			{Field: "event.src_ip", Type: models.EntityTypeIP},
+			{Field: "event.dest_host", Type: models.EntityTypeHost},
+			{Field: "host", Type: models.EntityTypeHost},
+		},
+		RiskPoints: "event.risk_points",
+		Timestamp:  "time",
+		ExternalID: "event.event_id",
+	}
+}
+
+// Event builds an event from a record's fields. raw is kept as the event's
+// raw data and the fields are kept as its context.
+func (m FieldMapping) Event(fields map[string]string, raw string) (*models.Event, error) {
+	event := &models.Event{RawData: raw}
+
+	value := fields[m.DetectionID]
+	if value == "" {
+		return nil, fmt.Errorf("missing detection ID field %s", m.DetectionID)
+	}
+	detectionID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || detectionID <= 0 {
+		return nil, fmt.Errorf("invalid detection ID: %s", value)
+	}
+	event.DetectionID = detectionID
+
+	entityType, entityValue := models.EntityType(fields[m.EntityType]), fields[m.EntityValue]
+	if entityType == "" || entityValue == "" {
+		entityType, entityValue = "", ""
+		for _, entity := range m.Entities {
+			if v := strings.TrimSpace(fields[entity.Field]); v != "" {
+				entityType, entityValue = entity.Type, v
+				break
+			}
+		}
+	}
+	switch entityType {
+	case models.EntityTypeUser, models.EntityTypeHost, models.EntityTypeIP:
+	case "":
+		return nil, fmt.Errorf("no entity field present")
+	default:
+		return nil, fmt.Errorf("invalid entity type: %s", entityType)
+	}
+	event.RiskObject = &models.RiskObject{EntityType: entityType, EntityValue: entityValue}
+
+	if value := fields[m.RiskPoints]; value != "" {
+		points, err := strconv.Atoi(value)
+		if err != nil || points < 0 {
+			return nil, fmt.Errorf("invalid risk points: %s", value)
+		}
+		event.RiskPoints = points
+	}
+
+	if value := fields[m.Timestamp]; value != "" {
+		timestamp, err := datasource.ParseTimestamp(value, "")
+		if err != nil {
+			return nil, err
+		}
+		event.Timestamp = timestamp
+	}
+
+	event.ExternalID = fields[m.ExternalID]
+
+	context, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding context: %w", err)
+	}
+	event.Context = string(context)
+
+	return event, nil
+}
+
+// flattenFields adds the values of a decoded JSON object to fields, joining
+// nested keys with dots
+func flattenFields(fields map[string]string, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flattenFields(fields, key, nested)
+		}
+	case nil:
+	default:
+		// Arrays are kept as JSON
+		fields[prefix] = datasource.FieldValue(v)
+	}
+}
@@ -0,0 +1,287 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+var (
+	// ErrQueueFull is returned for an event that was not queued because the
+	// worker it belongs to has no room left
+	ErrQueueFull = errors.New("ingestion queue is full")
+
+	// ErrStopped is returned for an event submitted while the pipeline is not
+	// running
+	ErrStopped = errors.New("ingestion pipeline is not running")
+)
+
+// Processor processes a batch of events, returning each event's error and an
+// error if the batch as a whole failed
+type Processor interface {
+	ProcessEventBatch(events []*models.Event) ([]error, error)
+}
+
+// Config holds the configuration for the ingestion pipeline
+type Config struct {
+	// Number of events each worker can hold before submissions are rejected
+	QueueSize int
+
+	// Number of workers; events for the same entity always go to the same
+	// worker so they are processed in the order they were submitted
+	Workers int
+
+	// Maximum number of events processed in one transaction
+	BatchSize int
+
+	// How long a worker waits for more events before processing a partial
+	// batch
+	BatchWait time.Duration
+
+	// How long clients are told to wait before retrying a rejected event
+	RetryAfter time.Duration
+}
+
+// DefaultConfig returns the default ingestion configuration
+func DefaultConfig() Config {
+	return Config{
+		QueueSize:  1000,
+		Workers:    4,
+		BatchSize:  100,
+		BatchWait:  50 * time.Millisecond,
+		RetryAfter: 5 * time.Second,
+	}
+}
+
+// job is a queued event and the channel its result is sent on, if the
+// submitter is waiting for it
+type job struct {
+	event  *models.Event
+	result chan error
+}
+
+// Pipeline queues events in memory and processes them in batches on a pool of
+// workers
+type Pipeline struct {
+	processor Processor
+	config    Config
+	queues    []chan *job
+
+	// mu guards running and the queues being closed
+	mu      sync.RWMutex
+	running bool
+
+	// writeMu lets one batch write at a time, as SQLite allows a single writer
+	writeMu sync.Mutex
+	wg      sync.WaitGroup
+}
+
+// NewPipeline creates a new ingestion pipeline. Zero config values fall back
+// to the defaults.
+func NewPipeline(processor Processor, config Config) *Pipeline {
+	defaults := DefaultConfig()
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.BatchWait <= 0 {
+		config.BatchWait = defaults.BatchWait
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = defaults.RetryAfter
+	}
+
+	return &Pipeline{
+		processor: processor,
+		config:    config,
+	}
+}
+
+// Start starts the workers
+func (p *Pipeline) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return
+	}
+
+	p.queues = make([]chan *job, p.config.Workers)
+	for i := range p.queues {
+		p.queues[i] = make(chan *job, p.config.QueueSize)
+		p.wg.Add(1)
+		go p.worker(p.queues[i])
+	}
+	p.running = true
+}
+
+// Stop stops accepting events and waits for the queued ones to be processed
+func (p *Pipeline) Stop() {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = false
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// RetryAfter returns how long clients should wait before retrying a rejected
+// event
+func (p *Pipeline) RetryAfter() time.Duration {
+	return p.config.RetryAfter
+}
+
+// QueueDepth returns the number of events waiting to be processed
+func (p *Pipeline) QueueDepth() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	depth := 0
+	for _, queue := range p.queues {
+		depth += len(queue)
+	}
+	return depth
+}
+
+// Submit queues events and waits until they have been processed. It returns
+// each event's error: ErrQueueFull or ErrStopped if it was not queued,
+// otherwise the result of processing it, or nil. Once an event is rejected,
+// later events for the same entity are rejected too so that none are
+// processed out of order.
+func (p *Pipeline) Submit(events []*models.Event) []error {
+	results, jobs := p.enqueue(events, true)
+
+	for i, j := range jobs {
+		if j != nil {
+			results[i] = <-j.result
+		}
+	}
+	return results
+}
+
+// Queue queues events without waiting for them to be processed. It returns
+// ErrQueueFull or ErrStopped for each event that was not queued; errors
+// processing the queued events are logged.
+func (p *Pipeline) Queue(events []*models.Event) []error {
+	results, _ := p.enqueue(events, false)
+	return results
+}
+
+// enqueue adds events to their workers' queues, returning an error for each
+// event that was not queued and the job of each one that was
+func (p *Pipeline) enqueue(events []*models.Event, wait bool) ([]error, []*job) {
+	results := make([]error, len(events))
+	jobs := make([]*job, len(events))
+
+	p.mu.RLock()
+	rejected := make(map[string]bool)
+	for i, event := range events {
+		if !p.running {
+			results[i] = ErrStopped
+			continue
+		}
+
+		key := entityKey(event)
+		if rejected[key] {
+			results[i] = ErrQueueFull
+			continue
+		}
+
+		j := &job{event: event}
+		if wait {
+			j.result = make(chan error, 1)
+		}
+		select {
+		case p.queues[p.shard(key)] <- j:
+			jobs[i] = j
+		default:
+			rejected[key] = true
+			results[i] = ErrQueueFull
+		}
+	}
+	p.mu.RUnlock()
+
+	return results, jobs
+}
+
+// worker processes events from a queue in batches until the queue is closed
+func (p *Pipeline) worker(queue chan *job) {
+	defer p.wg.Done()
+
+	for first := range queue {
+		batch := []*job{first}
+
+		timer := time.NewTimer(p.config.BatchWait)
+	collect:
+		for len(batch) < p.config.BatchSize {
+			select {
+			case j, ok := <-queue:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, j)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		p.process(batch)
+	}
+}
+
+// process runs a batch through the processor and sends each event its result
+func (p *Pipeline) process(batch []*job) {
+	events := make([]*models.Event, len(batch))
+	for i, j := range batch {
+		events[i] = j.event
+	}
+
+	p.writeMu.Lock()
+	results, err := p.processor.ProcessEventBatch(events)
+	p.writeMu.Unlock()
+
+	for i, j := range batch {
+		result := err
+		if err == nil {
+			result = results[i]
+		}
+
+		if j.result != nil {
+			j.result <- result
+		} else if result != nil {
+			log.Printf("Error processing queued event: %v", result)
+		}
+	}
+}
+
+// shard returns the index of the worker that handles an entity
+func (p *Pipeline) shard(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// entityKey identifies the entity an event belongs to
+func entityKey(event *models.Event) string {
+	if event.RiskObject != nil {
+		return fmt.Sprintf("%s|%s", event.RiskObject.EntityType, event.RiskObject.EntityValue)
+	}
+	return fmt.Sprintf("id|%d", event.EntityID)
+}
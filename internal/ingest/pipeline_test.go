@@ -0,0 +1,150 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+// testProcessor records the events it processes and can be held to keep
+// events queued
+type testProcessor struct {
+	mu      sync.Mutex
+	seen    []*models.Event
+	started chan struct{}
+	release chan struct{}
+	fail    map[int]bool
+}
+
+func (p *testProcessor) ProcessEventBatch(events []*models.Event) ([]error, error) {
+	if p.started != nil {
+		p.started <- struct{}{}
+	}
+	if p.release != nil {
+		<-p.release
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	results := make([]error, len(events))
+	for i, event := range events {
+		if p.fail[event.RiskPoints] {
+			results[i] = errors.New("processing failed")
+			continue
+		}
+		p.seen = append(p.seen, event)
+	}
+	return results, nil
+}
+
+func testEvent(entity string, points int) *models.Event {
+	return &models.Event{
+		RiskObject: &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: entity},
+		RiskPoints: points,
+	}
+}
+
+func TestPipeline_PerEntityOrder(t *testing.T) {
+	processor := &testProcessor{fail: map[int]bool{3: true}}
+	pipeline := NewPipeline(processor, Config{Workers: 4, BatchSize: 5, BatchWait: time.Millisecond})
+	pipeline.Start()
+	defer pipeline.Stop()
+
+	// Submit several entities concurrently, each with its own ordered events
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(entity string) {
+			defer wg.Done()
+			events := make([]*models.Event, 0)
+			for points := 1; points <= 10; points++ {
+				events = append(events, testEvent(entity, points))
+			}
+			results := pipeline.Submit(events)
+			for n, err := range results {
+				if (n == 2) != (err != nil) {
+					t.Errorf("Unexpected result for %s event %d: %v", entity, n+1, err)
+				}
+			}
+		}(fmt.Sprintf("user-%d", i))
+	}
+	wg.Wait()
+
+	last := make(map[string]int)
+	for _, event := range processor.seen {
+		entity := event.RiskObject.EntityValue
+		if event.RiskPoints <= last[entity] {
+			t.Errorf("Event %d for %s processed after event %d", event.RiskPoints, entity, last[entity])
+		}
+		last[entity] = event.RiskPoints
+	}
+	if len(processor.seen) != 36 {
+		t.Errorf("Expected 36 processed events, got %d", len(processor.seen))
+	}
+}
+
+func TestPipeline_QueueFull(t *testing.T) {
+	processor := &testProcessor{started: make(chan struct{}, 1), release: make(chan struct{})}
+	pipeline := NewPipeline(processor, Config{QueueSize: 1, Workers: 1, BatchSize: 1})
+	pipeline.Start()
+	defer pipeline.Stop()
+
+	// The first event holds the worker and the second fills the queue
+	first := make(chan []error, 1)
+	go func() { first <- pipeline.Submit([]*models.Event{testEvent("alice", 1)}) }()
+	<-processor.started
+
+	second := make(chan []error, 1)
+	go func() { second <- pipeline.Submit([]*models.Event{testEvent("alice", 2)}) }()
+	for pipeline.QueueDepth() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Once alice is rejected her later events are rejected too
+	results := pipeline.Submit([]*models.Event{testEvent("alice", 3), testEvent("alice", 4)})
+	for i, err := range results {
+		if !errors.Is(err, ErrQueueFull) {
+			t.Errorf("Expected event %d to be rejected with a full queue, got %v", i, err)
+		}
+	}
+
+	go func() {
+		for range 2 {
+			processor.release <- struct{}{}
+		}
+	}()
+	<-processor.started
+	if err := (<-first)[0]; err != nil {
+		t.Errorf("Expected first event to be processed, got %v", err)
+	}
+	if err := (<-second)[0]; err != nil {
+		t.Errorf("Expected second event to be processed, got %v", err)
+	}
+}
+
+func TestPipeline_Stopped(t *testing.T) {
+	processor := &testProcessor{}
+	pipeline := NewPipeline(processor, Config{})
+
+	if err := pipeline.Submit([]*models.Event{testEvent("alice", 1)})[0]; !errors.Is(err, ErrStopped) {
+		t.Errorf("Expected ErrStopped before start, got %v", err)
+	}
+
+	pipeline.Start()
+	if err := pipeline.Submit([]*models.Event{testEvent("alice", 1)})[0]; err != nil {
+		t.Errorf("Expected event to be processed, got %v", err)
+	}
+	pipeline.Stop()
+
+	if err := pipeline.Submit([]*models.Event{testEvent("alice", 2)})[0]; !errors.Is(err, ErrStopped) {
+		t.Errorf("Expected ErrStopped after stop, got %v", err)
+	}
+	if len(processor.seen) != 1 {
+		t.Errorf("Expected 1 processed event, got %d", len(processor.seen))
+	}
+}
@@ -0,0 +1,400 @@
+package ingest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"riskmatrix/pkg/models"
+)
+
+// maxSyslogMessage is the largest syslog message accepted
+const maxSyslogMessage = 64 * 1024
+
+// syslogHeaderFields names the space-separated RFC 5424 header fields after
+// the version
+var syslogHeaderFields = []string{"timestamp", "hostname", "app_name", "procid", "msgid"}
+
+// ParseSyslog parses an RFC 5424 syslog message into its header fields,
+// structured data and message. Structured data parameters are named
+// "<sd-id>.<param>". A CEF message body is parsed into its fields as well,
+// and CEF carried in another syslog format, such as RFC 3164, is accepted on
+// its own.
+func ParseSyslog(message string) (map[string]string, error) {
+	fields, err := parseRFC5424(message)
+	if err != nil {
+		if strings.Contains(message, "CEF:") {
+			return ParseCEF(message)
+		}
+		return nil, err
+	}
+
+	if strings.Contains(fields["message"], "CEF:") {
+		cef, err := ParseCEF(fields["message"])
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range cef {
+			fields[key] = value
+		}
+	}
+
+	return fields, nil
+}
+
+// parseRFC5424 parses the header, structured data and message of an RFC 5424
+// message
+func parseRFC5424(message string) (map[string]string, error) {
+	if !strings.HasPrefix(message, "<") {
+		return nil, fmt.Errorf("missing syslog priority")
+	}
+	end := strings.IndexByte(message, '>')
+	if end < 2 || end > 4 {
+		return nil, fmt.Errorf("invalid syslog priority")
+	}
+	if _, err := strconv.Atoi(message[1:end]); err != nil {
+		return nil, fmt.Errorf("invalid syslog priority: %s", message[1:end])
+	}
+
+	fields := map[string]string{"priority": message[1:end]}
+	rest := message[end+1:]
+
+	if !strings.HasPrefix(rest, "1 ") {
+		return nil, fmt.Errorf("unsupported syslog version")
+	}
+	rest = rest[2:]
+
+	for _, name := range syslogHeaderFields {
+		value, remainder, ok := strings.Cut(rest, " ")
+		if !ok {
+			return nil, fmt.Errorf("syslog header is missing %s", name)
+		}
+		if value != "-" {
+			fields[name] = value
+		}
+		rest = remainder
+	}
+
+	rest, err := parseStructuredData(rest, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// The message may start with a byte order mark
+	rest = strings.TrimPrefix(strings.TrimPrefix(rest, " "), "\ufeff")
+	if rest != "" {
+		fields["message"] = rest
+	}
+
+	return fields, nil
+}
+
+// parseStructuredData adds the parameters of RFC 5424 structured data
+// elements to fields and returns the rest of the message
+func parseStructuredData(data string, fields map[string]string) (string, error) {
+	if strings.HasPrefix(data, "-") {
+		return data[1:], nil
+	}
+
+	for strings.HasPrefix(data, "[") {
+		end := -1
+		escaped, quoted := false, false
+		for i := 1; i < len(data) && end < 0; i++ {
+			switch {
+			case escaped:
+				escaped = false
+			case data[i] == '\\':
+				escaped = true
+			case data[i] == '"':
+				quoted = !quoted
+			case data[i] == ']' && !quoted:
+				end = i
+			}
+		}
+		if end < 0 {
+			return "", fmt.Errorf("unterminated structured data")
+		}
+
+		id, params, _ := strings.Cut(data[1:end], " ")
+		for params != "" {
+			name, value, ok := strings.Cut(params, `="`)
+			if !ok {
+				return "", fmt.Errorf("invalid structured data parameter in %s", id)
+			}
+
+			var b strings.Builder
+			i := 0
+			for ; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+				}
+				b.WriteByte(value[i])
+			}
+			fields[id+"."+strings.TrimSpace(name)] = b.String()
+
+			if i < len(value) {
+				i++
+			}
+			params = strings.TrimPrefix(value[i:], " ")
+		}
+
+		data = data[end+1:]
+	}
+
+	return data, nil
+}
+
+// SyslogConfig holds the configuration for the syslog listener
+type SyslogConfig struct {
+	// Addresses to receive syslog on; either may be empty
+	UDPAddr string
+	TCPAddr string
+
+	// How message fields map onto events
+	Mapping FieldMapping
+}
+
+// SyslogListener receives syslog messages over UDP and TCP and queues the
+// events they describe
+type SyslogListener struct {
+	pipeline *Pipeline
+	config   SyslogConfig
+
+	udp net.PacketConn
+	tcp net.Listener
+
+	// mu guards conns, the open TCP connections, and closed
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewSyslogListener creates a new syslog listener
+func NewSyslogListener(pipeline *Pipeline, config SyslogConfig) *SyslogListener {
+	return &SyslogListener{
+		pipeline: pipeline,
+		config:   config,
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// Start starts listening on the configured addresses
+func (l *SyslogListener) Start() error {
+	if l.config.UDPAddr != "" {
+		conn, err := net.ListenPacket("udp", l.config.UDPAddr)
+		if err != nil {
+			return fmt.Errorf("error listening for syslog on udp %s: %w", l.config.UDPAddr, err)
+		}
+		l.udp = conn
+		l.wg.Add(1)
+		go l.serveUDP()
+	}
+
+	if l.config.TCPAddr != "" {
+		listener, err := net.Listen("tcp", l.config.TCPAddr)
+		if err != nil {
+			l.Stop()
+			return fmt.Errorf("error listening for syslog on tcp %s: %w", l.config.TCPAddr, err)
+		}
+		l.tcp = listener
+		l.wg.Add(1)
+		go l.serveTCP()
+	}
+
+	return nil
+}
+
+// Stop closes the listeners and open connections and waits for them to finish
+func (l *SyslogListener) Stop() {
+	if l.udp != nil {
+		l.udp.Close()
+	}
+	if l.tcp != nil {
+		l.tcp.Close()
+	}
+
+	l.mu.Lock()
+	l.closed = true
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+
+	l.wg.Wait()
+}
+
+// UDPAddr returns the address the UDP listener is bound to, or nil
+func (l *SyslogListener) UDPAddr() net.Addr {
+	if l.udp == nil {
+		return nil
+	}
+	return l.udp.LocalAddr()
+}
+
+// TCPAddr returns the address the TCP listener is bound to, or nil
+func (l *SyslogListener) TCPAddr() net.Addr {
+	if l.tcp == nil {
+		return nil
+	}
+	return l.tcp.Addr()
+}
+
+// serveUDP handles one message per datagram
+func (l *SyslogListener) serveUDP() {
+	defer l.wg.Done()
+
+	buf := make([]byte, maxSyslogMessage)
+	for {
+		n, _, err := l.udp.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Error reading syslog datagram: %v", err)
+			}
+			return
+		}
+		l.handleMessage(string(buf[:n]))
+	}
+}
+
+// serveTCP accepts connections until the listener is closed
+func (l *SyslogListener) serveTCP() {
+	defer l.wg.Done()
+
+	for {
+		conn, err := l.tcp.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Error accepting syslog connection: %v", err)
+			}
+			return
+		}
+
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			conn.Close()
+			return
+		}
+		l.conns[conn] = struct{}{}
+		l.mu.Unlock()
+
+		l.wg.Add(1)
+		go l.serveConn(conn)
+	}
+}
+
+// serveConn reads messages from a TCP connection, framed either by octet
+// counting or by newlines (RFC 6587)
+func (l *SyslogListener) serveConn(conn net.Conn) {
+	defer l.wg.Done()
+	defer func() {
+		l.mu.Lock()
+		delete(l.conns, conn)
+		l.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReaderSize(conn, maxSyslogMessage)
+	for {
+		message, err := readSyslogFrame(reader)
+		if message != "" {
+			l.handleMessage(message)
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Error reading syslog connection from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+// readSyslogFrame reads one message from a TCP stream. reader's buffer caps
+// the length of a newline-framed message; a longer line is an error, so the
+// connection is dropped rather than buffered without limit.
+func readSyslogFrame(reader *bufio.Reader) (string, error) {
+	if length, width := syslogFrameLength(reader); width > 0 {
+		if _, err := reader.Discard(width); err != nil {
+			return "", err
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+
+	return readSyslogSlice(reader, '\n')
+}
+
+// syslogFrameLength reads the length of an octet-counted frame at the start
+// of reader without consuming it, returning the length and the width of its
+// prefix. Only digits followed by a space that give a length within
+// maxSyslogMessage count; anything else, such as a newline-framed message
+// starting with a timestamp, has a width of 0.
+func syslogFrameLength(reader *bufio.Reader) (int, int) {
+	maxDigits := len(strconv.Itoa(maxSyslogMessage))
+	for width := 1; width <= maxDigits+1; width++ {
+		// Peek one byte at a time so a short frame is never waited on
+		peeked, err := reader.Peek(width)
+		if err != nil {
+			return 0, 0
+		}
+
+		c := peeked[width-1]
+		switch {
+		case c >= '1' && c <= '9', c == '0' && width > 1:
+		case c == ' ' && width > 1:
+			length, err := strconv.Atoi(string(peeked[:width-1]))
+			if err != nil || length > maxSyslogMessage {
+				return 0, 0
+			}
+			return length, width
+		default:
+			return 0, 0
+		}
+	}
+
+	return 0, 0
+}
+
+// readSyslogSlice reads up to and including delim, failing once more than
+// reader's buffer has been read without finding it
+func readSyslogSlice(reader *bufio.Reader, delim byte) (string, error) {
+	line, err := reader.ReadSlice(delim)
+	if err == bufio.ErrBufferFull {
+		return "", fmt.Errorf("syslog message longer than %d bytes", reader.Size())
+	}
+	return string(line), err
+}
+
+// handleMessage queues the event a syslog message describes
+func (l *SyslogListener) handleMessage(message string) {
+	message = strings.TrimRight(message, "\r\n\x00")
+	if message == "" {
+		return
+	}
+
+	fields, err := ParseSyslog(message)
+	if err != nil {
+		log.Printf("Dropping syslog message: %v", err)
+		return
+	}
+
+	event, err := l.config.Mapping.Event(fields, message)
+	if err != nil {
+		log.Printf("Dropping syslog message: %v", err)
+		return
+	}
+
+	if err := l.pipeline.Queue([]*models.Event{event})[0]; err != nil {
+		log.Printf("Dropping syslog event: %v", err)
+	}
+}
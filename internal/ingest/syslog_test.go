@@ -0,0 +1,219 @@
+package ingest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestParseCEF(t *testing.T) {
+	message := `CEF:0|Acme|EDR|1.0|100|Credential\|Dump|8|suser=alice dhost=ws-1 msg=found a\=b in C:\\temp cs1Label=detection_id cs1=42`
+
+	fields, err := ParseCEF(message)
+	if err != nil {
+		t.Fatalf("Failed to parse CEF: %v", err)
+	}
+
+	expected := map[string]string{
+		"device_vendor": "Acme",
+		"name":          "Credential|Dump",
+		"severity":      "8",
+		"suser":         "alice",
+		"dhost":         "ws-1",
+		"msg":           `found a=b in C:\temp`,
+		"detection_id":  "42",
+	}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Errorf("Expected %s '%s', got '%s'", key, value, fields[key])
+		}
+	}
+
+	if _, err := ParseCEF("CEF:0|Acme|EDR"); err == nil {
+		t.Error("Expected error for a truncated CEF header")
+	}
+}
+
+func TestParseSyslog(t *testing.T) {
+	message := `<134>1 2026-10-16T09:30:00Z edr-01 agent 1234 ALERT [meta detection_id="7" note="say \"hi\""] CEF:0|Acme|EDR|1.0|100|Dump|8|suser=bob`
+
+	fields, err := ParseSyslog(message)
+	if err != nil {
+		t.Fatalf("Failed to parse syslog: %v", err)
+	}
+
+	expected := map[string]string{
+		"priority":          "134",
+		"timestamp":         "2026-10-16T09:30:00Z",
+		"hostname":          "edr-01",
+		"app_name":          "agent",
+		"msgid":             "ALERT",
+		"meta.note":         `say "hi"`,
+		"meta.detection_id": "7",
+		"suser":             "bob",
+	}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Errorf("Expected %s '%s', got '%s'", key, value, fields[key])
+		}
+	}
+
+	// CEF in an RFC 3164 message is accepted on its own
+	fields, err = ParseSyslog(`<134>Oct 16 09:30:00 edr-01 CEF:0|Acme|EDR|1.0|100|Dump|8|dhost=ws-2`)
+	if err != nil {
+		t.Fatalf("Failed to parse RFC 3164 CEF: %v", err)
+	}
+	if fields["dhost"] != "ws-2" {
+		t.Errorf("Expected dhost 'ws-2', got '%s'", fields["dhost"])
+	}
+
+	if _, err := ParseSyslog("plain text"); err == nil {
+		t.Error("Expected error for a message that is not syslog")
+	}
+}
+
+func TestFieldMapping_Event(t *testing.T) {
+	mapping := DefaultSyslogMapping()
+
+	event, err := mapping.Event(map[string]string{
+		"detection_id": "42",
+		"dhost":        "ws-1",
+		"src":          "10.0.0.5",
+		"risk_points":  "15",
+		"rt":           "1760607000000",
+		"externalId":   "abc",
+	}, "raw")
+	if err != nil {
+		t.Fatalf("Failed to map event: %v", err)
+	}
+
+	if event.DetectionID != 42 || event.RiskPoints != 15 || event.ExternalID != "abc" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	// dhost comes before src in the default mapping
+	if event.RiskObject.EntityType != models.EntityTypeHost || event.RiskObject.EntityValue != "ws-1" {
+		t.Errorf("Expected host ws-1, got %s '%s'", event.RiskObject.EntityType, event.RiskObject.EntityValue)
+	}
+	if !event.Timestamp.Equal(time.Unix(1760607000, 0)) {
+		t.Errorf("Expected timestamp from milliseconds, got %s", event.Timestamp)
+	}
+
+	for _, fields := range []map[string]string{
+		{"dhost": "ws-1"},
+		{"detection_id": "x", "dhost": "ws-1"},
+		{"detection_id": "42"},
+		{"detection_id": "42", "entity_type": "printer", "entity_value": "p-1"},
+	} {
+		if _, err := mapping.Event(fields, ""); err == nil {
+			t.Errorf("Expected error mapping %v", fields)
+		}
+	}
+}
+
+func TestSyslogListener(t *testing.T) {
+	processor := &testProcessor{}
+	pipeline := NewPipeline(processor, Config{Workers: 1, BatchWait: time.Millisecond})
+	pipeline.Start()
+	defer pipeline.Stop()
+
+	listener := NewSyslogListener(pipeline, SyslogConfig{
+		UDPAddr: "127.0.0.1:0",
+		TCPAddr: "127.0.0.1:0",
+		Mapping: DefaultSyslogMapping(),
+	})
+	if err := listener.Start(); err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Stop()
+
+	cef := func(user string) string {
+		return fmt.Sprintf("<134>1 - - - - - - CEF:0|Acme|EDR|1.0|100|Dump|8|cs1Label=detection_id cs1=1 suser=%s", user)
+	}
+
+	udp, err := net.Dial("udp", listener.UDPAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial udp: %v", err)
+	}
+	defer udp.Close()
+	fmt.Fprint(udp, cef("udp-user"))
+
+	// One octet-counted and one newline-framed message
+	tcp, err := net.Dial("tcp", listener.TCPAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial tcp: %v", err)
+	}
+	counted := cef("tcp-user-1")
+	fmt.Fprintf(tcp, "%d %s%s\n", len(counted), counted, cef("tcp-user-2"))
+	tcp.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		processor.mu.Lock()
+		seen := len(processor.seen)
+		processor.mu.Unlock()
+		if seen == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 3 events, got %d", seen)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	users := make(map[string]bool)
+	for _, event := range processor.seen {
+		users[event.RiskObject.EntityValue] = true
+	}
+	for _, user := range []string{"udp-user", "tcp-user-1", "tcp-user-2"} {
+		if !users[user] {
+			t.Errorf("Expected an event for %s", user)
+		}
+	}
+}
+
+func TestReadSyslogFrame_TooLong(t *testing.T) {
+	reader := bufio.NewReaderSize(strings.NewReader("short\n"+strings.Repeat("a", 64)+"\nnext\n"), 32)
+
+	if message, err := readSyslogFrame(reader); err != nil || message != "short\n" {
+		t.Fatalf("Expected the short message, got %q (%v)", message, err)
+	}
+	if _, err := readSyslogFrame(reader); err == nil || !strings.Contains(err.Error(), "longer than 32 bytes") {
+		t.Errorf("Expected an error for a line longer than the buffer, got %v", err)
+	}
+
+	// An overlong length prefix is not a frame length, so the line is capped
+	// like any other
+	reader = bufio.NewReaderSize(strings.NewReader(strings.Repeat("9", 64)+" message"), 32)
+	if _, err := readSyslogFrame(reader); err == nil {
+		t.Error("Expected an error for an overlong frame length")
+	}
+}
+
+func TestReadSyslogFrame_Framing(t *testing.T) {
+	stream := "15 <13>Oct 16 ws-1" +
+		"2026-10-16T11:00:00Z ws-1 sshd: failed\n" +
+		"99999999 is not a frame length\n" +
+		"7 <13>ok\n"
+	reader := bufio.NewReaderSize(strings.NewReader(stream), 64)
+
+	expected := []string{
+		"<13>Oct 16 ws-1",
+		"2026-10-16T11:00:00Z ws-1 sshd: failed\n",
+		"99999999 is not a frame length\n",
+		"<13>ok\n",
+	}
+	for i, want := range expected {
+		message, err := readSyslogFrame(reader)
+		if err != nil {
+			t.Fatalf("Failed to read frame %d: %v", i, err)
+		}
+		if message != want {
+			t.Errorf("Expected frame %d to be %q, got %q", i, want, message)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"riskmatrix/pkg/models"
+)
+
+// ParseCSV reads inventory entries from CSV with a header row. Recognised
+// columns are entity_type, entity_value, owner, department, business_unit,
+// criticality and tags (separated by semicolons); others are ignored.
+func ParseCSV(r io.Reader) ([]*models.InventoryEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"entity_type", "entity_value"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the %s column", required)
+		}
+	}
+
+	entries := make([]*models.InventoryEntry, 0)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV line %d: %w", line, err)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		entry := &models.InventoryEntry{
+			EntityType:   models.EntityType(field("entity_type")),
+			EntityValue:  field("entity_value"),
+			Owner:        field("owner"),
+			Department:   field("department"),
+			BusinessUnit: field("business_unit"),
+			Criticality:  models.Criticality(strings.ToLower(field("criticality"))),
+		}
+		for _, tag := range strings.Split(field("tags"), ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				entry.Tags = append(entry.Tags, tag)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ParseJSON reads inventory entries from a JSON array, refusing null entries
+func ParseJSON(r io.Reader) ([]*models.InventoryEntry, error) {
+	var entries []*models.InventoryEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("error decoding JSON: %w", err)
+	}
+	for i, entry := range entries {
+		if entry == nil {
+			return nil, fmt.Errorf("entry %d is null", i+1)
+		}
+	}
+	return entries, nil
+}
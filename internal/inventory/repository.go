@@ -0,0 +1,371 @@
+package inventory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// Repository implements the models.InventoryRepository interface
+type Repository struct {
+	db *database.DB
+}
+
+// NewRepository creates a new inventory repository
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+const inventoryColumns = `id, entity_type, entity_value, owner, department, business_unit, criticality, tags, created_at, updated_at`
+
+// scanInventoryEntry scans an inventory row
+func scanInventoryEntry(row database.RowScanner) (*models.InventoryEntry, error) {
+	var entry models.InventoryEntry
+	var owner, department, businessUnit, criticality, tags sql.NullString
+	var createdAt, updatedAt string
+
+	err := row.Scan(
+		&entry.ID,
+		&entry.EntityType,
+		&entry.EntityValue,
+		&owner,
+		&department,
+		&businessUnit,
+		&criticality,
+		&tags,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle nullable fields
+	if owner.Valid {
+		entry.Owner = owner.String
+	}
+	if department.Valid {
+		entry.Department = department.String
+	}
+	if businessUnit.Valid {
+		entry.BusinessUnit = businessUnit.String
+	}
+	if criticality.Valid {
+		entry.Criticality = models.Criticality(criticality.String)
+	}
+	if tags.Valid && tags.String != "" {
+		_ = json.Unmarshal([]byte(tags.String), &entry.Tags)
+	}
+
+	// Parse timestamps
+	entry.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	entry.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+	return &entry, nil
+}
+
+// entryArgs returns the column values of an entry in insert order, after
+// normalising the entity type
+func entryArgs(entry *models.InventoryEntry) ([]interface{}, error) {
+	entry.EntityType = models.EntityType(strings.ToLower(string(entry.EntityType)))
+	entry.EntityValue = strings.TrimSpace(entry.EntityValue)
+
+	tags := sql.NullString{}
+	if len(entry.Tags) > 0 {
+		data, err := json.Marshal(entry.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding tags: %w", err)
+		}
+		tags = sql.NullString{String: string(data), Valid: true}
+	}
+
+	return []interface{}{
+		entry.EntityType,
+		entry.EntityValue,
+		sql.NullString{String: entry.Owner, Valid: entry.Owner != ""},
+		sql.NullString{String: entry.Department, Valid: entry.Department != ""},
+		sql.NullString{String: entry.BusinessUnit, Valid: entry.BusinessUnit != ""},
+		sql.NullString{String: string(entry.Criticality), Valid: entry.Criticality != ""},
+		tags,
+	}, nil
+}
+
+// GetInventoryEntry retrieves an inventory entry by ID
+func (r *Repository) GetInventoryEntry(id int64) (*models.InventoryEntry, error) {
+	query := `SELECT ` + inventoryColumns + ` FROM inventory WHERE id = ?`
+
+	entry, err := scanInventoryEntry(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("inventory entry not found: %d", id)
+		}
+		return nil, fmt.Errorf("error scanning inventory entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// ListInventoryEntries lists all inventory entries
+func (r *Repository) ListInventoryEntries() ([]*models.InventoryEntry, error) {
+	return r.listInventoryEntries(r.db, `SELECT `+inventoryColumns+` FROM inventory ORDER BY entity_type, entity_value`)
+}
+
+// listInventoryEntries runs query and scans the resulting inventory rows
+func (r *Repository) listInventoryEntries(q database.Queryer, query string, args ...interface{}) ([]*models.InventoryEntry, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying inventory: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*models.InventoryEntry, 0)
+	for rows.Next() {
+		entry, err := scanInventoryEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning inventory row: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// CreateInventoryEntry creates an inventory entry
+func (r *Repository) CreateInventoryEntry(entry *models.InventoryEntry) error {
+	args, err := entryArgs(entry)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+	args = append(args, now.Format(time.RFC3339), now.Format(time.RFC3339))
+
+	result, err := r.db.Exec(
+		`INSERT INTO inventory (entity_type, entity_value, owner, department, business_unit, criticality, tags, created_at, updated_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating inventory entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	entry.ID = id
+	return nil
+}
+
+// UpdateInventoryEntry updates an inventory entry
+func (r *Repository) UpdateInventoryEntry(entry *models.InventoryEntry) error {
+	args, err := entryArgs(entry)
+	if err != nil {
+		return err
+	}
+
+	entry.UpdatedAt = time.Now()
+	args = append(args, entry.UpdatedAt.Format(time.RFC3339), entry.ID)
+
+	result, err := r.db.Exec(
+		`UPDATE inventory
+         SET entity_type = ?, entity_value = ?, owner = ?, department = ?, business_unit = ?, criticality = ?, tags = ?, updated_at = ?
+         WHERE id = ?`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating inventory entry: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("inventory entry not found: %d", entry.ID)
+	}
+
+	return nil
+}
+
+// DeleteInventoryEntry deletes an inventory entry
+func (r *Repository) DeleteInventoryEntry(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM inventory WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting inventory entry: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("inventory entry not found: %d", id)
+	}
+
+	return nil
+}
+
+// ImportInventoryEntries creates or updates entries keyed on entity type and
+// value in a single transaction, returning how many were written
+func (r *Repository) ImportInventoryEntries(entries []*models.InventoryEntry) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO inventory (entity_type, entity_value, owner, department, business_unit, criticality, tags, created_at, updated_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+              ON CONFLICT(entity_type, entity_value) DO UPDATE SET
+                owner = excluded.owner,
+                department = excluded.department,
+                business_unit = excluded.business_unit,
+                criticality = excluded.criticality,
+                tags = excluded.tags,
+                updated_at = excluded.updated_at`
+
+	now := time.Now().Format(time.RFC3339)
+	for i, entry := range entries {
+		args, err := entryArgs(entry)
+		if err != nil {
+			return 0, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		args = append(args, now, now)
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			return 0, fmt.Errorf("error importing inventory entry %d: %w", i+1, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(entries), nil
+}
+
+// MatchInventoryEntry finds the entry describing an entity, or nil if none does
+func (r *Repository) MatchInventoryEntry(entityType models.EntityType, entityValue string) (*models.InventoryEntry, error) {
+	return r.match(r.db, entityType, entityValue)
+}
+
+// MatchInventoryEntryTx finds the entry describing an entity within a transaction
+func (r *Repository) MatchInventoryEntryTx(tx *sql.Tx, entityType models.EntityType, entityValue string) (*models.InventoryEntry, error) {
+	return r.match(tx, entityType, entityValue)
+}
+
+// match looks for an exact match on the entity value first and, for ip
+// entities, then for the narrowest CIDR range containing the address
+func (r *Repository) match(q database.Queryer, entityType models.EntityType, entityValue string) (*models.InventoryEntry, error) {
+	entityType = models.EntityType(strings.ToLower(string(entityType)))
+
+	entry, err := scanInventoryEntry(q.QueryRow(
+		`SELECT `+inventoryColumns+` FROM inventory WHERE entity_type = ? AND lower(entity_value) = lower(?)`,
+		entityType, entityValue,
+	))
+	if err == nil {
+		return entry, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error matching inventory entry: %w", err)
+	}
+
+	if entityType != models.EntityTypeIP {
+		return nil, nil
+	}
+
+	ranges, err := r.listInventoryEntries(q,
+		`SELECT `+inventoryColumns+` FROM inventory WHERE entity_type = ? AND entity_value LIKE '%/%'`,
+		models.EntityTypeIP,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMatcher(ranges).Match(entityType, entityValue), nil
+}
+
+// Matcher matches entities against a set of inventory entries in memory
+type Matcher struct {
+	exact  map[string]*models.InventoryEntry
+	ranges []cidrEntry
+}
+
+type cidrEntry struct {
+	network *net.IPNet
+	entry   *models.InventoryEntry
+}
+
+// NewMatcher builds a matcher over entries
+func NewMatcher(entries []*models.InventoryEntry) *Matcher {
+	m := &Matcher{exact: make(map[string]*models.InventoryEntry)}
+
+	for _, entry := range entries {
+		if strings.EqualFold(string(entry.EntityType), string(models.EntityTypeIP)) {
+			if _, network, err := net.ParseCIDR(entry.EntityValue); err == nil {
+				m.ranges = append(m.ranges, cidrEntry{network: network, entry: entry})
+				continue
+			}
+		}
+		m.exact[matchKey(entry.EntityType, entry.EntityValue)] = entry
+	}
+
+	return m
+}
+
+// LoadMatcher builds a matcher over the whole inventory
+func (r *Repository) LoadMatcher() (*Matcher, error) {
+	entries, err := r.ListInventoryEntries()
+	if err != nil {
+		return nil, err
+	}
+	return NewMatcher(entries), nil
+}
+
+// Match returns the entry describing an entity, or nil if none does. Exact
+// matches win over CIDR ranges, and narrower ranges over wider ones.
+func (m *Matcher) Match(entityType models.EntityType, entityValue string) *models.InventoryEntry {
+	if entry, ok := m.exact[matchKey(entityType, entityValue)]; ok {
+		return entry
+	}
+
+	if !strings.EqualFold(string(entityType), string(models.EntityTypeIP)) {
+		return nil
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(entityValue))
+	if ip == nil {
+		return nil
+	}
+
+	var best *models.InventoryEntry
+	bestSize := -1
+	for _, r := range m.ranges {
+		if !r.network.Contains(ip) {
+			continue
+		}
+		if size, _ := r.network.Mask.Size(); size > bestSize {
+			best = r.entry
+			bestSize = size
+		}
+	}
+
+	return best
+}
+
+// matchKey is the lookup key for exact matches
+func matchKey(entityType models.EntityType, entityValue string) string {
+	return strings.ToLower(string(entityType)) + "|" + strings.ToLower(strings.TrimSpace(entityValue))
+}
@@ -0,0 +1,151 @@
+package inventory
+
+import (
+	"strings"
+	"testing"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+func setupTestRepo(t *testing.T) (*Repository, *database.DB) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	return NewRepository(db), db
+}
+
+func TestRepository_InventoryCRUD(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	entry := &models.InventoryEntry{
+		EntityType:  "Host",
+		EntityValue: "web-01",
+		Owner:       "ops@example.com",
+		Criticality: models.CriticalityHigh,
+		Tags:        []string{"dmz", "linux"},
+	}
+	if err := repo.CreateInventoryEntry(entry); err != nil {
+		t.Fatalf("Failed to create inventory entry: %v", err)
+	}
+
+	got, err := repo.GetInventoryEntry(entry.ID)
+	if err != nil {
+		t.Fatalf("Failed to get inventory entry: %v", err)
+	}
+	if got.EntityType != models.EntityTypeHost {
+		t.Errorf("Expected entity type to be normalised to host, got %s", got.EntityType)
+	}
+	if len(got.Tags) != 2 || !got.HasTag("DMZ") {
+		t.Errorf("Expected tags [dmz linux], got %v", got.Tags)
+	}
+
+	got.Department = "Platform"
+	if err := repo.UpdateInventoryEntry(got); err != nil {
+		t.Fatalf("Failed to update inventory entry: %v", err)
+	}
+	updated, _ := repo.GetInventoryEntry(entry.ID)
+	if updated.Department != "Platform" {
+		t.Errorf("Expected department Platform, got %q", updated.Department)
+	}
+
+	if err := repo.DeleteInventoryEntry(entry.ID); err != nil {
+		t.Fatalf("Failed to delete inventory entry: %v", err)
+	}
+	if _, err := repo.GetInventoryEntry(entry.ID); err == nil {
+		t.Error("Expected error getting deleted inventory entry")
+	}
+	if err := repo.DeleteInventoryEntry(entry.ID); err == nil {
+		t.Error("Expected error deleting missing inventory entry")
+	}
+}
+
+func TestRepository_ImportAndMatch(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	csv := `entity_type,entity_value,owner,criticality,tags
+ip,10.0.0.0/8,netops,low,internal
+ip,10.1.0.0/16,dc-team,critical,datacenter;servers
+ip,10.1.2.3,db-team,high,
+user,Alice@Example.com,hr,medium,privileged
+`
+	entries, err := ParseCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 entries, got %d", len(entries))
+	}
+	if len(entries[1].Tags) != 2 {
+		t.Errorf("Expected 2 tags, got %v", entries[1].Tags)
+	}
+
+	if _, err := repo.ImportInventoryEntries(entries); err != nil {
+		t.Fatalf("Failed to import inventory: %v", err)
+	}
+
+	// Re-importing updates in place rather than duplicating
+	entries[0].Owner = "network"
+	if _, err := repo.ImportInventoryEntries(entries[:1]); err != nil {
+		t.Fatalf("Failed to re-import inventory: %v", err)
+	}
+	all, _ := repo.ListInventoryEntries()
+	if len(all) != 4 {
+		t.Errorf("Expected 4 entries after re-import, got %d", len(all))
+	}
+
+	tests := []struct {
+		name          string
+		entityType    models.EntityType
+		entityValue   string
+		expectedOwner string
+	}{
+		{"Exact IP beats ranges", models.EntityTypeIP, "10.1.2.3", "db-team"},
+		{"Narrowest range wins", models.EntityTypeIP, "10.1.9.9", "dc-team"},
+		{"Wide range", models.EntityTypeIP, "10.200.0.1", "network"},
+		{"Outside all ranges", models.EntityTypeIP, "192.168.1.1", ""},
+		{"Case-insensitive user", models.EntityTypeUser, "alice@example.com", "hr"},
+		{"Unknown host", models.EntityTypeHost, "web-01", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := repo.MatchInventoryEntry(tt.entityType, tt.entityValue)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			owner := ""
+			if entry != nil {
+				owner = entry.Owner
+			}
+			if owner != tt.expectedOwner {
+				t.Errorf("Expected owner %q, got %q", tt.expectedOwner, owner)
+			}
+
+			// The in-memory matcher agrees with the database lookup
+			matcher, err := repo.LoadMatcher()
+			if err != nil {
+				t.Fatalf("Failed to load matcher: %v", err)
+			}
+			if m := matcher.Match(tt.entityType, tt.entityValue); (m == nil) != (entry == nil) || (m != nil && m.Owner != owner) {
+				t.Errorf("Matcher disagrees with database lookup for %s", tt.entityValue)
+			}
+		})
+	}
+}
+
+func TestParseCSV_MissingColumn(t *testing.T) {
+	if _, err := ParseCSV(strings.NewReader("entity_value,owner\nweb-01,ops\n")); err == nil {
+		t.Error("Expected error for CSV without entity_type column")
+	}
+}
+
+func TestParseJSON_NullEntry(t *testing.T) {
+	if _, err := ParseJSON(strings.NewReader(`[{"entity_type": "host", "entity_value": "web-01"}, null]`)); err == nil {
+		t.Error("Expected error for a null entry")
+	}
+}
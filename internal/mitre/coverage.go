@@ -0,0 +1,256 @@
+package mitre
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"riskmatrix/pkg/models"
+)
+
+// statusQuality weighs a detection by how far it is through its lifecycle
+// when coverage is weighted by detection quality
+var statusQuality = map[models.DetectionStatus]float64{
+	models.StatusProduction: 1,
+	models.StatusTest:       0.5,
+	models.StatusDraft:      0.25,
+}
+
+// detectionQuality scores a detection from 0 to 1 for a coverage mode. In
+// weighted mode the status weight is reduced by the share of the last 30
+// days' events that were false positives.
+func detectionQuality(mode models.CoverageMode, status models.DetectionStatus, events, falsePositives int) float64 {
+	switch mode {
+	case models.CoverageProduction:
+		if status == models.StatusProduction {
+			return 1
+		}
+		return 0
+	case models.CoverageWeighted:
+		quality := statusQuality[status]
+		if events > 0 {
+			fpRate := float64(falsePositives) / float64(events)
+			if fpRate > 1 {
+				fpRate = 1
+			}
+			quality *= 1 - fpRate
+		}
+		return quality
+	default:
+		if status == models.StatusRetired {
+			return 0
+		}
+		return 1
+	}
+}
+
+// techniqueScores scores each technique with a detection counted under the
+// mode by its best detection, and counts those detections
+func (r *Repository) techniqueScores(mode models.CoverageMode) (map[string]float64, map[string]int, error) {
+	rows, err := r.db.Query(`
+		SELECT m.mitre_id, d.status, d.event_count_last_30_days, d.false_positives_last_30_days
+		FROM detection_mitre_map m
+		JOIN detections d ON d.id = m.detection_id
+	`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying technique detections: %w", err)
+	}
+	defer rows.Close()
+
+	scores := make(map[string]float64)
+	counts := make(map[string]int)
+	for rows.Next() {
+		var techniqueID string
+		var status models.DetectionStatus
+		var events, falsePositives int
+		if err := rows.Scan(&techniqueID, &status, &events, &falsePositives); err != nil {
+			return nil, nil, fmt.Errorf("error scanning technique detection row: %w", err)
+		}
+
+		quality := detectionQuality(mode, status, events, falsePositives)
+		if quality <= 0 {
+			continue
+		}
+		counts[techniqueID]++
+		if quality > scores[techniqueID] {
+			scores[techniqueID] = quality
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating technique detection rows: %w", err)
+	}
+
+	return scores, counts, nil
+}
+
+// coverageTechnique is a live technique with what places it in the tree
+type coverageTechnique struct {
+	node    *models.TechniqueCoverageNode
+	tactics []string
+	parent  string
+}
+
+// coverageTechniques loads the techniques that are neither revoked nor
+// deprecated, with all of their tactics
+func (r *Repository) coverageTechniques() ([]*coverageTechnique, error) {
+	rows, err := r.db.Query(`
+		SELECT id, name, tactic, tactics, is_sub_technique, sub_technique_of
+		FROM mitre_techniques
+		WHERE revoked = 0 AND deprecated = 0
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying techniques: %w", err)
+	}
+	defer rows.Close()
+
+	techniques := make([]*coverageTechnique, 0)
+	for rows.Next() {
+		var technique coverageTechnique
+		var node models.TechniqueCoverageNode
+		var tactic string
+		var tacticsJSON, subTechniqueOf sql.NullString
+		var isSubTechnique bool
+		if err := rows.Scan(&node.TechniqueID, &node.Name, &tactic, &tacticsJSON, &isSubTechnique, &subTechniqueOf); err != nil {
+			return nil, fmt.Errorf("error scanning technique row: %w", err)
+		}
+
+		if tacticsJSON.Valid && tacticsJSON.String != "" {
+			var tactics []string
+			if err := json.Unmarshal([]byte(tacticsJSON.String), &tactics); err != nil {
+				return nil, fmt.Errorf("error parsing tactics JSON: %w", err)
+			}
+			for _, name := range tactics {
+				technique.tactics = appendUnique(technique.tactics, name)
+			}
+		}
+		if len(technique.tactics) == 0 {
+			technique.tactics = []string{tactic}
+		}
+		if isSubTechnique || subTechniqueOf.String != "" {
+			technique.parent = subTechniqueOf.String
+		}
+		technique.node = &node
+		techniques = append(techniques, &technique)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating technique rows: %w", err)
+	}
+
+	return techniques, nil
+}
+
+// scoredTechniques scores the live techniques under the mode and nests
+// sub-techniques under their parent, whose coverage is its own score or the
+// average of its sub-techniques', whichever is higher. It returns the
+// techniques by ID and the top-level ones.
+func (r *Repository) scoredTechniques(mode models.CoverageMode) (map[string]*coverageTechnique, []*coverageTechnique, error) {
+	scores, counts, err := r.techniqueScores(mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	techniques, err := r.coverageTechniques()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[string]*coverageTechnique, len(techniques))
+	for _, technique := range techniques {
+		node := technique.node
+		node.Score = scores[node.TechniqueID]
+		node.Detections = counts[node.TechniqueID]
+		node.Coverage = node.Score
+		byID[node.TechniqueID] = technique
+	}
+
+	// Sub-techniques whose parent is missing stand on their own
+	topLevel := make([]*coverageTechnique, 0)
+	for _, technique := range techniques {
+		if parent, ok := byID[technique.parent]; ok && technique.parent != technique.node.TechniqueID {
+			parent.node.SubTechniques = append(parent.node.SubTechniques, technique.node)
+			continue
+		}
+		topLevel = append(topLevel, technique)
+	}
+
+	for _, technique := range topLevel {
+		node := technique.node
+		if len(node.SubTechniques) > 0 {
+			var total float64
+			for _, sub := range node.SubTechniques {
+				total += sub.Coverage
+				sub.Partial = sub.Coverage > 0 && sub.Coverage < 1
+			}
+			if average := total / float64(len(node.SubTechniques)); average > node.Coverage {
+				node.Coverage = average
+			}
+		}
+		node.Partial = node.Coverage > 0 && node.Coverage < 1
+	}
+
+	return byID, topLevel, nil
+}
+
+// CoverageTree returns coverage under the mode as a tree of tactics,
+// techniques and sub-techniques. Each technique is listed under all of its
+// tactics, and sub-techniques are nested under their parent, adding to its
+// coverage rather than counting alongside it. Revoked and deprecated
+// techniques are left out.
+func (r *Repository) CoverageTree(mode models.CoverageMode) (*models.CoverageTree, error) {
+	if mode == "" {
+		mode = models.CoverageAny
+	}
+
+	_, topLevel, err := r.scoredTechniques(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &models.CoverageTree{Mode: mode, Tactics: make([]*models.TacticCoverageNode, 0)}
+	byTactic := make(map[string]*models.TacticCoverageNode)
+	for _, technique := range topLevel {
+		node := technique.node
+		for _, tactic := range technique.tactics {
+			current, ok := byTactic[tactic]
+			if !ok {
+				current = &models.TacticCoverageNode{Tactic: tactic, Techniques: make([]*models.TechniqueCoverageNode, 0)}
+				byTactic[tactic] = current
+				tree.Tactics = append(tree.Tactics, current)
+			}
+			current.Techniques = append(current.Techniques, node)
+			current.TechniqueCount++
+			current.CoveragePercent += node.Coverage
+			if node.Coverage >= 1 {
+				current.Covered++
+			} else if node.Partial {
+				current.Partial++
+			}
+		}
+	}
+
+	for _, tactic := range tree.Tactics {
+		tactic.CoveragePercent = tactic.CoveragePercent / float64(tactic.TechniqueCount) * 100
+	}
+	sort.Slice(tree.Tactics, func(i, j int) bool {
+		return tree.Tactics[i].Tactic < tree.Tactics[j].Tactic
+	})
+
+	return tree, nil
+}
+
+// CoverageByTactic returns the coverage percentage of each tactic under the
+// mode, as summarized by CoverageTree
+func (r *Repository) CoverageByTactic(mode models.CoverageMode) (map[string]float64, error) {
+	tree, err := r.CoverageTree(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	coverage := make(map[string]float64, len(tree.Tactics))
+	for _, tactic := range tree.Tactics {
+		coverage[tactic.Tactic] = tactic.CoveragePercent
+	}
+
+	return coverage, nil
+}
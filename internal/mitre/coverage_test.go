@@ -0,0 +1,118 @@
+package mitre
+
+import (
+	"reflect"
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestRepository_CoverageTree(t *testing.T) {
+	repo := setupCoverageRepo(t)
+
+	// An uncovered sibling of PowerShell, and a technique under three
+	// tactics whose detection is half false positives
+	for _, technique := range []*models.MitreTechnique{
+		{ID: "T1059.003", Name: "Windows Command Shell", Tactic: "Execution", IsSubTechnique: true, SubTechniqueOf: "T1059"},
+		{ID: "T1078", Name: "Valid Accounts", Tactic: "Defense Evasion", Tactics: []string{"Defense Evasion", "Persistence", "Initial Access"}},
+	} {
+		if err := repo.CreateMitreTechnique(technique); err != nil {
+			t.Fatalf("Failed to create technique: %v", err)
+		}
+	}
+	noisy := createMappedDetection(t, repo, "Impossible Travel", "T1078")
+	if _, err := repo.db.Exec("UPDATE detections SET event_count_last_30_days = 100, false_positives_last_30_days = 50 WHERE id = ?", noisy); err != nil {
+		t.Fatalf("Failed to update detection: %v", err)
+	}
+
+	tests := []struct {
+		mode     models.CoverageMode
+		parent   float64 // coverage of T1059
+		partial  bool
+		accounts float64 // coverage of T1078
+		byTactic map[string]float64
+	}{
+		{
+			// The test detection covers the parent outright
+			mode: models.CoverageAny, parent: 1, accounts: 1,
+			byTactic: map[string]float64{"Execution": 100, "Credential Access": 0, "Defense Evasion": 100, "Persistence": 100, "Initial Access": 100},
+		},
+		{
+			// Only one of the two sub-techniques is covered
+			mode: models.CoverageProduction, parent: 0.5, partial: true, accounts: 1,
+			byTactic: map[string]float64{"Execution": 50, "Credential Access": 0, "Defense Evasion": 100, "Persistence": 100, "Initial Access": 100},
+		},
+		{
+			mode: models.CoverageWeighted, parent: 0.5, partial: true, accounts: 0.5,
+			byTactic: map[string]float64{"Execution": 50, "Credential Access": 0, "Defense Evasion": 50, "Persistence": 50, "Initial Access": 50},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			tree, err := repo.CoverageTree(tt.mode)
+			if err != nil {
+				t.Fatalf("Failed to get coverage tree: %v", err)
+			}
+			if tree.Mode != tt.mode || len(tree.Tactics) != 5 || tree.Tactics[0].Tactic != "Credential Access" {
+				t.Fatalf("Unexpected tree %+v", tree)
+			}
+
+			byTactic := make(map[string]*models.TacticCoverageNode)
+			for _, tactic := range tree.Tactics {
+				byTactic[tactic.Tactic] = tactic
+			}
+
+			// Sub-techniques are nested under their parent, not counted
+			// alongside it, and the revoked technique is left out
+			execution := byTactic["Execution"]
+			if execution.TechniqueCount != 1 || len(execution.Techniques) != 1 {
+				t.Fatalf("Unexpected Execution coverage %+v", execution)
+			}
+			parent := execution.Techniques[0]
+			if parent.TechniqueID != "T1059" || len(parent.SubTechniques) != 2 || parent.Coverage != tt.parent || parent.Partial != tt.partial {
+				t.Errorf("Unexpected parent %+v", parent)
+			}
+			if sub := parent.SubTechniques[0]; sub.TechniqueID != "T1059.001" || sub.Score != 1 || sub.Detections != 2 {
+				t.Errorf("Unexpected sub-technique %+v", sub)
+			}
+
+			if accounts := byTactic["Persistence"].Techniques[0]; accounts.TechniqueID != "T1078" || accounts.Coverage != tt.accounts {
+				t.Errorf("Unexpected Valid Accounts coverage %+v", accounts)
+			}
+
+			coverage, err := repo.CoverageByTactic(tt.mode)
+			if err != nil {
+				t.Fatalf("Failed to get coverage by tactic: %v", err)
+			}
+			if !reflect.DeepEqual(coverage, tt.byTactic) {
+				t.Errorf("Expected coverage %v, got %v", tt.byTactic, coverage)
+			}
+		})
+	}
+}
+
+func TestDetectionQuality(t *testing.T) {
+	tests := []struct {
+		mode     models.CoverageMode
+		status   models.DetectionStatus
+		events   int
+		fps      int
+		expected float64
+	}{
+		{models.CoverageAny, models.StatusIdea, 0, 0, 1},
+		{models.CoverageAny, models.StatusRetired, 0, 0, 0},
+		{models.CoverageProduction, models.StatusTest, 0, 0, 0},
+		{models.CoverageProduction, models.StatusProduction, 10, 10, 1},
+		{models.CoverageWeighted, models.StatusProduction, 0, 0, 1},
+		{models.CoverageWeighted, models.StatusTest, 10, 5, 0.25},
+		{models.CoverageWeighted, models.StatusProduction, 10, 20, 0},
+		{models.CoverageWeighted, models.StatusIdea, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := detectionQuality(tt.mode, tt.status, tt.events, tt.fps); got != tt.expected {
+			t.Errorf("detectionQuality(%s, %s, %d, %d) = %v, expected %v", tt.mode, tt.status, tt.events, tt.fps, got, tt.expected)
+		}
+	}
+}
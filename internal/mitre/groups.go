@@ -0,0 +1,253 @@
+package mitre
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// GroupImportReport summarizes an import of threat groups
+type GroupImportReport struct {
+	Groups int `json:"groups"`
+	Uses   int `json:"uses"` // technique uses recorded
+
+	// Techniques used by the groups that are not in the catalog, and so were
+	// left out
+	Unknown []string `json:"unknown"`
+}
+
+// ParseGroupsCSV reads threat groups from a CSV file with one row per
+// technique a group uses. The group_id, group_name and technique_id columns
+// are required; aliases (separated by semicolons) and description are
+// optional. A group may have a row without a technique.
+func ParseGroupsCSV(r io.Reader) ([]*models.ThreatGroup, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, required := range []string{"group_id", "group_name", "technique_id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV is missing the %s column", required)
+		}
+	}
+	field := func(row []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	groups := make([]*models.ThreatGroup, 0)
+	byID := make(map[string]*models.ThreatGroup)
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV line %d: %w", line, err)
+		}
+
+		id := field(row, "group_id")
+		if id == "" {
+			return nil, fmt.Errorf("CSV line %d has no group ID", line)
+		}
+		group, ok := byID[id]
+		if !ok {
+			group = &models.ThreatGroup{ID: id, Techniques: make([]string, 0)}
+			byID[id] = group
+			groups = append(groups, group)
+		}
+		if name := field(row, "group_name"); name != "" {
+			group.Name = name
+		}
+		if description := field(row, "description"); description != "" {
+			group.Description = description
+		}
+		for _, alias := range strings.Split(field(row, "aliases"), ";") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				group.Aliases = appendUnique(group.Aliases, alias)
+			}
+		}
+		if technique := field(row, "technique_id"); technique != "" {
+			group.Techniques = appendUnique(group.Techniques, strings.ToUpper(technique))
+		}
+	}
+
+	for _, group := range groups {
+		if group.Name == "" {
+			return nil, fmt.Errorf("group %s has no name", group.ID)
+		}
+		sort.Strings(group.Techniques)
+		group.TechniqueCount = len(group.Techniques)
+	}
+
+	return groups, nil
+}
+
+// ImportGroups upserts threat groups in a single transaction, replacing the
+// techniques each uses. Revoked techniques are recorded as their
+// replacement, and techniques missing from the catalog are left out and
+// reported. When version is set every group is stamped with it.
+func (r *Repository) ImportGroups(groups []*models.ThreatGroup, version string) (*GroupImportReport, error) {
+	report := &GroupImportReport{Unknown: make([]string, 0)}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	catalog, err := retiredCatalog(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	unknown := make(map[string]bool)
+	for _, group := range groups {
+		if group.ID == "" || group.Name == "" {
+			return nil, fmt.Errorf("group %q has no ID or name", group.ID)
+		}
+		if version != "" {
+			group.AttackVersion = version
+		}
+
+		var aliasesJSON []byte
+		if len(group.Aliases) > 0 {
+			if aliasesJSON, err = json.Marshal(group.Aliases); err != nil {
+				return nil, fmt.Errorf("error marshaling aliases to JSON: %w", err)
+			}
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO threat_groups (id, name, aliases, description, attack_version) VALUES (?, ?, ?, ?, ?)
+             ON CONFLICT(id) DO UPDATE SET name = excluded.name, aliases = excluded.aliases,
+             description = excluded.description, attack_version = excluded.attack_version`,
+			group.ID, group.Name, string(aliasesJSON), group.Description, group.AttackVersion,
+		); err != nil {
+			return nil, fmt.Errorf("error saving threat group %s: %w", group.ID, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM threat_group_techniques WHERE group_id = ?`, group.ID); err != nil {
+			return nil, fmt.Errorf("error clearing techniques of threat group %s: %w", group.ID, err)
+		}
+
+		for _, id := range group.Techniques {
+			if _, ok := catalog[id]; !ok {
+				unknown[id] = true
+				continue
+			}
+			if replacement := replacementFor(catalog, id); replacement != nil {
+				id = replacement.ID
+			}
+			result, err := tx.Exec(`INSERT OR IGNORE INTO threat_group_techniques (group_id, technique_id) VALUES (?, ?)`, group.ID, id)
+			if err != nil {
+				return nil, fmt.Errorf("error adding technique %s to threat group %s: %w", id, group.ID, err)
+			}
+			if added, _ := result.RowsAffected(); added > 0 {
+				report.Uses++
+			}
+		}
+		report.Groups++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for id := range unknown {
+		report.Unknown = append(report.Unknown, id)
+	}
+	sort.Strings(report.Unknown)
+
+	return report, nil
+}
+
+const groupColumns = `g.id, g.name, g.aliases, g.description, g.attack_version,
+	(SELECT COUNT(*) FROM threat_group_techniques gt WHERE gt.group_id = g.id)`
+
+// scanGroup scans a threat group row, without its techniques
+func scanGroup(row database.RowScanner) (*models.ThreatGroup, error) {
+	var group models.ThreatGroup
+	var aliasesJSON, description, attackVersion sql.NullString
+
+	if err := row.Scan(&group.ID, &group.Name, &aliasesJSON, &description, &attackVersion, &group.TechniqueCount); err != nil {
+		return nil, err
+	}
+	group.Description = description.String
+	group.AttackVersion = attackVersion.String
+	if aliasesJSON.Valid && aliasesJSON.String != "" {
+		if err := json.Unmarshal([]byte(aliasesJSON.String), &group.Aliases); err != nil {
+			return nil, fmt.Errorf("error parsing aliases JSON: %w", err)
+		}
+	}
+
+	return &group, nil
+}
+
+// ListThreatGroups returns the threat groups, without their techniques
+func (r *Repository) ListThreatGroups() ([]*models.ThreatGroup, error) {
+	rows, err := r.db.Query(`SELECT ` + groupColumns + ` FROM threat_groups g ORDER BY g.name`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying threat groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := make([]*models.ThreatGroup, 0)
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning threat group row: %w", err)
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating threat group rows: %w", err)
+	}
+
+	return groups, nil
+}
+
+// GetThreatGroup returns a threat group with the techniques it uses
+func (r *Repository) GetThreatGroup(id string) (*models.ThreatGroup, error) {
+	group, err := scanGroup(r.db.QueryRow(`SELECT `+groupColumns+` FROM threat_groups g WHERE g.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrThreatGroupNotFound, id)
+		}
+		return nil, fmt.Errorf("error scanning threat group: %w", err)
+	}
+
+	rows, err := r.db.Query(`SELECT technique_id FROM threat_group_techniques WHERE group_id = ? ORDER BY technique_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying threat group techniques: %w", err)
+	}
+	defer rows.Close()
+
+	group.Techniques = make([]string, 0, group.TechniqueCount)
+	for rows.Next() {
+		var techniqueID string
+		if err := rows.Scan(&techniqueID); err != nil {
+			return nil, fmt.Errorf("error scanning threat group technique row: %w", err)
+		}
+		group.Techniques = append(group.Techniques, techniqueID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating threat group technique rows: %w", err)
+	}
+
+	return group, nil
+}
@@ -0,0 +1,81 @@
+package mitre
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGroupsCSV(t *testing.T) {
+	input := `group_id,group_name,aliases,technique_id
+G0016,APT29,Cozy Bear;NOBELIUM,T1059.001
+G0016,APT29,,t1003
+G0046,FIN7,,
+`
+	groups, err := ParseGroupsCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(groups))
+	}
+	if apt29 := groups[0]; apt29.Name != "APT29" || !reflect.DeepEqual(apt29.Aliases, []string{"Cozy Bear", "NOBELIUM"}) ||
+		!reflect.DeepEqual(apt29.Techniques, []string{"T1003", "T1059.001"}) {
+		t.Errorf("Unexpected group %+v", apt29)
+	}
+	if fin7 := groups[1]; fin7.ID != "G0046" || len(fin7.Techniques) != 0 {
+		t.Errorf("Unexpected group %+v", fin7)
+	}
+
+	if _, err := ParseGroupsCSV(strings.NewReader("group_id,technique_id\nG0016,T1003\n")); err == nil {
+		t.Error("Expected error for a CSV without group names")
+	}
+	if _, err := ParseGroupsCSV(strings.NewReader("group_id,group_name,technique_id\n,APT29,T1003\n")); err == nil {
+		t.Error("Expected error for a row without a group ID")
+	}
+}
+
+func TestRepository_ImportGroups(t *testing.T) {
+	repo := setupCoverageRepo(t)
+
+	// T1086 is revoked by T1059.001, which the group also uses, and T1027 is
+	// not in the catalog
+	groups, err := ParseGroupsCSV(strings.NewReader(`group_id,group_name,technique_id
+G0016,APT29,T1059.001
+G0016,APT29,T1086
+G0016,APT29,T1027
+G0016,APT29,T1003
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+
+	report, err := repo.ImportGroups(groups, "17.1")
+	if err != nil {
+		t.Fatalf("Failed to import groups: %v", err)
+	}
+	if report.Groups != 1 || report.Uses != 2 || !reflect.DeepEqual(report.Unknown, []string{"T1027"}) {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	group, err := repo.GetThreatGroup("G0016")
+	if err != nil {
+		t.Fatalf("Failed to get group: %v", err)
+	}
+	if group.AttackVersion != "17.1" || !reflect.DeepEqual(group.Techniques, []string{"T1003", "T1059.001"}) {
+		t.Errorf("Unexpected group %+v", group)
+	}
+
+	// A re-import replaces the techniques of the group
+	groups[0].Techniques = []string{"T1003"}
+	if _, err := repo.ImportGroups(groups, ""); err != nil {
+		t.Fatalf("Failed to import groups: %v", err)
+	}
+	list, err := repo.ListThreatGroups()
+	if err != nil {
+		t.Fatalf("Failed to list groups: %v", err)
+	}
+	if len(list) != 1 || list[0].TechniqueCount != 1 {
+		t.Errorf("Unexpected groups %+v", list)
+	}
+}
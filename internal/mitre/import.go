@@ -0,0 +1,178 @@
+package mitre
+
+import (
+	"fmt"
+	"reflect"
+
+	"riskmatrix/pkg/models"
+)
+
+// ImportReport summarizes an import of MITRE techniques
+type ImportReport struct {
+	Version    string             `json:"version,omitempty"` // ATT&CK version imported
+	Added      []string           `json:"added"`
+	Changed    []*TechniqueChange `json:"changed"`
+	Revoked    []string           `json:"revoked"`    // newly revoked techniques
+	Deprecated []string           `json:"deprecated"` // newly deprecated techniques
+	Unchanged  int                `json:"unchanged"`
+
+	// Revoked or deprecated techniques that were not in the catalog and so
+	// were not added
+	Skipped int `json:"skipped"`
+
+	// Detection mappings moved off revoked techniques after the import
+	Remapped []*models.AppliedRemap `json:"remapped,omitempty"`
+
+	// Threat groups imported from the same bundles
+	Groups *GroupImportReport `json:"groups,omitempty"`
+}
+
+// TechniqueChange lists the fields of a technique an import changed
+type TechniqueChange struct {
+	ID     string   `json:"id"`
+	Fields []string `json:"fields"`
+}
+
+// ImportTechniques upserts techniques in a single transaction, reporting
+// which were added, changed, revoked and deprecated. A revoked technique
+// keeps the rest of its stored details, since MITRE strips revoked
+// techniques down to little more than their name. When version is set every
+// imported technique is stamped with it, without counting as a change.
+func (r *Repository) ImportTechniques(techniques []*models.MitreTechnique, version string) (*ImportReport, error) {
+	report := &ImportReport{
+		Version:    version,
+		Added:      make([]string, 0),
+		Changed:    make([]*TechniqueChange, 0),
+		Revoked:    make([]string, 0),
+		Deprecated: make([]string, 0),
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT ` + techniqueColumns + ` FROM mitre_techniques`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying MITRE techniques: %w", err)
+	}
+	existing := make(map[string]*models.MitreTechnique)
+	for rows.Next() {
+		technique, err := scanTechnique(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning MITRE technique row: %w", err)
+		}
+		existing[technique.ID] = technique
+	}
+	rows.Close()
+
+	for _, technique := range techniques {
+		if technique.ID == "" || technique.Name == "" {
+			return nil, fmt.Errorf("technique %q has no ID or name", technique.ID)
+		}
+
+		if version != "" {
+			technique.AttackVersion = version
+		}
+
+		current, ok := existing[technique.ID]
+		if !ok {
+			if technique.Revoked || technique.Deprecated {
+				report.Skipped++
+				continue
+			}
+
+			args, err := techniqueArgs(technique)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := tx.Exec(insertTechniqueQuery, append([]interface{}{technique.ID}, args...)...); err != nil {
+				return nil, fmt.Errorf("error creating MITRE technique %s: %w", technique.ID, err)
+			}
+			existing[technique.ID] = technique
+			report.Added = append(report.Added, technique.ID)
+			continue
+		}
+
+		if technique.Revoked {
+			revoked := *current
+			revoked.Revoked = true
+			revoked.Deprecated = technique.Deprecated
+			revoked.RevokedBy = technique.RevokedBy
+			revoked.AttackVersion = technique.AttackVersion
+			technique = &revoked
+		}
+
+		fields := changedFields(current, technique)
+		if len(fields) == 0 {
+			if technique.AttackVersion != "" && technique.AttackVersion != current.AttackVersion {
+				if _, err := tx.Exec(`UPDATE mitre_techniques SET attack_version = ? WHERE id = ?`, technique.AttackVersion, technique.ID); err != nil {
+					return nil, fmt.Errorf("error updating version of MITRE technique %s: %w", technique.ID, err)
+				}
+			}
+			report.Unchanged++
+			continue
+		}
+		if technique.AttackVersion == "" {
+			technique.AttackVersion = current.AttackVersion
+		}
+
+		args, err := techniqueArgs(technique)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(updateTechniqueQuery, append(args, technique.ID)...); err != nil {
+			return nil, fmt.Errorf("error updating MITRE technique %s: %w", technique.ID, err)
+		}
+		existing[technique.ID] = technique
+
+		switch {
+		case technique.Revoked && !current.Revoked:
+			report.Revoked = append(report.Revoked, technique.ID)
+		case technique.Deprecated && !current.Deprecated:
+			report.Deprecated = append(report.Deprecated, technique.ID)
+		default:
+			report.Changed = append(report.Changed, &TechniqueChange{ID: technique.ID, Fields: fields})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return report, nil
+}
+
+// changedFields returns the names of the fields that differ between two
+// versions of a technique. Empty and missing lists are the same, and the
+// ATT&CK version is not compared.
+func changedFields(old, new *models.MitreTechnique) []string {
+	fields := make([]string, 0)
+	check := func(name string, changed bool) {
+		if changed {
+			fields = append(fields, name)
+		}
+	}
+	sameList := func(a, b []string) bool {
+		return (len(a) == 0 && len(b) == 0) || reflect.DeepEqual(a, b)
+	}
+
+	check("name", old.Name != new.Name)
+	check("description", old.Description != new.Description)
+	check("tactic", old.Tactic != new.Tactic)
+	check("tactics", !sameList(old.Tactics, new.Tactics))
+	check("domain", old.Domain != new.Domain)
+	check("last_modified", old.LastModified != new.LastModified)
+	check("detection", old.Detection != new.Detection)
+	check("platforms", !sameList(old.Platforms, new.Platforms))
+	check("data_sources", !sameList(old.DataSources, new.DataSources))
+	check("is_sub_technique", old.IsSubTechnique != new.IsSubTechnique)
+	check("sub_technique_of", old.SubTechniqueOf != new.SubTechniqueOf)
+	check("revoked", old.Revoked != new.Revoked)
+	check("deprecated", old.Deprecated != new.Deprecated)
+	check("revoked_by", old.RevokedBy != new.RevokedBy)
+
+	return fields
+}
@@ -0,0 +1,199 @@
+package mitre
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"riskmatrix/pkg/models"
+)
+
+// Navigator and layer format versions of exported layers
+const (
+	navigatorVersion = "5.1.0"
+	layerVersion     = "4.5"
+)
+
+// Layer colors: techniques whose detections are not in production yet,
+// techniques with one production detection and techniques with more
+const (
+	colorMappedOnly  = "#fdae61"
+	colorCovered     = "#a6d96a"
+	colorWellCovered = "#1a9641"
+)
+
+// DefaultLayerDomain is the ATT&CK domain of layers when none is given
+const DefaultLayerDomain = "enterprise-attack"
+
+// layerDomains maps Navigator domains to the domain names of the CSV export,
+// since the catalog holds both depending on how it was imported
+var layerDomains = map[string]string{
+	"enterprise-attack": "Enterprise",
+	"mobile-attack":     "Mobile",
+	"ics-attack":        "ICS",
+}
+
+// NavigatorLayer builds an ATT&CK Navigator layer of the techniques the
+// filtered detections are mapped to. Each technique is scored by its number
+// of production detections and colored by it, with a comment listing its
+// detections. Revoked and deprecated techniques are left out, since
+// Navigator does not show them.
+func (r *Repository) NavigatorLayer(filter models.LayerFilter) (*models.NavigatorLayer, error) {
+	domain := filter.Domain
+	if domain == "" {
+		domain = DefaultLayerDomain
+	}
+
+	query := `
+		SELECT t.id, t.is_sub_technique, t.sub_technique_of, t.attack_version, d.name, d.status
+		FROM detection_mitre_map m
+		JOIN detections d ON d.id = m.detection_id
+		JOIN mitre_techniques t ON t.id = m.mitre_id
+		WHERE t.revoked = 0 AND t.deprecated = 0
+		AND (t.domain IS NULL OR t.domain = '' OR t.domain = ? OR t.domain = ?)`
+	args := []interface{}{domain, layerDomains[domain]}
+	description := []string{"Production detections per technique"}
+
+	if len(filter.Statuses) > 0 {
+		placeholders := make([]string, len(filter.Statuses))
+		names := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			placeholders[i] = "?"
+			names[i] = string(status)
+			args = append(args, status)
+		}
+		query += ` AND d.status IN (` + strings.Join(placeholders, ", ") + `)`
+		description = append(description, "status "+strings.Join(names, ", "))
+	} else {
+		query += ` AND d.status != ?`
+		args = append(args, models.StatusRetired)
+	}
+	if filter.ClassID != nil {
+		query += ` AND d.class_id = ?`
+		args = append(args, *filter.ClassID)
+		description = append(description, fmt.Sprintf("class %d", *filter.ClassID))
+	}
+	if filter.DataSource != "" {
+		query += ` AND EXISTS (
+			SELECT 1 FROM detection_datasource dd
+			JOIN data_sources s ON s.id = dd.datasource_id
+			WHERE dd.detection_id = d.id AND s.name = ?)`
+		args = append(args, filter.DataSource)
+		description = append(description, "data source "+filter.DataSource)
+	}
+	query += ` ORDER BY t.id, d.name`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying technique detections: %w", err)
+	}
+	defer rows.Close()
+
+	type techniqueDetections struct {
+		parent     string
+		production int
+		detections []string
+	}
+	order := make([]string, 0)
+	byTechnique := make(map[string]*techniqueDetections)
+	attackMajor := 0
+	for rows.Next() {
+		var id, name string
+		var status models.DetectionStatus
+		var isSubTechnique bool
+		var parent, version sql.NullString
+		if err := rows.Scan(&id, &isSubTechnique, &parent, &version, &name, &status); err != nil {
+			return nil, fmt.Errorf("error scanning technique detection row: %w", err)
+		}
+
+		technique, ok := byTechnique[id]
+		if !ok {
+			technique = &techniqueDetections{}
+			if isSubTechnique {
+				technique.parent = parent.String
+			}
+			byTechnique[id] = technique
+			order = append(order, id)
+		}
+		if status == models.StatusProduction {
+			technique.production++
+		}
+		technique.detections = append(technique.detections, fmt.Sprintf("%s (%s)", name, status))
+
+		if version.Valid {
+			major, _, _ := strings.Cut(version.String, ".")
+			if n, err := strconv.Atoi(major); err == nil && n > attackMajor {
+				attackMajor = n
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating technique detection rows: %w", err)
+	}
+
+	layer := &models.NavigatorLayer{
+		Name:        "DetectionMatrix coverage",
+		Versions:    &models.NavigatorVersions{Navigator: navigatorVersion, Layer: layerVersion},
+		Domain:      domain,
+		Description: strings.Join(description, ", "),
+		Techniques:  make([]*models.NavigatorTechnique, 0, len(order)),
+		Gradient: &models.NavigatorGradient{
+			Colors:   []string{colorMappedOnly, colorCovered, colorWellCovered},
+			MinValue: 0,
+			MaxValue: 2,
+		},
+		LegendItems: []*models.NavigatorLegendItem{
+			{Label: "Detections not in production", Color: colorMappedOnly},
+			{Label: "1 production detection", Color: colorCovered},
+			{Label: "2+ production detections", Color: colorWellCovered},
+		},
+	}
+	if attackMajor > 0 {
+		layer.Versions.Attack = strconv.Itoa(attackMajor)
+	}
+
+	entries := make(map[string]*models.NavigatorTechnique, len(order))
+	for _, id := range order {
+		technique := byTechnique[id]
+		score := float64(technique.production)
+		entry := &models.NavigatorTechnique{
+			TechniqueID: id,
+			Score:       &score,
+			Color:       scoreColor(technique.production),
+			Comment:     strings.Join(technique.detections, "; "),
+		}
+		entries[id] = entry
+		layer.Techniques = append(layer.Techniques, entry)
+	}
+
+	// Expand parents of covered sub-techniques, so the coverage is visible
+	for _, id := range order {
+		parent := byTechnique[id].parent
+		if parent == "" {
+			continue
+		}
+		if entry, ok := entries[parent]; ok {
+			entry.ShowSubtechniques = true
+			continue
+		}
+		entry := &models.NavigatorTechnique{TechniqueID: parent, ShowSubtechniques: true}
+		entries[parent] = entry
+		layer.Techniques = append(layer.Techniques, entry)
+	}
+
+	return layer, nil
+}
+
+// scoreColor returns the layer color of a technique with a number of
+// production detections
+func scoreColor(production int) string {
+	switch {
+	case production == 0:
+		return colorMappedOnly
+	case production == 1:
+		return colorCovered
+	default:
+		return colorWellCovered
+	}
+}
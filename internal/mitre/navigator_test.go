@@ -0,0 +1,141 @@
+package mitre
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+// setupCoverageRepo creates a catalog of PowerShell and its parent, a
+// technique revoked in favour of PowerShell and an unmapped technique, with
+// two production detections on PowerShell, one reading Sysmon, and one test
+// detection on the parent
+func setupCoverageRepo(t *testing.T) *Repository {
+	repo, db := setupTestRepo(t)
+	t.Cleanup(func() { db.Close() })
+
+	for _, technique := range []*models.MitreTechnique{
+		{ID: "T1059", Name: "Command and Scripting Interpreter", Tactic: "Execution", AttackVersion: "16.1"},
+		{ID: "T1059.001", Name: "PowerShell", Tactic: "Execution", IsSubTechnique: true, SubTechniqueOf: "T1059", AttackVersion: "17.1"},
+		{ID: "T1086", Name: "PowerShell", Tactic: "Execution", Revoked: true, RevokedBy: "T1059.001"},
+		{ID: "T1003", Name: "OS Credential Dumping", Tactic: "Credential Access"},
+	} {
+		if err := repo.CreateMitreTechnique(technique); err != nil {
+			t.Fatalf("Failed to create technique: %v", err)
+		}
+	}
+
+	encoded := createMappedDetection(t, repo, "Encoded PowerShell", "T1059.001")
+	createMappedDetection(t, repo, "PowerShell Download Cradle", "T1059.001")
+	if _, err := db.Exec("INSERT INTO detections (name, status, severity, risk_points) VALUES ('Suspicious Interpreter', 'test', 'low', 10)"); err != nil {
+		t.Fatalf("Failed to create test detection: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO detection_mitre_map (detection_id, mitre_id) SELECT id, 'T1059' FROM detections WHERE name = 'Suspicious Interpreter'"); err != nil {
+		t.Fatalf("Failed to map detection: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO data_sources (id, name) VALUES (1, 'Sysmon')"); err != nil {
+		t.Fatalf("Failed to create data source: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO detection_datasource (detection_id, datasource_id) VALUES (?, 1)", encoded); err != nil {
+		t.Fatalf("Failed to link data source: %v", err)
+	}
+
+	return repo
+}
+
+func TestRepository_NavigatorLayer(t *testing.T) {
+	repo := setupCoverageRepo(t)
+
+	layer, err := repo.NavigatorLayer(models.LayerFilter{})
+	if err != nil {
+		t.Fatalf("Failed to build layer: %v", err)
+	}
+	if layer.Domain != DefaultLayerDomain || layer.Versions.Attack != "17" || len(layer.Techniques) != 2 {
+		t.Fatalf("Unexpected layer %+v", layer)
+	}
+
+	parent, sub := layer.Techniques[0], layer.Techniques[1]
+	if parent.TechniqueID != "T1059" || *parent.Score != 0 || parent.Color != colorMappedOnly || !parent.ShowSubtechniques ||
+		parent.Comment != "Suspicious Interpreter (test)" {
+		t.Errorf("Unexpected parent technique %+v", parent)
+	}
+	if sub.TechniqueID != "T1059.001" || *sub.Score != 2 || sub.Color != colorWellCovered ||
+		sub.Comment != "Encoded PowerShell (production); PowerShell Download Cradle (production)" {
+		t.Errorf("Unexpected sub-technique %+v", sub)
+	}
+
+	// Filtering on a data source keeps the parent expanded without scoring it
+	layer, err = repo.NavigatorLayer(models.LayerFilter{DataSource: "Sysmon", Statuses: []models.DetectionStatus{models.StatusProduction}})
+	if err != nil {
+		t.Fatalf("Failed to build layer: %v", err)
+	}
+	if len(layer.Techniques) != 2 || *layer.Techniques[0].Score != 1 || layer.Techniques[0].Color != colorCovered ||
+		layer.Techniques[1].TechniqueID != "T1059" || layer.Techniques[1].Score != nil {
+		t.Errorf("Unexpected filtered layer %+v", layer.Techniques)
+	}
+}
+
+func TestRepository_ImportPriorityLayer(t *testing.T) {
+	repo := setupCoverageRepo(t)
+
+	score := func(v float64) *float64 { return &v }
+	disabled := false
+	layer := &models.NavigatorLayer{
+		Name:   "APT29 techniques",
+		Domain: DefaultLayerDomain,
+		Techniques: []*models.NavigatorTechnique{
+			{TechniqueID: "T1003", Tactic: "credential-access", Score: score(3), Comment: "Used by APT29"},
+			{TechniqueID: "T1086", Tactic: "execution", Score: score(2)},
+			{TechniqueID: "T1059", Tactic: "execution", Score: score(0)},
+			{TechniqueID: "T1027", Tactic: "defense-evasion", Score: score(5)},
+			{TechniqueID: "T1059.001", Score: score(4), Enabled: &disabled},
+		},
+	}
+
+	result, err := repo.ImportPriorityLayer(&models.PriorityList{Name: "APT29"}, layer, 0)
+	if err != nil {
+		t.Fatalf("Failed to import layer: %v", err)
+	}
+	if !reflect.DeepEqual(result.Unknown, []string{"T1027"}) || result.List.Source != "APT29 techniques" || result.List.TechniqueCount != 2 {
+		t.Fatalf("Unexpected import %+v", result)
+	}
+
+	// The revoked technique is listed under its replacement
+	list, err := repo.GetPriorityList(result.List.ID)
+	if err != nil {
+		t.Fatalf("Failed to get priority list: %v", err)
+	}
+	if len(list.Techniques) != 2 || list.Techniques[0].TechniqueID != "T1003" || list.Techniques[1].TechniqueID != "T1059.001" {
+		t.Errorf("Unexpected priority techniques %+v", list.Techniques)
+	}
+
+	coverage, err := repo.PriorityListCoverage(list.ID)
+	if err != nil {
+		t.Fatalf("Failed to get priority coverage: %v", err)
+	}
+	if len(coverage) != 2 || coverage[0].Covered || !coverage[1].Covered || coverage[1].ProductionDetections != 2 || coverage[0].Priority != 3 {
+		t.Errorf("Unexpected priority coverage %+v", coverage)
+	}
+
+	// Importing under the same name replaces the list
+	layer.Techniques = layer.Techniques[:1]
+	if _, err := repo.ImportPriorityLayer(&models.PriorityList{Name: "APT29"}, layer, 0); err != nil {
+		t.Fatalf("Failed to import layer: %v", err)
+	}
+	lists, err := repo.ListPriorityLists()
+	if err != nil {
+		t.Fatalf("Failed to list priority lists: %v", err)
+	}
+	if len(lists) != 1 || lists[0].ID != list.ID || lists[0].TechniqueCount != 1 {
+		t.Errorf("Unexpected priority lists %+v", lists)
+	}
+
+	if err := repo.DeletePriorityList(list.ID); err != nil {
+		t.Fatalf("Failed to delete priority list: %v", err)
+	}
+	if _, err := repo.PriorityListCoverage(list.ID); !errors.Is(err, ErrPriorityListNotFound) {
+		t.Errorf("Expected priority list not found, got %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package mitre
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// PriorityImport is the result of importing a threat-intel layer as a
+// priority list
+type PriorityImport struct {
+	List *models.PriorityList `json:"list"`
+
+	// Techniques of the layer that are not in the catalog, and so were left
+	// out of the list
+	Unknown []string `json:"unknown"`
+}
+
+// priorityTechniques reads the techniques a layer marks: enabled techniques
+// scored above minScore, or highlighted with a color when the layer has no
+// scores. A technique annotated under several tactics keeps its highest
+// score.
+func priorityTechniques(layer *models.NavigatorLayer, minScore float64) []*models.PriorityTechnique {
+	byID := make(map[string]*models.PriorityTechnique)
+	for _, entry := range layer.Techniques {
+		if entry.Enabled != nil && !*entry.Enabled {
+			continue
+		}
+		var score float64
+		switch {
+		case entry.Score != nil && *entry.Score > minScore:
+			score = *entry.Score
+		case entry.Score == nil && entry.Color != "" && minScore <= 0:
+		default:
+			continue
+		}
+
+		technique, ok := byID[entry.TechniqueID]
+		if !ok {
+			technique = &models.PriorityTechnique{TechniqueID: entry.TechniqueID, Score: score}
+			byID[entry.TechniqueID] = technique
+		}
+		if score > technique.Score {
+			technique.Score = score
+		}
+		if entry.Comment != "" && !strings.Contains(technique.Comment, entry.Comment) {
+			if technique.Comment != "" {
+				technique.Comment += "; "
+			}
+			technique.Comment += entry.Comment
+		}
+	}
+
+	techniques := make([]*models.PriorityTechnique, 0, len(byID))
+	for _, technique := range byID {
+		techniques = append(techniques, technique)
+	}
+	sortPriorityTechniques(techniques)
+	return techniques
+}
+
+// sortPriorityTechniques orders techniques by descending score, then ID
+func sortPriorityTechniques(techniques []*models.PriorityTechnique) {
+	sort.Slice(techniques, func(i, j int) bool {
+		if techniques[i].Score != techniques[j].Score {
+			return techniques[i].Score > techniques[j].Score
+		}
+		return techniques[i].TechniqueID < techniques[j].TechniqueID
+	})
+}
+
+// ImportPriorityLayer turns a threat-intel Navigator layer into a priority
+// list, replacing the techniques of an existing list of the same name.
+// Techniques the catalog holds as revoked are listed under their
+// replacement, and techniques missing from the catalog are reported as
+// unknown.
+func (r *Repository) ImportPriorityLayer(list *models.PriorityList, layer *models.NavigatorLayer, minScore float64) (*PriorityImport, error) {
+	result := &PriorityImport{List: list, Unknown: make([]string, 0)}
+	list.Source = layer.Name
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	catalog, err := retiredCatalog(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.PriorityTechnique)
+	for _, technique := range priorityTechniques(layer, minScore) {
+		current, ok := catalog[technique.TechniqueID]
+		if !ok {
+			result.Unknown = append(result.Unknown, technique.TechniqueID)
+			continue
+		}
+		if replacement := replacementFor(catalog, current.ID); replacement != nil {
+			technique.TechniqueID = replacement.ID
+		}
+		if existing, ok := byID[technique.TechniqueID]; ok {
+			if technique.Score > existing.Score {
+				existing.Score = technique.Score
+			}
+			continue
+		}
+		byID[technique.TechniqueID] = technique
+	}
+	list.Techniques = make([]*models.PriorityTechnique, 0, len(byID))
+	for _, technique := range byID {
+		list.Techniques = append(list.Techniques, technique)
+	}
+	sortPriorityTechniques(list.Techniques)
+	list.TechniqueCount = len(list.Techniques)
+
+	if _, err := tx.Exec(
+		`INSERT INTO priority_lists (name, description, source, created_at) VALUES (?, ?, ?, ?)
+         ON CONFLICT(name) DO UPDATE SET description = excluded.description, source = excluded.source`,
+		list.Name, list.Description, list.Source, time.Now().Format(time.RFC3339),
+	); err != nil {
+		return nil, fmt.Errorf("error saving priority list: %w", err)
+	}
+	var createdAt string
+	if err := tx.QueryRow(`SELECT id, created_at FROM priority_lists WHERE name = ?`, list.Name).Scan(&list.ID, &createdAt); err != nil {
+		return nil, fmt.Errorf("error scanning priority list: %w", err)
+	}
+	list.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+
+	if _, err := tx.Exec(`DELETE FROM priority_list_techniques WHERE list_id = ?`, list.ID); err != nil {
+		return nil, fmt.Errorf("error clearing priority list techniques: %w", err)
+	}
+	for _, technique := range list.Techniques {
+		if _, err := tx.Exec(
+			`INSERT INTO priority_list_techniques (list_id, technique_id, score, comment) VALUES (?, ?, ?, ?)`,
+			list.ID, technique.TechniqueID, technique.Score, technique.Comment,
+		); err != nil {
+			return nil, fmt.Errorf("error adding priority technique %s: %w", technique.TechniqueID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+const priorityListColumns = `l.id, l.name, l.description, l.source, l.created_at,
+	(SELECT COUNT(*) FROM priority_list_techniques p WHERE p.list_id = l.id)`
+
+// scanPriorityList scans a priority list row, without its techniques
+func scanPriorityList(row database.RowScanner) (*models.PriorityList, error) {
+	var list models.PriorityList
+	var description, source sql.NullString
+	var createdAt string
+
+	if err := row.Scan(&list.ID, &list.Name, &description, &source, &createdAt, &list.TechniqueCount); err != nil {
+		return nil, err
+	}
+	list.Description = description.String
+	list.Source = source.String
+	list.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+
+	return &list, nil
+}
+
+// ListPriorityLists returns the priority lists, without their techniques
+func (r *Repository) ListPriorityLists() ([]*models.PriorityList, error) {
+	rows, err := r.db.Query(`SELECT ` + priorityListColumns + ` FROM priority_lists l ORDER BY l.name`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying priority lists: %w", err)
+	}
+	defer rows.Close()
+
+	lists := make([]*models.PriorityList, 0)
+	for rows.Next() {
+		list, err := scanPriorityList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning priority list row: %w", err)
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating priority list rows: %w", err)
+	}
+
+	return lists, nil
+}
+
+// GetPriorityList returns a priority list with its techniques, highest
+// score first
+func (r *Repository) GetPriorityList(id int64) (*models.PriorityList, error) {
+	list, err := scanPriorityList(r.db.QueryRow(`SELECT `+priorityListColumns+` FROM priority_lists l WHERE l.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %d", ErrPriorityListNotFound, id)
+		}
+		return nil, fmt.Errorf("error scanning priority list: %w", err)
+	}
+
+	rows, err := r.db.Query(
+		`SELECT technique_id, score, comment FROM priority_list_techniques
+         WHERE list_id = ? ORDER BY score DESC, technique_id`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying priority techniques: %w", err)
+	}
+	defer rows.Close()
+
+	list.Techniques = make([]*models.PriorityTechnique, 0)
+	for rows.Next() {
+		var technique models.PriorityTechnique
+		var comment sql.NullString
+		if err := rows.Scan(&technique.TechniqueID, &technique.Score, &comment); err != nil {
+			return nil, fmt.Errorf("error scanning priority technique row: %w", err)
+		}
+		technique.Comment = comment.String
+		list.Techniques = append(list.Techniques, &technique)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating priority technique rows: %w", err)
+	}
+
+	return list, nil
+}
+
+// DeletePriorityList deletes a priority list and its techniques
+func (r *Repository) DeletePriorityList(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM priority_lists WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting priority list: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %d", ErrPriorityListNotFound, id)
+	}
+
+	return nil
+}
+
+// PriorityListCoverage compares a priority list against production
+// detections, highest priority first
+func (r *Repository) PriorityListCoverage(id int64) ([]*models.PriorityCoverage, error) {
+	var exists bool
+	if err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM priority_lists WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error checking priority list: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %d", ErrPriorityListNotFound, id)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT p.technique_id, t.name, t.tactic, p.score, COUNT(d.id)
+		FROM priority_list_techniques p
+		JOIN mitre_techniques t ON t.id = p.technique_id
+		LEFT JOIN detection_mitre_map m ON m.mitre_id = p.technique_id
+		LEFT JOIN detections d ON d.id = m.detection_id AND d.status = ?
+		WHERE p.list_id = ?
+		GROUP BY p.technique_id
+		ORDER BY p.score DESC, p.technique_id
+	`, models.StatusProduction, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying priority coverage: %w", err)
+	}
+	defer rows.Close()
+
+	coverage := make([]*models.PriorityCoverage, 0)
+	for rows.Next() {
+		var technique models.PriorityCoverage
+		if err := rows.Scan(&technique.TechniqueID, &technique.Name, &technique.Tactic, &technique.Priority, &technique.ProductionDetections); err != nil {
+			return nil, fmt.Errorf("error scanning priority coverage row: %w", err)
+		}
+		technique.Covered = technique.ProductionDetections > 0
+		coverage = append(coverage, &technique)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating priority coverage rows: %w", err)
+	}
+
+	return coverage, nil
+}
@@ -0,0 +1,361 @@
+package mitre
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// CreateThreatProfile creates a threat profile of existing threat groups
+func (r *Repository) CreateThreatProfile(profile *models.ThreatProfile) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkProfileName(tx, profile); err != nil {
+		return err
+	}
+
+	profile.CreatedAt = time.Now()
+	profile.UpdatedAt = profile.CreatedAt
+	result, err := tx.Exec(
+		`INSERT INTO threat_profiles (name, description, created_at, updated_at) VALUES (?, ?, ?, ?)`,
+		profile.Name, profile.Description, profile.CreatedAt.Format(time.RFC3339), profile.UpdatedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating threat profile: %w", err)
+	}
+	if profile.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	if err := setProfileGroups(tx, profile); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateThreatProfile updates the name, description and groups of a threat
+// profile
+func (r *Repository) UpdateThreatProfile(profile *models.ThreatProfile) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkProfileName(tx, profile); err != nil {
+		return err
+	}
+
+	profile.UpdatedAt = time.Now()
+	result, err := tx.Exec(
+		`UPDATE threat_profiles SET name = ?, description = ?, updated_at = ? WHERE id = ?`,
+		profile.Name, profile.Description, profile.UpdatedAt.Format(time.RFC3339), profile.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating threat profile: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %d", ErrThreatProfileNotFound, profile.ID)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM threat_profile_groups WHERE profile_id = ?`, profile.ID); err != nil {
+		return fmt.Errorf("error clearing threat profile groups: %w", err)
+	}
+	if err := setProfileGroups(tx, profile); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// checkProfileName refuses a profile name another profile already has
+func checkProfileName(tx *sql.Tx, profile *models.ThreatProfile) error {
+	var taken bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM threat_profiles WHERE name = ? AND id != ?)`, profile.Name, profile.ID).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("error checking threat profile name: %w", err)
+	}
+	if taken {
+		return fmt.Errorf("%w: %s", ErrThreatProfileExists, profile.Name)
+	}
+	return nil
+}
+
+// setProfileGroups adds the groups of a profile, refusing unknown groups
+func setProfileGroups(tx *sql.Tx, profile *models.ThreatProfile) error {
+	for _, groupID := range profile.GroupIDs {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM threat_groups WHERE id = ?)`, groupID).Scan(&exists); err != nil {
+			return fmt.Errorf("error checking threat group: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrThreatGroupNotFound, groupID)
+		}
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO threat_profile_groups (profile_id, group_id) VALUES (?, ?)`,
+			profile.ID, groupID,
+		); err != nil {
+			return fmt.Errorf("error adding group %s to threat profile: %w", groupID, err)
+		}
+	}
+	return nil
+}
+
+// scanProfile scans a threat profile row, without its groups
+func scanProfile(row database.RowScanner) (*models.ThreatProfile, error) {
+	var profile models.ThreatProfile
+	var description sql.NullString
+	var createdAt, updatedAt string
+
+	if err := row.Scan(&profile.ID, &profile.Name, &description, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	profile.Description = description.String
+	profile.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	profile.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+	return &profile, nil
+}
+
+// profileGroups loads the group IDs of a threat profile
+func (r *Repository) profileGroups(profile *models.ThreatProfile) error {
+	rows, err := r.db.Query(`SELECT group_id FROM threat_profile_groups WHERE profile_id = ? ORDER BY group_id`, profile.ID)
+	if err != nil {
+		return fmt.Errorf("error querying threat profile groups: %w", err)
+	}
+	defer rows.Close()
+
+	profile.GroupIDs = make([]string, 0)
+	for rows.Next() {
+		var groupID string
+		if err := rows.Scan(&groupID); err != nil {
+			return fmt.Errorf("error scanning threat profile group row: %w", err)
+		}
+		profile.GroupIDs = append(profile.GroupIDs, groupID)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating threat profile group rows: %w", err)
+	}
+
+	return nil
+}
+
+// GetThreatProfile returns a threat profile with its group IDs
+func (r *Repository) GetThreatProfile(id int64) (*models.ThreatProfile, error) {
+	profile, err := scanProfile(r.db.QueryRow(
+		`SELECT id, name, description, created_at, updated_at FROM threat_profiles WHERE id = ?`, id,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %d", ErrThreatProfileNotFound, id)
+		}
+		return nil, fmt.Errorf("error scanning threat profile: %w", err)
+	}
+
+	if err := r.profileGroups(profile); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// ListThreatProfiles returns the threat profiles with their group IDs
+func (r *Repository) ListThreatProfiles() ([]*models.ThreatProfile, error) {
+	rows, err := r.db.Query(`SELECT id, name, description, created_at, updated_at FROM threat_profiles ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying threat profiles: %w", err)
+	}
+
+	profiles := make([]*models.ThreatProfile, 0)
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning threat profile row: %w", err)
+		}
+		profiles = append(profiles, profile)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating threat profile rows: %w", err)
+	}
+
+	for _, profile := range profiles {
+		if err := r.profileGroups(profile); err != nil {
+			return nil, err
+		}
+	}
+
+	return profiles, nil
+}
+
+// DeleteThreatProfile deletes a threat profile
+func (r *Repository) DeleteThreatProfile(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM threat_profiles WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting threat profile: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %d", ErrThreatProfileNotFound, id)
+	}
+
+	return nil
+}
+
+// ThreatProfileCoverage reports coverage under the mode against only the
+// techniques the groups of a threat profile use, scored as in the coverage
+// tree: sub-techniques roll up into their parent, a technique counts
+// towards each of its tactics, and revoked techniques count under their
+// replacement. Techniques are ranked by how many of the
+// groups use them, and the gaps are the ranked techniques that are not fully
+// covered. The mode defaults to production.
+func (r *Repository) ThreatProfileCoverage(id int64, mode models.CoverageMode) (*models.ThreatCoverage, error) {
+	if mode == "" {
+		mode = models.CoverageProduction
+	}
+
+	profile, err := r.GetThreatProfile(id)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := retiredCatalog(r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT gt.technique_id, g.name
+		FROM threat_profile_groups pg
+		JOIN threat_groups g ON g.id = pg.group_id
+		JOIN threat_group_techniques gt ON gt.group_id = g.id
+		WHERE pg.profile_id = ?
+		ORDER BY gt.technique_id, g.name
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying threat profile techniques: %w", err)
+	}
+
+	// Revoked techniques count under their replacement
+	uses := make(map[string][]string)
+	techniqueIDs := make([]string, 0)
+	for rows.Next() {
+		var techniqueID, group string
+		if err := rows.Scan(&techniqueID, &group); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning threat profile technique row: %w", err)
+		}
+		if replacement := replacementFor(catalog, techniqueID); replacement != nil {
+			techniqueID = replacement.ID
+		}
+		if _, ok := uses[techniqueID]; !ok {
+			techniqueIDs = append(techniqueIDs, techniqueID)
+		}
+		uses[techniqueID] = appendUnique(uses[techniqueID], group)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating threat profile technique rows: %w", err)
+	}
+	sort.Strings(techniqueIDs)
+	for _, groups := range uses {
+		sort.Strings(groups)
+	}
+
+	scored, _, err := r.scoredTechniques(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	coverage := &models.ThreatCoverage{
+		Profile:    profile,
+		Mode:       mode,
+		Techniques: make([]*models.ThreatTechniqueCoverage, 0),
+		Gaps:       make([]*models.ThreatTechniqueCoverage, 0),
+		ByTactic:   make(map[string]float64),
+	}
+	// Unknown and deprecated techniques, and revoked ones without a
+	// replacement, are left out
+	for _, techniqueID := range techniqueIDs {
+		technique, ok := scored[techniqueID]
+		if !ok {
+			continue
+		}
+		node := technique.node
+		coverage.Techniques = append(coverage.Techniques, &models.ThreatTechniqueCoverage{
+			TechniqueID: node.TechniqueID,
+			Name:        node.Name,
+			Tactics:     technique.tactics,
+			Groups:      uses[techniqueID],
+			GroupCount:  len(uses[techniqueID]),
+			Detections:  node.Detections,
+			Coverage:    node.Coverage,
+			Covered:     node.Coverage >= 1,
+			Partial:     node.Partial,
+		})
+	}
+
+	sort.SliceStable(coverage.Techniques, func(i, j int) bool {
+		return coverage.Techniques[i].GroupCount > coverage.Techniques[j].GroupCount
+	})
+
+	var total, weighted float64
+	var groupUses int
+	tacticTotals := make(map[string]int)
+	for _, technique := range coverage.Techniques {
+		total += technique.Coverage
+		weighted += technique.Coverage * float64(technique.GroupCount)
+		groupUses += technique.GroupCount
+		for _, tactic := range technique.Tactics {
+			tacticTotals[tactic]++
+			coverage.ByTactic[tactic] += technique.Coverage
+		}
+
+		switch {
+		case technique.Covered:
+			coverage.Summary.Covered++
+		case technique.Partial:
+			coverage.Summary.Partial++
+		}
+		if !technique.Covered {
+			coverage.Gaps = append(coverage.Gaps, technique)
+		}
+	}
+	for tactic, count := range tacticTotals {
+		coverage.ByTactic[tactic] = coverage.ByTactic[tactic] / float64(count) * 100
+	}
+
+	coverage.Summary.Techniques = len(coverage.Techniques)
+	coverage.Summary.Gaps = len(coverage.Gaps)
+	if coverage.Summary.Techniques > 0 {
+		coverage.Summary.CoveragePercent = total / float64(coverage.Summary.Techniques) * 100
+		coverage.Summary.WeightedCoveragePercent = weighted / float64(groupUses) * 100
+	}
+
+	return coverage, nil
+}
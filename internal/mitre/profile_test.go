@@ -0,0 +1,99 @@
+package mitre
+
+import (
+	"errors"
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestRepository_ThreatProfileCoverage(t *testing.T) {
+	repo := setupCoverageRepo(t)
+
+	groups := []*models.ThreatGroup{
+		{ID: "G0016", Name: "APT29", Techniques: []string{"T1059.001", "T1003", "T1059"}},
+		{ID: "G0046", Name: "FIN7", Techniques: []string{"T1003", "T1059"}},
+		{ID: "G0007", Name: "APT28", Techniques: []string{"T1059.001"}},
+	}
+	if _, err := repo.ImportGroups(groups, ""); err != nil {
+		t.Fatalf("Failed to import groups: %v", err)
+	}
+
+	profile := &models.ThreatProfile{Name: "Finance", GroupIDs: []string{"G0016", "G0046"}}
+	if err := repo.CreateThreatProfile(profile); err != nil {
+		t.Fatalf("Failed to create threat profile: %v", err)
+	}
+
+	// Unknown groups and taken names are refused
+	if err := repo.CreateThreatProfile(&models.ThreatProfile{Name: "Other", GroupIDs: []string{"G9999"}}); !errors.Is(err, ErrThreatGroupNotFound) {
+		t.Errorf("Expected threat group not found, got %v", err)
+	}
+	if err := repo.CreateThreatProfile(&models.ThreatProfile{Name: "Finance", GroupIDs: []string{"G0007"}}); !errors.Is(err, ErrThreatProfileExists) {
+		t.Errorf("Expected threat profile exists, got %v", err)
+	}
+
+	// An uncovered sibling of PowerShell leaves the parent half covered
+	sibling := &models.MitreTechnique{ID: "T1059.003", Name: "Windows Command Shell", Tactic: "Execution", IsSubTechnique: true, SubTechniqueOf: "T1059"}
+	if err := repo.CreateMitreTechnique(sibling); err != nil {
+		t.Fatalf("Failed to create technique: %v", err)
+	}
+
+	// Only the production detections on T1059.001 count by default: the
+	// techniques both groups use are the top gaps
+	coverage, err := repo.ThreatProfileCoverage(profile.ID, "")
+	if err != nil {
+		t.Fatalf("Failed to get coverage: %v", err)
+	}
+	if coverage.Mode != models.CoverageProduction || len(coverage.Techniques) != 3 || coverage.Techniques[0].TechniqueID != "T1003" ||
+		coverage.Techniques[0].GroupCount != 2 || coverage.Techniques[2].TechniqueID != "T1059.001" || !coverage.Techniques[2].Covered ||
+		coverage.Techniques[2].Detections != 2 {
+		t.Fatalf("Unexpected techniques %+v", coverage.Techniques)
+	}
+	if len(coverage.Gaps) != 2 || coverage.Gaps[0].TechniqueID != "T1003" || coverage.Gaps[1].TechniqueID != "T1059" ||
+		len(coverage.Gaps[1].Groups) != 2 || coverage.Gaps[1].Coverage != 0.5 || !coverage.Gaps[1].Partial {
+		t.Errorf("Unexpected gaps %+v", coverage.Gaps)
+	}
+	if coverage.Summary.Covered != 1 || coverage.Summary.Partial != 1 || coverage.Summary.CoveragePercent != 50 ||
+		coverage.Summary.WeightedCoveragePercent != 40 || coverage.ByTactic["Execution"] != 75 || coverage.ByTactic["Credential Access"] != 0 {
+		t.Errorf("Unexpected summary %+v by tactic %v", coverage.Summary, coverage.ByTactic)
+	}
+
+	// Counting any detection, the test detection covers the parent outright
+	if coverage, err = repo.ThreatProfileCoverage(profile.ID, models.CoverageAny); err != nil {
+		t.Fatalf("Failed to get coverage: %v", err)
+	}
+	if len(coverage.Gaps) != 1 || coverage.Gaps[0].TechniqueID != "T1003" || coverage.Summary.Covered != 2 {
+		t.Errorf("Unexpected coverage in any mode %+v", coverage)
+	}
+
+	// A technique revoked since the groups were imported counts under its
+	// replacement, once per group
+	for _, groupID := range []string{"G0016", "G0046"} {
+		if _, err := repo.db.Exec("INSERT INTO threat_group_techniques (group_id, technique_id) VALUES (?, 'T1086')", groupID); err != nil {
+			t.Fatalf("Failed to add revoked technique: %v", err)
+		}
+	}
+	if coverage, err = repo.ThreatProfileCoverage(profile.ID, ""); err != nil {
+		t.Fatalf("Failed to get coverage: %v", err)
+	}
+	if len(coverage.Techniques) != 3 || coverage.Techniques[2].TechniqueID != "T1059.001" || coverage.Techniques[2].GroupCount != 2 ||
+		coverage.Summary.WeightedCoveragePercent != 50 {
+		t.Errorf("Unexpected coverage with a revoked technique %+v", coverage.Techniques)
+	}
+
+	// Swapping in a group changes the techniques measured
+	profile.GroupIDs = []string{"G0007"}
+	if err := repo.UpdateThreatProfile(profile); err != nil {
+		t.Fatalf("Failed to update threat profile: %v", err)
+	}
+	if coverage, err = repo.ThreatProfileCoverage(profile.ID, models.CoverageProduction); err != nil || coverage.Summary.Techniques != 1 || coverage.Summary.CoveragePercent != 100 {
+		t.Errorf("Unexpected coverage after update %+v (%v)", coverage, err)
+	}
+
+	if err := repo.DeleteThreatProfile(profile.ID); err != nil {
+		t.Fatalf("Failed to delete threat profile: %v", err)
+	}
+	if _, err := repo.GetThreatProfile(profile.ID); !errors.Is(err, ErrThreatProfileNotFound) {
+		t.Errorf("Expected threat profile not found, got %v", err)
+	}
+}
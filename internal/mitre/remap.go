@@ -0,0 +1,214 @@
+package mitre
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// Remap reasons
+const (
+	RemapRevoked    = "revoked"
+	RemapDeprecated = "deprecated"
+)
+
+// ProposeRemaps lists the detection mappings pointing at revoked or
+// deprecated techniques. A revoked technique is replaced by following its
+// revoked-by chain to a current technique in the catalog; deprecated
+// techniques, and revoked ones whose replacement is not in the catalog, have
+// no replacement and need remapping by hand.
+func (r *Repository) ProposeRemaps() ([]*models.TechniqueRemap, error) {
+	return proposeRemaps(r.db)
+}
+
+func proposeRemaps(q database.Queryer) ([]*models.TechniqueRemap, error) {
+	catalog, err := retiredCatalog(q)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`
+		SELECT d.id, d.name, d.status, t.id, t.name, t.revoked
+		FROM detection_mitre_map m
+		JOIN detections d ON d.id = m.detection_id
+		JOIN mitre_techniques t ON t.id = m.mitre_id
+		WHERE t.revoked = 1 OR t.deprecated = 1
+		ORDER BY t.id, d.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying retired technique mappings: %w", err)
+	}
+	defer rows.Close()
+
+	remaps := make([]*models.TechniqueRemap, 0)
+	for rows.Next() {
+		var remap models.TechniqueRemap
+		var revoked bool
+		if err := rows.Scan(&remap.DetectionID, &remap.DetectionName, &remap.DetectionStatus,
+			&remap.TechniqueID, &remap.TechniqueName, &revoked); err != nil {
+			return nil, fmt.Errorf("error scanning technique mapping row: %w", err)
+		}
+
+		remap.Reason = RemapDeprecated
+		if revoked {
+			remap.Reason = RemapRevoked
+			if replacement := replacementFor(catalog, remap.TechniqueID); replacement != nil {
+				remap.ReplacementID = replacement.ID
+				remap.ReplacementName = replacement.Name
+			}
+		}
+		remaps = append(remaps, &remap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating technique mapping rows: %w", err)
+	}
+
+	return remaps, nil
+}
+
+// retiredCatalog loads the ID, name and retirement state of every technique,
+// enough to follow revoked-by chains
+func retiredCatalog(q database.Queryer) (map[string]*models.MitreTechnique, error) {
+	rows, err := q.Query(`SELECT id, name, revoked, deprecated, revoked_by FROM mitre_techniques`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying MITRE techniques: %w", err)
+	}
+	defer rows.Close()
+
+	catalog := make(map[string]*models.MitreTechnique)
+	for rows.Next() {
+		var technique models.MitreTechnique
+		var revokedBy sql.NullString
+		if err := rows.Scan(&technique.ID, &technique.Name, &technique.Revoked, &technique.Deprecated, &revokedBy); err != nil {
+			return nil, fmt.Errorf("error scanning MITRE technique row: %w", err)
+		}
+		technique.RevokedBy = revokedBy.String
+		catalog[technique.ID] = &technique
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating MITRE technique rows: %w", err)
+	}
+
+	return catalog, nil
+}
+
+// replacementFor follows the revoked-by chain of a technique to the first
+// technique that is neither revoked nor deprecated, or returns nil
+func replacementFor(catalog map[string]*models.MitreTechnique, id string) *models.MitreTechnique {
+	seen := map[string]bool{id: true}
+	technique := catalog[id]
+	for technique != nil && technique.Revoked {
+		next := technique.RevokedBy
+		if next == "" || seen[next] {
+			return nil
+		}
+		seen[next] = true
+		technique = catalog[next]
+	}
+	if technique == nil || technique.ID == id || technique.Deprecated {
+		return nil
+	}
+	return technique
+}
+
+// ApplyRemaps moves the mappings of revoked techniques onto their
+// replacements in a single transaction and records each move. Only the given
+// techniques are remapped, or every revoked technique with a replacement when
+// none are given. Mappings without a replacement are left alone.
+func (r *Repository) ApplyRemaps(techniqueIDs []string, appliedBy string) ([]*models.AppliedRemap, error) {
+	only := make(map[string]bool, len(techniqueIDs))
+	for _, id := range techniqueIDs {
+		only[id] = true
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	proposals, err := proposeRemaps(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]*models.AppliedRemap, 0)
+	for _, proposal := range proposals {
+		if proposal.ReplacementID == "" || (len(only) > 0 && !only[proposal.TechniqueID]) {
+			continue
+		}
+
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO detection_mitre_map (detection_id, mitre_id) VALUES (?, ?)`,
+			proposal.DetectionID, proposal.ReplacementID,
+		); err != nil {
+			return nil, fmt.Errorf("error mapping detection %d to %s: %w", proposal.DetectionID, proposal.ReplacementID, err)
+		}
+		if _, err := tx.Exec(
+			`DELETE FROM detection_mitre_map WHERE detection_id = ? AND mitre_id = ?`,
+			proposal.DetectionID, proposal.TechniqueID,
+		); err != nil {
+			return nil, fmt.Errorf("error unmapping detection %d from %s: %w", proposal.DetectionID, proposal.TechniqueID, err)
+		}
+
+		remap := &models.AppliedRemap{
+			DetectionID:    proposal.DetectionID,
+			OldTechniqueID: proposal.TechniqueID,
+			NewTechniqueID: proposal.ReplacementID,
+			AppliedBy:      appliedBy,
+			AppliedAt:      time.Now(),
+		}
+		result, err := tx.Exec(
+			`INSERT INTO mitre_remaps (detection_id, old_technique_id, new_technique_id, applied_by, applied_at)
+             VALUES (?, ?, ?, ?, ?)`,
+			remap.DetectionID, remap.OldTechniqueID, remap.NewTechniqueID, remap.AppliedBy, remap.AppliedAt.Format(time.RFC3339),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error recording remap: %w", err)
+		}
+		if remap.ID, err = result.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("error getting last insert ID: %w", err)
+		}
+		applied = append(applied, remap)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return applied, nil
+}
+
+// ListAppliedRemaps returns the remaps applied so far, newest first
+func (r *Repository) ListAppliedRemaps() ([]*models.AppliedRemap, error) {
+	rows, err := r.db.Query(`
+		SELECT id, detection_id, old_technique_id, new_technique_id, applied_by, applied_at
+		FROM mitre_remaps
+		ORDER BY applied_at DESC, id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying remaps: %w", err)
+	}
+	defer rows.Close()
+
+	remaps := make([]*models.AppliedRemap, 0)
+	for rows.Next() {
+		var remap models.AppliedRemap
+		var appliedBy sql.NullString
+		var appliedAt string
+		if err := rows.Scan(&remap.ID, &remap.DetectionID, &remap.OldTechniqueID, &remap.NewTechniqueID, &appliedBy, &appliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning remap row: %w", err)
+		}
+		remap.AppliedBy = appliedBy.String
+		remap.AppliedAt, _ = time.Parse(time.RFC3339, appliedAt)
+		remaps = append(remaps, &remap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating remap rows: %w", err)
+	}
+
+	return remaps, nil
+}
@@ -0,0 +1,97 @@
+package mitre
+
+import (
+	"errors"
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+// createMappedDetection creates a production detection mapped to techniques
+func createMappedDetection(t *testing.T, repo *Repository, name string, techniqueIDs ...string) int64 {
+	result, err := repo.db.Exec("INSERT INTO detections (name, status, severity, risk_points) VALUES (?, 'production', 'high', 40)", name)
+	if err != nil {
+		t.Fatalf("Failed to create test detection: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	for _, techniqueID := range techniqueIDs {
+		if _, err := repo.db.Exec("INSERT INTO detection_mitre_map (detection_id, mitre_id) VALUES (?, ?)", id, techniqueID); err != nil {
+			t.Fatalf("Failed to map detection: %v", err)
+		}
+	}
+	return id
+}
+
+func TestRepository_ApplyRemaps(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	// T1086 was revoked by T1154, itself since revoked by T1059.001; T1064
+	// was deprecated without a replacement
+	for _, technique := range []*models.MitreTechnique{
+		{ID: "T1059.001", Name: "PowerShell", Tactic: "Execution", IsSubTechnique: true, SubTechniqueOf: "T1059"},
+		{ID: "T1154", Name: "Trap", Tactic: "Execution", Revoked: true, RevokedBy: "T1059.001"},
+		{ID: "T1086", Name: "PowerShell", Tactic: "Execution", Revoked: true, RevokedBy: "T1154"},
+		{ID: "T1064", Name: "Scripting", Tactic: "Defense Evasion", Deprecated: true},
+	} {
+		if err := repo.CreateMitreTechnique(technique); err != nil {
+			t.Fatalf("Failed to create technique: %v", err)
+		}
+	}
+	encoded := createMappedDetection(t, repo, "Encoded PowerShell", "T1086", "T1064")
+	cradle := createMappedDetection(t, repo, "PowerShell Download Cradle", "T1086", "T1059.001")
+
+	// A technique detections are mapped to cannot be deleted
+	if err := repo.DeleteMitreTechnique("T1086"); !errors.Is(err, ErrTechniqueInUse) {
+		t.Errorf("Expected technique in use error, got %v", err)
+	}
+
+	proposals, err := repo.ProposeRemaps()
+	if err != nil {
+		t.Fatalf("Failed to propose remaps: %v", err)
+	}
+	if len(proposals) != 3 {
+		t.Fatalf("Expected 3 proposals, got %d", len(proposals))
+	}
+	if deprecated := proposals[0]; deprecated.TechniqueID != "T1064" || deprecated.Reason != RemapDeprecated || deprecated.ReplacementID != "" {
+		t.Errorf("Unexpected proposal %+v", deprecated)
+	}
+	for _, revoked := range proposals[1:] {
+		if revoked.TechniqueID != "T1086" || revoked.Reason != RemapRevoked || revoked.ReplacementID != "T1059.001" {
+			t.Errorf("Unexpected proposal %+v", revoked)
+		}
+	}
+
+	applied, err := repo.ApplyRemaps(nil, "alice")
+	if err != nil {
+		t.Fatalf("Failed to apply remaps: %v", err)
+	}
+	if len(applied) != 2 || applied[0].DetectionID != encoded || applied[1].DetectionID != cradle || applied[0].AppliedBy != "alice" {
+		t.Fatalf("Unexpected applied remaps %+v", applied)
+	}
+
+	// The detections moved to T1059.001, the one already mapped there keeping
+	// a single mapping, and only the deprecated mapping is left
+	var mapped int
+	if err := db.QueryRow("SELECT COUNT(*) FROM detection_mitre_map WHERE mitre_id = 'T1059.001'").Scan(&mapped); err != nil {
+		t.Fatalf("Failed to count mappings: %v", err)
+	}
+	if mapped != 2 {
+		t.Errorf("Expected 2 detections on T1059.001, got %d", mapped)
+	}
+	if proposals, err = repo.ProposeRemaps(); err != nil || len(proposals) != 1 || proposals[0].TechniqueID != "T1064" {
+		t.Errorf("Expected only the deprecated mapping left, got %+v (%v)", proposals, err)
+	}
+
+	history, err := repo.ListAppliedRemaps()
+	if err != nil {
+		t.Fatalf("Failed to list remaps: %v", err)
+	}
+	if len(history) != 2 || history[0].OldTechniqueID != "T1086" || history[0].NewTechniqueID != "T1059.001" || history[0].AppliedAt.IsZero() {
+		t.Errorf("Unexpected remap history %+v", history)
+	}
+
+	if err := repo.DeleteMitreTechnique("T1086"); err != nil {
+		t.Errorf("Failed to delete remapped technique: %v", err)
+	}
+}
@@ -3,12 +3,31 @@ package mitre
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"riskmatrix/pkg/database"
 	"riskmatrix/pkg/models"
 )
 
+var (
+	// ErrTechniqueInUse is returned when deleting a technique detections are
+	// still mapped to
+	ErrTechniqueInUse = errors.New("MITRE technique is mapped to detections")
+
+	// ErrPriorityListNotFound is returned for unknown priority lists
+	ErrPriorityListNotFound = errors.New("priority list not found")
+
+	// ErrThreatGroupNotFound is returned for unknown threat groups
+	ErrThreatGroupNotFound = errors.New("threat group not found")
+
+	// ErrThreatProfileNotFound is returned for unknown threat profiles
+	ErrThreatProfileNotFound = errors.New("threat profile not found")
+
+	// ErrThreatProfileExists is returned when a threat profile name is taken
+	ErrThreatProfileExists = errors.New("threat profile already exists")
+)
+
 // Repository implements the models.MitreRepository interface
 type Repository struct {
 	db *database.DB
@@ -19,17 +38,16 @@ func NewRepository(db *database.DB) *Repository {
 	return &Repository{db: db}
 }
 
-// GetMitreTechnique retrieves a MITRE technique by ID
-func (r *Repository) GetMitreTechnique(id string) (*models.MitreTechnique, error) {
-	query := `SELECT id, name, description, tactic, tactics, domain, last_modified, 
-	          detection, platforms, data_sources, is_sub_technique, sub_technique_of 
-	          FROM mitre_techniques WHERE id = ?`
-
-	row := r.db.QueryRow(query, id)
+const techniqueColumns = `id, name, description, tactic, tactics, domain, last_modified,
+	detection, platforms, data_sources, is_sub_technique, sub_technique_of, revoked, deprecated,
+	revoked_by, attack_version`
 
+// scanTechnique scans a MITRE technique row
+func scanTechnique(row database.RowScanner) (*models.MitreTechnique, error) {
 	var technique models.MitreTechnique
 	var tacticsJSON, platformsJSON, dataSourcesJSON sql.NullString
 	var description, domain, lastModified, detection, subTechniqueOf sql.NullString
+	var revokedBy, attackVersion sql.NullString
 
 	err := row.Scan(
 		&technique.ID,
@@ -44,13 +62,13 @@ func (r *Repository) GetMitreTechnique(id string) (*models.MitreTechnique, error
 		&dataSourcesJSON,
 		&technique.IsSubTechnique,
 		&subTechniqueOf,
+		&technique.Revoked,
+		&technique.Deprecated,
+		&revokedBy,
+		&attackVersion,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("MITRE technique not found: %s", id)
-		}
-		return nil, fmt.Errorf("error scanning MITRE technique: %w", err)
+		return nil, err
 	}
 
 	// Handle nullable fields
@@ -69,6 +87,12 @@ func (r *Repository) GetMitreTechnique(id string) (*models.MitreTechnique, error
 	if subTechniqueOf.Valid {
 		technique.SubTechniqueOf = subTechniqueOf.String
 	}
+	if revokedBy.Valid {
+		technique.RevokedBy = revokedBy.String
+	}
+	if attackVersion.Valid {
+		technique.AttackVersion = attackVersion.String
+	}
 
 	// Parse JSON arrays
 	if tacticsJSON.Valid && tacticsJSON.String != "" {
@@ -92,10 +116,25 @@ func (r *Repository) GetMitreTechnique(id string) (*models.MitreTechnique, error
 	return &technique, nil
 }
 
+// GetMitreTechnique retrieves a MITRE technique by ID
+func (r *Repository) GetMitreTechnique(id string) (*models.MitreTechnique, error) {
+	query := `SELECT ` + techniqueColumns + `
+	          FROM mitre_techniques WHERE id = ?`
+
+	technique, err := scanTechnique(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("MITRE technique not found: %s", id)
+		}
+		return nil, fmt.Errorf("error scanning MITRE technique: %w", err)
+	}
+
+	return technique, nil
+}
+
 // ListMitreTechniques retrieves all MITRE techniques
 func (r *Repository) ListMitreTechniques() ([]*models.MitreTechnique, error) {
-	query := `SELECT id, name, description, tactic, tactics, domain, last_modified, 
-	          detection, platforms, data_sources, is_sub_technique, sub_technique_of 
+	query := `SELECT ` + techniqueColumns + `
 	          FROM mitre_techniques ORDER BY tactic, id`
 
 	rows, err := r.db.Query(query)
@@ -107,66 +146,12 @@ func (r *Repository) ListMitreTechniques() ([]*models.MitreTechnique, error) {
 	var techniques []*models.MitreTechnique
 
 	for rows.Next() {
-		var technique models.MitreTechnique
-		var tacticsJSON, platformsJSON, dataSourcesJSON sql.NullString
-		var description, domain, lastModified, detection, subTechniqueOf sql.NullString
-
-		err := rows.Scan(
-			&technique.ID,
-			&technique.Name,
-			&description,
-			&technique.Tactic,
-			&tacticsJSON,
-			&domain,
-			&lastModified,
-			&detection,
-			&platformsJSON,
-			&dataSourcesJSON,
-			&technique.IsSubTechnique,
-			&subTechniqueOf,
-		)
-
+		technique, err := scanTechnique(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning MITRE technique row: %w", err)
 		}
 
-		// Handle nullable fields
-		if description.Valid {
-			technique.Description = description.String
-		}
-		if domain.Valid {
-			technique.Domain = domain.String
-		}
-		if lastModified.Valid {
-			technique.LastModified = lastModified.String
-		}
-		if detection.Valid {
-			technique.Detection = detection.String
-		}
-		if subTechniqueOf.Valid {
-			technique.SubTechniqueOf = subTechniqueOf.String
-		}
-
-		// Parse JSON arrays
-		if tacticsJSON.Valid && tacticsJSON.String != "" {
-			if err := json.Unmarshal([]byte(tacticsJSON.String), &technique.Tactics); err != nil {
-				return nil, fmt.Errorf("error parsing tactics JSON: %w", err)
-			}
-		}
-
-		if platformsJSON.Valid && platformsJSON.String != "" {
-			if err := json.Unmarshal([]byte(platformsJSON.String), &technique.Platforms); err != nil {
-				return nil, fmt.Errorf("error parsing platforms JSON: %w", err)
-			}
-		}
-
-		if dataSourcesJSON.Valid && dataSourcesJSON.String != "" {
-			if err := json.Unmarshal([]byte(dataSourcesJSON.String), &technique.DataSources); err != nil {
-				return nil, fmt.Errorf("error parsing data sources JSON: %w", err)
-			}
-		}
-
-		techniques = append(techniques, &technique)
+		techniques = append(techniques, technique)
 	}
 
 	return techniques, nil
@@ -174,8 +159,7 @@ func (r *Repository) ListMitreTechniques() ([]*models.MitreTechnique, error) {
 
 // ListMitreTechniquesByTactic retrieves MITRE techniques by tactic
 func (r *Repository) ListMitreTechniquesByTactic(tactic string) ([]*models.MitreTechnique, error) {
-	query := `SELECT id, name, description, tactic, tactics, domain, last_modified, 
-	          detection, platforms, data_sources, is_sub_technique, sub_technique_of 
+	query := `SELECT ` + techniqueColumns + `
 	          FROM mitre_techniques WHERE tactic = ? ORDER BY id`
 
 	rows, err := r.db.Query(query, tactic)
@@ -187,78 +171,33 @@ func (r *Repository) ListMitreTechniquesByTactic(tactic string) ([]*models.Mitre
 	var techniques []*models.MitreTechnique
 
 	for rows.Next() {
-		var technique models.MitreTechnique
-		var tacticsJSON, platformsJSON, dataSourcesJSON sql.NullString
-		var description, domain, lastModified, detection, subTechniqueOf sql.NullString
-
-		err := rows.Scan(
-			&technique.ID,
-			&technique.Name,
-			&description,
-			&technique.Tactic,
-			&tacticsJSON,
-			&domain,
-			&lastModified,
-			&detection,
-			&platformsJSON,
-			&dataSourcesJSON,
-			&technique.IsSubTechnique,
-			&subTechniqueOf,
-		)
-
+		technique, err := scanTechnique(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning MITRE technique row: %w", err)
 		}
 
-		// Handle nullable fields
-		if description.Valid {
-			technique.Description = description.String
-		}
-		if domain.Valid {
-			technique.Domain = domain.String
-		}
-		if lastModified.Valid {
-			technique.LastModified = lastModified.String
-		}
-		if detection.Valid {
-			technique.Detection = detection.String
-		}
-		if subTechniqueOf.Valid {
-			technique.SubTechniqueOf = subTechniqueOf.String
-		}
-
-		// Parse JSON arrays
-		if tacticsJSON.Valid && tacticsJSON.String != "" {
-			if err := json.Unmarshal([]byte(tacticsJSON.String), &technique.Tactics); err != nil {
-				return nil, fmt.Errorf("error parsing tactics JSON: %w", err)
-			}
-		}
-
-		if platformsJSON.Valid && platformsJSON.String != "" {
-			if err := json.Unmarshal([]byte(platformsJSON.String), &technique.Platforms); err != nil {
-				return nil, fmt.Errorf("error parsing platforms JSON: %w", err)
-			}
-		}
-
-		if dataSourcesJSON.Valid && dataSourcesJSON.String != "" {
-			if err := json.Unmarshal([]byte(dataSourcesJSON.String), &technique.DataSources); err != nil {
-				return nil, fmt.Errorf("error parsing data sources JSON: %w", err)
-			}
-		}
-
-		techniques = append(techniques, &technique)
+		techniques = append(techniques, technique)
 	}
 
 	return techniques, nil
 }
 
-// CreateMitreTechnique creates a new MITRE technique
-func (r *Repository) CreateMitreTechnique(technique *models.MitreTechnique) error {
-	query := `INSERT INTO mitre_techniques (
-		id, name, description, tactic, tactics, domain, last_modified, 
-		detection, platforms, data_sources, is_sub_technique, sub_technique_of
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
+const insertTechniqueQuery = `INSERT INTO mitre_techniques (
+	id, name, description, tactic, tactics, domain, last_modified,
+	detection, platforms, data_sources, is_sub_technique, sub_technique_of, revoked, deprecated,
+	revoked_by, attack_version
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+const updateTechniqueQuery = `UPDATE mitre_techniques SET
+	name = ?, description = ?, tactic = ?, tactics = ?, domain = ?,
+	last_modified = ?, detection = ?, platforms = ?, data_sources = ?,
+	is_sub_technique = ?, sub_technique_of = ?, revoked = ?, deprecated = ?,
+	revoked_by = ?, attack_version = ?
+	WHERE id = ?`
+
+// techniqueArgs returns the column values of a technique in insert order,
+// after the ID, with slice fields encoded as JSON
+func techniqueArgs(technique *models.MitreTechnique) ([]interface{}, error) {
 	// Convert slice fields to JSON
 	var tacticsJSON, platformsJSON, dataSourcesJSON []byte
 	var err error
@@ -266,27 +205,25 @@ func (r *Repository) CreateMitreTechnique(technique *models.MitreTechnique) erro
 	if len(technique.Tactics) > 0 {
 		tacticsJSON, err = json.Marshal(technique.Tactics)
 		if err != nil {
-			return fmt.Errorf("error marshaling tactics to JSON: %w", err)
+			return nil, fmt.Errorf("error marshaling tactics to JSON: %w", err)
 		}
 	}
 
 	if len(technique.Platforms) > 0 {
 		platformsJSON, err = json.Marshal(technique.Platforms)
 		if err != nil {
-			return fmt.Errorf("error marshaling platforms to JSON: %w", err)
+			return nil, fmt.Errorf("error marshaling platforms to JSON: %w", err)
 		}
 	}
 
 	if len(technique.DataSources) > 0 {
 		dataSourcesJSON, err = json.Marshal(technique.DataSources)
 		if err != nil {
-			return fmt.Errorf("error marshaling data sources to JSON: %w", err)
+			return nil, fmt.Errorf("error marshaling data sources to JSON: %w", err)
 		}
 	}
 
-	_, err = r.db.Exec(
-		query,
-		technique.ID,
+	return []interface{}{
 		technique.Name,
 		technique.Description,
 		technique.Tactic,
@@ -298,8 +235,21 @@ func (r *Repository) CreateMitreTechnique(technique *models.MitreTechnique) erro
 		string(dataSourcesJSON),
 		technique.IsSubTechnique,
 		technique.SubTechniqueOf,
-	)
+		technique.Revoked,
+		technique.Deprecated,
+		technique.RevokedBy,
+		technique.AttackVersion,
+	}, nil
+}
 
+// CreateMitreTechnique creates a new MITRE technique
+func (r *Repository) CreateMitreTechnique(technique *models.MitreTechnique) error {
+	args, err := techniqueArgs(technique)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(insertTechniqueQuery, append([]interface{}{technique.ID}, args...)...)
 	if err != nil {
 		return fmt.Errorf("error creating MITRE technique: %w", err)
 	}
@@ -309,53 +259,12 @@ func (r *Repository) CreateMitreTechnique(technique *models.MitreTechnique) erro
 
 // UpdateMitreTechnique updates an existing MITRE technique
 func (r *Repository) UpdateMitreTechnique(technique *models.MitreTechnique) error {
-	query := `UPDATE mitre_techniques SET 
-		name = ?, description = ?, tactic = ?, tactics = ?, domain = ?, 
-		last_modified = ?, detection = ?, platforms = ?, data_sources = ?, 
-		is_sub_technique = ?, sub_technique_of = ? 
-		WHERE id = ?`
-
-	// Convert slice fields to JSON
-	var tacticsJSON, platformsJSON, dataSourcesJSON []byte
-	var err error
-
-	if len(technique.Tactics) > 0 {
-		tacticsJSON, err = json.Marshal(technique.Tactics)
-		if err != nil {
-			return fmt.Errorf("error marshaling tactics to JSON: %w", err)
-		}
-	}
-
-	if len(technique.Platforms) > 0 {
-		platformsJSON, err = json.Marshal(technique.Platforms)
-		if err != nil {
-			return fmt.Errorf("error marshaling platforms to JSON: %w", err)
-		}
-	}
-
-	if len(technique.DataSources) > 0 {
-		dataSourcesJSON, err = json.Marshal(technique.DataSources)
-		if err != nil {
-			return fmt.Errorf("error marshaling data sources to JSON: %w", err)
-		}
+	args, err := techniqueArgs(technique)
+	if err != nil {
+		return err
 	}
 
-	result, err := r.db.Exec(
-		query,
-		technique.Name,
-		technique.Description,
-		technique.Tactic,
-		string(tacticsJSON),
-		technique.Domain,
-		technique.LastModified,
-		technique.Detection,
-		string(platformsJSON),
-		string(dataSourcesJSON),
-		technique.IsSubTechnique,
-		technique.SubTechniqueOf,
-		technique.ID,
-	)
-
+	result, err := r.db.Exec(updateTechniqueQuery, append(args, technique.ID)...)
 	if err != nil {
 		return fmt.Errorf("error updating MITRE technique: %w", err)
 	}
@@ -372,8 +281,19 @@ func (r *Repository) UpdateMitreTechnique(technique *models.MitreTechnique) erro
 	return nil
 }
 
-// DeleteMitreTechnique deletes a MITRE technique
+// DeleteMitreTechnique deletes a MITRE technique. A technique detections are
+// mapped to is refused with ErrTechniqueInUse, rather than dropping the
+// mappings with it; remap the detections first.
 func (r *Repository) DeleteMitreTechnique(id string) error {
+	var mapped int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM detection_mitre_map WHERE mitre_id = ?`, id).Scan(&mapped)
+	if err != nil {
+		return fmt.Errorf("error counting technique mappings: %w", err)
+	}
+	if mapped > 0 {
+		return fmt.Errorf("%w: %s has %d detections", ErrTechniqueInUse, id, mapped)
+	}
+
 	query := `DELETE FROM mitre_techniques WHERE id = ?`
 
 	result, err := r.db.Exec(query, id)
@@ -393,47 +313,10 @@ func (r *Repository) DeleteMitreTechnique(id string) error {
 	return nil
 }
 
-// GetCoverageByTactic returns the coverage percentage by tactic
+// GetCoverageByTactic returns the coverage percentage by tactic, counting
+// any detection that is not retired
 func (r *Repository) GetCoverageByTactic() (map[string]float64, error) {
-	query := `
-		SELECT 
-			mt.tactic,
-			COUNT(DISTINCT mt.id) as total,
-			COUNT(DISTINCT dmm.mitre_id) as covered
-		FROM 
-			mitre_techniques mt
-		LEFT JOIN 
-			detection_mitre_map dmm ON mt.id = dmm.mitre_id
-		LEFT JOIN
-			detections d ON dmm.detection_id = d.id AND d.status = 'production'
-		GROUP BY 
-			mt.tactic
-	`
-
-	rows, err := r.db.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("error querying coverage by tactic: %w", err)
-	}
-	defer rows.Close()
-
-	coverage := make(map[string]float64)
-
-	for rows.Next() {
-		var tactic string
-		var total, covered int
-
-		if err := rows.Scan(&tactic, &total, &covered); err != nil {
-			return nil, fmt.Errorf("error scanning coverage row: %w", err)
-		}
-
-		if total > 0 {
-			coverage[tactic] = float64(covered) / float64(total) * 100
-		} else {
-			coverage[tactic] = 0
-		}
-	}
-
-	return coverage, nil
+	return r.CoverageByTactic(models.CoverageAny)
 }
 
 // GetDetectionsByTechnique returns detections that cover a specific technique
@@ -0,0 +1,277 @@
+package mitre
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+// stixSources are the external reference sources holding ATT&CK IDs in the
+// enterprise, mobile and ICS bundles
+var stixSources = map[string]bool{
+	"mitre-attack":        true,
+	"mitre-mobile-attack": true,
+	"mitre-ics-attack":    true,
+}
+
+// lastModifiedLayout is the date format of technique modification dates,
+// matching the CSV export
+const lastModifiedLayout = "02-Jan-06"
+
+// stixObject holds the fields of the STIX objects a bundle is read for:
+// attack-patterns, intrusion sets, tactics, data sources, data components
+// and relationships
+type stixObject struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Modified    string `json:"modified"`
+	Revoked     bool   `json:"revoked"`
+
+	Aliases []string `json:"aliases"`
+
+	ExternalReferences []struct {
+		SourceName string `json:"source_name"`
+		ExternalID string `json:"external_id"`
+	} `json:"external_references"`
+	KillChainPhases []struct {
+		KillChainName string `json:"kill_chain_name"`
+		PhaseName     string `json:"phase_name"`
+	} `json:"kill_chain_phases"`
+
+	Deprecated     bool     `json:"x_mitre_deprecated"`
+	IsSubtechnique bool     `json:"x_mitre_is_subtechnique"`
+	Detection      string   `json:"x_mitre_detection"`
+	Platforms      []string `json:"x_mitre_platforms"`
+	DataSources    []string `json:"x_mitre_data_sources"`
+	Domains        []string `json:"x_mitre_domains"`
+	ShortName      string   `json:"x_mitre_shortname"`
+	DataSourceRef  string   `json:"x_mitre_data_source_ref"`
+	Version        string   `json:"x_mitre_version"`
+
+	RelationshipType string `json:"relationship_type"`
+	SourceRef        string `json:"source_ref"`
+	TargetRef        string `json:"target_ref"`
+}
+
+// attackID returns the ATT&CK ID of an object, such as T1059.001 or TA0002
+func (o *stixObject) attackID() string {
+	for _, ref := range o.ExternalReferences {
+		if stixSources[ref.SourceName] && ref.ExternalID != "" {
+			return ref.ExternalID
+		}
+	}
+	return ""
+}
+
+// Bundle is the content of a MITRE ATT&CK STIX bundle
+type Bundle struct {
+	// Version is the ATT&CK release of the bundle's collection, e.g. 17.1,
+	// or empty for bundles without a collection object
+	Version    string
+	Techniques []*models.MitreTechnique
+	Groups     []*models.ThreatGroup
+}
+
+// ParseBundle reads the techniques of a MITRE ATT&CK STIX 2.1 bundle, such
+// as enterprise-attack.json. Tactics are named from the bundle's tactic
+// objects, sub-techniques are linked to their parents through subtechnique-of
+// relationships, and data sources are the data components detecting each
+// technique, as "Data Source: Data Component", falling back to the
+// technique's own data source list in older bundles. Revoked and deprecated
+// techniques are included, flagged, with revoked techniques pointing at
+// their replacement through revoked-by relationships. Every technique is
+// stamped with the ATT&CK version of the bundle's collection. Groups are read
+// from the current intrusion sets, with the techniques of their uses
+// relationships.
+func ParseBundle(r io.Reader) (*Bundle, error) {
+	var bundle struct {
+		Type    string        `json:"type"`
+		Objects []*stixObject `json:"objects"`
+	}
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("error decoding STIX bundle: %w", err)
+	}
+	if bundle.Type != "bundle" {
+		return nil, fmt.Errorf("not a STIX bundle: type %q", bundle.Type)
+	}
+
+	result := &Bundle{
+		Techniques: make([]*models.MitreTechnique, 0),
+		Groups:     make([]*models.ThreatGroup, 0),
+	}
+	objects := make(map[string]*stixObject, len(bundle.Objects))
+	tactics := make(map[string]string)
+	for _, object := range bundle.Objects {
+		objects[object.ID] = object
+		switch {
+		case object.Type == "x-mitre-tactic" && object.ShortName != "":
+			tactics[object.ShortName] = object.Name
+		case object.Type == "x-mitre-collection" && result.Version == "":
+			result.Version = object.Version
+		}
+	}
+
+	parents := make(map[string]string)
+	replacements := make(map[string]string)
+	uses := make(map[string][]string)
+	components := make(map[string][]string)
+	for _, object := range bundle.Objects {
+		if object.Type != "relationship" || object.Revoked || object.Deprecated {
+			continue
+		}
+		switch object.RelationshipType {
+		case "subtechnique-of":
+			if parent, ok := objects[object.TargetRef]; ok {
+				parents[object.SourceRef] = parent.attackID()
+			}
+		case "uses":
+			technique, ok := objects[object.TargetRef]
+			if !ok || technique.Type != "attack-pattern" || !strings.HasPrefix(object.SourceRef, "intrusion-set--") {
+				continue
+			}
+			if id := technique.attackID(); id != "" {
+				uses[object.SourceRef] = appendUnique(uses[object.SourceRef], id)
+			}
+		case "revoked-by":
+			if replacement, ok := objects[object.TargetRef]; ok {
+				replacements[object.SourceRef] = replacement.attackID()
+			}
+		case "detects":
+			component, ok := objects[object.SourceRef]
+			if !ok || component.Type != "x-mitre-data-component" {
+				continue
+			}
+			name := component.Name
+			if source, ok := objects[component.DataSourceRef]; ok {
+				name = source.Name + ": " + component.Name
+			}
+			components[object.TargetRef] = append(components[object.TargetRef], name)
+		}
+	}
+
+	for _, object := range bundle.Objects {
+		if object.Type != "attack-pattern" {
+			continue
+		}
+		id := object.attackID()
+		if id == "" {
+			continue
+		}
+
+		technique := &models.MitreTechnique{
+			ID:             id,
+			Name:           object.Name,
+			Description:    object.Description,
+			Detection:      object.Detection,
+			Platforms:      object.Platforms,
+			IsSubTechnique: object.IsSubtechnique,
+			Revoked:        object.Revoked,
+			Deprecated:     object.Deprecated,
+			AttackVersion:  result.Version,
+		}
+		if technique.Revoked {
+			technique.RevokedBy = replacements[object.ID]
+		}
+
+		if len(object.Domains) > 0 {
+			technique.Domain = object.Domains[0]
+		}
+		if modified, err := time.Parse(time.RFC3339, object.Modified); err == nil {
+			technique.LastModified = modified.Format(lastModifiedLayout)
+		}
+
+		for _, phase := range object.KillChainPhases {
+			if !stixSources[phase.KillChainName] {
+				continue
+			}
+			name, ok := tactics[phase.PhaseName]
+			if !ok {
+				name = tacticName(phase.PhaseName)
+			}
+			technique.Tactics = appendUnique(technique.Tactics, name)
+		}
+		if len(technique.Tactics) > 0 {
+			technique.Tactic = technique.Tactics[0]
+		}
+
+		if technique.IsSubTechnique {
+			technique.SubTechniqueOf = parents[object.ID]
+			if technique.SubTechniqueOf == "" {
+				technique.SubTechniqueOf, _, _ = strings.Cut(id, ".")
+			}
+		}
+
+		if names := components[object.ID]; len(names) > 0 {
+			sort.Strings(names)
+			for _, name := range names {
+				technique.DataSources = appendUnique(technique.DataSources, name)
+			}
+		} else {
+			technique.DataSources = object.DataSources
+		}
+
+		result.Techniques = append(result.Techniques, technique)
+	}
+
+	for _, object := range bundle.Objects {
+		if object.Type != "intrusion-set" || object.Revoked || object.Deprecated {
+			continue
+		}
+		id := object.attackID()
+		if id == "" {
+			continue
+		}
+
+		group := &models.ThreatGroup{
+			ID:            id,
+			Name:          object.Name,
+			Description:   object.Description,
+			AttackVersion: result.Version,
+			Techniques:    uses[object.ID],
+		}
+		// The first alias is the group's own name
+		for _, alias := range object.Aliases {
+			if alias != object.Name {
+				group.Aliases = append(group.Aliases, alias)
+			}
+		}
+		sort.Strings(group.Techniques)
+		group.TechniqueCount = len(group.Techniques)
+		result.Groups = append(result.Groups, group)
+	}
+
+	techniques := result.Techniques
+	sort.Slice(techniques, func(i, j int) bool { return techniques[i].ID < techniques[j].ID })
+	groups := result.Groups
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return result, nil
+}
+
+// tacticName turns a kill chain phase name such as privilege-escalation into
+// a tactic name, for bundles without tactic objects
+func tacticName(phase string) string {
+	words := strings.Split(phase, "-")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// appendUnique appends value to values unless it is already there
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
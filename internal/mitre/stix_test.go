@@ -0,0 +1,157 @@
+package mitre
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+// testBundle is a trimmed enterprise ATT&CK 17.1 bundle: a technique with a
+// sub-technique detected by a data component, a technique revoked by the
+// sub-technique, a deprecated one and a group using the sub-technique
+const testBundle = `{
+  "type": "bundle",
+  "id": "bundle--1",
+  "objects": [
+    {"type": "x-mitre-collection", "id": "x-mitre-collection--1", "name": "Enterprise ATT&CK", "x_mitre_version": "17.1"},
+    {"type": "x-mitre-tactic", "id": "x-mitre-tactic--exec", "name": "Execution", "x_mitre_shortname": "execution"},
+    {"type": "attack-pattern", "id": "attack-pattern--t1059", "name": "Command and Scripting Interpreter",
+     "description": "Adversaries may abuse interpreters.", "modified": "2025-04-15T22:06:18.312Z",
+     "kill_chain_phases": [{"kill_chain_name": "mitre-attack", "phase_name": "execution"}],
+     "external_references": [{"source_name": "mitre-attack", "external_id": "T1059"}],
+     "x_mitre_platforms": ["Windows", "Linux"], "x_mitre_domains": ["enterprise-attack"]},
+    {"type": "attack-pattern", "id": "attack-pattern--t1059-001", "name": "PowerShell",
+     "modified": "2025-04-15T22:06:18.312Z", "x_mitre_is_subtechnique": true,
+     "kill_chain_phases": [{"kill_chain_name": "mitre-attack", "phase_name": "execution"}],
+     "external_references": [{"source_name": "mitre-attack", "external_id": "T1059.001"}],
+     "x_mitre_domains": ["enterprise-attack"]},
+    {"type": "relationship", "id": "relationship--1", "relationship_type": "subtechnique-of",
+     "source_ref": "attack-pattern--t1059-001", "target_ref": "attack-pattern--t1059"},
+    {"type": "x-mitre-data-source", "id": "x-mitre-data-source--cmd", "name": "Command"},
+    {"type": "x-mitre-data-component", "id": "x-mitre-data-component--exec", "name": "Command Execution",
+     "x_mitre_data_source_ref": "x-mitre-data-source--cmd"},
+    {"type": "relationship", "id": "relationship--2", "relationship_type": "detects",
+     "source_ref": "x-mitre-data-component--exec", "target_ref": "attack-pattern--t1059-001"},
+    {"type": "attack-pattern", "id": "attack-pattern--t1086", "name": "PowerShell", "revoked": true,
+     "external_references": [{"source_name": "mitre-attack", "external_id": "T1086"}]},
+    {"type": "relationship", "id": "relationship--3", "relationship_type": "revoked-by",
+     "source_ref": "attack-pattern--t1086", "target_ref": "attack-pattern--t1059-001"},
+    {"type": "attack-pattern", "id": "attack-pattern--t1064", "name": "Scripting", "x_mitre_deprecated": true,
+     "kill_chain_phases": [{"kill_chain_name": "mitre-attack", "phase_name": "defense-evasion"}],
+     "external_references": [{"source_name": "mitre-attack", "external_id": "T1064"}]},
+    {"type": "intrusion-set", "id": "intrusion-set--apt29", "name": "APT29", "aliases": ["APT29", "Cozy Bear"],
+     "external_references": [{"source_name": "mitre-attack", "external_id": "G0016"}]},
+    {"type": "relationship", "id": "relationship--4", "relationship_type": "uses",
+     "source_ref": "intrusion-set--apt29", "target_ref": "attack-pattern--t1059-001"}
+  ]
+}`
+
+func TestParseBundle(t *testing.T) {
+	bundle, err := ParseBundle(strings.NewReader(testBundle))
+	if err != nil {
+		t.Fatalf("Failed to parse bundle: %v", err)
+	}
+	if bundle.Version != "17.1" {
+		t.Errorf("Expected version 17.1, got %q", bundle.Version)
+	}
+	techniques := bundle.Techniques
+	if len(techniques) != 4 {
+		t.Fatalf("Expected 4 techniques, got %d", len(techniques))
+	}
+
+	byID := make(map[string]int)
+	for i, technique := range techniques {
+		byID[technique.ID] = i
+	}
+
+	parent := techniques[byID["T1059"]]
+	if parent.Tactic != "Execution" || parent.Domain != "enterprise-attack" || parent.LastModified != "15-Apr-25" ||
+		!reflect.DeepEqual(parent.Platforms, []string{"Windows", "Linux"}) || parent.IsSubTechnique || parent.AttackVersion != "17.1" {
+		t.Errorf("Unexpected technique %+v", parent)
+	}
+
+	sub := techniques[byID["T1059.001"]]
+	if !sub.IsSubTechnique || sub.SubTechniqueOf != "T1059" || !reflect.DeepEqual(sub.DataSources, []string{"Command: Command Execution"}) {
+		t.Errorf("Unexpected sub-technique %+v", sub)
+	}
+
+	if revoked := techniques[byID["T1086"]]; !revoked.Revoked || revoked.RevokedBy != "T1059.001" {
+		t.Errorf("Expected T1086 to be revoked by T1059.001, got %+v", revoked)
+	}
+	if deprecated := techniques[byID["T1064"]]; !deprecated.Deprecated || deprecated.Tactic != "Defense Evasion" {
+		t.Errorf("Unexpected deprecated technique %+v", deprecated)
+	}
+
+	if len(bundle.Groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(bundle.Groups))
+	}
+	if group := bundle.Groups[0]; group.ID != "G0016" || !reflect.DeepEqual(group.Aliases, []string{"Cozy Bear"}) ||
+		!reflect.DeepEqual(group.Techniques, []string{"T1059.001"}) || group.AttackVersion != "17.1" {
+		t.Errorf("Unexpected group %+v", group)
+	}
+
+	if _, err := ParseBundle(strings.NewReader(`{"type": "x-mitre-collection"}`)); err == nil {
+		t.Error("Expected error parsing a document that is not a bundle")
+	}
+}
+
+func TestRepository_ImportTechniques(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	// The catalog holds T1059 and the since revoked T1086 from an older import
+	createTestMitreTechnique(t, repo)
+	old := &models.MitreTechnique{ID: "T1086", Name: "PowerShell", Description: "Adversaries may abuse PowerShell.", Tactic: "Execution"}
+	if err := repo.CreateMitreTechnique(old); err != nil {
+		t.Fatalf("Failed to create technique: %v", err)
+	}
+
+	bundle, err := ParseBundle(strings.NewReader(testBundle))
+	if err != nil {
+		t.Fatalf("Failed to parse bundle: %v", err)
+	}
+
+	report, err := repo.ImportTechniques(bundle.Techniques, bundle.Version)
+	if err != nil {
+		t.Fatalf("Failed to import techniques: %v", err)
+	}
+	if !reflect.DeepEqual(report.Added, []string{"T1059.001"}) || !reflect.DeepEqual(report.Revoked, []string{"T1086"}) ||
+		len(report.Deprecated) != 0 || report.Skipped != 1 || len(report.Changed) != 1 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	if change := report.Changed[0]; change.ID != "T1059" ||
+		!reflect.DeepEqual(change.Fields, []string{"description", "tactics", "domain", "last_modified", "platforms"}) {
+		t.Errorf("Unexpected change %+v", change)
+	}
+
+	// A revoked technique keeps its details
+	revoked, err := repo.GetMitreTechnique("T1086")
+	if err != nil {
+		t.Fatalf("Failed to get technique: %v", err)
+	}
+	if !revoked.Revoked || revoked.RevokedBy != "T1059.001" || revoked.AttackVersion != "17.1" || revoked.Description != old.Description {
+		t.Errorf("Unexpected revoked technique %+v", revoked)
+	}
+
+	// Importing the same bundle again changes nothing, while a new release
+	// only restamps the version
+	report, err = repo.ImportTechniques(bundle.Techniques, "17.1")
+	if err != nil {
+		t.Fatalf("Failed to import techniques: %v", err)
+	}
+	if report.Unchanged != 3 || len(report.Added)+len(report.Changed)+len(report.Revoked) != 0 {
+		t.Errorf("Unexpected report on re-import %+v", report)
+	}
+	report, err = repo.ImportTechniques(bundle.Techniques, "18.0")
+	if err != nil {
+		t.Fatalf("Failed to import techniques: %v", err)
+	}
+	if report.Unchanged != 3 || report.Version != "18.0" {
+		t.Errorf("Unexpected report on new release %+v", report)
+	}
+	if technique, err := repo.GetMitreTechnique("T1059"); err != nil || technique.AttackVersion != "18.0" {
+		t.Errorf("Expected T1059 from version 18.0, got %+v (%v)", technique, err)
+	}
+}
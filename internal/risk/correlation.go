@@ -0,0 +1,470 @@
+package risk
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+// CorrelationConfig configures how entities named together in event context
+// are linked into entity groups
+type CorrelationConfig struct {
+	// Link entities that appear together in event context
+	Enabled bool
+
+	// Event context keys naming related entities, mapped to the entity type
+	// of their values (nil uses DefaultCorrelationFields)
+	Fields map[string]models.EntityType
+
+	// Combined group score at which to raise a group alert (0 uses RiskThreshold)
+	GroupThreshold int
+
+	// Largest number of entities a group may hold; links that would grow a
+	// group beyond it are skipped so that shared infrastructure does not merge
+	// unrelated incidents (0 means no limit)
+	MaxGroupSize int
+}
+
+// DefaultCorrelationFields are the event context keys read when no fields
+// are configured
+var DefaultCorrelationFields = map[string]models.EntityType{
+	"user":     models.EntityTypeUser,
+	"username": models.EntityTypeUser,
+	"host":     models.EntityTypeHost,
+	"hostname": models.EntityTypeHost,
+	"ip":       models.EntityTypeIP,
+	"src_ip":   models.EntityTypeIP,
+}
+
+// contextEntities returns the entities named by fields of a JSON event
+// context, in field order and without duplicates
+func contextEntities(context string, fields map[string]models.EntityType) []*models.RiskObject {
+	if context == "" || len(fields) == 0 {
+		return nil
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(context), &values); err != nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entities := make([]*models.RiskObject, 0)
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		value, ok := values[key].(string)
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		entityType := fields[key]
+		if seen[string(entityType)+"|"+value] {
+			continue
+		}
+		seen[string(entityType)+"|"+value] = true
+
+		entities = append(entities, &models.RiskObject{EntityType: entityType, EntityValue: value})
+	}
+
+	return entities
+}
+
+// GetEntityGroupIDTx returns the group an entity belongs to within a
+// transaction, or 0 if it belongs to none
+func (r *Repository) GetEntityGroupIDTx(tx *sql.Tx, entityID int64) (int64, error) {
+	var groupID int64
+	err := tx.QueryRow(`SELECT group_id FROM entity_group_members WHERE entity_id = ?`, entityID).Scan(&groupID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error getting entity group: %w", err)
+	}
+
+	return groupID, nil
+}
+
+// GetEntityGroupScoreTx returns the combined score and number of members of
+// an entity group within a transaction
+func (r *Repository) GetEntityGroupScoreTx(tx *sql.Tx, groupID int64) (int, int, error) {
+	var score, size int
+	err := tx.QueryRow(
+		`SELECT COALESCE(SUM(ro.current_score), 0), COUNT(*)
+         FROM entity_group_members m
+         JOIN risk_objects ro ON ro.id = m.entity_id
+         WHERE m.group_id = ?`,
+		groupID,
+	).Scan(&score, &size)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error getting entity group score: %w", err)
+	}
+
+	return score, size, nil
+}
+
+// LinkEntitiesTx places two entities in the same group within a transaction,
+// creating a group or merging the smaller group into the larger as needed.
+// Links that would grow a group beyond maxSize (when positive) are skipped.
+// It returns the group of the first entity afterwards, or 0 if it has none.
+func (r *Repository) LinkEntitiesTx(tx *sql.Tx, entityID, otherID int64, maxSize int) (int64, error) {
+	groupID, err := r.GetEntityGroupIDTx(tx, entityID)
+	if err != nil {
+		return 0, err
+	}
+	otherGroupID, err := r.GetEntityGroupIDTx(tx, otherID)
+	if err != nil {
+		return 0, err
+	}
+
+	if groupID != 0 && groupID == otherGroupID {
+		return groupID, nil
+	}
+
+	size, otherSize := 1, 1
+	if groupID != 0 {
+		if _, size, err = r.GetEntityGroupScoreTx(tx, groupID); err != nil {
+			return 0, err
+		}
+	}
+	if otherGroupID != 0 {
+		if _, otherSize, err = r.GetEntityGroupScoreTx(tx, otherGroupID); err != nil {
+			return 0, err
+		}
+	}
+	if maxSize > 0 && size+otherSize > maxSize {
+		return groupID, nil
+	}
+
+	now := time.Now().Format(time.RFC3339)
+
+	switch {
+	case groupID == 0 && otherGroupID == 0:
+		result, err := tx.Exec(`INSERT INTO entity_groups (created_at, updated_at) VALUES (?, ?)`, now, now)
+		if err != nil {
+			return 0, fmt.Errorf("error creating entity group: %w", err)
+		}
+		if groupID, err = result.LastInsertId(); err != nil {
+			return 0, fmt.Errorf("error getting last insert ID: %w", err)
+		}
+		if err := r.addEntityGroupMemberTx(tx, groupID, entityID, now); err != nil {
+			return 0, err
+		}
+		if err := r.addEntityGroupMemberTx(tx, groupID, otherID, now); err != nil {
+			return 0, err
+		}
+		return groupID, nil
+
+	case groupID == 0:
+		groupID = otherGroupID
+		if err := r.addEntityGroupMemberTx(tx, groupID, entityID, now); err != nil {
+			return 0, err
+		}
+
+	case otherGroupID == 0:
+		if err := r.addEntityGroupMemberTx(tx, groupID, otherID, now); err != nil {
+			return 0, err
+		}
+
+	default:
+		into, from := groupID, otherGroupID
+		if otherSize > size {
+			into, from = otherGroupID, groupID
+		}
+		if err := r.mergeEntityGroupsTx(tx, into, from); err != nil {
+			return 0, err
+		}
+		groupID = into
+	}
+
+	if _, err := tx.Exec(`UPDATE entity_groups SET updated_at = ? WHERE id = ?`, now, groupID); err != nil {
+		return 0, fmt.Errorf("error updating entity group: %w", err)
+	}
+
+	return groupID, nil
+}
+
+// addEntityGroupMemberTx adds an entity to a group within a transaction
+func (r *Repository) addEntityGroupMemberTx(tx *sql.Tx, groupID, entityID int64, linkedAt string) error {
+	_, err := tx.Exec(`INSERT INTO entity_group_members (entity_id, group_id, linked_at) VALUES (?, ?, ?)`, entityID, groupID, linkedAt)
+	if err != nil {
+		return fmt.Errorf("error adding entity group member: %w", err)
+	}
+
+	return nil
+}
+
+// mergeEntityGroupsTx moves the members and alerts of group from into group
+// into and deletes from, within a transaction
+func (r *Repository) mergeEntityGroupsTx(tx *sql.Tx, into, from int64) error {
+	if _, err := tx.Exec(`UPDATE entity_group_members SET group_id = ? WHERE group_id = ?`, into, from); err != nil {
+		return fmt.Errorf("error merging entity groups: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE risk_alerts SET group_id = ? WHERE group_id = ?`, into, from); err != nil {
+		return fmt.Errorf("error merging entity group alerts: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM entity_groups WHERE id = ?`, from); err != nil {
+		return fmt.Errorf("error deleting entity group: %w", err)
+	}
+
+	return nil
+}
+
+// LinkGroupWindowEventsToAlertTx links every non-false-positive event of the
+// members of a group with a timestamp at or after since to a risk alert,
+// within a transaction
+func (r *Repository) LinkGroupWindowEventsToAlertTx(tx *sql.Tx, alertID, groupID int64, since time.Time) error {
+	query := `INSERT OR IGNORE INTO risk_alert_events (alert_id, event_id, risk_points)
+              SELECT ?, id, risk_points
+              FROM events
+              WHERE entity_id IN (SELECT entity_id FROM entity_group_members WHERE group_id = ?)
+                AND is_false_positive = 0 AND datetime(timestamp) >= datetime(?)`
+
+	_, err := tx.Exec(query, alertID, groupID, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("error linking events to risk alert: %w", err)
+	}
+
+	return nil
+}
+
+// LinkGroupScoreEventsToAlertTx links the events that make up the running
+// scores of the members of a group to a risk alert, within a transaction, so
+// the stored contributions add up to the group score
+func (r *Repository) LinkGroupScoreEventsToAlertTx(tx *sql.Tx, alertID, groupID int64) error {
+	rows, err := tx.Query(
+		`SELECT ro.id, ro.current_score
+         FROM entity_group_members m
+         JOIN risk_objects ro ON ro.id = m.entity_id
+         WHERE m.group_id = ? AND ro.current_score > 0`,
+		groupID,
+	)
+	if err != nil {
+		return fmt.Errorf("error querying entity group members: %w", err)
+	}
+
+	type member struct {
+		entityID int64
+		score    int
+	}
+	members := make([]member, 0)
+	for rows.Next() {
+		var m member
+		if err := rows.Scan(&m.entityID, &m.score); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning entity group member row: %w", err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("error iterating entity group member rows: %w", err)
+	}
+	rows.Close()
+
+	for _, m := range members {
+		if err := r.LinkScoreEventsToAlertTx(tx, alertID, m.entityID, m.score); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetEntityGroup retrieves an entity group and its members by ID
+func (r *Repository) GetEntityGroup(id int64) (*models.EntityGroup, error) {
+	groups, err := r.listEntityGroups(`WHERE g.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("entity group not found: %d", id)
+	}
+
+	return groups[0], nil
+}
+
+// GetEntityGroupByEntity retrieves the group an entity belongs to, or nil if
+// it belongs to none
+func (r *Repository) GetEntityGroupByEntity(entityID int64) (*models.EntityGroup, error) {
+	groups, err := r.listEntityGroups(`WHERE g.id = (SELECT group_id FROM entity_group_members WHERE entity_id = ?)`, entityID)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	return groups[0], nil
+}
+
+// ListEntityGroups lists entity groups with their members, highest combined
+// score first
+func (r *Repository) ListEntityGroups() ([]*models.EntityGroup, error) {
+	return r.listEntityGroups("")
+}
+
+// listEntityGroups loads the groups selected by where, which may refer to the
+// entity_groups table as g
+func (r *Repository) listEntityGroups(where string, args ...interface{}) ([]*models.EntityGroup, error) {
+	query := `SELECT g.id, g.created_at, g.updated_at, ro.id, ro.entity_type, ro.entity_value, ro.current_score, ro.last_seen
+              FROM entity_groups g
+              JOIN entity_group_members m ON m.group_id = g.id
+              JOIN risk_objects ro ON ro.id = m.entity_id
+              ` + where + `
+              ORDER BY g.id, ro.current_score DESC, ro.id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying entity groups: %w", err)
+	}
+	defer rows.Close()
+
+	// Initialize empty slice to avoid nil
+	groups := make([]*models.EntityGroup, 0)
+	var group *models.EntityGroup
+
+	for rows.Next() {
+		var groupID int64
+		var createdAt, updatedAt, lastSeen string
+		var obj models.RiskObject
+
+		err := rows.Scan(
+			&groupID,
+			&createdAt,
+			&updatedAt,
+			&obj.ID,
+			&obj.EntityType,
+			&obj.EntityValue,
+			&obj.CurrentScore,
+			&lastSeen,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning entity group row: %w", err)
+		}
+
+		if group == nil || group.ID != groupID {
+			group = &models.EntityGroup{ID: groupID, Members: make([]*models.RiskObject, 0)}
+			group.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+			group.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+			groups = append(groups, group)
+		}
+
+		obj.LastSeen, _ = time.Parse(time.RFC3339, lastSeen)
+		group.Members = append(group.Members, &obj)
+		group.Score += obj.CurrentScore
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Score > groups[j].Score
+	})
+
+	return groups, nil
+}
+
+// correlateTx links the entities named in an event's context to the event's
+// entity, then alerts on the entity's group when its combined score reaches
+// the group threshold. scoreDelta is the change this event made to the
+// entity's score.
+func (e *Engine) correlateTx(tx *sql.Tx, event *models.Event, riskObject *models.RiskObject, scoreDelta int, asset *models.InventoryEntry, now time.Time) error {
+	fields := e.config.Correlation.Fields
+	if fields == nil {
+		fields = DefaultCorrelationFields
+	}
+
+	groupID, err := e.repo.GetEntityGroupIDTx(tx, riskObject.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, related := range contextEntities(event.Context, fields) {
+		if related.EntityType == riskObject.EntityType && related.EntityValue == riskObject.EntityValue {
+			continue
+		}
+
+		relatedObject, err := e.getOrCreateRiskObjectTx(tx, related.EntityType, related.EntityValue)
+		if err != nil {
+			return err
+		}
+
+		if groupID, err = e.repo.LinkEntitiesTx(tx, riskObject.ID, relatedObject.ID, e.config.Correlation.MaxGroupSize); err != nil {
+			return err
+		}
+	}
+
+	if groupID == 0 {
+		return nil
+	}
+
+	score, _, err := e.repo.GetEntityGroupScoreTx(tx, groupID)
+	if err != nil {
+		return err
+	}
+
+	threshold := e.config.Correlation.GroupThreshold
+	if threshold <= 0 {
+		threshold = e.config.RiskThreshold
+	}
+
+	latest, err := e.repo.GetLatestRiskAlertByGroupTx(tx, groupID)
+	if err != nil {
+		return err
+	}
+
+	if latest != nil && latest.Status != models.AlertStatusClosed {
+		// Attach the event to the group alert that is still being worked
+		if err := e.repo.AddAlertEventTx(tx, latest.ID, event.ID, event.RiskPoints); err != nil {
+			return err
+		}
+		return e.repo.UpdateRiskAlertScoreTx(tx, latest.ID, latest.TotalScore+event.RiskPoints)
+	}
+
+	// A group that has never alerted alerts as soon as it is over the
+	// threshold, since linking can push it over without an event crossing it
+	if score < threshold || (latest != nil && !e.shouldAlert(score-scoreDelta, threshold, latest, now)) {
+		return nil
+	}
+
+	alert := &models.RiskAlert{
+		EntityID:    riskObject.ID,
+		GroupID:     &groupID,
+		TriggeredAt: now,
+		TotalScore:  score,
+		Status:      models.AlertStatusNew,
+	}
+	if asset != nil {
+		alert.Owner = asset.Owner
+	}
+
+	if err := e.repo.CreateRiskAlertTx(tx, alert); err != nil {
+		return err
+	}
+
+	if e.config.ScoreWindow > 0 {
+		err = e.repo.LinkGroupWindowEventsToAlertTx(tx, alert.ID, groupID, e.windowStart(now))
+	} else {
+		err = e.repo.LinkGroupScoreEventsToAlertTx(tx, alert.ID, groupID)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Risk alert generated for entity group %d via %s '%s' with score %d",
+		groupID, riskObject.EntityType, riskObject.EntityValue, score)
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package risk
+
+import (
+	"testing"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestContextEntities(t *testing.T) {
+	context := `{"user": "alice", "username": "alice", "host": " ws-1 ", "src_ip": 42, "ip": ""}`
+
+	entities := contextEntities(context, DefaultCorrelationFields)
+	if len(entities) != 2 {
+		t.Fatalf("Expected 2 entities, got %d", len(entities))
+	}
+	if entities[0].EntityType != models.EntityTypeHost || entities[0].EntityValue != "ws-1" {
+		t.Errorf("Expected host ws-1, got %s '%s'", entities[0].EntityType, entities[0].EntityValue)
+	}
+	if entities[1].EntityType != models.EntityTypeUser || entities[1].EntityValue != "alice" {
+		t.Errorf("Expected user alice, got %s '%s'", entities[1].EntityType, entities[1].EntityValue)
+	}
+
+	if entities := contextEntities("not json", DefaultCorrelationFields); len(entities) != 0 {
+		t.Errorf("Expected no entities for invalid context, got %d", len(entities))
+	}
+}
+
+func TestRepository_LinkEntities(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	user := createSimpleRiskObject(t, engine, models.EntityTypeUser, "alice")
+	host := createSimpleRiskObject(t, engine, models.EntityTypeHost, "ws-1")
+	ip := createSimpleRiskObject(t, engine, models.EntityTypeIP, "10.0.0.5")
+	other := createSimpleRiskObject(t, engine, models.EntityTypeHost, "ws-2")
+
+	tx, err := engine.db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	first, err := engine.repo.LinkEntitiesTx(tx, user.ID, host.ID, 3)
+	if err != nil {
+		t.Fatalf("Failed to link entities: %v", err)
+	}
+	second, err := engine.repo.LinkEntitiesTx(tx, ip.ID, other.ID, 3)
+	if err != nil {
+		t.Fatalf("Failed to link entities: %v", err)
+	}
+	if first == 0 || second == 0 || first == second {
+		t.Fatalf("Expected two separate groups, got %d and %d", first, second)
+	}
+
+	// Merging would make a group of four, over the limit of three
+	groupID, err := engine.repo.LinkEntitiesTx(tx, user.ID, ip.ID, 3)
+	if err != nil {
+		t.Fatalf("Failed to link entities: %v", err)
+	}
+	if groupID != first {
+		t.Errorf("Expected link over the size limit to be skipped, got group %d", groupID)
+	}
+
+	groupID, err = engine.repo.LinkEntitiesTx(tx, user.ID, ip.ID, 0)
+	if err != nil {
+		t.Fatalf("Failed to link entities: %v", err)
+	}
+	_, size, err := engine.repo.GetEntityGroupScoreTx(tx, groupID)
+	if err != nil {
+		t.Fatalf("Failed to get group score: %v", err)
+	}
+	if size != 4 {
+		t.Errorf("Expected merged group of 4, got %d", size)
+	}
+
+	for _, obj := range []*models.RiskObject{user, host, ip, other} {
+		id, err := engine.repo.GetEntityGroupIDTx(tx, obj.ID)
+		if err != nil {
+			t.Fatalf("Failed to get entity group: %v", err)
+		}
+		if id != groupID {
+			t.Errorf("Expected %s '%s' in group %d, got %d", obj.EntityType, obj.EntityValue, groupID, id)
+		}
+	}
+}
+
+func TestEngine_EntityGroupAlert(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	engine.config.Correlation = CorrelationConfig{Enabled: true, GroupThreshold: 100}
+
+	// Three entities, each under the threshold of 100 on its own
+	detection := createSimpleTestDetection(t, engine)
+	events := []*models.Event{
+		{RiskObject: &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "alice"}, Context: `{"host": "ws-1"}`},
+		{RiskObject: &models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "ws-1"}, Context: `{"src_ip": "10.0.0.5"}`},
+		{RiskObject: &models.RiskObject{EntityType: models.EntityTypeIP, EntityValue: "10.0.0.5"}},
+	}
+	for _, event := range events {
+		event.DetectionID = detection.ID
+		event.RiskPoints = 40
+		if err := engine.ProcessEvent(event); err != nil {
+			t.Fatalf("Failed to process event: %v", err)
+		}
+	}
+
+	alerts, err := engine.GetRiskAlerts()
+	if err != nil {
+		t.Fatalf("Failed to get risk alerts: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 group alert, got %d", len(alerts))
+	}
+	alert := alerts[0]
+	if alert.GroupID == nil {
+		t.Fatal("Expected alert to be raised on an entity group")
+	}
+	if alert.TotalScore != 120 {
+		t.Errorf("Expected group alert score 120, got %d", alert.TotalScore)
+	}
+
+	group, err := engine.repo.GetEntityGroup(*alert.GroupID)
+	if err != nil {
+		t.Fatalf("Failed to get entity group: %v", err)
+	}
+	if len(group.Members) != 3 || group.Score != 120 {
+		t.Errorf("Expected 3 members scoring 120, got %d scoring %d", len(group.Members), group.Score)
+	}
+
+	alertEvents, err := engine.repo.GetEventsForAlert(alert.ID)
+	if err != nil {
+		t.Fatalf("Failed to get alert events: %v", err)
+	}
+	if len(alertEvents) != 3 {
+		t.Errorf("Expected 3 events linked to the group alert, got %d", len(alertEvents))
+	}
+
+	// Further events from any member attach to the open group alert
+	event := &models.Event{
+		DetectionID: detection.ID,
+		RiskObject:  &models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "ws-1"},
+		RiskPoints:  10,
+	}
+	if err := engine.ProcessEvent(event); err != nil {
+		t.Fatalf("Failed to process event: %v", err)
+	}
+
+	alerts, _ = engine.GetRiskAlerts()
+	if len(alerts) != 1 {
+		t.Fatalf("Expected event to attach to the open group alert, got %d alerts", len(alerts))
+	}
+	if alerts[0].TotalScore != 130 {
+		t.Errorf("Expected group alert score 130, got %d", alerts[0].TotalScore)
+	}
+}
+
+func TestEngine_EntityGroupAlertLinksScoreEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, engine *Engine, old *models.Event)
+	}{
+		{
+			name: "score window",
+			setup: func(t *testing.T, engine *Engine, old *models.Event) {
+				engine.config.ScoreWindow = 24 * time.Hour
+				old.Timestamp = time.Now().Add(-48 * time.Hour)
+				if err := engine.ProcessEvent(old); err != nil {
+					t.Fatalf("Failed to process old event: %v", err)
+				}
+			},
+		},
+		{
+			name: "score history",
+			setup: func(t *testing.T, engine *Engine, old *models.Event) {
+				if err := engine.ProcessEvent(old); err != nil {
+					t.Fatalf("Failed to process old event: %v", err)
+				}
+				// Decay the old event's points away before the group forms
+				engine.config.DecayFactor = 1
+				if err := engine.DecayRiskScores(); err != nil {
+					t.Fatalf("Failed to decay scores: %v", err)
+				}
+				engine.config.DecayFactor = 0
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := setupSimpleTestEngine(t)
+			defer engine.db.Close()
+
+			engine.config.Correlation = CorrelationConfig{Enabled: true, GroupThreshold: 100}
+			detection := createSimpleTestDetection(t, engine)
+
+			old := &models.Event{
+				DetectionID: detection.ID,
+				RiskObject:  &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "alice"},
+				RiskPoints:  80,
+			}
+			tt.setup(t, engine, old)
+
+			events := []*models.Event{
+				{RiskObject: &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "alice"}, Context: `{"host": "ws-1"}`},
+				{RiskObject: &models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "ws-1"}, Context: `{"src_ip": "10.0.0.5"}`},
+				{RiskObject: &models.RiskObject{EntityType: models.EntityTypeIP, EntityValue: "10.0.0.5"}},
+			}
+			for _, event := range events {
+				event.DetectionID = detection.ID
+				event.RiskPoints = 40
+				if err := engine.ProcessEvent(event); err != nil {
+					t.Fatalf("Failed to process event: %v", err)
+				}
+			}
+
+			alerts, err := engine.GetRiskAlerts()
+			if err != nil {
+				t.Fatalf("Failed to get risk alerts: %v", err)
+			}
+			if len(alerts) != 1 || alerts[0].GroupID == nil {
+				t.Fatalf("Expected 1 group alert, got %d alerts", len(alerts))
+			}
+			if alerts[0].TotalScore != 120 {
+				t.Errorf("Expected group alert score 120, got %d", alerts[0].TotalScore)
+			}
+
+			alertEvents, err := engine.repo.GetEventsForAlert(alerts[0].ID)
+			if err != nil {
+				t.Fatalf("Failed to get alert events: %v", err)
+			}
+			if len(alertEvents) != 3 {
+				t.Errorf("Expected 3 events linked to the group alert, got %d", len(alertEvents))
+			}
+			for _, event := range alertEvents {
+				if event.ID == old.ID {
+					t.Errorf("Expected old event %d not to be linked to the group alert", old.ID)
+				}
+			}
+
+			var linked int
+			if err := engine.db.QueryRow(`SELECT COALESCE(SUM(risk_points), 0) FROM risk_alert_events WHERE alert_id = ?`, alerts[0].ID).Scan(&linked); err != nil {
+				t.Fatalf("Failed to sum linked points: %v", err)
+			}
+			if linked != 120 {
+				t.Errorf("Expected linked points to add up to 120, got %d", linked)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package risk
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+// eventDedupKey returns the idempotency key of an event: its external ID
+// when the client supplied one, otherwise a hash of the detection, entity,
+// timestamp and raw data. Events with neither an external ID nor a timestamp
+// have no key, as a retry could not be told apart from a new occurrence.
+func eventDedupKey(event *models.Event) string {
+	if event.ExternalID != "" {
+		return "external:" + event.ExternalID
+	}
+	if event.Timestamp.IsZero() {
+		return ""
+	}
+
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatInt(event.DetectionID, 10)))
+	h.Write([]byte{0})
+	if event.RiskObject != nil {
+		h.Write([]byte(event.RiskObject.EntityType))
+		h.Write([]byte{0})
+		h.Write([]byte(event.RiskObject.EntityValue))
+	} else {
+		h.Write([]byte(strconv.FormatInt(event.EntityID, 10)))
+	}
+	h.Write([]byte{0})
+	// Timestamps are stored to the second
+	h.Write([]byte(event.Timestamp.UTC().Format(time.RFC3339)))
+	h.Write([]byte{0})
+	h.Write([]byte(event.RawData))
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// GetDedupEventIDTx returns the ID of the event recorded under a dedup key at
+// or after since, or 0 if there is none
+func (r *Repository) GetDedupEventIDTx(tx *sql.Tx, key string, since time.Time) (int64, error) {
+	var eventID int64
+	err := tx.QueryRow(
+		`SELECT event_id FROM event_dedup_keys WHERE dedup_key = ? AND datetime(created_at) >= datetime(?)`,
+		key, since.UTC().Format(time.RFC3339),
+	).Scan(&eventID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error querying dedup key: %w", err)
+	}
+	return eventID, nil
+}
+
+// SaveDedupKeyTx records the event a dedup key belongs to, replacing an
+// expired entry for the same key
+func (r *Repository) SaveDedupKeyTx(tx *sql.Tx, key string, eventID int64, createdAt time.Time) error {
+	_, err := tx.Exec(
+		`INSERT OR REPLACE INTO event_dedup_keys (dedup_key, event_id, created_at) VALUES (?, ?, ?)`,
+		key, eventID, createdAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving dedup key: %w", err)
+	}
+	return nil
+}
+
+// PruneDedupKeys deletes dedup keys recorded before a point in time and
+// returns the number deleted
+func (r *Repository) PruneDedupKeys(before time.Time) (int64, error) {
+	result, err := r.db.Exec(
+		`DELETE FROM event_dedup_keys WHERE datetime(created_at) < datetime(?)`,
+		before.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning dedup keys: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// replayedEventTx returns the original of an event already ingested under the
+// same dedup key within the dedup window, or nil
+func (e *Engine) replayedEventTx(tx *sql.Tx, key string, now time.Time) (*models.Event, error) {
+	eventID, err := e.repo.GetDedupEventIDTx(tx, key, now.Add(-e.config.DedupWindow))
+	if err != nil || eventID == 0 {
+		return nil, err
+	}
+	return e.repo.GetEventTx(tx, eventID)
+}
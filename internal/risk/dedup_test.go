@@ -0,0 +1,113 @@
+package risk
+
+import (
+	"testing"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestEventDedupKey(t *testing.T) {
+	timestamp := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	event := func() *models.Event {
+		return &models.Event{
+			DetectionID: 1,
+			RiskObject:  &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "alice"},
+			Timestamp:   timestamp,
+			RawData:     "login failed",
+		}
+	}
+
+	key := eventDedupKey(event())
+	if key == "" {
+		t.Fatal("Expected a key for an event with a timestamp")
+	}
+
+	// The same occurrence in another time zone has the same key
+	replay := event()
+	replay.Timestamp = timestamp.In(time.FixedZone("UTC+2", 2*60*60))
+	if eventDedupKey(replay) != key {
+		t.Error("Expected the same key for the same instant in another zone")
+	}
+
+	other := event()
+	other.RawData = "login succeeded"
+	if eventDedupKey(other) == key {
+		t.Error("Expected a different key for different raw data")
+	}
+
+	external := event()
+	external.ExternalID = "siem-123"
+	if got := eventDedupKey(external); got != "external:siem-123" {
+		t.Errorf("Expected external ID key, got '%s'", got)
+	}
+
+	untimed := event()
+	untimed.Timestamp = time.Time{}
+	if got := eventDedupKey(untimed); got != "" {
+		t.Errorf("Expected no key without a timestamp, got '%s'", got)
+	}
+}
+
+func TestEngine_DuplicateEvent(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	detection := createSimpleTestDetection(t, engine)
+	newEvent := func() *models.Event {
+		return &models.Event{
+			DetectionID: detection.ID,
+			RiskObject:  &models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "ws-1"},
+			ExternalID:  "siem-123",
+			RiskPoints:  30,
+		}
+	}
+
+	original := newEvent()
+	if err := engine.ProcessEvent(original); err != nil {
+		t.Fatalf("Failed to process event: %v", err)
+	}
+	if original.Duplicate {
+		t.Fatal("Expected first event not to be a duplicate")
+	}
+
+	// A retry returns the original without scoring again
+	replay := newEvent()
+	if err := engine.ProcessEvent(replay); err != nil {
+		t.Fatalf("Failed to process replayed event: %v", err)
+	}
+	if !replay.Duplicate || replay.ID != original.ID {
+		t.Errorf("Expected replay to return event %d as a duplicate, got event %d (duplicate %v)", original.ID, replay.ID, replay.Duplicate)
+	}
+
+	obj, err := engine.repo.GetRiskObjectByEntity(models.EntityTypeHost, "ws-1")
+	if err != nil {
+		t.Fatalf("Failed to get risk object: %v", err)
+	}
+	if obj.CurrentScore != 30 {
+		t.Errorf("Expected score 30 after replay, got %d", obj.CurrentScore)
+	}
+
+	// Once the key has left the window the event is scored again
+	past := time.Now().Add(-25 * time.Hour).UTC().Format(time.RFC3339)
+	if _, err := engine.db.Exec(`UPDATE event_dedup_keys SET created_at = ?`, past); err != nil {
+		t.Fatalf("Failed to backdate dedup key: %v", err)
+	}
+
+	late := newEvent()
+	if err := engine.ProcessEvent(late); err != nil {
+		t.Fatalf("Failed to process event: %v", err)
+	}
+	if late.Duplicate || late.ID == original.ID {
+		t.Error("Expected event outside the dedup window to be processed")
+	}
+
+	obj, _ = engine.repo.GetRiskObjectByEntity(models.EntityTypeHost, "ws-1")
+	if obj.CurrentScore != 60 {
+		t.Errorf("Expected score 60, got %d", obj.CurrentScore)
+	}
+
+	if n, err := engine.repo.PruneDedupKeys(time.Now().Add(-engine.config.DedupWindow)); err != nil || n != 0 {
+		t.Errorf("Expected no expired keys to prune, got %d (%v)", n, err)
+	}
+}
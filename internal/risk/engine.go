@@ -1,6 +1,7 @@
 package risk
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
 	"time"
@@ -19,6 +20,32 @@ type Config struct {
 
 	// How often to run the decay process
 	DecayInterval time.Duration
+
+	// Sliding window over which an entity's score is summed from its events
+	// (0 keeps the running score that is reduced by DecayFactor)
+	ScoreWindow time.Duration
+
+	// Threshold policies from configuration, evaluated together with the
+	// policies stored in the database
+	Thresholds []models.RiskThreshold
+
+	// How long after an alert is closed before an entity that is still over
+	// its threshold can alert again
+	AlertCooldown time.Duration
+
+	// Modifiers that compute event risk points from the detection
+	Modifiers ModifierConfig
+
+	// Correlation of entities that appear together in event context
+	Correlation CorrelationConfig
+
+	// Bonus points and alerting on the number of distinct MITRE tactics hit
+	TacticDiversity TacticDiversityConfig
+
+	// How long an event's idempotency key is remembered; a replay within it
+	// returns the original event instead of being scored again (0 disables
+	// deduplication)
+	DedupWindow time.Duration
 }
 
 // DefaultConfig returns a default configuration
@@ -27,6 +54,7 @@ func DefaultConfig() Config {
 		RiskThreshold: 50,
 		DecayFactor:   0.1,
 		DecayInterval: 24 * time.Hour,
+		DedupWindow:   24 * time.Hour,
 	}
 }
 
@@ -55,79 +83,280 @@ func (e *Engine) ProcessEvent(event *models.Event) error {
 	}
 	defer tx.Rollback()
 
+	if err := e.processEventTx(tx, event); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// processEventTx scores an event and raises any alerts it causes, within a
+// transaction
+func (e *Engine) processEventTx(tx *sql.Tx, event *models.Event) error {
+	var err error
+
+	// A replayed event returns the original instead of being scored again
+	now := time.Now()
+	dedupKey := ""
+	if e.config.DedupWindow > 0 {
+		dedupKey = eventDedupKey(event)
+	}
+	if dedupKey != "" {
+		original, err := e.replayedEventTx(tx, dedupKey, now)
+		if err != nil {
+			return fmt.Errorf("failed to check for duplicate event: %w", err)
+		}
+		if original != nil {
+			original.ExternalID = event.ExternalID
+			original.RiskObject = event.RiskObject
+			original.Duplicate = true
+			*event = *original
+			return nil
+		}
+	}
+
+	// Events without a timestamp happened now
+	if event.Timestamp.IsZero() {
+		event.Timestamp = now
+	}
+
 	// Get or create risk object
-	riskObject, err := e.repo.GetRiskObjectByEntityTx(tx, event.RiskObject.EntityType, event.RiskObject.EntityValue)
+	riskObject, err := e.getOrCreateRiskObjectTx(tx, event.RiskObject.EntityType, event.RiskObject.EntityValue)
 	if err != nil {
-		// Create new risk object if not found
-		riskObject = &models.RiskObject{
-			EntityType:   event.RiskObject.EntityType,
-			EntityValue:  event.RiskObject.EntityValue,
-			CurrentScore: 0,
-			LastSeen:     time.Now(),
+		return err
+	}
+
+	// Set entity ID in event
+	event.EntityID = riskObject.ID
+
+	// Score before this event; a windowed score is recomputed because
+	// events may have aged out since it was last stored
+	storedScore := riskObject.CurrentScore
+	oldScore := riskObject.CurrentScore
+	if e.config.ScoreWindow > 0 {
+		oldScore, err = e.repo.GetWindowedScoreTx(tx, riskObject.ID, e.windowStart(now))
+		if err != nil {
+			return fmt.Errorf("failed to calculate risk score: %w", err)
 		}
+	}
+
+	// Asset or identity details used for modifiers and alert routing
+	asset, err := e.repo.GetInventoryEntryTx(tx, riskObject)
+	if err != nil {
+		return fmt.Errorf("failed to look up inventory: %w", err)
+	}
 
-		if err := e.repo.CreateRiskObjectTx(tx, riskObject); err != nil {
-			return fmt.Errorf("failed to create risk object: %w", err)
+	if e.config.Modifiers.Enabled {
+		if err := e.applyModifiersTx(tx, event, riskObject, asset); err != nil {
+			return fmt.Errorf("failed to apply risk modifiers: %w", err)
 		}
 	}
 
-	// Set entity ID in event
-	event.EntityID = riskObject.ID
+	// Kill-chain breadth across the entity's recent detections
+	broadened := false
+	if e.config.TacticDiversity.Enabled {
+		broadened, err = e.applyTacticDiversityTx(tx, event, riskObject, now)
+		if err != nil {
+			return fmt.Errorf("failed to apply tactic diversity: %w", err)
+		}
+	}
 
 	// Save event
 	if err := e.repo.CreateEventTx(tx, event); err != nil {
 		return fmt.Errorf("failed to create event: %w", err)
 	}
+	if dedupKey != "" {
+		if err := e.repo.SaveDedupKeyTx(tx, dedupKey, event.ID, now); err != nil {
+			return fmt.Errorf("failed to save dedup key: %w", err)
+		}
+	}
 
 	// Update risk score
-	oldScore := riskObject.CurrentScore
-	riskObject.CurrentScore += event.RiskPoints
-	riskObject.LastSeen = time.Now()
+	if e.config.ScoreWindow > 0 {
+		riskObject.CurrentScore, err = e.repo.GetWindowedScoreTx(tx, riskObject.ID, e.windowStart(now))
+		if err != nil {
+			return fmt.Errorf("failed to calculate risk score: %w", err)
+		}
+	} else {
+		riskObject.CurrentScore = oldScore + event.RiskPoints
+	}
+	riskObject.LastSeen = now
 
-	if err := e.repo.UpdateRiskObjectTx(tx, riskObject); err != nil {
+	if err := e.saveScoreTx(tx, riskObject, storedScore, models.ScoreChangeEvent, &event.ID, ""); err != nil {
 		return fmt.Errorf("failed to update risk object: %w", err)
 	}
 
 	// Check if threshold crossed
-	if oldScore < e.config.RiskThreshold && riskObject.CurrentScore >= e.config.RiskThreshold {
+	threshold, err := e.thresholdForTx(tx, riskObject, asset, event.DetectionID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve risk threshold: %w", err)
+	}
+
+	latest, err := e.repo.GetLatestRiskAlertByEntityTx(tx, riskObject.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get risk alert: %w", err)
+	}
+
+	// Exceeding the tactic limit can alert whatever the score
+	broadAlert := broadened && e.config.TacticDiversity.Alert && !e.inCooldown(latest, now)
+
+	if latest != nil && latest.Status != models.AlertStatusClosed {
+		// Attach the event to the alert that is still being worked
+		if err := e.repo.AddAlertEventTx(tx, latest.ID, event.ID, event.RiskPoints); err != nil {
+			return fmt.Errorf("failed to attach event to risk alert: %w", err)
+		}
+
+		latest.TotalScore += event.RiskPoints
+		if err := e.repo.UpdateRiskAlertScoreTx(tx, latest.ID, latest.TotalScore); err != nil {
+			return fmt.Errorf("failed to update risk alert: %w", err)
+		}
+	} else if (riskObject.CurrentScore >= threshold && e.shouldAlert(oldScore, threshold, latest, now)) || broadAlert {
 		// Create risk alert
 		alert := &models.RiskAlert{
 			EntityID:    riskObject.ID,
-			TriggeredAt: time.Now(),
+			TriggeredAt: now,
 			TotalScore:  riskObject.CurrentScore,
 			Status:      models.AlertStatusNew,
 			Notes:       "",
 			Owner:       "",
 		}
 
+		// Route the alert to the owner of the asset or identity
+		if asset != nil {
+			alert.Owner = asset.Owner
+		}
+		if broadAlert && riskObject.CurrentScore < threshold {
+			alert.Notes = fmt.Sprintf("Detections span more than %d MITRE tactics", e.config.TacticDiversity.Limit)
+		}
+
 		if err := e.repo.CreateRiskAlertTx(tx, alert); err != nil {
 			return fmt.Errorf("failed to create risk alert: %w", err)
 		}
 
+		// Record the events that make up the alert score
+		if e.config.ScoreWindow > 0 {
+			err = e.repo.LinkWindowEventsToAlertTx(tx, alert.ID, riskObject.ID, e.windowStart(now))
+		} else {
+			err = e.repo.LinkScoreEventsToAlertTx(tx, alert.ID, riskObject.ID, alert.TotalScore)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to link events to risk alert: %w", err)
+		}
+
 		log.Printf("Risk alert generated for %s '%s' with score %d",
 			riskObject.EntityType, riskObject.EntityValue, riskObject.CurrentScore)
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	// Alert rules that look at the entity's recent events
+	if err := e.evaluateRulesTx(tx, event, riskObject, asset, now); err != nil {
+		return fmt.Errorf("failed to evaluate alert rules: %w", err)
+	}
+
+	// Link related entities and alert on their combined score
+	if e.config.Correlation.Enabled {
+		if err := e.correlateTx(tx, event, riskObject, riskObject.CurrentScore-storedScore, asset, now); err != nil {
+			return fmt.Errorf("failed to correlate entities: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// ProcessEvents processes multiple events in batch
+// getOrCreateRiskObjectTx gets the risk object for an entity, creating it
+// with no score if it does not exist yet
+func (e *Engine) getOrCreateRiskObjectTx(tx *sql.Tx, entityType models.EntityType, entityValue string) (*models.RiskObject, error) {
+	riskObject, err := e.repo.GetRiskObjectByEntityTx(tx, entityType, entityValue)
+	if err == nil {
+		return riskObject, nil
+	}
+
+	// Create new risk object if not found
+	riskObject = &models.RiskObject{
+		EntityType:   entityType,
+		EntityValue:  entityValue,
+		CurrentScore: 0,
+		LastSeen:     time.Now(),
+	}
+
+	if err := e.repo.CreateRiskObjectTx(tx, riskObject); err != nil {
+		return nil, fmt.Errorf("failed to create risk object: %w", err)
+	}
+
+	return riskObject, nil
+}
+
+// ProcessEvents processes multiple events in a single transaction. If any
+// event fails, none of them are saved.
 func (e *Engine) ProcessEvents(events []*models.Event) error {
+	tx, err := e.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	for _, event := range events {
-		if err := e.ProcessEvent(event); err != nil {
+		if err := e.processEventTx(tx, event); err != nil {
 			return fmt.Errorf("failed to process event: %w", err)
 		}
 	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
-// DecayRiskScores reduces all risk scores by the decay factor
+// ProcessEventBatch processes events in order in a single transaction. Each
+// event runs in its own savepoint, so one that fails is rolled back without
+// losing the rest. It returns each event's error, or nil if it was saved, and
+// an error if the batch as a whole could not be committed.
+func (e *Engine) ProcessEventBatch(events []*models.Event) ([]error, error) {
+	tx, err := e.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	results := make([]error, len(events))
+	for i, event := range events {
+		if _, err := tx.Exec("SAVEPOINT event"); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+
+		if err := e.processEventTx(tx, event); err != nil {
+			if _, rbErr := tx.Exec("ROLLBACK TO event"); rbErr != nil {
+				return nil, fmt.Errorf("failed to roll back event: %w", rbErr)
+			}
+			event.ID = 0
+			results[i] = err
+		}
+
+		if _, err := tx.Exec("RELEASE event"); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return results, nil
+}
+
+// DecayRiskScores reduces all risk scores by the decay factor. With a
+// score window configured, scores are instead recalculated so that events
+// which have left the window stop counting.
 func (e *Engine) DecayRiskScores() error {
+	if e.config.ScoreWindow > 0 {
+		return e.repo.RecalculateRiskScores(e.windowStart(time.Now()))
+	}
+
 	if e.config.DecayFactor <= 0 {
 		return nil // No decay needed
 	}
@@ -135,6 +364,114 @@ func (e *Engine) DecayRiskScores() error {
 	return e.repo.DecayRiskScores(e.config.DecayFactor)
 }
 
+// thresholdForTx returns the alert threshold for an entity with the given
+// inventory entry, which may be nil, consulting the configured and stored
+// threshold policies before the global threshold
+func (e *Engine) thresholdForTx(tx *sql.Tx, riskObject *models.RiskObject, asset *models.InventoryEntry, detectionID int64) (int, error) {
+	policies, err := e.repo.ListEnabledRiskThresholdsTx(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range e.config.Thresholds {
+		if e.config.Thresholds[i].Enabled {
+			policies = append(policies, &e.config.Thresholds[i])
+		}
+	}
+
+	if len(policies) == 0 {
+		return e.config.RiskThreshold, nil
+	}
+
+	classID, err := e.repo.GetDetectionClassIDTx(tx, detectionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return resolveThreshold(policies, riskObject, asset, classID, e.config.RiskThreshold), nil
+}
+
+// shouldAlert decides whether an entity at or above its threshold with no
+// open alert gets a new one. Crossing the threshold always alerts, and an
+// entity that stays above it alerts again once its last alert has been
+// closed for longer than the cooldown.
+func (e *Engine) shouldAlert(oldScore, threshold int, latest *models.RiskAlert, now time.Time) bool {
+	if e.inCooldown(latest, now) {
+		return false
+	}
+
+	return oldScore < threshold || latest != nil
+}
+
+// inCooldown reports whether latest was closed less than the cooldown ago
+func (e *Engine) inCooldown(latest *models.RiskAlert, now time.Time) bool {
+	return latest != nil && latest.ClosedAt != nil && now.Sub(*latest.ClosedAt) < e.config.AlertCooldown
+}
+
+// applyModifiersTx sets an event's risk points from its detection's base
+// points and the configured modifiers. Detections without base points fall
+// back to the points sent with the event.
+func (e *Engine) applyModifiersTx(tx *sql.Tx, event *models.Event, riskObject *models.RiskObject, asset *models.InventoryEntry) error {
+	severity, basePoints, err := e.repo.GetDetectionScoringTx(tx, event.DetectionID)
+	if err != nil {
+		return err
+	}
+
+	if basePoints == 0 {
+		basePoints = event.RiskPoints
+	}
+
+	event.RiskBreakdown = computeRiskPoints(e.config.Modifiers, basePoints, severity, riskObject, asset, event.Context)
+	event.RiskPoints = event.RiskBreakdown.EffectivePoints
+	return nil
+}
+
+// windowStart returns the start of the scoring window ending at now
+func (e *Engine) windowStart(now time.Time) time.Time {
+	return now.Add(-e.config.ScoreWindow)
+}
+
+// adjustScoreTx updates an entity's score after one of its events changed,
+// either by recalculating the window or by applying delta to the running score
+func (e *Engine) adjustScoreTx(tx *sql.Tx, riskObject *models.RiskObject, delta int) error {
+	if e.config.ScoreWindow > 0 {
+		score, err := e.repo.GetWindowedScoreTx(tx, riskObject.ID, e.windowStart(time.Now()))
+		if err != nil {
+			return err
+		}
+		riskObject.CurrentScore = score
+	} else {
+		riskObject.CurrentScore += delta
+	}
+
+	if riskObject.CurrentScore < 0 {
+		riskObject.CurrentScore = 0
+	}
+
+	return nil
+}
+
+// saveScoreTx stores a risk object's score and records the change from
+// oldScore in the score history
+func (e *Engine) saveScoreTx(tx *sql.Tx, riskObject *models.RiskObject, oldScore int, reason models.ScoreChangeReason, eventID *int64, notes string) error {
+	if err := e.repo.UpdateRiskObjectTx(tx, riskObject); err != nil {
+		return err
+	}
+
+	if riskObject.CurrentScore == oldScore {
+		return nil
+	}
+
+	return e.repo.RecordScoreChangeTx(tx, &models.RiskScoreChange{
+		EntityID: riskObject.ID,
+		OldScore: oldScore,
+		NewScore: riskObject.CurrentScore,
+		Reason:   reason,
+		EventID:  eventID,
+		Notes:    notes,
+	})
+}
+
 // StartDecayProcess starts a background process to decay risk scores periodically
 func (e *Engine) StartDecayProcess(stop <-chan struct{}) {
 	ticker := time.NewTicker(e.config.DecayInterval)
@@ -145,9 +482,17 @@ func (e *Engine) StartDecayProcess(stop <-chan struct{}) {
 		case <-ticker.C:
 			if err := e.DecayRiskScores(); err != nil {
 				log.Printf("Error decaying risk scores: %v", err)
+			} else if e.config.ScoreWindow > 0 {
+				log.Printf("Risk scores recalculated over %s window", e.config.ScoreWindow)
 			} else {
 				log.Printf("Risk scores decayed by factor %.2f", e.config.DecayFactor)
 			}
+
+			if e.config.DedupWindow > 0 {
+				if _, err := e.repo.PruneDedupKeys(time.Now().Add(-e.config.DedupWindow)); err != nil {
+					log.Printf("Error pruning event dedup keys: %v", err)
+				}
+			}
 		case <-stop:
 			return
 		}
@@ -193,12 +538,12 @@ func (e *Engine) MarkEventAsFalsePositive(eventID int64, fpInfo *models.FalsePos
 	}
 
 	// Subtract risk points
-	riskObject.CurrentScore -= event.RiskPoints
-	if riskObject.CurrentScore < 0 {
-		riskObject.CurrentScore = 0
+	oldScore := riskObject.CurrentScore
+	if err := e.adjustScoreTx(tx, riskObject, -event.RiskPoints); err != nil {
+		return fmt.Errorf("failed to calculate risk score: %w", err)
 	}
 
-	if err := e.repo.UpdateRiskObjectTx(tx, riskObject); err != nil {
+	if err := e.saveScoreTx(tx, riskObject, oldScore, models.ScoreChangeFalsePositive, &event.ID, fpInfo.Reason); err != nil {
 		return fmt.Errorf("failed to update risk object: %w", err)
 	}
 
@@ -248,9 +593,12 @@ func (e *Engine) UnmarkEventAsFalsePositive(eventID int64) error {
 	}
 
 	// Add back risk points
-	riskObject.CurrentScore += event.RiskPoints
+	oldScore := riskObject.CurrentScore
+	if err := e.adjustScoreTx(tx, riskObject, event.RiskPoints); err != nil {
+		return fmt.Errorf("failed to calculate risk score: %w", err)
+	}
 
-	if err := e.repo.UpdateRiskObjectTx(tx, riskObject); err != nil {
+	if err := e.saveScoreTx(tx, riskObject, oldScore, models.ScoreChangeFalsePositiveRemoved, &event.ID, ""); err != nil {
 		return fmt.Errorf("failed to update risk object: %w", err)
 	}
 
@@ -262,6 +610,37 @@ func (e *Engine) UnmarkEventAsFalsePositive(eventID int64) error {
 	return nil
 }
 
+// SetRiskScore manually sets an entity's risk score, recording notes in the
+// score history. With a score window configured the score is recalculated
+// from events on the next decay run.
+func (e *Engine) SetRiskScore(entityID int64, score int, notes string) (*models.RiskObject, error) {
+	// Begin transaction
+	tx, err := e.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	riskObject, err := e.repo.GetRiskObjectTx(tx, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get risk object: %w", err)
+	}
+
+	oldScore := riskObject.CurrentScore
+	riskObject.CurrentScore = score
+
+	if err := e.saveScoreTx(tx, riskObject, oldScore, models.ScoreChangeManual, nil, notes); err != nil {
+		return nil, fmt.Errorf("failed to update risk object: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return riskObject, nil
+}
+
 // GetHighRiskEntities returns entities with risk scores above the threshold
 func (e *Engine) GetHighRiskEntities() ([]*models.RiskObject, error) {
 	return e.repo.ListHighRiskObjects(e.config.RiskThreshold)
@@ -1,6 +1,7 @@
 package risk
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -351,3 +352,299 @@ func abs(x int) int {
 	}
 	return x
 }
+
+func TestEngine_WindowedScoring(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	engine.config.ScoreWindow = 24 * time.Hour
+	detection := createSimpleTestDetection(t, engine)
+
+	riskObject := &models.RiskObject{
+		EntityType:  models.EntityTypeHost,
+		EntityValue: "backfill-host",
+	}
+
+	// A backfilled event older than the window should not count
+	oldEvent := &models.Event{
+		DetectionID: detection.ID,
+		RiskPoints:  80,
+		Timestamp:   time.Now().Add(-48 * time.Hour),
+		RiskObject:  riskObject,
+	}
+	if err := engine.ProcessEvent(oldEvent); err != nil {
+		t.Fatalf("Failed to process old event: %v", err)
+	}
+
+	obj, err := engine.repo.GetRiskObjectByEntity(models.EntityTypeHost, "backfill-host")
+	if err != nil {
+		t.Fatalf("Failed to get risk object: %v", err)
+	}
+	if obj.CurrentScore != 0 {
+		t.Errorf("Expected score 0 for event outside the window, got %d", obj.CurrentScore)
+	}
+
+	// Two recent events cross the threshold (60 + 60 >= 100)
+	var recent []*models.Event
+	for i := 0; i < 2; i++ {
+		event := &models.Event{
+			DetectionID: detection.ID,
+			RiskPoints:  60,
+			Timestamp:   time.Now().Add(-time.Duration(i+1) * time.Hour),
+			RiskObject:  riskObject,
+		}
+		if err := engine.ProcessEvent(event); err != nil {
+			t.Fatalf("Failed to process event: %v", err)
+		}
+		recent = append(recent, event)
+	}
+
+	obj, err = engine.repo.GetRiskObject(obj.ID)
+	if err != nil {
+		t.Fatalf("Failed to get risk object: %v", err)
+	}
+	if obj.CurrentScore != 120 {
+		t.Errorf("Expected windowed score 120, got %d", obj.CurrentScore)
+	}
+
+	alerts, err := engine.GetRiskAlerts()
+	if err != nil {
+		t.Fatalf("Failed to get risk alerts: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].TotalScore != 120 {
+		t.Errorf("Expected one alert with score 120, got %d alerts", len(alerts))
+	}
+
+	// Marking an in-window event as a false positive recalculates the window
+	fpInfo := &models.FalsePositive{AnalystName: "analyst@example.com", Timestamp: time.Now()}
+	if err := engine.MarkEventAsFalsePositive(recent[0].ID, fpInfo); err != nil {
+		t.Fatalf("Failed to mark false positive: %v", err)
+	}
+	obj, _ = engine.repo.GetRiskObject(obj.ID)
+	if obj.CurrentScore != 60 {
+		t.Errorf("Expected score 60 after false positive, got %d", obj.CurrentScore)
+	}
+}
+
+func TestEngine_DecayRecalculatesWindow(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	engine.config.ScoreWindow = 24 * time.Hour
+	detection := createSimpleTestDetection(t, engine)
+	riskObj := createSimpleRiskObject(t, engine, models.EntityTypeUser, "aged@example.com")
+
+	// One event inside the window, one outside, and a stale stored score
+	now := time.Now()
+	for _, ts := range []time.Time{now.Add(-time.Hour), now.Add(-72 * time.Hour)} {
+		_, err := engine.db.Exec(`INSERT INTO events (detection_id, entity_id, timestamp, risk_points) VALUES (?, ?, ?, ?)`,
+			detection.ID, riskObj.ID, ts.Format(time.RFC3339), 30)
+		if err != nil {
+			t.Fatalf("Failed to insert event: %v", err)
+		}
+	}
+	if _, err := engine.db.Exec(`UPDATE risk_objects SET current_score = 60 WHERE id = ?`, riskObj.ID); err != nil {
+		t.Fatalf("Failed to set score: %v", err)
+	}
+
+	if err := engine.DecayRiskScores(); err != nil {
+		t.Fatalf("Failed to recalculate scores: %v", err)
+	}
+
+	updated, err := engine.repo.GetRiskObject(riskObj.ID)
+	if err != nil {
+		t.Fatalf("Failed to get risk object: %v", err)
+	}
+	if updated.CurrentScore != 30 {
+		t.Errorf("Expected windowed score 30, got %d", updated.CurrentScore)
+	}
+}
+
+func TestEngine_AttachEventsToOpenAlert(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	detection := createSimpleTestDetection(t, engine)
+	riskObject := &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "dedup@example.com"}
+
+	// 60 + 60 crosses the threshold, the third event lands on the open alert
+	for i := 0; i < 3; i++ {
+		event := &models.Event{DetectionID: detection.ID, RiskPoints: 60, RiskObject: riskObject}
+		if err := engine.ProcessEvent(event); err != nil {
+			t.Fatalf("Failed to process event %d: %v", i, err)
+		}
+	}
+
+	alerts, err := engine.GetRiskAlerts()
+	if err != nil {
+		t.Fatalf("Failed to get risk alerts: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(alerts))
+	}
+	if alerts[0].TotalScore != 180 {
+		t.Errorf("Expected alert score 180, got %d", alerts[0].TotalScore)
+	}
+
+	var linked int
+	if err := engine.db.QueryRow(`SELECT COUNT(*) FROM risk_alert_events WHERE alert_id = ?`, alerts[0].ID).Scan(&linked); err != nil {
+		t.Fatalf("Failed to count linked events: %v", err)
+	}
+	if linked != 3 {
+		t.Errorf("Expected 3 linked events, got %d", linked)
+	}
+}
+
+func TestEngine_AlertLinksScoreEvents(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	detection := createSimpleTestDetection(t, engine)
+	riskObject := &models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "decayed-host"}
+
+	processEvent := func(points int, timestamp time.Time) {
+		event := &models.Event{DetectionID: detection.ID, RiskPoints: points, Timestamp: timestamp, RiskObject: riskObject}
+		if err := engine.ProcessEvent(event); err != nil {
+			t.Fatalf("Failed to process event: %v", err)
+		}
+	}
+
+	// An event from before the score decayed to zero no longer counts
+	processEvent(80, time.Now().Add(-2*time.Hour))
+	engine.config.DecayFactor = 1
+	if err := engine.DecayRiskScores(); err != nil {
+		t.Fatalf("Failed to decay scores: %v", err)
+	}
+
+	// 60 decays to 54 before the second event crosses the threshold
+	processEvent(60, time.Now().Add(-time.Hour))
+	engine.config.DecayFactor = 0.1
+	if err := engine.DecayRiskScores(); err != nil {
+		t.Fatalf("Failed to decay scores: %v", err)
+	}
+	processEvent(60, time.Now())
+
+	alerts, err := engine.GetRiskAlerts()
+	if err != nil {
+		t.Fatalf("Failed to get risk alerts: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].TotalScore != 114 {
+		t.Fatalf("Expected one alert with score 114, got %+v", alerts)
+	}
+
+	var linked, points int
+	if err := engine.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(risk_points), 0) FROM risk_alert_events WHERE alert_id = ?`, alerts[0].ID).Scan(&linked, &points); err != nil {
+		t.Fatalf("Failed to count linked events: %v", err)
+	}
+	if linked != 2 || points != 114 {
+		t.Errorf("Expected 2 linked events worth 114 points, got %d worth %d", linked, points)
+	}
+}
+
+func TestEngine_RealertAfterCooldown(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+	engine.config.AlertCooldown = time.Hour
+
+	detection := createSimpleTestDetection(t, engine)
+	riskObject := &models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "realert-host"}
+
+	processEvent := func() {
+		event := &models.Event{DetectionID: detection.ID, RiskPoints: 100, RiskObject: riskObject}
+		if err := engine.ProcessEvent(event); err != nil {
+			t.Fatalf("Failed to process event: %v", err)
+		}
+	}
+	countAlerts := func() int {
+		alerts, err := engine.GetRiskAlerts()
+		if err != nil {
+			t.Fatalf("Failed to get risk alerts: %v", err)
+		}
+		return len(alerts)
+	}
+
+	processEvent()
+	if n := countAlerts(); n != 1 {
+		t.Fatalf("Expected 1 alert, got %d", n)
+	}
+
+	// Close the alert; the entity is still above the threshold
+	alerts, _ := engine.GetRiskAlerts()
+	alert := alerts[0]
+	alert.Status = models.AlertStatusClosed
+	if err := engine.repo.UpdateRiskAlert(alert); err != nil {
+		t.Fatalf("Failed to close alert: %v", err)
+	}
+
+	closed, err := engine.repo.GetRiskAlert(alert.ID)
+	if err != nil {
+		t.Fatalf("Failed to get alert: %v", err)
+	}
+	if closed.ClosedAt == nil {
+		t.Fatal("Expected closed_at to be set on close")
+	}
+	var closedAt string
+	if err := engine.db.QueryRow(`SELECT closed_at FROM risk_alerts WHERE id = ?`, alert.ID).Scan(&closedAt); err != nil {
+		t.Fatalf("Failed to get closed_at: %v", err)
+	}
+	if !strings.HasSuffix(closedAt, "Z") {
+		t.Errorf("Expected closed_at to be stored in UTC, got %s", closedAt)
+	}
+
+	// Within the cooldown no new alert is raised
+	processEvent()
+	if n := countAlerts(); n != 1 {
+		t.Errorf("Expected no new alert during cooldown, got %d alerts", n)
+	}
+
+	// Once the cooldown has passed the entity alerts again
+	past := time.Now().UTC().Add(-2 * time.Hour).Format(time.RFC3339)
+	if _, err := engine.db.Exec(`UPDATE risk_alerts SET closed_at = ? WHERE id = ?`, past, alert.ID); err != nil {
+		t.Fatalf("Failed to backdate closed_at: %v", err)
+	}
+	processEvent()
+	if n := countAlerts(); n != 2 {
+		t.Errorf("Expected a new alert after cooldown, got %d alerts", n)
+	}
+}
+
+func TestEngine_ProcessEventBatch(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	detection := createSimpleTestDetection(t, engine)
+	riskObject := &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "batch-user"}
+
+	// The middle event references a detection that does not exist
+	events := []*models.Event{
+		{DetectionID: detection.ID, RiskObject: riskObject, RiskPoints: 30},
+		{DetectionID: 9999, RiskObject: riskObject, RiskPoints: 500},
+		{DetectionID: detection.ID, RiskObject: riskObject, RiskPoints: 40},
+	}
+
+	results, err := engine.ProcessEventBatch(events)
+	if err != nil {
+		t.Fatalf("Failed to process batch: %v", err)
+	}
+	if results[0] != nil || results[2] != nil {
+		t.Fatalf("Expected valid events to be processed, got %v and %v", results[0], results[2])
+	}
+	if results[1] == nil {
+		t.Fatal("Expected event with unknown detection to fail")
+	}
+	if events[1].ID != 0 {
+		t.Errorf("Expected failed event to have no ID, got %d", events[1].ID)
+	}
+
+	obj, err := engine.repo.GetRiskObjectByEntity(models.EntityTypeUser, "batch-user")
+	if err != nil {
+		t.Fatalf("Failed to get risk object: %v", err)
+	}
+	if obj.CurrentScore != 70 {
+		t.Errorf("Expected score 70 from the two saved events, got %d", obj.CurrentScore)
+	}
+
+	saved, err := engine.repo.ListEventsByEntity(obj.ID)
+	if err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	if len(saved) != 2 {
+		t.Errorf("Expected 2 saved events, got %d", len(saved))
+	}
+}
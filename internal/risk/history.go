@@ -0,0 +1,168 @@
+package risk
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+// RecordScoreChangeTx records a change to a risk object's score within a transaction
+func (r *Repository) RecordScoreChangeTx(tx *sql.Tx, change *models.RiskScoreChange) error {
+	query := `INSERT INTO risk_score_history (entity_id, recorded_at, old_score, new_score, reason, event_id, notes)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	if change.RecordedAt.IsZero() {
+		change.RecordedAt = time.Now()
+	}
+
+	result, err := tx.Exec(
+		query,
+		change.EntityID,
+		change.RecordedAt.UTC().Format(time.RFC3339),
+		change.OldScore,
+		change.NewScore,
+		change.Reason,
+		change.EventID,
+		sql.NullString{String: change.Notes, Valid: change.Notes != ""},
+	)
+	if err != nil {
+		return fmt.Errorf("error recording risk score change: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	change.ID = id
+	return nil
+}
+
+// ListScoreHistory lists the score changes of an entity recorded between since
+// and until, inclusive
+func (r *Repository) ListScoreHistory(entityID int64, since, until time.Time) ([]*models.RiskScoreChange, error) {
+	query := `SELECT id, entity_id, recorded_at, old_score, new_score, reason, event_id, notes
+              FROM risk_score_history
+              WHERE entity_id = ? AND datetime(recorded_at) >= datetime(?) AND datetime(recorded_at) <= datetime(?)
+              ORDER BY datetime(recorded_at), id`
+
+	rows, err := r.db.Query(query, entityID, since.UTC().Format(time.RFC3339), until.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("error querying risk score history: %w", err)
+	}
+	defer rows.Close()
+
+	changes := make([]*models.RiskScoreChange, 0)
+	for rows.Next() {
+		var change models.RiskScoreChange
+		var recordedAt string
+		var eventID sql.NullInt64
+		var notes sql.NullString
+
+		err := rows.Scan(
+			&change.ID,
+			&change.EntityID,
+			&recordedAt,
+			&change.OldScore,
+			&change.NewScore,
+			&change.Reason,
+			&eventID,
+			&notes,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning risk score history row: %w", err)
+		}
+
+		// Handle nullable fields
+		if eventID.Valid {
+			change.EventID = &eventID.Int64
+		}
+		if notes.Valid {
+			change.Notes = notes.String
+		}
+
+		change.RecordedAt, _ = time.Parse(time.RFC3339, recordedAt)
+
+		changes = append(changes, &change)
+	}
+
+	return changes, nil
+}
+
+// GetScoreAt returns an entity's score as of at. Entities with no history
+// before at fall back to the score their first later change started from,
+// and then to their current score.
+func (r *Repository) GetScoreAt(entityID int64, at time.Time) (int, error) {
+	atUTC := at.UTC().Format(time.RFC3339)
+
+	var score int
+	err := r.db.QueryRow(
+		`SELECT new_score FROM risk_score_history
+         WHERE entity_id = ? AND datetime(recorded_at) < datetime(?)
+         ORDER BY datetime(recorded_at) DESC, id DESC LIMIT 1`,
+		entityID, atUTC,
+	).Scan(&score)
+	if err == nil {
+		return score, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("error getting risk score history: %w", err)
+	}
+
+	err = r.db.QueryRow(
+		`SELECT old_score FROM risk_score_history
+         WHERE entity_id = ? AND datetime(recorded_at) >= datetime(?)
+         ORDER BY datetime(recorded_at), id LIMIT 1`,
+		entityID, atUTC,
+	).Scan(&score)
+	if err == nil {
+		return score, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("error getting risk score history: %w", err)
+	}
+
+	err = r.db.QueryRow(`SELECT current_score FROM risk_objects WHERE id = ?`, entityID).Scan(&score)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("%w: %d", ErrRiskObjectNotFound, entityID)
+		}
+		return 0, fmt.Errorf("error getting risk score: %w", err)
+	}
+
+	return score, nil
+}
+
+// BucketScoreHistory summarises score changes into fixed-size buckets
+// covering since to until. initial is the score held at since; buckets
+// without changes carry the previous score forward.
+func BucketScoreHistory(initial int, changes []*models.RiskScoreChange, since, until time.Time, bucket time.Duration) []*models.RiskScorePoint {
+	points := make([]*models.RiskScorePoint, 0)
+	score := initial
+	next := 0
+
+	for start := since.Truncate(bucket); start.Before(until); start = start.Add(bucket) {
+		end := start.Add(bucket)
+		last := !end.Before(until)
+		point := &models.RiskScorePoint{Time: start, Score: score, Min: score, Max: score}
+
+		for next < len(changes) && (last || changes[next].RecordedAt.Before(end)) {
+			score = changes[next].NewScore
+			if score < point.Min {
+				point.Min = score
+			}
+			if score > point.Max {
+				point.Max = score
+			}
+			point.Score = score
+			point.Changes++
+			next++
+		}
+
+		points = append(points, point)
+	}
+
+	return points
+}
@@ -0,0 +1,101 @@
+package risk
+
+import (
+	"testing"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestBucketScoreHistory(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := since.Add(4 * time.Hour)
+
+	changes := []*models.RiskScoreChange{
+		{RecordedAt: since.Add(10 * time.Minute), OldScore: 20, NewScore: 50},
+		{RecordedAt: since.Add(20 * time.Minute), OldScore: 50, NewScore: 10},
+		{RecordedAt: since.Add(150 * time.Minute), OldScore: 10, NewScore: 70},
+	}
+
+	points := BucketScoreHistory(20, changes, since, until, time.Hour)
+
+	if len(points) != 4 {
+		t.Fatalf("Expected 4 buckets, got %d", len(points))
+	}
+
+	expected := []models.RiskScorePoint{
+		{Time: since, Score: 10, Min: 10, Max: 50, Changes: 2},
+		{Time: since.Add(time.Hour), Score: 10, Min: 10, Max: 10},
+		{Time: since.Add(2 * time.Hour), Score: 70, Min: 10, Max: 70, Changes: 1},
+		{Time: since.Add(3 * time.Hour), Score: 70, Min: 70, Max: 70},
+	}
+	for i, want := range expected {
+		if *points[i] != want {
+			t.Errorf("Bucket %d: expected %+v, got %+v", i, want, *points[i])
+		}
+	}
+}
+
+func TestEngine_ScoreHistoryRecorded(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	detection := createSimpleTestDetection(t, engine)
+	event := &models.Event{
+		DetectionID: detection.ID,
+		RiskPoints:  40,
+		RiskObject:  &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "history@example.com"},
+	}
+	if err := engine.ProcessEvent(event); err != nil {
+		t.Fatalf("Failed to process event: %v", err)
+	}
+
+	fpInfo := &models.FalsePositive{AnalystName: "analyst@example.com", Reason: "test account", Timestamp: time.Now()}
+	if err := engine.MarkEventAsFalsePositive(event.ID, fpInfo); err != nil {
+		t.Fatalf("Failed to mark false positive: %v", err)
+	}
+	if err := engine.UnmarkEventAsFalsePositive(event.ID); err != nil {
+		t.Fatalf("Failed to unmark false positive: %v", err)
+	}
+	if err := engine.DecayRiskScores(); err != nil {
+		t.Fatalf("Failed to decay risk scores: %v", err)
+	}
+	if _, err := engine.SetRiskScore(event.EntityID, 5, "reviewed"); err != nil {
+		t.Fatalf("Failed to set risk score: %v", err)
+	}
+
+	changes, err := engine.repo.ListScoreHistory(event.EntityID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to list score history: %v", err)
+	}
+
+	expected := []struct {
+		reason   models.ScoreChangeReason
+		oldScore int
+		newScore int
+	}{
+		{models.ScoreChangeEvent, 0, 40},
+		{models.ScoreChangeFalsePositive, 40, 0},
+		{models.ScoreChangeFalsePositiveRemoved, 0, 40},
+		{models.ScoreChangeDecay, 40, 36},
+		{models.ScoreChangeManual, 36, 5},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d score changes, got %d", len(expected), len(changes))
+	}
+	for i, want := range expected {
+		got := changes[i]
+		if got.Reason != want.reason || got.OldScore != want.oldScore || got.NewScore != want.newScore {
+			t.Errorf("Change %d: expected %s %d->%d, got %s %d->%d",
+				i, want.reason, want.oldScore, want.newScore, got.Reason, got.OldScore, got.NewScore)
+		}
+	}
+
+	if changes[0].EventID == nil || *changes[0].EventID != event.ID {
+		t.Errorf("Expected event change to reference event %d", event.ID)
+	}
+	if changes[4].Notes != "reviewed" {
+		t.Errorf("Expected manual change notes 'reviewed', got %q", changes[4].Notes)
+	}
+}
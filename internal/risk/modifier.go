@@ -0,0 +1,169 @@
+package risk
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"riskmatrix/pkg/models"
+)
+
+// ModifierConfig configures how an event's risk points are computed from its
+// detection's base points
+type ModifierConfig struct {
+	// Compute event risk points instead of trusting the points sent with the event
+	Enabled bool
+
+	// Multipliers by detection severity; severities not listed use 1
+	Severity map[models.Severity]float64
+
+	// Multipliers by the criticality recorded for the entity in the inventory
+	Criticality map[models.Criticality]float64
+
+	// Multipliers for critical entities such as crown-jewel hosts or privileged users
+	Entities []EntityModifier
+
+	// Key in the event context holding a detection confidence between 0 and 1
+	// (values above 1 are read as a percentage)
+	ConfidenceField string
+}
+
+// EntityModifier scales the risk points of events for matching entities.
+// Empty criteria match anything; the largest matching multiplier applies.
+type EntityModifier struct {
+	Name         string
+	EntityType   models.EntityType
+	ValuePattern string // glob matched against entity_value, e.g. dc-*
+	Tag          string // inventory tag the entity must carry, e.g. privileged
+	Multiplier   float64
+}
+
+// matches reports whether the modifier applies to an entity with the given
+// inventory entry, which may be nil
+func (m *EntityModifier) matches(obj *models.RiskObject, asset *models.InventoryEntry) bool {
+	if !entityMatches(m.EntityType, m.ValuePattern, obj) {
+		return false
+	}
+	return m.Tag == "" || (asset != nil && asset.HasTag(m.Tag))
+}
+
+// GetDetectionScoringTx returns the severity and base risk points of a
+// detection within a transaction
+func (r *Repository) GetDetectionScoringTx(tx *sql.Tx, detectionID int64) (models.Severity, int, error) {
+	var severity models.Severity
+	var riskPoints int
+
+	err := tx.QueryRow(`SELECT severity, risk_points FROM detections WHERE id = ?`, detectionID).Scan(&severity, &riskPoints)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", 0, fmt.Errorf("detection not found: %d", detectionID)
+		}
+		return "", 0, fmt.Errorf("error getting detection scoring: %w", err)
+	}
+
+	return severity, riskPoints, nil
+}
+
+// parseRiskBreakdown decodes a stored risk breakdown, ignoring missing or
+// malformed values
+func parseRiskBreakdown(breakdown sql.NullString) *models.RiskBreakdown {
+	if !breakdown.Valid || breakdown.String == "" {
+		return nil
+	}
+
+	var parsed models.RiskBreakdown
+	if err := json.Unmarshal([]byte(breakdown.String), &parsed); err != nil {
+		return nil
+	}
+	return &parsed
+}
+
+// formatRiskBreakdown encodes a risk breakdown for storage
+func formatRiskBreakdown(breakdown *models.RiskBreakdown) (sql.NullString, error) {
+	if breakdown == nil {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(breakdown)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// computeRiskPoints applies the configured modifiers to basePoints. asset is
+// the entity's inventory entry, or nil if it has none.
+func computeRiskPoints(config ModifierConfig, basePoints int, severity models.Severity, obj *models.RiskObject, asset *models.InventoryEntry, context string) *models.RiskBreakdown {
+	breakdown := &models.RiskBreakdown{
+		BasePoints: basePoints,
+		Modifiers:  make([]models.RiskModifier, 0),
+	}
+	total := 1.0
+
+	if multiplier, ok := config.Severity[severity]; ok {
+		breakdown.Modifiers = append(breakdown.Modifiers, models.RiskModifier{Type: "severity", Name: string(severity), Multiplier: multiplier})
+		total *= multiplier
+	}
+
+	if asset != nil && asset.Criticality != "" {
+		if multiplier, ok := config.Criticality[asset.Criticality]; ok {
+			breakdown.Modifiers = append(breakdown.Modifiers, models.RiskModifier{Type: "criticality", Name: string(asset.Criticality), Multiplier: multiplier})
+			total *= multiplier
+		}
+	}
+
+	var entity *EntityModifier
+	for i := range config.Entities {
+		modifier := &config.Entities[i]
+		if modifier.matches(obj, asset) && (entity == nil || modifier.Multiplier > entity.Multiplier) {
+			entity = modifier
+		}
+	}
+	if entity != nil {
+		breakdown.Modifiers = append(breakdown.Modifiers, models.RiskModifier{Type: "entity", Name: entity.Name, Multiplier: entity.Multiplier})
+		total *= entity.Multiplier
+	}
+
+	if confidence, ok := contextConfidence(context, config.ConfidenceField); ok {
+		breakdown.Modifiers = append(breakdown.Modifiers, models.RiskModifier{Type: "confidence", Name: config.ConfidenceField, Multiplier: confidence})
+		total *= confidence
+	}
+
+	breakdown.EffectivePoints = int(math.Round(float64(basePoints) * total))
+	return breakdown
+}
+
+// contextConfidence reads a confidence between 0 and 1 from field of a JSON
+// event context
+func contextConfidence(context, field string) (float64, bool) {
+	if field == "" || context == "" {
+		return 0, false
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(context), &values); err != nil {
+		return 0, false
+	}
+
+	var confidence float64
+	switch v := values[field].(type) {
+	case float64:
+		confidence = v
+	case string:
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false
+		}
+		confidence = parsed
+	default:
+		return 0, false
+	}
+
+	// Treat values above 1 as percentages
+	if confidence > 1 {
+		confidence /= 100
+	}
+	return math.Max(0, math.Min(1, confidence)), true
+}
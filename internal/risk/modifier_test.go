@@ -0,0 +1,151 @@
+package risk
+
+import (
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestComputeRiskPoints(t *testing.T) {
+	config := ModifierConfig{
+		Enabled:         true,
+		Severity:        map[models.Severity]float64{models.SeverityLow: 0.5, models.SeverityHigh: 1.5},
+		ConfidenceField: "confidence",
+		Entities: []EntityModifier{
+			{Name: "Servers", EntityType: models.EntityTypeHost, Multiplier: 1.2},
+			{Name: "Crown jewels", EntityType: models.EntityTypeHost, ValuePattern: "dc-*", Multiplier: 2},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		severity  models.Severity
+		entity    *models.RiskObject
+		context   string
+		expected  int
+		modifiers int
+	}{
+		{"Severity only", models.SeverityHigh, &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "bob"}, "", 60, 1},
+		{"Unlisted severity", models.SeverityMedium, &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "bob"}, "", 40, 0},
+		{"Largest entity multiplier wins", models.SeverityLow, &models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "DC-01"}, "", 40, 2},
+		{"Confidence fraction", models.SeverityHigh, &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "bob"}, `{"confidence": 0.5}`, 30, 2},
+		{"Confidence percentage", models.SeverityHigh, &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "bob"}, `{"confidence": "25"}`, 15, 2},
+		{"Malformed context ignored", models.SeverityHigh, &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "bob"}, `not json`, 60, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breakdown := computeRiskPoints(config, 40, tt.severity, tt.entity, nil, tt.context)
+			if breakdown.BasePoints != 40 {
+				t.Errorf("Expected base points 40, got %d", breakdown.BasePoints)
+			}
+			if breakdown.EffectivePoints != tt.expected {
+				t.Errorf("Expected %d effective points, got %d", tt.expected, breakdown.EffectivePoints)
+			}
+			if len(breakdown.Modifiers) != tt.modifiers {
+				t.Errorf("Expected %d modifiers, got %d", tt.modifiers, len(breakdown.Modifiers))
+			}
+		})
+	}
+}
+
+func TestEngine_RiskModifiers(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	engine.config.Modifiers = ModifierConfig{
+		Enabled:         true,
+		Severity:        map[models.Severity]float64{models.SeverityHigh: 1.5},
+		ConfidenceField: "confidence",
+		Entities:        []EntityModifier{{Name: "Crown jewels", EntityType: models.EntityTypeHost, ValuePattern: "dc-*", Multiplier: 2}},
+	}
+
+	// The test detection is high severity and worth 25 points; the points
+	// sent with the event are ignored
+	detection := createSimpleTestDetection(t, engine)
+	event := &models.Event{
+		DetectionID: detection.ID,
+		RiskPoints:  0,
+		Context:     `{"confidence": 80}`,
+		RiskObject:  &models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "dc-01"},
+	}
+	if err := engine.ProcessEvent(event); err != nil {
+		t.Fatalf("Failed to process event: %v", err)
+	}
+
+	// 25 * 1.5 * 2 * 0.8
+	if event.RiskPoints != 60 {
+		t.Errorf("Expected 60 risk points, got %d", event.RiskPoints)
+	}
+
+	stored, err := engine.repo.GetEvent(event.ID)
+	if err != nil {
+		t.Fatalf("Failed to get event: %v", err)
+	}
+	if stored.RiskPoints != 60 {
+		t.Errorf("Expected stored risk points 60, got %d", stored.RiskPoints)
+	}
+	if stored.RiskBreakdown == nil {
+		t.Fatal("Expected stored risk breakdown")
+	}
+	if stored.RiskBreakdown.BasePoints != 25 || len(stored.RiskBreakdown.Modifiers) != 3 {
+		t.Errorf("Expected base 25 with 3 modifiers, got base %d with %d modifiers",
+			stored.RiskBreakdown.BasePoints, len(stored.RiskBreakdown.Modifiers))
+	}
+
+	obj, _ := engine.repo.GetRiskObjectByEntity(models.EntityTypeHost, "dc-01")
+	if obj.CurrentScore != 60 {
+		t.Errorf("Expected score 60, got %d", obj.CurrentScore)
+	}
+}
+
+func TestEngine_InventoryModifiersAndRouting(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	engine.config.Modifiers = ModifierConfig{
+		Enabled:     true,
+		Criticality: map[models.Criticality]float64{models.CriticalityCritical: 2},
+		Entities:    []EntityModifier{{Name: "Privileged", Tag: "privileged", Multiplier: 1.5}},
+	}
+
+	_, err := engine.db.Exec(`INSERT INTO inventory (entity_type, entity_value, owner, criticality, tags) VALUES (?, ?, ?, ?, ?)`,
+		"ip", "10.0.0.0/24", "soc-dc@example.com", "critical", `["privileged"]`)
+	if err != nil {
+		t.Fatalf("Failed to create inventory entry: %v", err)
+	}
+
+	// 25 base points * 2 (critical) * 1.5 (privileged) = 75 per event
+	detection := createSimpleTestDetection(t, engine)
+	for i := 0; i < 2; i++ {
+		event := &models.Event{
+			DetectionID: detection.ID,
+			RiskObject:  &models.RiskObject{EntityType: models.EntityTypeIP, EntityValue: "10.0.0.7"},
+		}
+		if err := engine.ProcessEvent(event); err != nil {
+			t.Fatalf("Failed to process event: %v", err)
+		}
+		if event.RiskPoints != 75 {
+			t.Errorf("Expected 75 risk points, got %d", event.RiskPoints)
+		}
+	}
+
+	alerts, err := engine.GetRiskAlerts()
+	if err != nil {
+		t.Fatalf("Failed to get risk alerts: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(alerts))
+	}
+	if alerts[0].Owner != "soc-dc@example.com" {
+		t.Errorf("Expected alert routed to asset owner, got %q", alerts[0].Owner)
+	}
+
+	obj, _ := engine.repo.GetRiskObjectByEntity(models.EntityTypeIP, "10.0.0.7")
+	if err := engine.repo.EnrichRiskObjects(obj); err != nil {
+		t.Fatalf("Failed to enrich risk object: %v", err)
+	}
+	if obj.Inventory == nil || obj.Inventory.Criticality != models.CriticalityCritical {
+		t.Error("Expected risk object enriched with critical inventory entry")
+	}
+}
@@ -2,21 +2,61 @@ package risk
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"riskmatrix/internal/inventory"
 	"riskmatrix/pkg/database"
 	"riskmatrix/pkg/models"
 )
 
+// ErrRiskObjectNotFound is returned when a risk object does not exist
+var ErrRiskObjectNotFound = errors.New("risk object not found")
+
 // Repository implements the risk-related data access
 type Repository struct {
-	db *database.DB
+	db        *database.DB
+	inventory *inventory.Repository
 }
 
 // NewRepository creates a new risk repository
 func NewRepository(db *database.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{db: db, inventory: inventory.NewRepository(db)}
+}
+
+// EnrichRiskObjects attaches the matching asset or identity inventory entry
+// to each risk object
+func (r *Repository) EnrichRiskObjects(objs ...*models.RiskObject) error {
+	if len(objs) == 0 {
+		return nil
+	}
+
+	if len(objs) == 1 {
+		entry, err := r.inventory.MatchInventoryEntry(objs[0].EntityType, objs[0].EntityValue)
+		if err != nil {
+			return err
+		}
+		objs[0].Inventory = entry
+		return nil
+	}
+
+	matcher, err := r.inventory.LoadMatcher()
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objs {
+		obj.Inventory = matcher.Match(obj.EntityType, obj.EntityValue)
+	}
+
+	return nil
+}
+
+// GetInventoryEntryTx returns the inventory entry describing a risk object
+// within a transaction, or nil if there is none
+func (r *Repository) GetInventoryEntryTx(tx *sql.Tx, obj *models.RiskObject) (*models.InventoryEntry, error) {
+	return r.inventory.MatchInventoryEntryTx(tx, obj.EntityType, obj.EntityValue)
 }
 
 // Transaction methods
@@ -42,7 +82,7 @@ func (r *Repository) GetRiskObjectByEntityTx(tx *sql.Tx, entityType models.Entit
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("risk object not found for %s '%s'", entityType, entityValue)
+			return nil, fmt.Errorf("%w for %s '%s'", ErrRiskObjectNotFound, entityType, entityValue)
 		}
 		return nil, fmt.Errorf("error scanning risk object: %w", err)
 	}
@@ -120,7 +160,7 @@ func (r *Repository) GetRiskObjectTx(tx *sql.Tx, id int64) (*models.RiskObject,
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("risk object not found: %d", id)
+			return nil, fmt.Errorf("%w: %d", ErrRiskObjectNotFound, id)
 		}
 		return nil, fmt.Errorf("error scanning risk object: %w", err)
 	}
@@ -133,8 +173,13 @@ func (r *Repository) GetRiskObjectTx(tx *sql.Tx, id int64) (*models.RiskObject,
 
 // CreateEventTx creates an event within a transaction
 func (r *Repository) CreateEventTx(tx *sql.Tx, event *models.Event) error {
-	query := `INSERT INTO events (detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive) 
-              VALUES (?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO events (detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive, risk_breakdown) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	breakdown, err := formatRiskBreakdown(event.RiskBreakdown)
+	if err != nil {
+		return fmt.Errorf("error encoding risk breakdown: %w", err)
+	}
 
 	result, err := tx.Exec(
 		query,
@@ -145,6 +190,7 @@ func (r *Repository) CreateEventTx(tx *sql.Tx, event *models.Event) error {
 		event.Context,
 		event.RiskPoints,
 		event.IsFalsePositive,
+		breakdown,
 	)
 
 	if err != nil {
@@ -162,7 +208,7 @@ func (r *Repository) CreateEventTx(tx *sql.Tx, event *models.Event) error {
 
 // GetEventTx gets an event by ID within a transaction
 func (r *Repository) GetEventTx(tx *sql.Tx, id int64) (*models.Event, error) {
-	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive 
+	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive, risk_breakdown 
               FROM events 
               WHERE id = ?`
 
@@ -170,7 +216,7 @@ func (r *Repository) GetEventTx(tx *sql.Tx, id int64) (*models.Event, error) {
 
 	var event models.Event
 	var timestamp string
-	var context sql.NullString
+	var context, breakdown sql.NullString
 
 	err := row.Scan(
 		&event.ID,
@@ -181,6 +227,7 @@ func (r *Repository) GetEventTx(tx *sql.Tx, id int64) (*models.Event, error) {
 		&context,
 		&event.RiskPoints,
 		&event.IsFalsePositive,
+		&breakdown,
 	)
 
 	if err != nil {
@@ -197,6 +244,7 @@ func (r *Repository) GetEventTx(tx *sql.Tx, id int64) (*models.Event, error) {
 	if context.Valid {
 		event.Context = context.String
 	}
+	event.RiskBreakdown = parseRiskBreakdown(breakdown)
 
 	return &event, nil
 }
@@ -222,8 +270,8 @@ func (r *Repository) UpdateEventTx(tx *sql.Tx, event *models.Event) error {
 
 // CreateRiskAlertTx creates a risk alert within a transaction
 func (r *Repository) CreateRiskAlertTx(tx *sql.Tx, alert *models.RiskAlert) error {
-	query := `INSERT INTO risk_alerts (entity_id, triggered_at, total_score, status, notes, owner) 
-              VALUES (?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO risk_alerts (entity_id, triggered_at, total_score, status, notes, owner, group_id, rule_id) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
 	result, err := tx.Exec(
 		query,
@@ -233,6 +281,8 @@ func (r *Repository) CreateRiskAlertTx(tx *sql.Tx, alert *models.RiskAlert) erro
 		alert.Status,
 		alert.Notes,
 		alert.Owner,
+		alert.GroupID,
+		alert.RuleID,
 	)
 
 	if err != nil {
@@ -248,6 +298,165 @@ func (r *Repository) CreateRiskAlertTx(tx *sql.Tx, alert *models.RiskAlert) erro
 	return nil
 }
 
+// GetLatestRiskAlertByEntityTx gets the most recently triggered alert for an
+// entity within a transaction, or nil if the entity has never alerted. Alerts
+// raised on the entity's group or by alert rules are not included.
+func (r *Repository) GetLatestRiskAlertByEntityTx(tx *sql.Tx, entityID int64) (*models.RiskAlert, error) {
+	return r.getLatestRiskAlertTx(tx, `entity_id = ? AND group_id IS NULL AND rule_id IS NULL`, entityID)
+}
+
+// GetLatestRiskAlertByRuleTx gets the most recently triggered alert raised by
+// a rule for an entity within a transaction, or nil if there is none
+func (r *Repository) GetLatestRiskAlertByRuleTx(tx *sql.Tx, entityID, ruleID int64) (*models.RiskAlert, error) {
+	return r.getLatestRiskAlertTx(tx, `entity_id = ? AND rule_id = ?`, entityID, ruleID)
+}
+
+// GetLatestRiskAlertByGroupTx gets the most recently triggered alert for an
+// entity group within a transaction, or nil if the group has never alerted
+func (r *Repository) GetLatestRiskAlertByGroupTx(tx *sql.Tx, groupID int64) (*models.RiskAlert, error) {
+	return r.getLatestRiskAlertTx(tx, `group_id = ?`, groupID)
+}
+
+// getLatestRiskAlertTx gets the most recently triggered alert matching where
+func (r *Repository) getLatestRiskAlertTx(tx *sql.Tx, where string, args ...interface{}) (*models.RiskAlert, error) {
+	query := `SELECT id, entity_id, triggered_at, total_score, status, closed_at, group_id, rule_id 
+              FROM risk_alerts 
+              WHERE ` + where + ` 
+              ORDER BY triggered_at DESC, id DESC 
+              LIMIT 1`
+
+	var alert models.RiskAlert
+	var triggeredAt string
+	var closedAt sql.NullString
+	var groupID, ruleID sql.NullInt64
+
+	err := tx.QueryRow(query, args...).Scan(
+		&alert.ID,
+		&alert.EntityID,
+		&triggeredAt,
+		&alert.TotalScore,
+		&alert.Status,
+		&closedAt,
+		&groupID,
+		&ruleID,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error scanning risk alert: %w", err)
+	}
+
+	// Parse timestamps
+	alert.TriggeredAt, _ = time.Parse(time.RFC3339, triggeredAt)
+	if closedAt.Valid {
+		if parsedTime, err := time.Parse(time.RFC3339, closedAt.String); err == nil {
+			alert.ClosedAt = &parsedTime
+		}
+	}
+	if groupID.Valid {
+		alert.GroupID = &groupID.Int64
+	}
+	if ruleID.Valid {
+		alert.RuleID = &ruleID.Int64
+	}
+
+	return &alert, nil
+}
+
+// UpdateRiskAlertScoreTx updates the total score of a risk alert within a transaction
+func (r *Repository) UpdateRiskAlertScoreTx(tx *sql.Tx, alertID int64, totalScore int) error {
+	_, err := tx.Exec(`UPDATE risk_alerts SET total_score = ? WHERE id = ?`, totalScore, alertID)
+	if err != nil {
+		return fmt.Errorf("error updating risk alert score: %w", err)
+	}
+
+	return nil
+}
+
+// AddAlertEventTx links an event to a risk alert with the points it contributed,
+// within a transaction
+func (r *Repository) AddAlertEventTx(tx *sql.Tx, alertID, eventID int64, riskPoints int) error {
+	query := `INSERT OR IGNORE INTO risk_alert_events (alert_id, event_id, risk_points) 
+              VALUES (?, ?, ?)`
+
+	_, err := tx.Exec(query, alertID, eventID, riskPoints)
+	if err != nil {
+		return fmt.Errorf("error linking event to risk alert: %w", err)
+	}
+
+	return nil
+}
+
+// LinkWindowEventsToAlertTx links every non-false-positive event of an entity
+// with a timestamp at or after since to a risk alert, within a transaction
+func (r *Repository) LinkWindowEventsToAlertTx(tx *sql.Tx, alertID, entityID int64, since time.Time) error {
+	query := `INSERT OR IGNORE INTO risk_alert_events (alert_id, event_id, risk_points) 
+              SELECT ?, id, risk_points 
+              FROM events 
+              WHERE entity_id = ? AND is_false_positive = 0 AND datetime(timestamp) >= datetime(?)`
+
+	_, err := tx.Exec(query, alertID, entityID, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("error linking events to risk alert: %w", err)
+	}
+
+	return nil
+}
+
+// LinkScoreEventsToAlertTx links the events that make up an entity's running
+// score to a risk alert, within a transaction. Only events added to the score
+// since it last reached zero count, and they are linked newest first until
+// their points reach score, so the stored contributions add up to the alert
+// score even after decay has worn down the older events.
+func (r *Repository) LinkScoreEventsToAlertTx(tx *sql.Tx, alertID, entityID int64, score int) error {
+	query := `SELECT e.id, e.risk_points 
+              FROM events e 
+              JOIN risk_score_history h ON h.event_id = e.id AND h.reason IN (?, ?) 
+              WHERE e.entity_id = ? AND e.is_false_positive = 0 AND e.risk_points > 0 
+                AND h.id > COALESCE((SELECT MAX(id) FROM risk_score_history WHERE entity_id = ? AND new_score = 0), 0) 
+              GROUP BY e.id 
+              ORDER BY MAX(h.id) DESC`
+
+	rows, err := tx.Query(query, models.ScoreChangeEvent, models.ScoreChangeFalsePositiveRemoved, entityID, entityID)
+	if err != nil {
+		return fmt.Errorf("error querying events for risk alert: %w", err)
+	}
+
+	type contribution struct {
+		eventID int64
+		points  int
+	}
+	contributions := make([]contribution, 0)
+	remaining := score
+	for remaining > 0 && rows.Next() {
+		var c contribution
+		if err := rows.Scan(&c.eventID, &c.points); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning event row: %w", err)
+		}
+		if c.points > remaining {
+			c.points = remaining
+		}
+		remaining -= c.points
+		contributions = append(contributions, c)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("error iterating event rows: %w", err)
+	}
+	rows.Close()
+
+	for _, c := range contributions {
+		if err := r.AddAlertEventTx(tx, alertID, c.eventID, c.points); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // CreateFalsePositiveTx creates a false positive record within a transaction
 func (r *Repository) CreateFalsePositiveTx(tx *sql.Tx, fp *models.FalsePositive) error {
 	query := `INSERT INTO false_positives (event_id, reason, analyst_name, timestamp) 
@@ -286,6 +495,21 @@ func (r *Repository) DeleteFalsePositiveByEventTx(tx *sql.Tx, eventID int64) err
 	return nil
 }
 
+// GetWindowedScoreTx sums the risk points of an entity's non-false-positive
+// events with a timestamp at or after since, within a transaction
+func (r *Repository) GetWindowedScoreTx(tx *sql.Tx, entityID int64, since time.Time) (int, error) {
+	query := `SELECT COALESCE(SUM(risk_points), 0) 
+              FROM events 
+              WHERE entity_id = ? AND is_false_positive = 0 AND datetime(timestamp) >= datetime(?)`
+
+	var score int
+	if err := tx.QueryRow(query, entityID, since.UTC().Format(time.RFC3339)).Scan(&score); err != nil {
+		return 0, fmt.Errorf("error calculating windowed score: %w", err)
+	}
+
+	return score, nil
+}
+
 // Non-transaction methods
 
 // GetRiskObject gets a risk object by ID
@@ -309,7 +533,7 @@ func (r *Repository) GetRiskObject(id int64) (*models.RiskObject, error) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("risk object not found: %d", id)
+			return nil, fmt.Errorf("%w: %d", ErrRiskObjectNotFound, id)
 		}
 		return nil, fmt.Errorf("error scanning risk object: %w", err)
 	}
@@ -341,7 +565,7 @@ func (r *Repository) GetRiskObjectByEntity(entityType models.EntityType, entityV
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("risk object not found for %s '%s'", entityType, entityValue)
+			return nil, fmt.Errorf("%w for %s '%s'", ErrRiskObjectNotFound, entityType, entityValue)
 		}
 		return nil, fmt.Errorf("error scanning risk object: %w", err)
 	}
@@ -435,7 +659,7 @@ func (r *Repository) ListHighRiskObjects(threshold int) ([]*models.RiskObject, e
 
 // GetEvent gets an event by ID
 func (r *Repository) GetEvent(id int64) (*models.Event, error) {
-	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive 
+	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive, risk_breakdown 
               FROM events 
               WHERE id = ?`
 
@@ -443,7 +667,7 @@ func (r *Repository) GetEvent(id int64) (*models.Event, error) {
 
 	var event models.Event
 	var timestamp string
-	var context sql.NullString
+	var context, breakdown sql.NullString
 
 	err := row.Scan(
 		&event.ID,
@@ -454,6 +678,7 @@ func (r *Repository) GetEvent(id int64) (*models.Event, error) {
 		&context,
 		&event.RiskPoints,
 		&event.IsFalsePositive,
+		&breakdown,
 	)
 
 	if err != nil {
@@ -470,13 +695,14 @@ func (r *Repository) GetEvent(id int64) (*models.Event, error) {
 	if context.Valid {
 		event.Context = context.String
 	}
+	event.RiskBreakdown = parseRiskBreakdown(breakdown)
 
 	return &event, nil
 }
 
 // ListEvents lists all events
 func (r *Repository) ListEvents() ([]*models.Event, error) {
-	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive 
+	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive, risk_breakdown 
               FROM events 
               ORDER BY timestamp DESC`
 
@@ -491,7 +717,7 @@ func (r *Repository) ListEvents() ([]*models.Event, error) {
 	for rows.Next() {
 		var event models.Event
 		var timestamp string
-		var context sql.NullString
+		var context, breakdown sql.NullString
 
 		err := rows.Scan(
 			&event.ID,
@@ -502,6 +728,7 @@ func (r *Repository) ListEvents() ([]*models.Event, error) {
 			&context,
 			&event.RiskPoints,
 			&event.IsFalsePositive,
+			&breakdown,
 		)
 
 		if err != nil {
@@ -512,6 +739,7 @@ func (r *Repository) ListEvents() ([]*models.Event, error) {
 		if context.Valid {
 			event.Context = context.String
 		}
+		event.RiskBreakdown = parseRiskBreakdown(breakdown)
 
 		// Parse timestamp
 		event.Timestamp, _ = time.Parse(time.RFC3339, timestamp)
@@ -533,7 +761,7 @@ func (r *Repository) ListEventsPaginated(limit, offset int) ([]*models.Event, in
 	}
 
 	// Get paginated events
-	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive 
+	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive, risk_breakdown 
               FROM events 
               ORDER BY timestamp DESC
               LIMIT ? OFFSET ?`
@@ -549,7 +777,7 @@ func (r *Repository) ListEventsPaginated(limit, offset int) ([]*models.Event, in
 	for rows.Next() {
 		var event models.Event
 		var timestamp string
-		var context sql.NullString
+		var context, breakdown sql.NullString
 
 		err := rows.Scan(
 			&event.ID,
@@ -560,6 +788,7 @@ func (r *Repository) ListEventsPaginated(limit, offset int) ([]*models.Event, in
 			&context,
 			&event.RiskPoints,
 			&event.IsFalsePositive,
+			&breakdown,
 		)
 
 		if err != nil {
@@ -570,6 +799,7 @@ func (r *Repository) ListEventsPaginated(limit, offset int) ([]*models.Event, in
 		if context.Valid {
 			event.Context = context.String
 		}
+		event.RiskBreakdown = parseRiskBreakdown(breakdown)
 
 		// Parse timestamp
 		event.Timestamp, _ = time.Parse(time.RFC3339, timestamp)
@@ -582,7 +812,7 @@ func (r *Repository) ListEventsPaginated(limit, offset int) ([]*models.Event, in
 
 // ListEventsByEntity lists events for an entity
 func (r *Repository) ListEventsByEntity(entityID int64) ([]*models.Event, error) {
-	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive 
+	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive, risk_breakdown 
               FROM events 
               WHERE entity_id = ? 
               ORDER BY timestamp DESC`
@@ -598,7 +828,7 @@ func (r *Repository) ListEventsByEntity(entityID int64) ([]*models.Event, error)
 	for rows.Next() {
 		var event models.Event
 		var timestamp string
-		var context sql.NullString
+		var context, breakdown sql.NullString
 
 		err := rows.Scan(
 			&event.ID,
@@ -609,6 +839,7 @@ func (r *Repository) ListEventsByEntity(entityID int64) ([]*models.Event, error)
 			&context,
 			&event.RiskPoints,
 			&event.IsFalsePositive,
+			&breakdown,
 		)
 
 		if err != nil {
@@ -619,6 +850,7 @@ func (r *Repository) ListEventsByEntity(entityID int64) ([]*models.Event, error)
 		if context.Valid {
 			event.Context = context.String
 		}
+		event.RiskBreakdown = parseRiskBreakdown(breakdown)
 
 		// Parse timestamp
 		event.Timestamp, _ = time.Parse(time.RFC3339, timestamp)
@@ -640,13 +872,13 @@ func (r *Repository) ListRiskAlertsByStatus(status models.AlertStatus) ([]*model
 	var args []interface{}
 
 	if status != "" {
-		query = `SELECT id, entity_id, triggered_at, total_score, status, notes, owner 
+		query = `SELECT id, entity_id, triggered_at, total_score, status, notes, owner, closed_at, group_id, rule_id 
                  FROM risk_alerts 
                  WHERE status = ?
                  ORDER BY triggered_at DESC`
 		args = append(args, status)
 	} else {
-		query = `SELECT id, entity_id, triggered_at, total_score, status, notes, owner 
+		query = `SELECT id, entity_id, triggered_at, total_score, status, notes, owner, closed_at, group_id, rule_id 
                  FROM risk_alerts 
                  ORDER BY triggered_at DESC`
 	}
@@ -663,7 +895,8 @@ func (r *Repository) ListRiskAlertsByStatus(status models.AlertStatus) ([]*model
 	for rows.Next() {
 		var alert models.RiskAlert
 		var triggeredAt string
-		var notes, owner sql.NullString
+		var notes, owner, closedAt sql.NullString
+		var groupID, ruleID sql.NullInt64
 
 		err := rows.Scan(
 			&alert.ID,
@@ -673,6 +906,9 @@ func (r *Repository) ListRiskAlertsByStatus(status models.AlertStatus) ([]*model
 			&alert.Status,
 			&notes,
 			&owner,
+			&closedAt,
+			&groupID,
+			&ruleID,
 		)
 
 		if err != nil {
@@ -689,6 +925,17 @@ func (r *Repository) ListRiskAlertsByStatus(status models.AlertStatus) ([]*model
 
 		// Parse timestamp
 		alert.TriggeredAt, _ = time.Parse(time.RFC3339, triggeredAt)
+		if closedAt.Valid {
+			if parsedTime, err := time.Parse(time.RFC3339, closedAt.String); err == nil {
+				alert.ClosedAt = &parsedTime
+			}
+		}
+		if groupID.Valid {
+			alert.GroupID = &groupID.Int64
+		}
+		if ruleID.Valid {
+			alert.RuleID = &ruleID.Int64
+		}
 
 		alerts = append(alerts, &alert)
 	}
@@ -720,14 +967,14 @@ func (r *Repository) ListRiskAlertsPaginated(limit, offset int, status models.Al
 	var args []interface{}
 
 	if status != "" {
-		query = `SELECT id, entity_id, triggered_at, total_score, status, notes, owner 
+		query = `SELECT id, entity_id, triggered_at, total_score, status, notes, owner, closed_at, group_id, rule_id 
                  FROM risk_alerts 
                  WHERE status = ?
                  ORDER BY triggered_at DESC
                  LIMIT ? OFFSET ?`
 		args = append(args, status, limit, offset)
 	} else {
-		query = `SELECT id, entity_id, triggered_at, total_score, status, notes, owner 
+		query = `SELECT id, entity_id, triggered_at, total_score, status, notes, owner, closed_at, group_id, rule_id 
                  FROM risk_alerts 
                  ORDER BY triggered_at DESC
                  LIMIT ? OFFSET ?`
@@ -746,7 +993,8 @@ func (r *Repository) ListRiskAlertsPaginated(limit, offset int, status models.Al
 	for rows.Next() {
 		var alert models.RiskAlert
 		var triggeredAt string
-		var notes, owner sql.NullString
+		var notes, owner, closedAt sql.NullString
+		var groupID, ruleID sql.NullInt64
 
 		err := rows.Scan(
 			&alert.ID,
@@ -756,6 +1004,9 @@ func (r *Repository) ListRiskAlertsPaginated(limit, offset int, status models.Al
 			&alert.Status,
 			&notes,
 			&owner,
+			&closedAt,
+			&groupID,
+			&ruleID,
 		)
 
 		if err != nil {
@@ -772,6 +1023,17 @@ func (r *Repository) ListRiskAlertsPaginated(limit, offset int, status models.Al
 
 		// Parse timestamp
 		alert.TriggeredAt, _ = time.Parse(time.RFC3339, triggeredAt)
+		if closedAt.Valid {
+			if parsedTime, err := time.Parse(time.RFC3339, closedAt.String); err == nil {
+				alert.ClosedAt = &parsedTime
+			}
+		}
+		if groupID.Valid {
+			alert.GroupID = &groupID.Int64
+		}
+		if ruleID.Valid {
+			alert.RuleID = &ruleID.Int64
+		}
 
 		alerts = append(alerts, &alert)
 	}
@@ -781,7 +1043,7 @@ func (r *Repository) ListRiskAlertsPaginated(limit, offset int, status models.Al
 
 // GetRiskAlert retrieves a risk alert by ID
 func (r *Repository) GetRiskAlert(id int64) (*models.RiskAlert, error) {
-	query := `SELECT id, entity_id, triggered_at, total_score, status, notes, owner 
+	query := `SELECT id, entity_id, triggered_at, total_score, status, notes, owner, closed_at, group_id, rule_id 
               FROM risk_alerts 
               WHERE id = ?`
 
@@ -789,7 +1051,8 @@ func (r *Repository) GetRiskAlert(id int64) (*models.RiskAlert, error) {
 
 	var alert models.RiskAlert
 	var triggeredAt string
-	var notes, owner sql.NullString
+	var notes, owner, closedAt sql.NullString
+	var groupID, ruleID sql.NullInt64
 
 	err := row.Scan(
 		&alert.ID,
@@ -799,6 +1062,9 @@ func (r *Repository) GetRiskAlert(id int64) (*models.RiskAlert, error) {
 		&alert.Status,
 		&notes,
 		&owner,
+		&closedAt,
+		&groupID,
+		&ruleID,
 	)
 
 	if err != nil {
@@ -818,14 +1084,26 @@ func (r *Repository) GetRiskAlert(id int64) (*models.RiskAlert, error) {
 
 	// Parse timestamp
 	alert.TriggeredAt, _ = time.Parse(time.RFC3339, triggeredAt)
+	if closedAt.Valid {
+		if parsedTime, err := time.Parse(time.RFC3339, closedAt.String); err == nil {
+			alert.ClosedAt = &parsedTime
+		}
+	}
+	if groupID.Valid {
+		alert.GroupID = &groupID.Int64
+	}
+	if ruleID.Valid {
+		alert.RuleID = &ruleID.Int64
+	}
 
 	return &alert, nil
 }
 
-// UpdateRiskAlert updates a risk alert
+// UpdateRiskAlert updates a risk alert, recording when it was closed
 func (r *Repository) UpdateRiskAlert(alert *models.RiskAlert) error {
 	query := `UPDATE risk_alerts 
-              SET status = ?, notes = ?, owner = ? 
+              SET status = ?, notes = ?, owner = ?, 
+                  closed_at = CASE WHEN ? = 'Closed' THEN COALESCE(closed_at, ?) ELSE NULL END 
               WHERE id = ?`
 
 	result, err := r.db.Exec(
@@ -833,6 +1111,8 @@ func (r *Repository) UpdateRiskAlert(alert *models.RiskAlert) error {
 		alert.Status,
 		alert.Notes,
 		alert.Owner,
+		alert.Status,
+		time.Now().UTC().Format(time.RFC3339),
 		alert.ID,
 	)
 
@@ -852,18 +1132,11 @@ func (r *Repository) UpdateRiskAlert(alert *models.RiskAlert) error {
 	return nil
 }
 
-// GetEventsForAlert gets events that contributed to a risk alert
+// GetEventsForAlert gets the events linked to a risk alert along with the
+// points each contributed to it
 func (r *Repository) GetEventsForAlert(alertID int64) ([]*models.Event, error) {
-	// First get the alert to find the entity and timestamp
-	var alert models.RiskAlert
-	var triggeredAt string
-	var notes, owner sql.NullString
-
-	err := r.db.QueryRow(
-		`SELECT id, entity_id, triggered_at, total_score, status, notes, owner FROM risk_alerts WHERE id = ?`,
-		alertID,
-	).Scan(&alert.ID, &alert.EntityID, &triggeredAt, &alert.TotalScore, &alert.Status, &notes, &owner)
-
+	var exists int
+	err := r.db.QueryRow(`SELECT 1 FROM risk_alerts WHERE id = ?`, alertID).Scan(&exists)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("risk alert not found: %d", alertID)
@@ -871,26 +1144,13 @@ func (r *Repository) GetEventsForAlert(alertID int64) ([]*models.Event, error) {
 		return nil, fmt.Errorf("error scanning risk alert: %w", err)
 	}
 
-	// Handle nullable fields
-	if notes.Valid {
-		alert.Notes = notes.String
-	}
-	if owner.Valid {
-		alert.Owner = owner.String
-	}
-
-	alert.TriggeredAt, _ = time.Parse(time.RFC3339, triggeredAt)
-
-	// Get events for this entity before the alert was triggered
-	// Convert alert timestamp to UTC for proper comparison with event timestamps
-	alertTimeUTC := alert.TriggeredAt.UTC()
+	query := `SELECT e.id, e.detection_id, e.entity_id, e.timestamp, e.raw_data, e.context, e.risk_points, e.is_false_positive, e.risk_breakdown, ae.risk_points 
+              FROM risk_alert_events ae 
+              JOIN events e ON e.id = ae.event_id 
+              WHERE ae.alert_id = ? 
+              ORDER BY e.timestamp DESC`
 
-	query := `SELECT id, detection_id, entity_id, timestamp, raw_data, context, risk_points, is_false_positive 
-              FROM events 
-              WHERE entity_id = ? AND datetime(timestamp) <= datetime(?) AND is_false_positive = 0
-              ORDER BY timestamp DESC`
-
-	rows, err := r.db.Query(query, alert.EntityID, alertTimeUTC.Format(time.RFC3339))
+	rows, err := r.db.Query(query, alertID)
 	if err != nil {
 		return nil, fmt.Errorf("error querying events for alert: %w", err)
 	}
@@ -901,7 +1161,7 @@ func (r *Repository) GetEventsForAlert(alertID int64) ([]*models.Event, error) {
 	for rows.Next() {
 		var event models.Event
 		var timestamp string
-		var context sql.NullString
+		var context, breakdown sql.NullString
 
 		err := rows.Scan(
 			&event.ID,
@@ -912,6 +1172,8 @@ func (r *Repository) GetEventsForAlert(alertID int64) ([]*models.Event, error) {
 			&context,
 			&event.RiskPoints,
 			&event.IsFalsePositive,
+			&breakdown,
+			&event.Contribution,
 		)
 
 		if err != nil {
@@ -922,6 +1184,7 @@ func (r *Repository) GetEventsForAlert(alertID int64) ([]*models.Event, error) {
 		if context.Valid {
 			event.Context = context.String
 		}
+		event.RiskBreakdown = parseRiskBreakdown(breakdown)
 
 		// Parse timestamp
 		event.Timestamp, _ = time.Parse(time.RFC3339, timestamp)
@@ -932,18 +1195,60 @@ func (r *Repository) GetEventsForAlert(alertID int64) ([]*models.Event, error) {
 	return events, nil
 }
 
+// RecalculateRiskScores sets every risk object's score to the sum of its
+// non-false-positive events with a timestamp at or after since
+func (r *Repository) RecalculateRiskScores(since time.Time) error {
+	windowScore := `(SELECT COALESCE(SUM(e.risk_points), 0) 
+                FROM events e 
+                WHERE e.entity_id = risk_objects.id AND e.is_false_positive = 0 
+                  AND datetime(e.timestamp) >= datetime(?))`
+
+	if err := r.rescoreAll(windowScore, since.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("error recalculating risk scores: %w", err)
+	}
+
+	return nil
+}
+
 // DecayRiskScores reduces all risk scores by the decay factor
 func (r *Repository) DecayRiskScores(decayFactor float64) error {
-	query := `UPDATE risk_objects 
-              SET current_score = CASE 
+	decayedScore := `(CASE 
                 WHEN current_score * (1.0 - ?) < 1.0 AND current_score > 0 THEN 0 
                 ELSE CAST(current_score * (1.0 - ?) AS INTEGER) 
-              END`
+              END)`
 
-	_, err := r.db.Exec(query, decayFactor, decayFactor)
-	if err != nil {
+	if err := r.rescoreAll(decayedScore, decayFactor, decayFactor); err != nil {
 		return fmt.Errorf("error decaying risk scores: %w", err)
 	}
 
 	return nil
 }
+
+// rescoreAll sets every risk object's score to scoreExpr, recording a decay
+// entry in the score history for each score that changes
+func (r *Repository) rescoreAll(scoreExpr string, args ...interface{}) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	history := `INSERT INTO risk_score_history (entity_id, recorded_at, old_score, new_score, reason) 
+              SELECT id, ?, current_score, ` + scoreExpr + `, ? 
+              FROM risk_objects 
+              WHERE ` + scoreExpr + ` <> current_score`
+
+	historyArgs := append([]interface{}{time.Now().UTC().Format(time.RFC3339)}, args...)
+	historyArgs = append(historyArgs, models.ScoreChangeDecay)
+	historyArgs = append(historyArgs, args...)
+
+	if _, err := tx.Exec(history, historyArgs...); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE risk_objects SET current_score = `+scoreExpr, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
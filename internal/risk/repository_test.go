@@ -215,3 +215,55 @@ func TestRepository_ListRiskAlerts(t *testing.T) {
 		t.Errorf("Expected 1 alert, got %d", len(alerts))
 	}
 }
+
+func TestRepository_GetEventsForAlert(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	_, err := db.Exec("INSERT INTO detections (name, description, status, severity, risk_points) VALUES (?, ?, ?, ?, ?)",
+		"Test Detection", "Test", "draft", "medium", 50)
+	if err != nil {
+		t.Fatalf("Failed to create test detection: %v", err)
+	}
+
+	testObj := createTestRiskObject(t, db)
+	now := time.Now()
+
+	// An old event that was never part of the alert, and one that was
+	eventQuery := "INSERT INTO events (detection_id, entity_id, timestamp, raw_data, risk_points) VALUES (?, ?, ?, ?, ?)"
+	if _, err := db.Exec(eventQuery, 1, testObj.ID, now.Add(-90*24*time.Hour).Format(time.RFC3339), "old event", 10); err != nil {
+		t.Fatalf("Failed to create old event: %v", err)
+	}
+	result, err := db.Exec(eventQuery, 1, testObj.ID, now.Format(time.RFC3339), "alert event", 40)
+	if err != nil {
+		t.Fatalf("Failed to create alert event: %v", err)
+	}
+	eventID, _ := result.LastInsertId()
+
+	result, err = db.Exec("INSERT INTO risk_alerts (entity_id, triggered_at, total_score) VALUES (?, ?, ?)",
+		testObj.ID, now.Format(time.RFC3339), 75)
+	if err != nil {
+		t.Fatalf("Failed to create test risk alert: %v", err)
+	}
+	alertID, _ := result.LastInsertId()
+
+	if _, err := db.Exec("INSERT INTO risk_alert_events (alert_id, event_id, risk_points) VALUES (?, ?, ?)", alertID, eventID, 35); err != nil {
+		t.Fatalf("Failed to link event to alert: %v", err)
+	}
+
+	events, err := repo.GetEventsForAlert(alertID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 linked event, got %d", len(events))
+	}
+	if events[0].ID != eventID || events[0].Contribution != 35 {
+		t.Errorf("Expected event %d contributing 35, got event %d contributing %d", eventID, events[0].ID, events[0].Contribution)
+	}
+
+	if _, err := repo.GetEventsForAlert(alertID + 1); err == nil {
+		t.Error("Expected error for non-existent alert")
+	}
+}
@@ -0,0 +1,402 @@
+package risk
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// scanAlertRule scans an alert_rules row
+func scanAlertRule(row database.RowScanner) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	var description, entityType, sequence sql.NullString
+	var createdAt, updatedAt string
+
+	err := row.Scan(
+		&rule.ID,
+		&rule.Name,
+		&description,
+		&rule.Type,
+		&entityType,
+		&rule.MinCount,
+		&sequence,
+		&rule.WindowMinutes,
+		&rule.Enabled,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle nullable fields
+	if description.Valid {
+		rule.Description = description.String
+	}
+	if entityType.Valid {
+		rule.EntityType = models.EntityType(entityType.String)
+	}
+	if sequence.Valid && sequence.String != "" {
+		_ = json.Unmarshal([]byte(sequence.String), &rule.Sequence)
+	}
+
+	// Parse timestamps
+	rule.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	rule.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+	return &rule, nil
+}
+
+const alertRuleColumns = `id, name, description, rule_type, entity_type, min_count, sequence, window_minutes, enabled, created_at, updated_at`
+
+// alertRuleArgs returns the column values of a rule shared by inserts and updates
+func alertRuleArgs(rule *models.AlertRule) ([]interface{}, error) {
+	sequence := sql.NullString{}
+	if len(rule.Sequence) > 0 {
+		data, err := json.Marshal(rule.Sequence)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding sequence: %w", err)
+		}
+		sequence = sql.NullString{String: string(data), Valid: true}
+	}
+
+	return []interface{}{
+		rule.Name,
+		sql.NullString{String: rule.Description, Valid: rule.Description != ""},
+		rule.Type,
+		sql.NullString{String: string(rule.EntityType), Valid: rule.EntityType != ""},
+		rule.MinCount,
+		sequence,
+		rule.WindowMinutes,
+		rule.Enabled,
+	}, nil
+}
+
+// GetAlertRule retrieves an alert rule by ID
+func (r *Repository) GetAlertRule(id int64) (*models.AlertRule, error) {
+	query := `SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE id = ?`
+
+	rule, err := scanAlertRule(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("alert rule not found: %d", id)
+		}
+		return nil, fmt.Errorf("error scanning alert rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// ListAlertRules lists all alert rules
+func (r *Repository) ListAlertRules() ([]*models.AlertRule, error) {
+	return r.listAlertRules(r.db, `SELECT `+alertRuleColumns+` FROM alert_rules ORDER BY id`)
+}
+
+// ListEnabledAlertRulesTx lists enabled alert rules within a transaction
+func (r *Repository) ListEnabledAlertRulesTx(tx *sql.Tx) ([]*models.AlertRule, error) {
+	return r.listAlertRules(tx, `SELECT `+alertRuleColumns+` FROM alert_rules WHERE enabled = 1 ORDER BY id`)
+}
+
+// listAlertRules runs query and scans the resulting alert rule rows
+func (r *Repository) listAlertRules(q database.Queryer, query string, args ...interface{}) ([]*models.AlertRule, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*models.AlertRule, 0)
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning alert rule row: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// CreateAlertRule creates an alert rule
+func (r *Repository) CreateAlertRule(rule *models.AlertRule) error {
+	args, err := alertRuleArgs(rule)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	args = append(args, now.Format(time.RFC3339), now.Format(time.RFC3339))
+
+	result, err := r.db.Exec(
+		`INSERT INTO alert_rules (name, description, rule_type, entity_type, min_count, sequence, window_minutes, enabled, created_at, updated_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating alert rule: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	rule.ID = id
+	return nil
+}
+
+// UpdateAlertRule updates an alert rule
+func (r *Repository) UpdateAlertRule(rule *models.AlertRule) error {
+	args, err := alertRuleArgs(rule)
+	if err != nil {
+		return err
+	}
+
+	rule.UpdatedAt = time.Now()
+	args = append(args, rule.UpdatedAt.Format(time.RFC3339), rule.ID)
+
+	result, err := r.db.Exec(
+		`UPDATE alert_rules
+         SET name = ?, description = ?, rule_type = ?, entity_type = ?, min_count = ?, sequence = ?, window_minutes = ?, enabled = ?, updated_at = ?
+         WHERE id = ?`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating alert rule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("alert rule not found: %d", rule.ID)
+	}
+
+	return nil
+}
+
+// DeleteAlertRule deletes an alert rule
+func (r *Repository) DeleteAlertRule(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM alert_rules WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting alert rule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("alert rule not found: %d", id)
+	}
+
+	return nil
+}
+
+// ruleEvent is an event as seen by alert rules: its detection and the MITRE
+// tactics the detection covers
+type ruleEvent struct {
+	ID          int64
+	DetectionID int64
+	Timestamp   time.Time
+	Tactics     []string
+}
+
+// listRuleEventsTx lists the non-false-positive events of an entity with a
+// timestamp at or after since, oldest first, within a transaction
+func (r *Repository) listRuleEventsTx(tx *sql.Tx, entityID int64, since time.Time) ([]*ruleEvent, error) {
+	query := `SELECT e.id, e.detection_id, e.timestamp, mt.tactic, mt.tactics
+              FROM events e
+              LEFT JOIN detection_mitre_map dm ON dm.detection_id = e.detection_id
+              LEFT JOIN mitre_techniques mt ON mt.id = dm.mitre_id
+              WHERE e.entity_id = ? AND e.is_false_positive = 0 AND datetime(e.timestamp) >= datetime(?)
+              ORDER BY datetime(e.timestamp), e.id`
+
+	rows, err := tx.Query(query, entityID, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("error querying rule events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*ruleEvent, 0)
+	var event *ruleEvent
+
+	for rows.Next() {
+		var id, detectionID int64
+		var timestamp string
+		var tactic, tactics sql.NullString
+
+		if err := rows.Scan(&id, &detectionID, &timestamp, &tactic, &tactics); err != nil {
+			return nil, fmt.Errorf("error scanning rule event row: %w", err)
+		}
+
+		// Each mapped technique adds a row for the same event
+		if event == nil || event.ID != id {
+			event = &ruleEvent{ID: id, DetectionID: detectionID}
+			event.Timestamp, _ = time.Parse(time.RFC3339, timestamp)
+			events = append(events, event)
+		}
+
+		if tactic.Valid && tactic.String != "" {
+			event.Tactics = append(event.Tactics, tactic.String)
+		}
+		if tactics.Valid && tactics.String != "" {
+			var extra []string
+			if err := json.Unmarshal([]byte(tactics.String), &extra); err == nil {
+				event.Tactics = append(event.Tactics, extra...)
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// normalizeTactic folds tactic names such as "Initial Access" and
+// "initial-access" to the same key
+func normalizeTactic(tactic string) string {
+	tactic = strings.ToLower(strings.TrimSpace(tactic))
+	return strings.NewReplacer("-", " ", "_", " ").Replace(tactic)
+}
+
+// hasTactic reports whether an event's detection covers a normalized tactic
+func (ev *ruleEvent) hasTactic(tactic string) bool {
+	for _, t := range ev.Tactics {
+		if normalizeTactic(t) == tactic {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateAlertRule reports whether events, oldest first, meet a rule's condition
+func evaluateAlertRule(rule *models.AlertRule, events []*ruleEvent) bool {
+	switch rule.Type {
+	case models.AlertRuleDistinctDetections:
+		detections := make(map[int64]bool)
+		for _, ev := range events {
+			detections[ev.DetectionID] = true
+		}
+		return rule.MinCount > 0 && len(detections) >= rule.MinCount
+
+	case models.AlertRuleDistinctTactics:
+		tactics := make(map[string]bool)
+		for _, ev := range events {
+			for _, t := range ev.Tactics {
+				tactics[normalizeTactic(t)] = true
+			}
+		}
+		return rule.MinCount > 0 && len(tactics) >= rule.MinCount
+
+	case models.AlertRuleTacticSequence:
+		// Each step must be hit by a later event than the step before it
+		step := 0
+		for _, ev := range events {
+			if step < len(rule.Sequence) && ev.hasTactic(normalizeTactic(rule.Sequence[step])) {
+				step++
+			}
+		}
+		return len(rule.Sequence) > 0 && step == len(rule.Sequence)
+	}
+
+	return false
+}
+
+// evaluateRulesTx raises an alert for each enabled rule the entity's recent
+// events now meet. Events for an entity with an open alert from a rule are
+// attached to that alert instead.
+func (e *Engine) evaluateRulesTx(tx *sql.Tx, event *models.Event, riskObject *models.RiskObject, asset *models.InventoryEntry, now time.Time) error {
+	enabled, err := e.repo.ListEnabledAlertRulesTx(tx)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]*models.AlertRule, 0, len(enabled))
+	var maxWindow time.Duration
+	for _, rule := range enabled {
+		if !entityMatches(rule.EntityType, "", riskObject) {
+			continue
+		}
+		rules = append(rules, rule)
+		if window := time.Duration(rule.WindowMinutes) * time.Minute; window > maxWindow {
+			maxWindow = window
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	events, err := e.repo.listRuleEventsTx(tx, riskObject.ID, now.Add(-maxWindow))
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		since := now.Add(-time.Duration(rule.WindowMinutes) * time.Minute)
+
+		inWindow := make([]*ruleEvent, 0, len(events))
+		for _, ev := range events {
+			if !ev.Timestamp.Before(since) {
+				inWindow = append(inWindow, ev)
+			}
+		}
+		if !evaluateAlertRule(rule, inWindow) {
+			continue
+		}
+
+		latest, err := e.repo.GetLatestRiskAlertByRuleTx(tx, riskObject.ID, rule.ID)
+		if err != nil {
+			return err
+		}
+
+		if latest != nil && latest.Status != models.AlertStatusClosed {
+			// Attach the event to the rule's alert that is still being worked
+			if err := e.repo.AddAlertEventTx(tx, latest.ID, event.ID, event.RiskPoints); err != nil {
+				return err
+			}
+			if err := e.repo.UpdateRiskAlertScoreTx(tx, latest.ID, latest.TotalScore+event.RiskPoints); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if e.inCooldown(latest, now) {
+			continue
+		}
+
+		alert := &models.RiskAlert{
+			EntityID:    riskObject.ID,
+			RuleID:      &rule.ID,
+			TriggeredAt: now,
+			TotalScore:  riskObject.CurrentScore,
+			Status:      models.AlertStatusNew,
+		}
+		if asset != nil {
+			alert.Owner = asset.Owner
+		}
+
+		if err := e.repo.CreateRiskAlertTx(tx, alert); err != nil {
+			return err
+		}
+
+		// Record the events the rule was evaluated over
+		if err := e.repo.LinkWindowEventsToAlertTx(tx, alert.ID, riskObject.ID, since); err != nil {
+			return err
+		}
+
+		log.Printf("Risk alert generated for %s '%s' by alert rule '%s'",
+			riskObject.EntityType, riskObject.EntityValue, rule.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package risk
+
+import (
+	"testing"
+	"time"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestEvaluateAlertRule(t *testing.T) {
+	events := []*ruleEvent{
+		{ID: 1, DetectionID: 1, Tactics: []string{"Execution"}},
+		{ID: 2, DetectionID: 2, Tactics: []string{"Initial Access"}},
+		{ID: 3, DetectionID: 2, Tactics: []string{"Initial Access"}},
+		{ID: 4, DetectionID: 3, Tactics: []string{"execution", "persistence"}},
+	}
+
+	tests := []struct {
+		name     string
+		rule     models.AlertRule
+		expected bool
+	}{
+		{"Distinct detections met", models.AlertRule{Type: models.AlertRuleDistinctDetections, MinCount: 3}, true},
+		{"Distinct detections not met", models.AlertRule{Type: models.AlertRuleDistinctDetections, MinCount: 4}, false},
+		{"Distinct tactics met", models.AlertRule{Type: models.AlertRuleDistinctTactics, MinCount: 3}, true},
+		{"Distinct tactics not met", models.AlertRule{Type: models.AlertRuleDistinctTactics, MinCount: 4}, false},
+		{"Sequence in order", models.AlertRule{Type: models.AlertRuleTacticSequence, Sequence: []string{"initial-access", "Execution"}}, true},
+		{"Sequence out of order", models.AlertRule{Type: models.AlertRuleTacticSequence, Sequence: []string{"Persistence", "Initial Access"}}, false},
+		{"Empty sequence", models.AlertRule{Type: models.AlertRuleTacticSequence}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluateAlertRule(&tt.rule, events); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEngine_AlertRuleSequence(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	initialAccess := createSimpleTestDetection(t, engine)
+	execution := createSimpleTestDetection(t, engine)
+
+	for _, mapping := range []struct {
+		detectionID     int64
+		technique, name string
+		tactic          string
+	}{
+		{initialAccess.ID, "T1566", "Phishing", "Initial Access"},
+		{execution.ID, "T1059", "Command and Scripting Interpreter", "Execution"},
+	} {
+		if _, err := engine.db.Exec(`INSERT INTO mitre_techniques (id, name, tactic) VALUES (?, ?, ?)`, mapping.technique, mapping.name, mapping.tactic); err != nil {
+			t.Fatalf("Failed to create technique: %v", err)
+		}
+		if _, err := engine.db.Exec(`INSERT INTO detection_mitre_map (detection_id, mitre_id) VALUES (?, ?)`, mapping.detectionID, mapping.technique); err != nil {
+			t.Fatalf("Failed to map technique: %v", err)
+		}
+	}
+
+	rule := &models.AlertRule{
+		Name:          "Initial access then execution",
+		Type:          models.AlertRuleTacticSequence,
+		Sequence:      []string{"Initial Access", "Execution"},
+		WindowMinutes: 60,
+		Enabled:       true,
+	}
+	if err := engine.repo.CreateAlertRule(rule); err != nil {
+		t.Fatalf("Failed to create alert rule: %v", err)
+	}
+
+	// Execution before initial access does not match, the later execution does
+	now := time.Now()
+	for i, detectionID := range []int64{execution.ID, initialAccess.ID, execution.ID} {
+		event := &models.Event{
+			DetectionID: detectionID,
+			RiskObject:  &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "alice"},
+			Timestamp:   now.Add(time.Duration(i-3) * time.Minute),
+			RiskPoints:  10,
+		}
+		if err := engine.ProcessEvent(event); err != nil {
+			t.Fatalf("Failed to process event: %v", err)
+		}
+
+		alerts, err := engine.GetRiskAlerts()
+		if err != nil {
+			t.Fatalf("Failed to get risk alerts: %v", err)
+		}
+		if i < 2 && len(alerts) != 0 {
+			t.Fatalf("Expected no alert after event %d, got %d", i+1, len(alerts))
+		}
+		if i == 2 {
+			if len(alerts) != 1 {
+				t.Fatalf("Expected 1 rule alert, got %d", len(alerts))
+			}
+			if alerts[0].RuleID == nil || *alerts[0].RuleID != rule.ID {
+				t.Errorf("Expected alert to record rule %d, got %v", rule.ID, alerts[0].RuleID)
+			}
+		}
+	}
+}
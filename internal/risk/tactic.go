@@ -0,0 +1,220 @@
+package risk
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// TacticDiversityConfig configures scoring on the breadth of the kill chain
+// an entity's detections cover
+type TacticDiversityConfig struct {
+	// Track distinct tactics per entity and act when they exceed Limit
+	Enabled bool
+
+	// Window over which distinct tactics are counted (0 uses ScoreWindow,
+	// and then 24 hours)
+	Window time.Duration
+
+	// Number of distinct tactics an entity can reach before the bonus and
+	// alert apply
+	Limit int
+
+	// Points added to an event for each new tactic beyond Limit
+	Bonus int
+
+	// Raise a risk alert when an entity first exceeds Limit, whatever its score
+	Alert bool
+}
+
+// tacticWindow returns the window over which distinct tactics are counted
+func (e *Engine) tacticWindow() time.Duration {
+	if e.config.TacticDiversity.Window > 0 {
+		return e.config.TacticDiversity.Window
+	}
+	if e.config.ScoreWindow > 0 {
+		return e.config.ScoreWindow
+	}
+	return 24 * time.Hour
+}
+
+// scanTacticCoverage collects the distinct techniques and tactics from rows of
+// technique id, primary tactic and JSON tactics
+func scanTacticCoverage(rows *sql.Rows) (*models.TacticCoverage, error) {
+	defer rows.Close()
+
+	coverage := &models.TacticCoverage{Tactics: make([]string, 0), Techniques: make([]string, 0)}
+	seenTactics := make(map[string]bool)
+	seenTechniques := make(map[string]bool)
+
+	for rows.Next() {
+		var techniqueID string
+		var tactic, tactics sql.NullString
+
+		if err := rows.Scan(&techniqueID, &tactic, &tactics); err != nil {
+			return nil, fmt.Errorf("error scanning tactic coverage row: %w", err)
+		}
+
+		if !seenTechniques[techniqueID] {
+			seenTechniques[techniqueID] = true
+			coverage.Techniques = append(coverage.Techniques, techniqueID)
+		}
+
+		names := make([]string, 0)
+		if tactic.Valid && tactic.String != "" {
+			names = append(names, tactic.String)
+		}
+		if tactics.Valid && tactics.String != "" {
+			var extra []string
+			if err := json.Unmarshal([]byte(tactics.String), &extra); err == nil {
+				names = append(names, extra...)
+			}
+		}
+		for _, name := range names {
+			if key := normalizeTactic(name); key != "" && !seenTactics[key] {
+				seenTactics[key] = true
+				coverage.Tactics = append(coverage.Tactics, name)
+			}
+		}
+	}
+
+	sort.Strings(coverage.Tactics)
+	sort.Strings(coverage.Techniques)
+	return coverage, nil
+}
+
+// entityTacticCoverage returns the tactics and techniques of an entity's
+// non-false-positive events with a timestamp at or after since
+func (r *Repository) entityTacticCoverage(q database.Queryer, entityID int64, since time.Time) (*models.TacticCoverage, error) {
+	rows, err := q.Query(
+		`SELECT DISTINCT mt.id, mt.tactic, mt.tactics
+         FROM events e
+         JOIN detection_mitre_map dm ON dm.detection_id = e.detection_id
+         JOIN mitre_techniques mt ON mt.id = dm.mitre_id
+         WHERE e.entity_id = ? AND e.is_false_positive = 0 AND datetime(e.timestamp) >= datetime(?)`,
+		entityID, since.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tactic coverage: %w", err)
+	}
+
+	coverage, err := scanTacticCoverage(rows)
+	if err != nil {
+		return nil, err
+	}
+	coverage.Since = &since
+	return coverage, nil
+}
+
+// GetEntityTacticCoverage returns the distinct tactics and techniques an
+// entity's events have hit since a point in time
+func (r *Repository) GetEntityTacticCoverage(entityID int64, since time.Time) (*models.TacticCoverage, error) {
+	return r.entityTacticCoverage(r.db, entityID, since)
+}
+
+// GetEntityTacticCoverageTx returns the distinct tactics and techniques an
+// entity's events have hit since a point in time, within a transaction
+func (r *Repository) GetEntityTacticCoverageTx(tx *sql.Tx, entityID int64, since time.Time) (*models.TacticCoverage, error) {
+	return r.entityTacticCoverage(tx, entityID, since)
+}
+
+// GetAlertTacticCoverage returns the distinct tactics and techniques of the
+// events linked to a risk alert
+func (r *Repository) GetAlertTacticCoverage(alertID int64) (*models.TacticCoverage, error) {
+	rows, err := r.db.Query(
+		`SELECT DISTINCT mt.id, mt.tactic, mt.tactics
+         FROM risk_alert_events rae
+         JOIN events e ON e.id = rae.event_id
+         JOIN detection_mitre_map dm ON dm.detection_id = e.detection_id
+         JOIN mitre_techniques mt ON mt.id = dm.mitre_id
+         WHERE rae.alert_id = ?`,
+		alertID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tactic coverage: %w", err)
+	}
+
+	return scanTacticCoverage(rows)
+}
+
+// GetDetectionTacticCoverageTx returns the tactics and techniques a detection
+// is mapped to, within a transaction
+func (r *Repository) GetDetectionTacticCoverageTx(tx *sql.Tx, detectionID int64) (*models.TacticCoverage, error) {
+	rows, err := tx.Query(
+		`SELECT mt.id, mt.tactic, mt.tactics
+         FROM detection_mitre_map dm
+         JOIN mitre_techniques mt ON mt.id = dm.mitre_id
+         WHERE dm.detection_id = ?`,
+		detectionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tactic coverage: %w", err)
+	}
+
+	return scanTacticCoverage(rows)
+}
+
+// countTactics returns the number of distinct tactics across coverages
+func countTactics(coverages ...*models.TacticCoverage) int {
+	seen := make(map[string]bool)
+	for _, coverage := range coverages {
+		for _, tactic := range coverage.Tactics {
+			seen[normalizeTactic(tactic)] = true
+		}
+	}
+	return len(seen)
+}
+
+// TacticCoverage returns the distinct tactics and techniques an entity's
+// events have hit over the tactic diversity window
+func (e *Engine) TacticCoverage(entityID int64) (*models.TacticCoverage, error) {
+	return e.repo.GetEntityTacticCoverage(entityID, time.Now().Add(-e.tacticWindow()))
+}
+
+// applyTacticDiversityTx adds the configured bonus to an event whose detection
+// takes the entity past the tactic limit, and reports whether it is the event
+// that first crossed the limit. It runs before the event is saved.
+func (e *Engine) applyTacticDiversityTx(tx *sql.Tx, event *models.Event, riskObject *models.RiskObject, now time.Time) (bool, error) {
+	config := e.config.TacticDiversity
+
+	before, err := e.repo.GetEntityTacticCoverageTx(tx, riskObject.ID, now.Add(-e.tacticWindow()))
+	if err != nil {
+		return false, err
+	}
+	detection, err := e.repo.GetDetectionTacticCoverageTx(tx, event.DetectionID)
+	if err != nil {
+		return false, err
+	}
+
+	countBefore := countTactics(before)
+	countAfter := countTactics(before, detection)
+	if countAfter <= config.Limit || countAfter == countBefore {
+		return false, nil
+	}
+
+	// Each tactic beyond the limit earns the bonus once
+	if config.Bonus > 0 {
+		newTactics := countAfter - countBefore
+		if countBefore < config.Limit {
+			newTactics = countAfter - config.Limit
+		}
+		bonus := config.Bonus * newTactics
+
+		if event.RiskBreakdown == nil {
+			event.RiskBreakdown = &models.RiskBreakdown{
+				BasePoints: event.RiskPoints,
+				Modifiers:  make([]models.RiskModifier, 0),
+			}
+		}
+		event.RiskBreakdown.BonusPoints += bonus
+		event.RiskBreakdown.EffectivePoints = event.RiskPoints + bonus
+		event.RiskPoints += bonus
+	}
+
+	return countBefore <= config.Limit, nil
+}
@@ -0,0 +1,77 @@
+package risk
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+// mapTestTechnique creates a technique under tactic and maps it to a detection
+func mapTestTechnique(t *testing.T, engine *Engine, detectionID int64, techniqueID, tactic string) {
+	if _, err := engine.db.Exec(`INSERT INTO mitre_techniques (id, name, tactic) VALUES (?, ?, ?)`, techniqueID, techniqueID, tactic); err != nil {
+		t.Fatalf("Failed to create technique: %v", err)
+	}
+	if _, err := engine.db.Exec(`INSERT INTO detection_mitre_map (detection_id, mitre_id) VALUES (?, ?)`, detectionID, techniqueID); err != nil {
+		t.Fatalf("Failed to map technique: %v", err)
+	}
+}
+
+func TestEngine_TacticDiversity(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	defer engine.db.Close()
+
+	engine.config.TacticDiversity = TacticDiversityConfig{Enabled: true, Limit: 2, Bonus: 10, Alert: true}
+
+	tactics := []string{"Initial Access", "Execution", "Persistence", "Discovery"}
+	detections := make([]*models.Detection, 0, len(tactics))
+	for i, tactic := range tactics {
+		detection := createSimpleTestDetection(t, engine)
+		mapTestTechnique(t, engine, detection.ID, fmt.Sprintf("T100%d", i), tactic)
+		detections = append(detections, detection)
+	}
+
+	expectedPoints := []int{10, 10, 20, 20}
+	for i, detection := range detections {
+		event := &models.Event{
+			DetectionID: detection.ID,
+			RiskObject:  &models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "ws-1"},
+			RiskPoints:  10,
+		}
+		if err := engine.ProcessEvent(event); err != nil {
+			t.Fatalf("Failed to process event: %v", err)
+		}
+		if event.RiskPoints != expectedPoints[i] {
+			t.Errorf("Event %d: expected %d risk points, got %d", i+1, expectedPoints[i], event.RiskPoints)
+		}
+	}
+
+	// The third tactic crossed the limit and alerted well under the score threshold
+	alerts, err := engine.GetRiskAlerts()
+	if err != nil {
+		t.Fatalf("Failed to get risk alerts: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(alerts))
+	}
+	if !strings.Contains(alerts[0].Notes, "2 MITRE tactics") {
+		t.Errorf("Expected alert notes to explain the tactic limit, got %q", alerts[0].Notes)
+	}
+
+	coverage, err := engine.TacticCoverage(alerts[0].EntityID)
+	if err != nil {
+		t.Fatalf("Failed to get tactic coverage: %v", err)
+	}
+	if len(coverage.Tactics) != 4 || len(coverage.Techniques) != 4 {
+		t.Errorf("Expected 4 tactics and techniques, got %v and %v", coverage.Tactics, coverage.Techniques)
+	}
+
+	alertCoverage, err := engine.repo.GetAlertTacticCoverage(alerts[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to get alert tactic coverage: %v", err)
+	}
+	if len(alertCoverage.Tactics) != 4 {
+		t.Errorf("Expected the alert to cover 4 tactics, got %v", alertCoverage.Tactics)
+	}
+}
@@ -0,0 +1,301 @@
+package risk
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// scanRiskThreshold scans a risk_thresholds row
+func scanRiskThreshold(row database.RowScanner) (*models.RiskThreshold, error) {
+	var threshold models.RiskThreshold
+	var entityType, valuePattern, tag sql.NullString
+	var classID sql.NullInt64
+	var createdAt, updatedAt string
+
+	err := row.Scan(
+		&threshold.ID,
+		&threshold.Name,
+		&entityType,
+		&valuePattern,
+		&tag,
+		&classID,
+		&threshold.Threshold,
+		&threshold.Priority,
+		&threshold.Enabled,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle nullable fields
+	if entityType.Valid {
+		threshold.EntityType = models.EntityType(entityType.String)
+	}
+	if valuePattern.Valid {
+		threshold.ValuePattern = valuePattern.String
+	}
+	if tag.Valid {
+		threshold.Tag = tag.String
+	}
+	if classID.Valid {
+		threshold.DetectionClassID = &classID.Int64
+	}
+
+	// Parse timestamps
+	threshold.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	threshold.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+	return &threshold, nil
+}
+
+const riskThresholdColumns = `id, name, entity_type, value_pattern, tag, detection_class_id, threshold, priority, enabled, created_at, updated_at`
+
+// GetRiskThreshold retrieves a threshold policy by ID
+func (r *Repository) GetRiskThreshold(id int64) (*models.RiskThreshold, error) {
+	query := `SELECT ` + riskThresholdColumns + ` FROM risk_thresholds WHERE id = ?`
+
+	threshold, err := scanRiskThreshold(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("risk threshold not found: %d", id)
+		}
+		return nil, fmt.Errorf("error scanning risk threshold: %w", err)
+	}
+
+	return threshold, nil
+}
+
+// ListRiskThresholds lists all threshold policies
+func (r *Repository) ListRiskThresholds() ([]*models.RiskThreshold, error) {
+	query := `SELECT ` + riskThresholdColumns + ` FROM risk_thresholds ORDER BY priority DESC, id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying risk thresholds: %w", err)
+	}
+	defer rows.Close()
+
+	thresholds := make([]*models.RiskThreshold, 0)
+	for rows.Next() {
+		threshold, err := scanRiskThreshold(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning risk threshold row: %w", err)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+
+	return thresholds, nil
+}
+
+// ListEnabledRiskThresholdsTx lists enabled threshold policies within a transaction
+func (r *Repository) ListEnabledRiskThresholdsTx(tx *sql.Tx) ([]*models.RiskThreshold, error) {
+	query := `SELECT ` + riskThresholdColumns + ` FROM risk_thresholds WHERE enabled = 1`
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying risk thresholds: %w", err)
+	}
+	defer rows.Close()
+
+	thresholds := make([]*models.RiskThreshold, 0)
+	for rows.Next() {
+		threshold, err := scanRiskThreshold(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning risk threshold row: %w", err)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+
+	return thresholds, nil
+}
+
+// CreateRiskThreshold creates a threshold policy
+func (r *Repository) CreateRiskThreshold(threshold *models.RiskThreshold) error {
+	query := `INSERT INTO risk_thresholds (name, entity_type, value_pattern, tag, detection_class_id, threshold, priority, enabled, created_at, updated_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	threshold.CreatedAt = now
+	threshold.UpdatedAt = now
+
+	result, err := r.db.Exec(
+		query,
+		threshold.Name,
+		sql.NullString{String: string(threshold.EntityType), Valid: threshold.EntityType != ""},
+		sql.NullString{String: threshold.ValuePattern, Valid: threshold.ValuePattern != ""},
+		sql.NullString{String: threshold.Tag, Valid: threshold.Tag != ""},
+		threshold.DetectionClassID,
+		threshold.Threshold,
+		threshold.Priority,
+		threshold.Enabled,
+		threshold.CreatedAt.Format(time.RFC3339),
+		threshold.UpdatedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating risk threshold: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	threshold.ID = id
+	return nil
+}
+
+// UpdateRiskThreshold updates a threshold policy
+func (r *Repository) UpdateRiskThreshold(threshold *models.RiskThreshold) error {
+	query := `UPDATE risk_thresholds
+              SET name = ?, entity_type = ?, value_pattern = ?, tag = ?, detection_class_id = ?, threshold = ?, priority = ?, enabled = ?, updated_at = ?
+              WHERE id = ?`
+
+	threshold.UpdatedAt = time.Now()
+
+	result, err := r.db.Exec(
+		query,
+		threshold.Name,
+		sql.NullString{String: string(threshold.EntityType), Valid: threshold.EntityType != ""},
+		sql.NullString{String: threshold.ValuePattern, Valid: threshold.ValuePattern != ""},
+		sql.NullString{String: threshold.Tag, Valid: threshold.Tag != ""},
+		threshold.DetectionClassID,
+		threshold.Threshold,
+		threshold.Priority,
+		threshold.Enabled,
+		threshold.UpdatedAt.Format(time.RFC3339),
+		threshold.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating risk threshold: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("risk threshold not found: %d", threshold.ID)
+	}
+
+	return nil
+}
+
+// DeleteRiskThreshold deletes a threshold policy
+func (r *Repository) DeleteRiskThreshold(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM risk_thresholds WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting risk threshold: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("risk threshold not found: %d", id)
+	}
+
+	return nil
+}
+
+// GetDetectionClassIDTx returns the class of a detection, or nil if it has none
+func (r *Repository) GetDetectionClassIDTx(tx *sql.Tx, detectionID int64) (*int64, error) {
+	var classID sql.NullInt64
+
+	err := tx.QueryRow(`SELECT class_id FROM detections WHERE id = ?`, detectionID).Scan(&classID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error getting detection class: %w", err)
+	}
+
+	if !classID.Valid {
+		return nil, nil
+	}
+	return &classID.Int64, nil
+}
+
+// thresholdMatches reports whether a policy applies to an entity with the
+// given inventory entry, which may be nil, whose event came from a detection
+// in classID
+func thresholdMatches(policy *models.RiskThreshold, obj *models.RiskObject, asset *models.InventoryEntry, classID *int64) bool {
+	if !entityMatches(policy.EntityType, policy.ValuePattern, obj) {
+		return false
+	}
+
+	if policy.Tag != "" && (asset == nil || !asset.HasTag(policy.Tag)) {
+		return false
+	}
+
+	if policy.DetectionClassID != nil && (classID == nil || *classID != *policy.DetectionClassID) {
+		return false
+	}
+
+	return true
+}
+
+// entityMatches reports whether an entity has entityType and a value matching
+// the glob valuePattern; empty criteria match anything
+func entityMatches(entityType models.EntityType, valuePattern string, obj *models.RiskObject) bool {
+	if entityType != "" && !strings.EqualFold(string(entityType), string(obj.EntityType)) {
+		return false
+	}
+
+	if valuePattern != "" {
+		matched, err := path.Match(strings.ToLower(valuePattern), strings.ToLower(obj.EntityValue))
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// thresholdSpecificity counts how many criteria a policy constrains
+func thresholdSpecificity(policy *models.RiskThreshold) int {
+	specificity := 0
+	if policy.EntityType != "" {
+		specificity++
+	}
+	if policy.ValuePattern != "" {
+		specificity++
+	}
+	if policy.Tag != "" {
+		specificity++
+	}
+	if policy.DetectionClassID != nil {
+		specificity++
+	}
+	return specificity
+}
+
+// resolveThreshold picks the threshold from the best matching policy, or
+// fallback when none match
+func resolveThreshold(policies []*models.RiskThreshold, obj *models.RiskObject, asset *models.InventoryEntry, classID *int64, fallback int) int {
+	var best *models.RiskThreshold
+
+	for _, policy := range policies {
+		if !thresholdMatches(policy, obj, asset, classID) {
+			continue
+		}
+
+		if best == nil ||
+			policy.Priority > best.Priority ||
+			(policy.Priority == best.Priority && thresholdSpecificity(policy) > thresholdSpecificity(best)) {
+			best = policy
+		}
+	}
+
+	if best == nil {
+		return fallback
+	}
+	return best.Threshold
+}
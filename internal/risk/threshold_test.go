@@ -0,0 +1,123 @@
+package risk
+
+import (
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestRepository_RiskThresholdCRUD(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	threshold := &models.RiskThreshold{
+		Name:         "Domain controllers",
+		EntityType:   models.EntityTypeHost,
+		ValuePattern: "dc-*",
+		Tag:          "tier0",
+		Threshold:    30,
+		Priority:     5,
+		Enabled:      true,
+	}
+	if err := repo.CreateRiskThreshold(threshold); err != nil {
+		t.Fatalf("Failed to create threshold: %v", err)
+	}
+	if threshold.ID == 0 {
+		t.Fatal("Expected threshold ID to be set")
+	}
+
+	fetched, err := repo.GetRiskThreshold(threshold.ID)
+	if err != nil {
+		t.Fatalf("Failed to get threshold: %v", err)
+	}
+	if fetched.ValuePattern != "dc-*" || fetched.Tag != "tier0" || fetched.EntityType != models.EntityTypeHost || !fetched.Enabled {
+		t.Errorf("Unexpected threshold: %+v", fetched)
+	}
+
+	fetched.Threshold = 40
+	if err := repo.UpdateRiskThreshold(fetched); err != nil {
+		t.Fatalf("Failed to update threshold: %v", err)
+	}
+
+	thresholds, err := repo.ListRiskThresholds()
+	if err != nil {
+		t.Fatalf("Failed to list thresholds: %v", err)
+	}
+	if len(thresholds) != 1 || thresholds[0].Threshold != 40 {
+		t.Errorf("Expected one threshold of 40, got %+v", thresholds)
+	}
+
+	if err := repo.DeleteRiskThreshold(threshold.ID); err != nil {
+		t.Fatalf("Failed to delete threshold: %v", err)
+	}
+	if _, err := repo.GetRiskThreshold(threshold.ID); err == nil {
+		t.Error("Expected error getting deleted threshold")
+	}
+}
+
+func TestResolveThreshold(t *testing.T) {
+	classID := int64(3)
+	policies := []*models.RiskThreshold{
+		{Name: "hosts", EntityType: models.EntityTypeHost, Threshold: 80},
+		{Name: "dcs", EntityType: models.EntityTypeHost, ValuePattern: "dc-*", Threshold: 30},
+		{Name: "auth class", DetectionClassID: &classID, Threshold: 20, Priority: 10},
+		{Name: "privileged", Tag: "privileged", Threshold: 40},
+	}
+	privileged := &models.InventoryEntry{Tags: []string{"Privileged"}}
+
+	tests := []struct {
+		name     string
+		obj      models.RiskObject
+		asset    *models.InventoryEntry
+		classID  *int64
+		expected int
+	}{
+		{"no match uses fallback", models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "alice"}, nil, nil, 50},
+		{"entity type", models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "ws-01"}, nil, nil, 80},
+		{"more specific pattern wins", models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "DC-01"}, nil, nil, 30},
+		{"priority wins", models.RiskObject{EntityType: models.EntityTypeHost, EntityValue: "dc-01"}, nil, &classID, 20},
+		{"inventory tag", models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "admin"}, privileged, nil, 40},
+		{"tag without inventory entry", models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "admin"}, &models.InventoryEntry{}, nil, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveThreshold(policies, &tt.obj, tt.asset, tt.classID, 50)
+			if got != tt.expected {
+				t.Errorf("Expected threshold %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEngine_EntityTypeThreshold(t *testing.T) {
+	engine := setupSimpleTestEngine(t)
+	engine.config.Thresholds = []models.RiskThreshold{
+		{Name: "hosts", EntityType: models.EntityTypeHost, Threshold: 40, Enabled: true},
+	}
+	detection := createSimpleTestDetection(t, engine)
+
+	// 50 points is below the global threshold (100) but above the host policy
+	for _, entity := range []*models.RiskObject{
+		{EntityType: models.EntityTypeHost, EntityValue: "ws-01"},
+		{EntityType: models.EntityTypeUser, EntityValue: "bob@example.com"},
+	} {
+		event := &models.Event{DetectionID: detection.ID, RiskPoints: 50, RiskObject: entity}
+		if err := engine.ProcessEvent(event); err != nil {
+			t.Fatalf("Failed to process event: %v", err)
+		}
+	}
+
+	alerts, err := engine.GetRiskAlerts()
+	if err != nil {
+		t.Fatalf("Failed to get risk alerts: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(alerts))
+	}
+
+	host, _ := engine.repo.GetRiskObjectByEntity(models.EntityTypeHost, "ws-01")
+	if alerts[0].EntityID != host.ID {
+		t.Errorf("Expected alert for host entity %d, got %d", host.ID, alerts[0].EntityID)
+	}
+}
@@ -0,0 +1,196 @@
+package rulesync
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"riskmatrix/pkg/models"
+)
+
+// Definition is a detection as written in a rule file. The external ID keys
+// the detection across syncs, so it must never change once pushed.
+type Definition struct {
+	ExternalID         string                 `json:"external_id"`
+	Name               string                 `json:"name"`
+	Description        string                 `json:"description,omitempty"`
+	Query              string                 `json:"query,omitempty"`
+	Status             models.DetectionStatus `json:"status,omitempty"`
+	Severity           models.Severity        `json:"severity,omitempty"`
+	RiskPoints         int                    `json:"risk_points,omitempty"`
+	PlaybookLink       string                 `json:"playbook_link,omitempty"`
+	Owner              string                 `json:"owner,omitempty"`
+	RiskObject         models.RiskObjectType  `json:"risk_object,omitempty"`
+	TestingDescription string                 `json:"testing_description,omitempty"`
+	MitreTechniques    []string               `json:"mitre_techniques,omitempty"`
+	DataSources        []string               `json:"data_sources,omitempty"`
+
+	// File is the rule file the definition was read from
+	File string `json:"-"`
+}
+
+// isDefinitionFile reports whether a file name has a rule file extension
+func isDefinitionFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yml", ".yaml", ".json":
+		return true
+	}
+	return false
+}
+
+// ParseDefinition parses one rule file. YAML files are read with the same
+// field names as JSON ones, and unknown fields are rejected so that typos do
+// not silently drop a setting.
+func ParseDefinition(file string, data []byte) (*Definition, error) {
+	if ext := strings.ToLower(path.Ext(file)); ext == ".yml" || ext == ".yaml" {
+		var document interface{}
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("%s: invalid YAML: %w", file, err)
+		}
+		converted, err := json.Marshal(document)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid YAML: %w", file, err)
+		}
+		data = converted
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var def Definition
+	if err := decoder.Decode(&def); err != nil {
+		return nil, fmt.Errorf("%s: invalid definition: %w", file, err)
+	}
+	if def.ExternalID == "" {
+		return nil, fmt.Errorf("%s: external_id is required", file)
+	}
+
+	def.ExternalID = strings.ToLower(def.ExternalID)
+	def.File = file
+	return &def, nil
+}
+
+// LoadDir parses every rule file under dir, in path order
+func LoadDir(dir string) ([]*Definition, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !isDefinitionFile(file) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", dir, err)
+	}
+
+	return parseFiles(files)
+}
+
+const (
+	// maxRuleFileSize is the largest rule file read from an archive
+	maxRuleFileSize = 1024 * 1024
+
+	// maxArchiveSize is the most rule file content read from an archive,
+	// after decompression
+	maxArchiveSize = 64 * 1024 * 1024
+)
+
+// ReadArchive parses every rule file in a tar archive, which may be gzipped.
+// Archives with a rule file over maxRuleFileSize, or more than
+// maxArchiveSize of rule files once decompressed, are rejected.
+func ReadArchive(r io.Reader) ([]*Definition, error) {
+	buffered := bufio.NewReader(r)
+	var archive io.Reader = buffered
+
+	// Gzip streams start with 0x1f 0x8b
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip archive: %w", err)
+		}
+		defer gz.Close()
+		archive = gz
+	}
+
+	files := make(map[string][]byte)
+	total := 0
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || !isDefinitionFile(header.Name) {
+			continue
+		}
+
+		// The header size is not trusted; reading one byte past the limit
+		// catches entries that are larger than they claim
+		data, err := io.ReadAll(io.LimitReader(reader, maxRuleFileSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", header.Name, err)
+		}
+		if len(data) > maxRuleFileSize {
+			return nil, fmt.Errorf("%s is larger than %d bytes", header.Name, maxRuleFileSize)
+		}
+		if total += len(data); total > maxArchiveSize {
+			return nil, fmt.Errorf("archive rule files are larger than %d bytes", maxArchiveSize)
+		}
+		files[strings.TrimPrefix(path.Clean(header.Name), "./")] = data
+	}
+
+	return parseFiles(files)
+}
+
+// parseFiles parses rule files in path order, rejecting external IDs used by
+// more than one file
+func parseFiles(files map[string][]byte) ([]*Definition, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	defs := make([]*Definition, 0, len(names))
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		def, err := ParseDefinition(name, files[name])
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[def.ExternalID]; ok {
+			return nil, fmt.Errorf("%s: external_id %s is also used by %s", name, def.ExternalID, other)
+		}
+		seen[def.ExternalID] = name
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
@@ -0,0 +1,345 @@
+package rulesync
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+const (
+	bruteForceID = "6f1c2e1a-8a0b-4c55-9d1e-2b3c4d5e6f70"
+	powerShellID = "0a9b8c7d-6e5f-4a3b-8c1d-0e9f8a7b6c5d"
+)
+
+var testFiles = map[string]string{
+	"auth/brute_force.yml": `external_id: ` + bruteForceID + `
+name: Brute Force Logons
+description: Many failed logons for one account
+query: index=win EventCode=4625 | stats count by user | where count > 20
+status: test
+severity: high
+risk_points: 40
+risk_object: User
+mitre_techniques: [T1110]
+data_sources: [windows_security]
+`,
+	"execution/powershell.json": `{
+  "external_id": "` + powerShellID + `",
+  "name": "Encoded PowerShell",
+  "query": "index=win powershell.exe -enc",
+  "mitre_techniques": ["t1059.001"]
+}`,
+	"README.md": "Not a rule file",
+}
+
+// setupTestRepo creates a sync repository with a test database holding the
+// techniques used by the test rule files
+func setupTestRepo(t *testing.T) (*Repository, *database.DB) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+
+	repo := NewRepository(db)
+	for _, technique := range []*models.MitreTechnique{
+		{ID: "T1110", Name: "Brute Force", Tactic: "Credential Access"},
+		{ID: "T1059.001", Name: "PowerShell", Tactic: "Execution"},
+	} {
+		if err := repo.mitre.CreateMitreTechnique(technique); err != nil {
+			t.Fatalf("Failed to create test technique: %v", err)
+		}
+	}
+	return repo, db
+}
+
+// writeTestDir writes rule files to a temporary directory
+func writeTestDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestLoadDir(t *testing.T) {
+	defs, err := LoadDir(writeTestDir(t, testFiles))
+	if err != nil {
+		t.Fatalf("Failed to load rule files: %v", err)
+	}
+	if len(defs) != 2 {
+		t.Fatalf("Expected 2 definitions, got %d", len(defs))
+	}
+
+	// Definitions come in path order, whatever their format
+	if defs[0].File != "auth/brute_force.yml" || defs[0].RiskPoints != 40 || defs[0].RiskObject != models.RiskObjectUser {
+		t.Errorf("Unexpected YAML definition %+v", defs[0])
+	}
+	if defs[1].File != "execution/powershell.json" || defs[1].ExternalID != powerShellID {
+		t.Errorf("Unexpected JSON definition %+v", defs[1])
+	}
+
+	for name, content := range map[string]string{
+		"missing_id.yml":  "name: No ID\n",
+		"unknown.yml":     "external_id: " + bruteForceID + "\nname: Typo\nseverty: high\n",
+		"invalid.json":    "{",
+		"not_object.yaml": "- a\n- b\n",
+	} {
+		if _, err := ParseDefinition(name, []byte(content)); err == nil {
+			t.Errorf("Expected error parsing %s", name)
+		}
+	}
+
+	duplicate := map[string]string{
+		"a.yml": "external_id: " + bruteForceID + "\nname: A\n",
+		"b.yml": "external_id: " + strings.ToUpper(bruteForceID) + "\nname: B\n",
+	}
+	if _, err := LoadDir(writeTestDir(t, duplicate)); err == nil || !strings.Contains(err.Error(), "a.yml") {
+		t.Errorf("Expected duplicate external ID error, got %v", err)
+	}
+}
+
+func TestReadArchive(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	for name, content := range testFiles {
+		header := &tar.Header{Name: "./rules/" + name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := archive.WriteHeader(header); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := archive.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	archive.Close()
+	gz.Close()
+
+	defs, err := ReadArchive(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	if len(defs) != 2 || defs[0].File != "rules/auth/brute_force.yml" {
+		t.Errorf("Unexpected definitions %+v", defs)
+	}
+
+	if _, err := ReadArchive(strings.NewReader("not an archive")); err == nil {
+		t.Error("Expected error reading an invalid archive")
+	}
+}
+
+func TestReadArchive_TooLarge(t *testing.T) {
+	// A rule file of zeros compresses to almost nothing
+	archiveOf := func(sizes ...int) *bytes.Buffer {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		archive := tar.NewWriter(gz)
+		for i, size := range sizes {
+			header := &tar.Header{Name: fmt.Sprintf("rule%d.yml", i), Mode: 0644, Size: int64(size), Typeflag: tar.TypeReg}
+			if err := archive.WriteHeader(header); err != nil {
+				t.Fatalf("Failed to write header: %v", err)
+			}
+			if _, err := archive.Write(make([]byte, size)); err != nil {
+				t.Fatalf("Failed to write rule: %v", err)
+			}
+		}
+		archive.Close()
+		gz.Close()
+		return &buf
+	}
+
+	if _, err := ReadArchive(archiveOf(maxRuleFileSize + 1)); err == nil || !strings.Contains(err.Error(), "rule0.yml") {
+		t.Errorf("Expected oversized rule file error, got %v", err)
+	}
+
+	sizes := make([]int, maxArchiveSize/maxRuleFileSize+1)
+	for i := range sizes {
+		sizes[i] = maxRuleFileSize
+	}
+	if _, err := ReadArchive(archiveOf(sizes...)); err == nil || !strings.Contains(err.Error(), "archive") {
+		t.Errorf("Expected oversized archive error, got %v", err)
+	}
+}
+
+func TestRepository_Sync(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	// A detection that was never synced is taken over by name
+	existing := &models.Detection{Name: "Encoded PowerShell", Status: models.StatusDraft, Severity: models.SeverityLow}
+	if err := repo.detections.CreateDetection(existing); err != nil {
+		t.Fatalf("Failed to create detection: %v", err)
+	}
+
+	defs, err := LoadDir(writeTestDir(t, testFiles))
+	if err != nil {
+		t.Fatalf("Failed to load rule files: %v", err)
+	}
+
+	plan, err := repo.Plan(defs)
+	if err != nil {
+		t.Fatalf("Failed to plan sync: %v", err)
+	}
+	if plan.Created != 1 || plan.Updated != 1 || plan.Retired != 0 {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+	if count, _ := repo.detections.GetDetectionCount(); count != 1 {
+		t.Errorf("Expected a dry run to change nothing, got %d detections", count)
+	}
+
+	if _, err := repo.Apply(defs); err != nil {
+		t.Fatalf("Failed to apply sync: %v", err)
+	}
+
+	det, err := repo.detections.GetDetection(existing.ID)
+	if err != nil {
+		t.Fatalf("Failed to get detection: %v", err)
+	}
+	if det.ExternalID != powerShellID || det.Severity != models.SeverityMedium || len(det.MitreTechniques) != 1 {
+		t.Errorf("Expected the existing detection to be synced, got %+v", det)
+	}
+
+	detections, _ := repo.detections.ListDetections()
+	var bruteForce *models.Detection
+	for _, d := range detections {
+		if d.ExternalID == bruteForceID {
+			bruteForce = d
+		}
+	}
+	if bruteForce == nil || bruteForce.RiskPoints != 40 || len(bruteForce.DataSources) != 1 || bruteForce.DataSources[0].Name != "windows_security" {
+		t.Fatalf("Expected the new detection with its data source, got %+v", bruteForce)
+	}
+
+	// Syncing the same files again is a no-op
+	plan, err = repo.Apply(defs)
+	if err != nil {
+		t.Fatalf("Failed to apply sync: %v", err)
+	}
+	if plan.Unchanged != 2 || plan.Created+plan.Updated+plan.Retired != 0 {
+		t.Errorf("Expected an unchanged plan, got %+v", plan)
+	}
+
+	// Changed files update their detection; removed ones retire it
+	files := map[string]string{
+		"auth/brute_force.yml": strings.Replace(testFiles["auth/brute_force.yml"], "risk_points: 40", "risk_points: 60", 1),
+	}
+	defs, _ = LoadDir(writeTestDir(t, files))
+	plan, err = repo.Apply(defs)
+	if err != nil {
+		t.Fatalf("Failed to apply sync: %v", err)
+	}
+	if plan.Updated != 1 || plan.Retired != 1 {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+	changes := plan.Actions[0].Changes
+	if len(changes) != 1 || changes[0] != (Change{Field: "risk_points", Old: "40", New: "60"}) {
+		t.Errorf("Unexpected changes %+v", changes)
+	}
+
+	det, _ = repo.detections.GetDetection(existing.ID)
+	if det.Status != models.StatusRetired {
+		t.Errorf("Expected removed detection to be retired, got %s", det.Status)
+	}
+}
+
+func TestRepository_Plan_Invalid(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	files := map[string]string{
+		"a.yml": "external_id: " + bruteForceID + "\nname: A\nstatus: production\n",
+		"b.yml": "external_id: " + powerShellID + "\nname: B\nmitre_techniques: [T9999]\n",
+		"c.yml": "external_id: not-a-uuid\nname: C\n",
+	}
+	defs, err := LoadDir(writeTestDir(t, files))
+	if err != nil {
+		t.Fatalf("Failed to load rule files: %v", err)
+	}
+
+	_, err = repo.Apply(defs)
+	if !errors.Is(err, ErrInvalidDefinitions) {
+		t.Fatalf("Expected invalid definitions error, got %v", err)
+	}
+	for _, expected := range []string{"a.yml", "b.yml: unknown ATT&CK technique T9999", "c.yml"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to mention %q: %v", expected, err)
+		}
+	}
+
+	if count, _ := repo.detections.GetDetectionCount(); count != 0 {
+		t.Errorf("Expected nothing to be synced, got %d detections", count)
+	}
+}
+
+func TestRepository_Plan_Lifecycle(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	// A detection promoted to production outside of the rule files
+	promoted := &models.Detection{
+		ExternalID:         bruteForceID,
+		Name:               "Brute Force Logons",
+		Query:              "index=win EventCode=4625",
+		Status:             models.StatusProduction,
+		Severity:           models.SeverityHigh,
+		Owner:              "soc@example.com",
+		PlaybookLink:       "https://example.com/playbook",
+		TestingDescription: "Fail 25 logons for one account",
+	}
+	if err := repo.detections.CreateDetection(promoted); err != nil {
+		t.Fatalf("Failed to create detection: %v", err)
+	}
+
+	definition := "external_id: " + bruteForceID + "\nname: Brute Force Logons\nquery: index=win EventCode=4625\n" +
+		"status: production\nseverity: high\nowner: soc@example.com\nplaybook_link: https://example.com/playbook\n"
+	tests := []struct {
+		name     string
+		extra    string
+		expected string
+	}{
+		{"missing requirements", "", "mitre_techniques, testing_description"},
+		{"production kept", "testing_description: Fail 25 logons for one account\nmitre_techniques: [T1110]\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defs, err := LoadDir(writeTestDir(t, map[string]string{"brute_force.yml": definition + tt.extra}))
+			if err != nil {
+				t.Fatalf("Failed to load rule files: %v", err)
+			}
+
+			plan, err := repo.Plan(defs)
+			if tt.expected != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expected) {
+					t.Errorf("Expected error mentioning %q, got %v", tt.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to plan sync: %v", err)
+			}
+			if plan.Updated != 1 {
+				t.Errorf("Expected the detection to be updated, got %+v", plan)
+			}
+		})
+	}
+
+	// Demoting to draft skips a stage the lifecycle requires
+	defs, _ := LoadDir(writeTestDir(t, map[string]string{"brute_force.yml": "external_id: " + bruteForceID + "\nname: Brute Force Logons\nstatus: draft\n"}))
+	if _, err := repo.Plan(defs); err == nil || !strings.Contains(err.Error(), "production to draft") {
+		t.Errorf("Expected transition error, got %v", err)
+	}
+}
@@ -0,0 +1,440 @@
+package rulesync
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"riskmatrix/internal/datasource"
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/mitre"
+	validation "riskmatrix/pkg"
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// Sync actions
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionRetire    = "retire"
+	ActionUnchanged = "unchanged"
+)
+
+// ErrInvalidDefinitions is returned when rule files do not make valid
+// detections
+var ErrInvalidDefinitions = errors.New("invalid detection definitions")
+
+// changedBy is recorded as the author of the detection revisions a sync makes
+const changedBy = "detection-sync"
+
+// Change is one field a sync changes on a detection
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Action is what a sync does to one detection
+type Action struct {
+	Action      string   `json:"action"`
+	ExternalID  string   `json:"external_id"`
+	DetectionID int64    `json:"detection_id,omitempty"`
+	Name        string   `json:"name"`
+	File        string   `json:"file,omitempty"`
+	Changes     []Change `json:"changes,omitempty"`
+
+	detection   *models.Detection
+	techniques  []string
+	dataSources []string
+	previous    *models.Detection
+}
+
+// Plan lists the actions that bring the catalog in line with rule files
+type Plan struct {
+	Actions   []*Action `json:"actions"`
+	Created   int       `json:"created"`
+	Updated   int       `json:"updated"`
+	Retired   int       `json:"retired"`
+	Unchanged int       `json:"unchanged"`
+}
+
+// Repository reconciles the detection catalog with rule files
+type Repository struct {
+	db          *database.DB
+	detections  *detection.Repository
+	dataSources *datasource.Repository
+	mitre       *mitre.Repository
+	lifecycle   *detection.Lifecycle
+}
+
+// NewRepository creates a new rule sync repository
+func NewRepository(db *database.DB) *Repository {
+	detections := detection.NewRepository(db)
+	return &Repository{
+		db:          db,
+		detections:  detections,
+		dataSources: datasource.NewRepository(db),
+		mitre:       mitre.NewRepository(db),
+		lifecycle:   detection.NewLifecycle(detections, detection.DefaultLifecycleConfig()),
+	}
+}
+
+// SetLifecycle sets the lifecycle that rule file status changes must follow,
+// replacing the default one
+func (r *Repository) SetLifecycle(lifecycle *detection.Lifecycle) {
+	r.lifecycle = lifecycle
+}
+
+// Plan works out how to bring the catalog in line with defs without changing
+// anything. Detections are matched by external ID; a definition with a new
+// external ID takes over a detection of the same name that has none. Synced
+// detections whose definition is gone are retired, while detections that were
+// never synced are left alone. Status changes must follow the lifecycle, so a
+// definition can only keep a detection in a status that needs approval once
+// it has been approved. Every invalid definition is reported at once.
+func (r *Repository) Plan(defs []*Definition) (*Plan, error) {
+	existing, err := r.detections.ListDetections()
+	if err != nil {
+		return nil, err
+	}
+
+	byExternalID := make(map[string]*models.Detection)
+	byName := make(map[string]*models.Detection)
+	for _, det := range existing {
+		if det.ExternalID != "" {
+			byExternalID[det.ExternalID] = det
+		} else if _, ok := byName[strings.ToLower(det.Name)]; !ok {
+			byName[strings.ToLower(det.Name)] = det
+		}
+	}
+
+	plan := &Plan{Actions: make([]*Action, 0, len(defs))}
+	errs := make([]error, 0)
+	defined := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		defined[def.ExternalID] = true
+
+		previous := byExternalID[def.ExternalID]
+		if previous == nil {
+			// Each unsynced detection can only be taken over once
+			key := strings.ToLower(strings.TrimSpace(def.Name))
+			previous = byName[key]
+			delete(byName, key)
+		}
+
+		action, err := r.planDefinition(def, previous)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", def.File, err))
+			continue
+		}
+		plan.add(action)
+	}
+
+	for _, det := range existing {
+		if det.ExternalID == "" || defined[det.ExternalID] || det.Status == models.StatusRetired {
+			continue
+		}
+
+		if err := r.lifecycle.CheckTransition(det.Status, models.StatusRetired); err != nil {
+			errs = append(errs, fmt.Errorf("retiring %s: %w", det.ExternalID, err))
+			continue
+		}
+
+		retired := *det
+		retired.Status = models.StatusRetired
+		plan.add(&Action{
+			Action:      ActionRetire,
+			ExternalID:  det.ExternalID,
+			DetectionID: det.ID,
+			Name:        det.Name,
+			Changes:     []Change{{Field: "status", Old: string(det.Status), New: string(models.StatusRetired)}},
+			detection:   &retired,
+		})
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w:\n%w", ErrInvalidDefinitions, errors.Join(errs...))
+	}
+	return plan, nil
+}
+
+// planDefinition works out the action for one definition against the
+// detection it updates, which is nil for a new detection
+func (r *Repository) planDefinition(def *Definition, previous *models.Detection) (*Action, error) {
+	det := &models.Detection{}
+	if previous != nil {
+		copied := *previous
+		det = &copied
+	}
+
+	det.ExternalID = def.ExternalID
+	det.Name = strings.TrimSpace(def.Name)
+	det.Description = def.Description
+	det.Query = def.Query
+	det.Status = def.Status
+	if det.Status == "" {
+		det.Status = models.StatusDraft
+	}
+	det.Severity = def.Severity
+	if det.Severity == "" {
+		det.Severity = models.SeverityMedium
+	}
+	det.RiskPoints = def.RiskPoints
+	det.PlaybookLink = def.PlaybookLink
+	det.Owner = def.Owner
+	det.RiskObject = def.RiskObject
+	det.TestingDescription = def.TestingDescription
+
+	if err := validation.ValidateDetection(det); err != nil {
+		return nil, err
+	}
+
+	techniques := sortedUnique(def.MitreTechniques, strings.ToUpper)
+	for _, id := range techniques {
+		if _, err := r.mitre.GetMitreTechnique(id); err != nil {
+			return nil, fmt.Errorf("unknown ATT&CK technique %s", id)
+		}
+	}
+	dataSources := sortedUnique(def.DataSources, nil)
+
+	if err := r.checkLifecycle(previous, det, techniques, dataSources); err != nil {
+		return nil, err
+	}
+
+	action := &Action{
+		Action:      ActionCreate,
+		ExternalID:  def.ExternalID,
+		Name:        det.Name,
+		File:        def.File,
+		detection:   det,
+		techniques:  techniques,
+		dataSources: dataSources,
+		previous:    previous,
+	}
+	if previous == nil {
+		return action, nil
+	}
+
+	action.DetectionID = previous.ID
+	action.Changes = diff(previous, det, techniques, dataSources)
+	action.Action = ActionUpdate
+	if len(action.Changes) == 0 {
+		action.Action = ActionUnchanged
+	}
+	return action, nil
+}
+
+// checkLifecycle checks that the lifecycle allows a definition to move a
+// detection to its status, and that the detection, with the definition's
+// links, meets the requirements of that status
+func (r *Repository) checkLifecycle(previous, det *models.Detection, techniques, dataSources []string) error {
+	var from models.DetectionStatus
+	if previous != nil {
+		from = previous.Status
+	}
+	if err := r.lifecycle.CheckTransition(from, det.Status); err != nil {
+		return err
+	}
+
+	linked := *det
+	linked.MitreTechniques = make([]models.MitreTechnique, len(techniques))
+	for i, id := range techniques {
+		linked.MitreTechniques[i] = models.MitreTechnique{ID: id}
+	}
+	linked.DataSources = make([]models.DataSource, len(dataSources))
+	for i, name := range dataSources {
+		linked.DataSources[i] = models.DataSource{Name: name}
+	}
+	return r.lifecycle.CheckRequirements(&linked)
+}
+
+// add appends an action to the plan and counts it
+func (p *Plan) add(action *Action) {
+	p.Actions = append(p.Actions, action)
+	switch action.Action {
+	case ActionCreate:
+		p.Created++
+	case ActionUpdate:
+		p.Updated++
+	case ActionRetire:
+		p.Retired++
+	case ActionUnchanged:
+		p.Unchanged++
+	}
+}
+
+// Apply brings the catalog in line with defs in a single transaction and
+// returns the plan it carried out. Data sources named by definitions are
+// created if they do not exist yet.
+func (r *Repository) Apply(defs []*Definition) (*Plan, error) {
+	plan, err := r.Plan(defs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look up data sources before the transaction starts
+	dataSourceIDs := make(map[string]int64)
+	missing := make([]string, 0)
+	for _, action := range plan.Actions {
+		for _, name := range action.dataSources {
+			if _, ok := dataSourceIDs[name]; ok {
+				continue
+			}
+			if dataSource, err := r.dataSources.GetDataSourceByName(name); err == nil {
+				dataSourceIDs[name] = dataSource.ID
+			} else {
+				dataSourceIDs[name] = 0
+				missing = append(missing, name)
+			}
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, name := range missing {
+		dataSource := &models.DataSource{Name: name, Description: "Created by detection sync"}
+		if err := r.dataSources.CreateDataSourceTx(tx, dataSource); err != nil {
+			return nil, fmt.Errorf("error creating data source %s: %w", name, err)
+		}
+		dataSourceIDs[name] = dataSource.ID
+	}
+
+	for _, action := range plan.Actions {
+		det := action.detection
+		switch action.Action {
+		case ActionCreate:
+			if err := r.detections.CreateDetectionTx(tx, det); err != nil {
+				return nil, fmt.Errorf("error creating detection %s: %w", det.Name, err)
+			}
+			action.DetectionID = det.ID
+		case ActionUpdate:
+			if err := r.detections.UpdateDetectionTx(tx, det, changedBy, "Synced from "+action.File); err != nil {
+				return nil, fmt.Errorf("error updating detection %s: %w", det.Name, err)
+			}
+		case ActionRetire:
+			if err := r.detections.UpdateDetectionTx(tx, det, changedBy, "Rule file removed"); err != nil {
+				return nil, fmt.Errorf("error updating detection %s: %w", det.Name, err)
+			}
+		}
+		if action.Action != ActionCreate && action.Action != ActionUpdate {
+			continue
+		}
+
+		if err := r.syncLinks(tx, action, dataSourceIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return plan, nil
+}
+
+// syncLinks maps a detection to exactly the techniques and data sources of
+// its definition
+func (r *Repository) syncLinks(tx *sql.Tx, action *Action, dataSourceIDs map[string]int64) error {
+	det := action.detection
+
+	wantedTechniques := make(map[string]bool, len(action.techniques))
+	for _, id := range action.techniques {
+		wantedTechniques[id] = true
+	}
+	wantedDataSources := make(map[string]bool, len(action.dataSources))
+	for _, name := range action.dataSources {
+		wantedDataSources[name] = true
+	}
+
+	if action.previous != nil {
+		for _, technique := range action.previous.MitreTechniques {
+			if !wantedTechniques[technique.ID] {
+				if err := r.detections.RemoveMitreTechniqueTx(tx, det.ID, technique.ID); err != nil {
+					return fmt.Errorf("error removing technique %s: %w", technique.ID, err)
+				}
+			}
+		}
+		for _, dataSource := range action.previous.DataSources {
+			if !wantedDataSources[dataSource.Name] {
+				if err := r.detections.RemoveDataSourceTx(tx, det.ID, dataSource.ID); err != nil {
+					return fmt.Errorf("error removing data source %s: %w", dataSource.Name, err)
+				}
+			}
+		}
+	}
+
+	for _, id := range action.techniques {
+		if err := r.detections.AddMitreTechniqueTx(tx, det.ID, id); err != nil {
+			return fmt.Errorf("error adding technique %s: %w", id, err)
+		}
+	}
+	for _, name := range action.dataSources {
+		if err := r.detections.AddDataSourceTx(tx, det.ID, dataSourceIDs[name]); err != nil {
+			return fmt.Errorf("error adding data source %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// diff lists the fields that differ between a detection and its new version
+func diff(old, new *models.Detection, techniques, dataSources []string) []Change {
+	changes := make([]Change, 0)
+	field := func(name, oldValue, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, Change{Field: name, Old: oldValue, New: newValue})
+		}
+	}
+
+	field("external_id", old.ExternalID, new.ExternalID)
+	field("name", old.Name, new.Name)
+	field("description", old.Description, new.Description)
+	field("query", old.Query, new.Query)
+	field("status", string(old.Status), string(new.Status))
+	field("severity", string(old.Severity), string(new.Severity))
+	field("risk_points", strconv.Itoa(old.RiskPoints), strconv.Itoa(new.RiskPoints))
+	field("playbook_link", old.PlaybookLink, new.PlaybookLink)
+	field("owner", old.Owner, new.Owner)
+	field("risk_object", string(old.RiskObject), string(new.RiskObject))
+	field("testing_description", old.TestingDescription, new.TestingDescription)
+
+	oldTechniques := make([]string, 0, len(old.MitreTechniques))
+	for _, technique := range old.MitreTechniques {
+		oldTechniques = append(oldTechniques, technique.ID)
+	}
+	field("mitre_techniques", strings.Join(sortedUnique(oldTechniques, nil), ", "), strings.Join(techniques, ", "))
+
+	oldDataSources := make([]string, 0, len(old.DataSources))
+	for _, dataSource := range old.DataSources {
+		oldDataSources = append(oldDataSources, dataSource.Name)
+	}
+	field("data_sources", strings.Join(sortedUnique(oldDataSources, nil), ", "), strings.Join(dataSources, ", "))
+
+	return changes
+}
+
+// sortedUnique trims, normalizes and sorts values, dropping blanks and
+// duplicates
+func sortedUnique(values []string, normalize func(string) string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if normalize != nil {
+			value = normalize(value)
+		}
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	sort.Strings(result)
+	return result
+}
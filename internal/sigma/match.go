@@ -0,0 +1,554 @@
+package sigma
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Matcher evaluates a Sigma detection block against log records
+type Matcher struct {
+	searches  map[string]search
+	condition condition
+}
+
+// search is a named search of a detection block
+type search func(record map[string]interface{}) bool
+
+// condition is a parsed detection condition
+type condition func(searches map[string]search, record map[string]interface{}) bool
+
+// IsQuery reports whether a detection's query is a Sigma detection block
+func IsQuery(query string) bool {
+	_, ok := detectionBlock(query)
+	return ok
+}
+
+// CompileQuery compiles a detection's query, a Sigma detection block as kept
+// by Import, into a matcher. The field modifiers contains, startswith,
+// endswith, all, re, cidr and exists are supported.
+func CompileQuery(query string) (*Matcher, error) {
+	block, ok := detectionBlock(query)
+	if !ok {
+		return nil, fmt.Errorf("query is not a Sigma detection block")
+	}
+
+	matcher := &Matcher{searches: make(map[string]search)}
+	var conditions []string
+	for i := 0; i+1 < len(block.Content); i += 2 {
+		name, value := block.Content[i].Value, block.Content[i+1]
+		switch name {
+		case "condition":
+			if err := value.Decode(&conditions); err != nil {
+				var single string
+				if err := value.Decode(&single); err != nil {
+					return nil, fmt.Errorf("invalid condition: %w", err)
+				}
+				conditions = []string{single}
+			}
+		case "timeframe":
+			// Aggregation windows do not apply to single records
+		default:
+			compiled, err := compileSearch(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid search %s: %w", name, err)
+			}
+			matcher.searches[name] = compiled
+		}
+	}
+
+	// A list of conditions matches when any of them does
+	parsed := make([]condition, 0, len(conditions))
+	for _, text := range conditions {
+		cond, err := parseCondition(text, matcher.searches)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %w", text, err)
+		}
+		parsed = append(parsed, cond)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("detection has no condition")
+	}
+	matcher.condition = func(searches map[string]search, record map[string]interface{}) bool {
+		for _, cond := range parsed {
+			if cond(searches, record) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return matcher, nil
+}
+
+// Match reports whether a record matches the detection
+func (m *Matcher) Match(record map[string]interface{}) bool {
+	return m.condition(m.searches, record)
+}
+
+// detectionBlock returns a query's Sigma detection block, if it is one
+func detectionBlock(query string) (*yaml.Node, bool) {
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(query), &node); err != nil || len(node.Content) != 1 {
+		return nil, false
+	}
+	block := node.Content[0]
+	if block.Kind != yaml.MappingNode || !hasKey(block, "condition") {
+		return nil, false
+	}
+	return block, true
+}
+
+// compileSearch compiles a search: a map of field matches that must all
+// match, a list of such maps of which one must match, or a list of keywords
+// any field may contain
+func compileSearch(node *yaml.Node) (search, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return compileFieldMap(node)
+	case yaml.SequenceNode:
+		if len(node.Content) > 0 && node.Content[0].Kind == yaml.MappingNode {
+			alternatives := make([]search, 0, len(node.Content))
+			for _, item := range node.Content {
+				compiled, err := compileSearch(item)
+				if err != nil {
+					return nil, err
+				}
+				alternatives = append(alternatives, compiled)
+			}
+			return func(record map[string]interface{}) bool {
+				for _, alternative := range alternatives {
+					if alternative(record) {
+						return true
+					}
+				}
+				return false
+			}, nil
+		}
+		return compileKeywords(node.Content)
+	case yaml.ScalarNode:
+		return compileKeywords([]*yaml.Node{node})
+	}
+	return nil, fmt.Errorf("unsupported search")
+}
+
+// compileKeywords compiles keywords that match when any field value of a
+// record contains one of them
+func compileKeywords(nodes []*yaml.Node) (search, error) {
+	patterns := make([]*regexp.Regexp, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("keywords must be values")
+		}
+		patterns = append(patterns, wildcardPattern("*"+node.Value+"*"))
+	}
+
+	return func(record map[string]interface{}) bool {
+		values := allValues(record)
+		for _, pattern := range patterns {
+			for _, value := range values {
+				if pattern.MatchString(value) {
+					return true
+				}
+			}
+		}
+		return false
+	}, nil
+}
+
+// compileFieldMap compiles a map of field matches that must all match
+func compileFieldMap(node *yaml.Node) (search, error) {
+	fields := make([]search, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		compiled, err := compileField(node.Content[i].Value, node.Content[i+1])
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, compiled)
+	}
+
+	return func(record map[string]interface{}) bool {
+		for _, field := range fields {
+			if !field(record) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// compileField compiles a match of one field, such as CommandLine|contains,
+// against a value or a list of values of which one, or with the all modifier
+// every one, must match
+func compileField(key string, node *yaml.Node) (search, error) {
+	parts := strings.Split(key, "|")
+	field, modifiers := parts[0], parts[1:]
+
+	values := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		values = node.Content
+	}
+
+	all := false
+	kind := ""
+	for _, modifier := range modifiers {
+		switch modifier {
+		case "all":
+			all = true
+		case "contains", "startswith", "endswith", "re", "cidr", "exists":
+			if kind != "" {
+				return nil, fmt.Errorf("%s: only one of contains, startswith, endswith, re, cidr and exists can be used", key)
+			}
+			kind = modifier
+		default:
+			return nil, fmt.Errorf("%s: unsupported modifier %s", key, modifier)
+		}
+	}
+
+	if kind == "exists" {
+		var exists bool
+		if err := node.Decode(&exists); err != nil {
+			return nil, fmt.Errorf("%s: exists needs true or false", key)
+		}
+		return func(record map[string]interface{}) bool {
+			return (len(fieldValues(record, field)) > 0) == exists
+		}, nil
+	}
+
+	matchers := make([]func(string) bool, 0, len(values))
+	matchMissing := false
+	for _, value := range values {
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%s: values must be scalars", key)
+		}
+		if value.Tag == "!!null" {
+			// A null value matches a missing or empty field
+			matchMissing = true
+			matchers = append(matchers, func(v string) bool { return v == "" })
+			continue
+		}
+
+		matcher, err := valueMatcher(kind, value.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	return func(record map[string]interface{}) bool {
+		fieldVals := fieldValues(record, field)
+		if len(fieldVals) == 0 && matchMissing {
+			fieldVals = []string{""}
+		}
+
+		matchesAny := func(matcher func(string) bool) bool {
+			for _, v := range fieldVals {
+				if matcher(v) {
+					return true
+				}
+			}
+			return false
+		}
+
+		for _, matcher := range matchers {
+			matched := matchesAny(matcher)
+			if all && !matched {
+				return false
+			}
+			if !all && matched {
+				return true
+			}
+		}
+		return all && len(matchers) > 0
+	}, nil
+}
+
+// valueMatcher returns a function matching field values against a Sigma
+// value with the given modifier
+func valueMatcher(kind, value string) (func(string) bool, error) {
+	switch kind {
+	case "re":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		return re.MatchString, nil
+	case "cidr":
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %w", err)
+		}
+		return func(v string) bool {
+			ip := net.ParseIP(v)
+			return ip != nil && network.Contains(ip)
+		}, nil
+	case "contains":
+		value = "*" + value + "*"
+	case "startswith":
+		value = value + "*"
+	case "endswith":
+		value = "*" + value
+	}
+	return wildcardPattern(value).MatchString, nil
+}
+
+// wildcardPattern compiles a Sigma value, where * and ? are wildcards unless
+// escaped with a backslash, into a case-insensitive regex
+func wildcardPattern(value string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString("(?is)^")
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && i+1 < len(value) && strings.IndexByte(`*?\`, value[i+1]) >= 0:
+			i++
+			pattern.WriteString(regexp.QuoteMeta(string(value[i])))
+		case c == '*':
+			pattern.WriteString(".*")
+		case c == '?':
+			pattern.WriteString(".")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	pattern.WriteString("$")
+	return regexp.MustCompile(pattern.String())
+}
+
+// parseCondition parses a detection condition: search names combined with
+// and, or, not and parentheses, and "1 of" or "all of" a search name pattern
+// or "them"
+func parseCondition(text string, searches map[string]search) (condition, error) {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(text))
+	parser := &conditionParser{tokens: tokens, searches: searches}
+	cond, err := parser.or()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(tokens) {
+		return nil, fmt.Errorf("unexpected %q", tokens[parser.pos])
+	}
+	return cond, nil
+}
+
+// conditionParser is a recursive descent parser for detection conditions
+type conditionParser struct {
+	tokens   []string
+	pos      int
+	searches map[string]search
+}
+
+// peek returns the next token, lowercased, or an empty string at the end
+func (p *conditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return strings.ToLower(p.tokens[p.pos])
+	}
+	return ""
+}
+
+// or parses conditions joined with or
+func (p *conditionParser) or() (condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(s map[string]search, r map[string]interface{}) bool { return l(s, r) || right(s, r) }
+	}
+	return left, nil
+}
+
+// and parses conditions joined with and
+func (p *conditionParser) and() (condition, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" {
+		p.pos++
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(s map[string]search, r map[string]interface{}) bool { return l(s, r) && right(s, r) }
+	}
+	return left, nil
+}
+
+// not parses a condition that may be negated
+func (p *conditionParser) not() (condition, error) {
+	if p.peek() == "not" {
+		p.pos++
+		inner, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(s map[string]search, r map[string]interface{}) bool { return !inner(s, r) }, nil
+	}
+	return p.primary()
+}
+
+// primary parses a parenthesized condition, a quantified search pattern or a
+// search name
+func (p *conditionParser) primary() (condition, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of condition")
+	case token == "(":
+		p.pos++
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	case (token == "all" || token == "any" || isCount(token)) && p.pos+1 < len(p.tokens) && strings.EqualFold(p.tokens[p.pos+1], "of"):
+		if p.pos+2 >= len(p.tokens) {
+			return nil, fmt.Errorf("missing search pattern after %s of", token)
+		}
+		pattern := p.tokens[p.pos+2]
+		p.pos += 3
+		return p.quantified(token, pattern)
+	}
+
+	name := p.tokens[p.pos]
+	if _, ok := p.searches[name]; !ok {
+		return nil, fmt.Errorf("unknown search %s", name)
+	}
+	p.pos++
+	return func(s map[string]search, r map[string]interface{}) bool { return s[name](r) }, nil
+}
+
+// quantified builds a condition matching when all, or at least n, of the
+// searches whose names match pattern match
+func (p *conditionParser) quantified(quantifier, pattern string) (condition, error) {
+	names := make([]string, 0)
+	for name := range p.searches {
+		if matched, _ := path.Match(pattern, name); strings.EqualFold(pattern, "them") || matched {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no search matches %s", pattern)
+	}
+
+	need := len(names)
+	if quantifier == "any" {
+		need = 1
+	} else if quantifier != "all" {
+		need, _ = strconv.Atoi(quantifier)
+	}
+
+	return func(s map[string]search, r map[string]interface{}) bool {
+		matched := 0
+		for _, name := range names {
+			if s[name](r) {
+				matched++
+				if matched >= need {
+					return true
+				}
+			}
+		}
+		return false
+	}, nil
+}
+
+// isCount reports whether a condition token is a positive count, as in
+// "1 of selection*"
+func isCount(token string) bool {
+	n, err := strconv.Atoi(token)
+	return err == nil && n > 0
+}
+
+// fieldValues returns the values of a record's field as strings. A field
+// that is not a key of the record is looked up as a dotted path into nested
+// objects. Each element of an array is a value.
+func fieldValues(record map[string]interface{}, field string) []string {
+	value, ok := record[field]
+	if !ok {
+		var current interface{} = record
+		for _, key := range strings.Split(field, ".") {
+			object, isObject := current.(map[string]interface{})
+			if !isObject {
+				return nil
+			}
+			if current, ok = object[key]; !ok {
+				return nil
+			}
+		}
+		value = current
+	}
+
+	if items, isArray := value.([]interface{}); isArray {
+		values := make([]string, 0, len(items))
+		for _, item := range items {
+			if v, ok := scalarString(item); ok {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+	if v, ok := scalarString(value); ok {
+		return []string{v}
+	}
+	return nil
+}
+
+// allValues returns every scalar value in a record, at any depth
+func allValues(value interface{}) []string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		values := make([]string, 0, len(v))
+		for _, nested := range v {
+			values = append(values, allValues(nested)...)
+		}
+		return values
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, nested := range v {
+			values = append(values, allValues(nested)...)
+		}
+		return values
+	}
+	if s, ok := scalarString(value); ok {
+		return []string{s}
+	}
+	return nil
+}
+
+// scalarString returns a decoded JSON scalar as a string; objects are
+// re-encoded and null is no value
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+}
@@ -0,0 +1,383 @@
+package sigma
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"riskmatrix/internal/datasource"
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/mitre"
+	validation "riskmatrix/pkg"
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+// Import actions
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportFailed  = "failed"
+)
+
+// ImportResult is the outcome of importing one rule
+type ImportResult struct {
+	Title       string   `json:"title"`
+	RuleID      string   `json:"rule_id,omitempty"`
+	DetectionID int64    `json:"detection_id,omitempty"`
+	Action      string   `json:"action"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// Repository imports Sigma rules into the detection catalog and exports
+// detections as Sigma rules
+type Repository struct {
+	db          *database.DB
+	detections  *detection.Repository
+	dataSources *datasource.Repository
+	mitre       *mitre.Repository
+	lifecycle   *detection.Lifecycle
+}
+
+// NewRepository creates a new Sigma repository
+func NewRepository(db *database.DB) *Repository {
+	detections := detection.NewRepository(db)
+	return &Repository{
+		db:          db,
+		detections:  detections,
+		dataSources: datasource.NewRepository(db),
+		mitre:       mitre.NewRepository(db),
+		lifecycle:   detection.NewLifecycle(detections, detection.DefaultLifecycleConfig()),
+	}
+}
+
+// SetLifecycle sets the lifecycle that decides which rule statuses apply,
+// replacing the default one
+func (r *Repository) SetLifecycle(lifecycle *detection.Lifecycle) {
+	r.lifecycle = lifecycle
+}
+
+// Import creates or updates a detection for each rule. A rule updates the
+// detection previously imported with the same rule ID, or else the detection
+// with the same name, unless it was imported from another rule.
+func (r *Repository) Import(rules []*Rule) []ImportResult {
+	results := make([]ImportResult, len(rules))
+	for i, rule := range rules {
+		results[i] = ImportResult{Title: rule.Title, RuleID: rule.ID}
+		if err := r.importRule(rule, &results[i]); err != nil {
+			results[i].Action = ImportFailed
+			results[i].Error = err.Error()
+		}
+	}
+	return results
+}
+
+// importRule imports one rule, recording what it did in result. The
+// detection, its links and the rule are written in a single transaction.
+func (r *Repository) importRule(rule *Rule, result *ImportResult) error {
+	query, err := rule.Query()
+	if err != nil {
+		return err
+	}
+
+	existing, err := r.findDetection(rule)
+	if err != nil {
+		return err
+	}
+
+	det := &models.Detection{Status: models.StatusDraft}
+	var from models.DetectionStatus
+	if existing != nil {
+		copied := *existing
+		det = &copied
+		from = existing.Status
+	}
+
+	// A rule cannot make a status change the lifecycle does not allow, such
+	// as a promotion that needs approval. The detection keeps its status
+	// instead, or a new one starts as a draft.
+	warnings := make([]string, 0)
+	if status := rule.DetectionStatus(); status != det.Status {
+		if err := r.lifecycle.CheckTransition(from, status); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%v; imported as %s", err, det.Status))
+		} else {
+			det.Status = status
+		}
+	}
+
+	det.Name = strings.TrimSpace(rule.Title)
+	det.Description = strings.TrimSpace(rule.Description)
+	det.Query = query
+	det.Severity = rule.Severity()
+
+	if err := validation.ValidateDetection(det); err != nil {
+		return err
+	}
+
+	// Look up everything else before the transaction starts: the rule this
+	// detection was imported from before, if any, the techniques in the
+	// catalog and the data sources to link and unlink
+	var previous *Rule
+	if existing != nil {
+		if previous, err = r.GetRule(existing.ID); err != nil {
+			return err
+		}
+	}
+
+	techniques := make([]string, 0)
+	for _, id := range rule.TechniqueIDs() {
+		if _, err := r.mitre.GetMitreTechnique(id); err != nil {
+			warnings = append(warnings, fmt.Sprintf("unknown ATT&CK technique %s", id))
+			continue
+		}
+		techniques = append(techniques, id)
+	}
+
+	name := rule.DataSourceName()
+	var dataSource, oldDataSource *models.DataSource
+	if name != "" {
+		// A data source that does not exist yet is created
+		dataSource, _ = r.dataSources.GetDataSourceByName(name)
+	}
+	if previous != nil {
+		if old := previous.DataSourceName(); old != "" && old != name {
+			oldDataSource, _ = r.dataSources.GetDataSourceByName(old)
+		}
+	}
+
+	// The detection, with its new links, must still meet the requirements of
+	// its status; a re-import cannot strip a production detection's query or
+	// techniques
+	linked := *det
+	linked.MitreTechniques = make([]models.MitreTechnique, len(techniques))
+	for i, id := range techniques {
+		linked.MitreTechniques[i] = models.MitreTechnique{ID: id}
+	}
+	linked.DataSources = nil
+	if name != "" {
+		linked.DataSources = []models.DataSource{{Name: name}}
+	}
+	if err := r.lifecycle.CheckUpdate(existing, &linked); err != nil {
+		return err
+	}
+
+	data, err := rule.Render()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	action := ImportCreated
+	if existing != nil {
+		if err := r.detections.UpdateDetectionTx(tx, det, "sigma", "Imported from Sigma rule"); err != nil {
+			return fmt.Errorf("error updating detection: %w", err)
+		}
+		action = ImportUpdated
+	} else if err := r.detections.CreateDetectionTx(tx, det); err != nil {
+		return err
+	}
+
+	if err := r.syncTechniques(tx, det, techniques); err != nil {
+		return err
+	}
+
+	if dataSource == nil && name != "" {
+		dataSource = &models.DataSource{
+			Name:        name,
+			Description: fmt.Sprintf("Sigma logsource %s", logSourceDescription(rule.LogSource)),
+		}
+		if err := r.dataSources.CreateDataSourceTx(tx, dataSource); err != nil {
+			return fmt.Errorf("error creating data source %s: %w", name, err)
+		}
+	}
+	if err := r.syncDataSource(tx, det.ID, dataSource, oldDataSource); err != nil {
+		return err
+	}
+
+	if err := saveRule(tx, det.ID, rule.ID, data); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	result.Action = action
+	result.DetectionID = det.ID
+	result.Warnings = append(result.Warnings, warnings...)
+	return nil
+}
+
+// findDetection returns the detection a rule updates, or nil if it is new.
+// A rule whose ID is not recorded yet only takes over a detection of the same
+// title that was not imported from another Sigma rule.
+func (r *Repository) findDetection(rule *Rule) (*models.Detection, error) {
+	if rule.ID != "" {
+		var id int64
+		err := r.db.QueryRow(`SELECT detection_id FROM detection_sigma_rules WHERE rule_id = ?`, rule.ID).Scan(&id)
+		if err == nil {
+			return r.detections.GetDetection(id)
+		}
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("error querying sigma rule: %w", err)
+		}
+	}
+
+	// A rule without an ID can only match an earlier import of a rule
+	// without one
+	query := `SELECT d.id FROM detections d
+              WHERE d.name = ? COLLATE NOCASE
+              AND NOT EXISTS (SELECT 1 FROM detection_sigma_rules s WHERE s.detection_id = d.id AND (? OR s.rule_id IS NOT NULL))
+              ORDER BY d.id LIMIT 1`
+	var id int64
+	err := r.db.QueryRow(query, strings.TrimSpace(rule.Title), rule.ID != "").Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying detection: %w", err)
+	}
+	return r.detections.GetDetection(id)
+}
+
+// syncTechniques maps a detection to exactly the given techniques, which
+// must be in the catalog
+func (r *Repository) syncTechniques(tx *sql.Tx, det *models.Detection, techniqueIDs []string) error {
+	wanted := make(map[string]bool, len(techniqueIDs))
+	for _, id := range techniqueIDs {
+		wanted[id] = true
+	}
+
+	for _, technique := range det.MitreTechniques {
+		if !wanted[technique.ID] {
+			if err := r.detections.RemoveMitreTechniqueTx(tx, det.ID, technique.ID); err != nil {
+				return fmt.Errorf("error removing technique %s: %w", technique.ID, err)
+			}
+		}
+	}
+
+	for _, id := range techniqueIDs {
+		if err := r.detections.AddMitreTechniqueTx(tx, det.ID, id); err != nil {
+			return fmt.Errorf("error adding technique %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// syncDataSource links a detection to the data source for its rule's
+// logsource, if any, and unlinks the data source of the rule it was
+// previously imported from, if that differs
+func (r *Repository) syncDataSource(tx *sql.Tx, detectionID int64, dataSource, old *models.DataSource) error {
+	if old != nil {
+		if err := r.detections.RemoveDataSourceTx(tx, detectionID, old.ID); err != nil {
+			return fmt.Errorf("error removing data source %s: %w", old.Name, err)
+		}
+	}
+
+	if dataSource == nil {
+		return nil
+	}
+	if err := r.detections.AddDataSourceTx(tx, detectionID, dataSource.ID); err != nil {
+		return fmt.Errorf("error adding data source %s: %w", dataSource.Name, err)
+	}
+	return nil
+}
+
+// GetRule retrieves the rule a detection was imported from, or nil if it was
+// not imported
+func (r *Repository) GetRule(detectionID int64) (*Rule, error) {
+	var data string
+	err := r.db.QueryRow(`SELECT rule FROM detection_sigma_rules WHERE detection_id = ?`, detectionID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying sigma rule: %w", err)
+	}
+
+	rules, err := Parse([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	return rules[0], nil
+}
+
+// SaveRule records the rule a detection was imported from
+func (r *Repository) SaveRule(detectionID int64, rule *Rule) error {
+	data, err := rule.Render()
+	if err != nil {
+		return err
+	}
+	return saveRule(r.db, detectionID, rule.ID, data)
+}
+
+// saveRule records a rendered rule with db
+func saveRule(db database.Execer, detectionID int64, ruleID string, data []byte) error {
+	var id sql.NullString
+	if ruleID != "" {
+		id = sql.NullString{String: ruleID, Valid: true}
+	}
+
+	query := `INSERT INTO detection_sigma_rules (detection_id, rule_id, rule, updated_at) VALUES (?, ?, ?, ?)
+              ON CONFLICT(detection_id) DO UPDATE SET rule_id = excluded.rule_id, rule = excluded.rule, updated_at = excluded.updated_at`
+	if _, err := db.Exec(query, detectionID, id, string(data), time.Now().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("error saving sigma rule: %w", err)
+	}
+	return nil
+}
+
+// Export returns a detection as a Sigma rule. A detection imported from a
+// rule keeps that rule's other fields.
+func (r *Repository) Export(detectionID int64) (*Rule, error) {
+	det, err := r.detections.GetDetection(detectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := r.GetRule(detectionID)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		rule = &Rule{}
+		if len(det.DataSources) > 0 {
+			rule.LogSource.Service = det.DataSources[0].Name
+		}
+	}
+
+	// Technique tags that could not be mapped are kept as they were
+	unmapped := make([]string, 0)
+	for _, tag := range rule.Tags {
+		if match := techniqueTag.FindStringSubmatch(strings.ToLower(tag)); match != nil {
+			if _, err := r.mitre.GetMitreTechnique(strings.ToUpper(match[1])); err != nil {
+				unmapped = append(unmapped, tag)
+			}
+		}
+	}
+
+	rule.Apply(det)
+	rule.Tags = append(rule.Tags, unmapped...)
+	return rule, nil
+}
+
+// logSourceDescription describes a logsource as key=value pairs
+func logSourceDescription(logSource LogSource) string {
+	parts := make([]string, 0, 3)
+	for _, field := range []struct{ key, value string }{
+		{"product", logSource.Product},
+		{"category", logSource.Category},
+		{"service", logSource.Service},
+	} {
+		if field.value != "" {
+			parts = append(parts, field.key+"="+field.value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
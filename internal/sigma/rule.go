@@ -0,0 +1,220 @@
+package sigma
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"riskmatrix/pkg/models"
+)
+
+// Rule is a Sigma detection rule. Fields the catalog does not model are kept
+// in Extra so a rule can be rendered back without losing them.
+type Rule struct {
+	Title          string                 `yaml:"title"`
+	ID             string                 `yaml:"id,omitempty"`
+	Status         string                 `yaml:"status,omitempty"`
+	Description    string                 `yaml:"description,omitempty"`
+	References     []string               `yaml:"references,omitempty"`
+	Author         string                 `yaml:"author,omitempty"`
+	Date           string                 `yaml:"date,omitempty"`
+	Modified       string                 `yaml:"modified,omitempty"`
+	Tags           []string               `yaml:"tags,omitempty"`
+	LogSource      LogSource              `yaml:"logsource"`
+	Detection      yaml.Node              `yaml:"detection"`
+	FalsePositives []string               `yaml:"falsepositives,omitempty"`
+	Level          string                 `yaml:"level,omitempty"`
+	Extra          map[string]interface{} `yaml:",inline"`
+}
+
+// LogSource names the logs a Sigma rule applies to
+type LogSource struct {
+	Product    string `yaml:"product,omitempty"`
+	Category   string `yaml:"category,omitempty"`
+	Service    string `yaml:"service,omitempty"`
+	Definition string `yaml:"definition,omitempty"`
+}
+
+// techniqueTag matches ATT&CK technique tags such as attack.t1059.001
+var techniqueTag = regexp.MustCompile(`^attack\.(t\d{4}(?:\.\d{3})?)$`)
+
+// Sigma statuses and levels and the catalog values they map to
+var (
+	statusToDetection = map[string]models.DetectionStatus{
+		"stable":       models.StatusProduction,
+		"test":         models.StatusTest,
+		"experimental": models.StatusDraft,
+		"deprecated":   models.StatusRetired,
+		"unsupported":  models.StatusRetired,
+	}
+	statusFromDetection = map[models.DetectionStatus]string{
+		models.StatusIdea:       "experimental",
+		models.StatusDraft:      "experimental",
+		models.StatusTest:       "test",
+		models.StatusProduction: "stable",
+		models.StatusRetired:    "deprecated",
+	}
+	levelToSeverity = map[string]models.Severity{
+		"informational": models.SeverityLow,
+		"low":           models.SeverityLow,
+		"medium":        models.SeverityMedium,
+		"high":          models.SeverityHigh,
+		"critical":      models.SeverityCritical,
+	}
+)
+
+// Parse parses one or more Sigma rules from YAML documents
+func Parse(data []byte) ([]*Rule, error) {
+	rules := make([]*Rule, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for n := 0; ; n++ {
+		var rule Rule
+		if err := decoder.Decode(&rule); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing rule %d: %w", n, err)
+		}
+
+		if rule.Title == "" {
+			return nil, fmt.Errorf("rule %d has no title", n)
+		}
+		if rule.Detection.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("rule %s has no detection", rule.Title)
+		}
+		rules = append(rules, &rule)
+	}
+
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no rules found")
+	}
+	return rules, nil
+}
+
+// Render renders a rule as a YAML document
+func (r *Rule) Render() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(4)
+	if err := encoder.Encode(r); err != nil {
+		return nil, fmt.Errorf("error rendering rule: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("error rendering rule: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Query returns the rule's detection block as YAML, the form it is kept in
+// as a detection's query
+func (r *Rule) Query() (string, error) {
+	data, err := yaml.Marshal(&r.Detection)
+	if err != nil {
+		return "", fmt.Errorf("error rendering detection: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SetQuery sets the rule's detection block from a detection's query. A query
+// that is not a Sigma detection block is kept as a keyword search.
+func (r *Rule) SetQuery(query string) {
+	if block, ok := detectionBlock(query); ok {
+		r.Detection = *block
+		return
+	}
+
+	keywords := make([]string, 0)
+	if query = strings.TrimSpace(query); query != "" {
+		keywords = append(keywords, query)
+	}
+	r.Detection = yaml.Node{}
+	_ = r.Detection.Encode(map[string]interface{}{
+		"keywords":  keywords,
+		"condition": "keywords",
+	})
+}
+
+// Severity returns the catalog severity for the rule's level, defaulting to
+// medium
+func (r *Rule) Severity() models.Severity {
+	if severity, ok := levelToSeverity[strings.ToLower(r.Level)]; ok {
+		return severity
+	}
+	return models.SeverityMedium
+}
+
+// DetectionStatus returns the catalog status for the rule's status,
+// defaulting to draft
+func (r *Rule) DetectionStatus() models.DetectionStatus {
+	if status, ok := statusToDetection[strings.ToLower(r.Status)]; ok {
+		return status
+	}
+	return models.StatusDraft
+}
+
+// TechniqueIDs returns the ATT&CK technique IDs in the rule's tags, such as
+// T1059.001 for attack.t1059.001
+func (r *Rule) TechniqueIDs() []string {
+	ids := make([]string, 0)
+	for _, tag := range r.Tags {
+		if match := techniqueTag.FindStringSubmatch(strings.ToLower(tag)); match != nil {
+			ids = append(ids, strings.ToUpper(match[1]))
+		}
+	}
+	return ids
+}
+
+// DataSourceName returns the name of the data source the rule's logsource
+// maps to: its product, category and service joined with underscores, such
+// as windows_process_creation
+func (r *Rule) DataSourceName() string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{r.LogSource.Product, r.LogSource.Category, r.LogSource.Service} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, strings.ToLower(part))
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// Apply sets the rule's fields from a detection. Tags other than technique
+// tags are kept, as are a status and level that still map to the detection's.
+func (r *Rule) Apply(detection *models.Detection) {
+	r.Title = detection.Name
+	r.Description = detection.Description
+	if r.DetectionStatus() != detection.Status || r.Status == "" {
+		r.Status = statusFromDetection[detection.Status]
+	}
+	if r.Severity() != detection.Severity || r.Level == "" {
+		r.Level = string(detection.Severity)
+	}
+	r.SetQuery(detection.Query)
+
+	tags := make([]string, 0, len(r.Tags)+len(detection.MitreTechniques))
+	for _, tag := range r.Tags {
+		if !techniqueTag.MatchString(strings.ToLower(tag)) {
+			tags = append(tags, tag)
+		}
+	}
+	techniques := make([]string, 0, len(detection.MitreTechniques))
+	for _, technique := range detection.MitreTechniques {
+		techniques = append(techniques, "attack."+strings.ToLower(technique.ID))
+	}
+	sort.Strings(techniques)
+	r.Tags = append(tags, techniques...)
+}
+
+// hasKey reports whether a mapping node has the given key
+func hasKey(node *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,341 @@
+package sigma
+
+import (
+	"strings"
+	"testing"
+
+	"riskmatrix/pkg/database"
+	"riskmatrix/pkg/models"
+)
+
+const testRule = `title: Suspicious Encoded PowerShell
+id: 5b1a3c5e-9d1f-4a2b-8c3d-1e2f3a4b5c6d
+status: stable
+description: Detects PowerShell started with an encoded command
+author: Detection Team
+date: 2026-10-01
+tags:
+    - attack.execution
+    - attack.t1059.001
+    - attack.t9999
+logsource:
+    product: windows
+    category: process_creation
+detection:
+    selection:
+        Image|endswith: '\powershell.exe'
+        CommandLine|contains: ' -enc '
+    condition: selection
+falsepositives:
+    - Admin scripts
+level: informational
+custom_field: kept
+`
+
+// setupTestRepo creates a Sigma repository with a test database holding one
+// ATT&CK technique
+func setupTestRepo(t *testing.T) (*Repository, *database.DB) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+
+	repo := NewRepository(db)
+	if err := repo.mitre.CreateMitreTechnique(&models.MitreTechnique{ID: "T1059.001", Name: "PowerShell", Tactic: "Execution"}); err != nil {
+		t.Fatalf("Failed to create test technique: %v", err)
+	}
+	return repo, db
+}
+
+func TestParse(t *testing.T) {
+	rules, err := Parse([]byte(testRule + "---\ntitle: Second\nlogsource:\n    service: cloudtrail\ndetection:\n    keywords: [x]\n    condition: keywords\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(rules))
+	}
+
+	rule := rules[0]
+	if rule.DetectionStatus() != models.StatusProduction || rule.Severity() != models.SeverityLow {
+		t.Errorf("Unexpected status %s and severity %s", rule.DetectionStatus(), rule.Severity())
+	}
+	if ids := rule.TechniqueIDs(); len(ids) != 2 || ids[0] != "T1059.001" || ids[1] != "T9999" {
+		t.Errorf("Unexpected technique IDs %v", ids)
+	}
+	if name := rule.DataSourceName(); name != "windows_process_creation" {
+		t.Errorf("Expected data source windows_process_creation, got %s", name)
+	}
+	if rules[1].DetectionStatus() != models.StatusDraft || rules[1].DataSourceName() != "cloudtrail" {
+		t.Errorf("Unexpected second rule %+v", rules[1])
+	}
+
+	for _, invalid := range []string{"", "title: No Detection\n", "detection:\n    condition: x\n", "title: [unclosed"} {
+		if _, err := Parse([]byte(invalid)); err == nil {
+			t.Errorf("Expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestRepository_Import(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	rules, err := Parse([]byte(testRule))
+	if err != nil {
+		t.Fatalf("Failed to parse rule: %v", err)
+	}
+
+	results := repo.Import(rules)
+	if results[0].Action != ImportCreated || results[0].Error != "" {
+		t.Fatalf("Expected rule to be created, got %+v", results[0])
+	}
+	if warnings := strings.Join(results[0].Warnings, "\n"); !strings.Contains(warnings, "T9999") || !strings.Contains(warnings, "approval") {
+		t.Errorf("Expected warnings for the unknown technique and the promotion, got %v", results[0].Warnings)
+	}
+
+	det, err := repo.detections.GetDetection(results[0].DetectionID)
+	if err != nil {
+		t.Fatalf("Failed to get detection: %v", err)
+	}
+	// A stable rule is production, but promotion to production needs approval
+	if det.Name != "Suspicious Encoded PowerShell" || det.Status != models.StatusDraft || det.Severity != models.SeverityLow {
+		t.Errorf("Unexpected detection %+v", det)
+	}
+	if !strings.HasPrefix(det.Query, "selection:") || !strings.Contains(det.Query, "condition: selection") {
+		t.Errorf("Expected the detection block as query, got %q", det.Query)
+	}
+	if len(det.MitreTechniques) != 1 || det.MitreTechniques[0].ID != "T1059.001" {
+		t.Errorf("Expected technique T1059.001, got %+v", det.MitreTechniques)
+	}
+	if len(det.DataSources) != 1 || det.DataSources[0].Name != "windows_process_creation" {
+		t.Errorf("Expected data source windows_process_creation, got %+v", det.DataSources)
+	}
+
+	// Once promoted, re-imports keep the detection in production
+	det.PlaybookLink = "https://example.com/playbook"
+	det.Owner = "detections@example.com"
+	det.TestingDescription = "Run an encoded command"
+	det.Status = models.StatusProduction
+	if err := repo.detections.UpdateDetection(det); err != nil {
+		t.Fatalf("Failed to update detection: %v", err)
+	}
+
+	// A re-import cannot leave a production detection without techniques
+	rules[0].Tags = []string{"attack.execution"}
+	results = repo.Import(rules)
+	if results[0].Action != ImportFailed || !strings.Contains(results[0].Error, "mitre_techniques") {
+		t.Fatalf("Expected import to fail on the production requirements, got %+v", results[0])
+	}
+
+	// A re-import with the same rule ID updates the detection, even renamed
+	rules[0].Title = "Encoded PowerShell"
+	rules[0].Tags = []string{"attack.execution", "attack.t1059.001"}
+	rules[0].LogSource = LogSource{Product: "windows", Service: "sysmon"}
+	results = repo.Import(rules)
+	if results[0].Action != ImportUpdated || results[0].DetectionID != det.ID {
+		t.Fatalf("Expected detection %d to be updated, got %+v", det.ID, results[0])
+	}
+
+	det, _ = repo.detections.GetDetection(det.ID)
+	if det.Name != "Encoded PowerShell" || det.Status != models.StatusProduction || len(det.MitreTechniques) != 1 {
+		t.Errorf("Expected renamed production detection, got %+v", det)
+	}
+	if len(det.DataSources) != 1 || det.DataSources[0].Name != "windows_sysmon" {
+		t.Errorf("Expected only data source windows_sysmon, got %+v", det.DataSources)
+	}
+
+	// Rules that do not make valid detections fail on their own
+	rules[0].ID = ""
+	rules[0].Title = strings.Repeat("x", 300)
+	if results = repo.Import(rules); results[0].Action != ImportFailed || results[0].Error == "" {
+		t.Errorf("Expected import to fail, got %+v", results[0])
+	}
+}
+
+func TestRepository_Import_Atomic(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	// Saving the rule fails after the detection and its links are written
+	if _, err := db.Exec(`CREATE TRIGGER fail_sigma_rule BEFORE INSERT ON detection_sigma_rules
+		BEGIN SELECT RAISE(ABORT, 'cannot save rule'); END`); err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+
+	rules, _ := Parse([]byte(testRule))
+	if result := repo.Import(rules)[0]; result.Action != ImportFailed || result.DetectionID != 0 {
+		t.Fatalf("Expected import to fail, got %+v", result)
+	}
+
+	var detections, dataSources int
+	db.QueryRow("SELECT COUNT(*) FROM detections").Scan(&detections)
+	db.QueryRow("SELECT COUNT(*) FROM data_sources").Scan(&dataSources)
+	if detections != 0 || dataSources != 0 {
+		t.Errorf("Expected nothing written, got %d detections and %d data sources", detections, dataSources)
+	}
+}
+
+func TestRepository_Import_TitleMatch(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	// A hand-written detection is taken over by a rule of the same title
+	manual := &models.Detection{Name: "Suspicious Encoded PowerShell", Status: models.StatusDraft, Severity: models.SeverityMedium}
+	if err := repo.detections.CreateDetection(manual); err != nil {
+		t.Fatalf("Failed to create detection: %v", err)
+	}
+	rules, _ := Parse([]byte(testRule))
+	if result := repo.Import(rules)[0]; result.Action != ImportUpdated || result.DetectionID != manual.ID {
+		t.Fatalf("Expected detection %d to be updated, got %+v", manual.ID, result)
+	}
+
+	// Another rule with the same title does not take it over
+	other, _ := Parse([]byte(testRule))
+	other[0].ID = "0c9f7a52-3e8d-4b6a-9f1e-2d3c4b5a6e7f"
+	result := repo.Import(other)[0]
+	if result.Action != ImportCreated || result.DetectionID == manual.ID {
+		t.Fatalf("Expected a new detection, got %+v", result)
+	}
+	if rule, err := repo.GetRule(manual.ID); err != nil || rule.ID != rules[0].ID {
+		t.Errorf("Expected detection %d to keep rule %s, got %+v (%v)", manual.ID, rules[0].ID, rule, err)
+	}
+}
+
+func TestRepository_Export(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	rules, _ := Parse([]byte(testRule))
+	id := repo.Import(rules)[0].DetectionID
+
+	// Catalog changes are exported along with the rule's other fields
+	det, _ := repo.detections.GetDetection(id)
+	det.Severity = models.SeverityHigh
+	if err := repo.detections.UpdateDetection(det); err != nil {
+		t.Fatalf("Failed to update detection: %v", err)
+	}
+
+	rule, err := repo.Export(id)
+	if err != nil {
+		t.Fatalf("Failed to export detection: %v", err)
+	}
+	data, err := rule.Render()
+	if err != nil {
+		t.Fatalf("Failed to render rule: %v", err)
+	}
+
+	exported := string(data)
+	for _, expected := range []string{
+		"id: 5b1a3c5e-9d1f-4a2b-8c3d-1e2f3a4b5c6d",
+		"status: experimental",
+		"level: high",
+		"- attack.execution",
+		"- attack.t1059.001",
+		"category: process_creation",
+		"CommandLine|contains: ' -enc '",
+		"- Admin scripts",
+		"custom_field: kept",
+	} {
+		if !strings.Contains(exported, expected) {
+			t.Errorf("Expected export to contain %q:\n%s", expected, exported)
+		}
+	}
+	if !strings.Contains(exported, "- attack.t9999") {
+		t.Errorf("Expected the unmapped technique tag to be kept:\n%s", exported)
+	}
+
+	// The export imports back as the same detection
+	reimported, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse export: %v", err)
+	}
+	if result := repo.Import(reimported)[0]; result.Action != ImportUpdated || result.DetectionID != id {
+		t.Errorf("Expected export to update detection %d, got %+v", id, result)
+	}
+}
+
+func TestRepository_Export_NotImported(t *testing.T) {
+	repo, db := setupTestRepo(t)
+	defer db.Close()
+
+	det := &models.Detection{Name: "Free Text", Query: "index=win EventCode=4625", Status: models.StatusDraft, Severity: models.SeverityMedium}
+	if err := repo.detections.CreateDetection(det); err != nil {
+		t.Fatalf("Failed to create detection: %v", err)
+	}
+
+	rule, err := repo.Export(det.ID)
+	if err != nil {
+		t.Fatalf("Failed to export detection: %v", err)
+	}
+	if rule.Title != "Free Text" || rule.Status != "experimental" || rule.Level != "medium" {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+
+	// A free-text query becomes a keyword search
+	query, _ := rule.Query()
+	if !strings.Contains(query, "index=win EventCode=4625") || !strings.Contains(query, "condition: keywords") {
+		t.Errorf("Expected a keyword search, got %q", query)
+	}
+
+	if _, err := repo.Export(999); err == nil {
+		t.Error("Expected error exporting a missing detection")
+	}
+}
+
+func TestCompileQuery(t *testing.T) {
+	query := `selection_img:
+    Image|endswith: '\powershell.exe'
+selection_cli:
+    CommandLine|contains|all:
+        - ' -enc'
+        - 'hidden'
+filter:
+    User:
+        - SYSTEM
+        - 'NT AUTHORITY\*'
+condition: all of selection_* and not filter`
+
+	matcher, err := CompileQuery(query)
+	if err != nil {
+		t.Fatalf("Failed to compile query: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		record   map[string]interface{}
+		expected bool
+	}{
+		{"match", map[string]interface{}{"Image": `C:\Windows\PowerShell.exe`, "CommandLine": "powershell -w Hidden -enc AAA", "User": "alice"}, true},
+		{"missing all value", map[string]interface{}{"Image": `C:\powershell.exe`, "CommandLine": "powershell -enc AAA", "User": "alice"}, false},
+		{"filtered", map[string]interface{}{"Image": `C:\powershell.exe`, "CommandLine": "-enc hidden", "User": `NT AUTHORITY\NETWORK SERVICE`}, false},
+		{"other image", map[string]interface{}{"Image": `C:\cmd.exe`, "CommandLine": "-enc hidden"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if matched := matcher.Match(tt.record); matched != tt.expected {
+				t.Errorf("Expected match %v, got %v", tt.expected, matched)
+			}
+		})
+	}
+
+	// Keywords match any value, including nested ones
+	keywords, err := CompileQuery("keywords:\n    - mimikatz\ncondition: keywords")
+	if err != nil {
+		t.Fatalf("Failed to compile query: %v", err)
+	}
+	if !keywords.Match(map[string]interface{}{"process": map[string]interface{}{"cmd": "Invoke-Mimikatz"}}) {
+		t.Error("Expected keyword to match a nested value")
+	}
+
+	for _, invalid := range []string{
+		"selection:\n    Image|base64: x\ncondition: selection",
+		"selection:\n    Image: x\ncondition: selection and other",
+		"selection:\n    Image: x\ncondition: (selection",
+		"index=win EventCode=4625",
+	} {
+		if _, err := CompileQuery(invalid); err == nil {
+			t.Errorf("Expected error compiling %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+-- Migration: Alert Deduplication and Re-alerting
+-- Version: 002
+-- Date: 2026-10-16
+-- Description: Adds closed_at to risk_alerts and the risk_alert_events link table
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Record when an alert was closed so re-alerting can honour a cooldown
+ALTER TABLE risk_alerts ADD COLUMN closed_at TIMESTAMP;
+
+-- Alerts closed before this migration count as closed when they triggered
+UPDATE risk_alerts SET closed_at = triggered_at WHERE status = 'Closed';
+
+-- Events that contributed to a risk alert
+CREATE TABLE IF NOT EXISTS risk_alert_events (
+    alert_id INTEGER NOT NULL,
+    event_id INTEGER NOT NULL,
+    risk_points INTEGER NOT NULL DEFAULT 0, -- points the event contributed to the alert score
+    PRIMARY KEY (alert_id, event_id),
+    FOREIGN KEY (alert_id) REFERENCES risk_alerts(id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_risk_alert_events_event_id ON risk_alert_events(event_id);
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- Rollback Migration: Remove Alert Deduplication
+-- Version: 002
+-- Date: 2026-10-16
+-- Description: Drops the risk_alert_events link table
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_risk_alert_events_event_id;
+DROP TABLE IF EXISTS risk_alert_events;
+
+-- SQLite doesn't support dropping columns directly; closed_at is left in
+-- place and is ignored by older versions
+
+COMMIT;
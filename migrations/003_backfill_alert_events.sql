@@ -0,0 +1,24 @@
+-- Migration: Backfill Alert Event Links
+-- Version: 003
+-- Date: 2026-10-16
+-- Description: Links events to risk alerts raised before alert events were stored
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- For each alert with no stored events, link the entity's non-false-positive
+-- events seen after its previous alert and up to when it triggered
+INSERT OR IGNORE INTO risk_alert_events (alert_id, event_id, risk_points)
+SELECT a.id, e.id, e.risk_points
+FROM risk_alerts a
+JOIN events e ON e.entity_id = a.entity_id
+WHERE e.is_false_positive = 0
+  AND datetime(e.timestamp) <= datetime(a.triggered_at)
+  AND datetime(e.timestamp) > COALESCE(
+      (SELECT MAX(datetime(p.triggered_at)) FROM risk_alerts p
+       WHERE p.entity_id = a.entity_id AND datetime(p.triggered_at) < datetime(a.triggered_at)),
+      '0001-01-01 00:00:00')
+  AND NOT EXISTS (SELECT 1 FROM risk_alert_events l WHERE l.alert_id = a.id);
+
+COMMIT;
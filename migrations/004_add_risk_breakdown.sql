@@ -0,0 +1,13 @@
+-- Migration: Add Risk Breakdown
+-- Version: 004
+-- Date: 2026-10-16
+-- Description: Adds risk_breakdown to events to explain modified risk points
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- JSON explanation of how risk_points was calculated
+ALTER TABLE events ADD COLUMN risk_breakdown TEXT;
+
+COMMIT;
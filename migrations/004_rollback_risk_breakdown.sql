@@ -0,0 +1,14 @@
+-- Rollback Migration: Remove Risk Breakdown
+-- Version: 004
+-- Date: 2026-10-16
+-- Description: Clears risk_breakdown on events
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- SQLite doesn't support dropping columns directly; clear the values so the
+-- column is ignored by older versions
+UPDATE events SET risk_breakdown = NULL;
+
+COMMIT;
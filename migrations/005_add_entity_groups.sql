@@ -0,0 +1,31 @@
+-- Migration: Entity Correlation Groups
+-- Version: 005
+-- Date: 2026-10-16
+-- Description: Adds entity groups for correlated entities and group_id to risk_alerts
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Groups of entities correlated by appearing together in event context
+CREATE TABLE IF NOT EXISTS entity_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Entity group membership; an entity belongs to at most one group
+CREATE TABLE IF NOT EXISTS entity_group_members (
+    entity_id INTEGER PRIMARY KEY,
+    group_id INTEGER NOT NULL,
+    linked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (entity_id) REFERENCES risk_objects(id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES entity_groups(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_entity_group_members_group_id ON entity_group_members(group_id);
+
+-- Alerts raised on an entity group rather than a single entity
+ALTER TABLE risk_alerts ADD COLUMN group_id INTEGER REFERENCES entity_groups(id) ON DELETE SET NULL;
+
+COMMIT;
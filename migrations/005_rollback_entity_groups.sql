@@ -0,0 +1,18 @@
+-- Rollback Migration: Remove Entity Correlation Groups
+-- Version: 005
+-- Date: 2026-10-16
+-- Description: Drops the entity group tables
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- SQLite doesn't support dropping columns directly; clear group_id so the
+-- column is ignored by older versions
+UPDATE risk_alerts SET group_id = NULL;
+
+DROP INDEX IF EXISTS idx_entity_group_members_group_id;
+DROP TABLE IF EXISTS entity_group_members;
+DROP TABLE IF EXISTS entity_groups;
+
+COMMIT;
@@ -0,0 +1,28 @@
+-- Migration: Alert Rules
+-- Version: 006
+-- Date: 2026-10-16
+-- Description: Adds alert rules and rule_id to risk_alerts
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Alert rules evaluated against an entity's recent events alongside the score threshold
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT,
+    rule_type TEXT NOT NULL CHECK (rule_type IN ('distinct_detections', 'distinct_tactics', 'tactic_sequence')),
+    entity_type TEXT, -- user, host, ip; NULL matches any type
+    min_count INTEGER NOT NULL DEFAULT 0, -- distinct detections or tactics required
+    sequence TEXT, -- JSON array of tactics that must occur in order
+    window_minutes INTEGER NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Alerts raised by an alert rule rather than the score threshold
+ALTER TABLE risk_alerts ADD COLUMN rule_id INTEGER REFERENCES alert_rules(id) ON DELETE SET NULL;
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- Rollback Migration: Remove Alert Rules
+-- Version: 006
+-- Date: 2026-10-16
+-- Description: Drops the alert_rules table
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- SQLite doesn't support dropping columns directly; clear rule_id so the
+-- column is ignored by older versions
+UPDATE risk_alerts SET rule_id = NULL;
+
+DROP TABLE IF EXISTS alert_rules;
+
+COMMIT;
@@ -0,0 +1,20 @@
+-- Migration: Event Deduplication Keys
+-- Version: 007
+-- Date: 2026-10-16
+-- Description: Adds idempotency keys for ingested events
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Idempotency keys of ingested events, so replayed events are not scored twice
+CREATE TABLE IF NOT EXISTS event_dedup_keys (
+    dedup_key TEXT PRIMARY KEY, -- client external_id, or a hash of detection, entity, timestamp and raw_data
+    event_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_dedup_keys_created_at ON event_dedup_keys(created_at);
+
+COMMIT;
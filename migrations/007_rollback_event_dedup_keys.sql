@@ -0,0 +1,13 @@
+-- Rollback Migration: Remove Event Deduplication Keys
+-- Version: 007
+-- Date: 2026-10-16
+-- Description: Drops the event_dedup_keys table
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_event_dedup_keys_created_at;
+DROP TABLE IF EXISTS event_dedup_keys;
+
+COMMIT;
@@ -0,0 +1,13 @@
+-- Migration: Data Source Mapping Profiles
+-- Version: 008
+-- Date: 2026-10-16
+-- Description: Adds field-mapping profiles to data sources
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- JSON field-mapping profile for normalizing raw records
+ALTER TABLE data_sources ADD COLUMN mapping TEXT;
+
+COMMIT;
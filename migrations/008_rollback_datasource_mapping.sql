@@ -0,0 +1,14 @@
+-- Rollback Migration: Remove Data Source Mapping Profiles
+-- Version: 008
+-- Date: 2026-10-16
+-- Description: Clears data source mapping profiles
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- SQLite doesn't support dropping columns directly; clear mapping so the
+-- column is ignored by older versions
+UPDATE data_sources SET mapping = NULL;
+
+COMMIT;
@@ -0,0 +1,20 @@
+-- Migration: Detection Sigma Rules
+-- Version: 009
+-- Date: 2026-10-16
+-- Description: Adds the Sigma rules detections were imported from
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Sigma rules detections were imported from, kept so exports carry the rule
+-- fields the catalog does not model
+CREATE TABLE IF NOT EXISTS detection_sigma_rules (
+    detection_id INTEGER PRIMARY KEY,
+    rule_id TEXT UNIQUE, -- the rule's id, used to match re-imports
+    rule TEXT NOT NULL, -- the rule as imported, in YAML
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+COMMIT;
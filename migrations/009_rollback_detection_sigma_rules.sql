@@ -0,0 +1,12 @@
+-- Rollback Migration: Remove Detection Sigma Rules
+-- Version: 009
+-- Date: 2026-10-16
+-- Description: Drops the detection_sigma_rules table
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS detection_sigma_rules;
+
+COMMIT;
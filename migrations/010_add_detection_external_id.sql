@@ -0,0 +1,15 @@
+-- Migration: Detection External IDs
+-- Version: 010
+-- Date: 2026-10-16
+-- Description: Adds a stable external UUID to detections for syncing them from rule files
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Stable UUID used to sync detections from rule files
+ALTER TABLE detections ADD COLUMN external_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_detections_external_id ON detections(external_id);
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- Rollback Migration: Remove Detection External IDs
+-- Version: 010
+-- Date: 2026-10-16
+-- Description: Clears detection external IDs
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_detections_external_id;
+
+-- SQLite doesn't support dropping columns directly; clear external_id so the
+-- column is ignored by older versions
+UPDATE detections SET external_id = NULL;
+
+COMMIT;
@@ -0,0 +1,35 @@
+-- Migration: Detection Revisions
+-- Version: 011
+-- Date: 2026-10-16
+-- Description: Adds an immutable revision of each detection for every change
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Detection revisions, a snapshot of a detection after each change along with
+-- who made it and why
+CREATE TABLE IF NOT EXISTS detection_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL, -- 1 for the first revision of each detection
+    name TEXT NOT NULL,
+    description TEXT,
+    query TEXT,
+    status TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    risk_points INTEGER NOT NULL DEFAULT 0,
+    playbook_link TEXT,
+    owner TEXT,
+    risk_object TEXT,
+    testing_description TEXT,
+    class_id INTEGER,
+    external_id TEXT,
+    changed_by TEXT,
+    reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (detection_id, revision),
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- Rollback Migration: Remove Detection Revisions
+-- Version: 011
+-- Date: 2026-10-16
+-- Description: Drops the detection_revisions table
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS detection_revisions;
+
+COMMIT;
@@ -0,0 +1,42 @@
+-- Migration: Detection Lifecycle
+-- Version: 012
+-- Date: 2026-10-16
+-- Description: Adds detection status transitions and promotion approvals
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Detection status transitions, recorded for every status change
+CREATE TABLE IF NOT EXISTS detection_transitions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    from_status TEXT, -- NULL when the detection was created
+    to_status TEXT NOT NULL,
+    actor TEXT,
+    comment TEXT,
+    approval_id INTEGER REFERENCES detection_approvals(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Requests to move a detection to a status that needs a reviewer's approval
+CREATE TABLE IF NOT EXISTS detection_approvals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    requested_by TEXT NOT NULL,
+    comment TEXT,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reviewer TEXT,
+    review_comment TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_detection_transitions_detection_id ON detection_transitions(detection_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_detection_approvals_pending ON detection_approvals(detection_id) WHERE status = 'pending';
+
+COMMIT;
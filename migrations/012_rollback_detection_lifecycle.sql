@@ -0,0 +1,15 @@
+-- Rollback Migration: Remove Detection Lifecycle
+-- Version: 012
+-- Date: 2026-10-16
+-- Description: Drops the detection_transitions and detection_approvals tables
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_detection_approvals_pending;
+DROP INDEX IF EXISTS idx_detection_transitions_detection_id;
+DROP TABLE IF EXISTS detection_transitions;
+DROP TABLE IF EXISTS detection_approvals;
+
+COMMIT;
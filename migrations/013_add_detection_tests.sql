@@ -0,0 +1,58 @@
+-- Migration: Detection Tests
+-- Version: 013
+-- Date: 2026-10-16
+-- Description: Adds detection test cases and the history of their runs
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Sample log records with the result a detection should give for them
+CREATE TABLE IF NOT EXISTS detection_test_cases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    records TEXT NOT NULL, -- JSON array of log records
+    expect_match BOOLEAN NOT NULL DEFAULT 1,
+    expected_entity_type TEXT CHECK (expected_entity_type IN ('user', 'host', 'ip')),
+    expected_entity_value TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Runs of a detection's test cases against its query
+CREATE TABLE IF NOT EXISTS detection_test_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    query TEXT NOT NULL, -- the query as it was when tested
+    language TEXT,
+    status TEXT NOT NULL CHECK (status IN ('passed', 'failed', 'error')),
+    passed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    run_by TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Outcome of each test case in a run; kept when the test case is deleted
+CREATE TABLE IF NOT EXISTS detection_test_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL,
+    test_case_id INTEGER REFERENCES detection_test_cases(id) ON DELETE SET NULL,
+    test_case_name TEXT NOT NULL,
+    passed BOOLEAN NOT NULL,
+    matched BOOLEAN NOT NULL,
+    entity_type TEXT,
+    entity_value TEXT,
+    message TEXT,
+    FOREIGN KEY (run_id) REFERENCES detection_test_runs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_detection_test_cases_detection_id ON detection_test_cases(detection_id);
+CREATE INDEX IF NOT EXISTS idx_detection_test_runs_detection_id ON detection_test_runs(detection_id);
+CREATE INDEX IF NOT EXISTS idx_detection_test_results_run_id ON detection_test_results(run_id);
+
+COMMIT;
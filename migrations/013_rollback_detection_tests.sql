@@ -0,0 +1,17 @@
+-- Rollback Migration: Remove Detection Tests
+-- Version: 013
+-- Date: 2026-10-16
+-- Description: Drops the detection test case, run and result tables
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_detection_test_results_run_id;
+DROP INDEX IF EXISTS idx_detection_test_runs_detection_id;
+DROP INDEX IF EXISTS idx_detection_test_cases_detection_id;
+DROP TABLE IF EXISTS detection_test_results;
+DROP TABLE IF EXISTS detection_test_runs;
+DROP TABLE IF EXISTS detection_test_cases;
+
+COMMIT;
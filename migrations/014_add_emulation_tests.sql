@@ -0,0 +1,40 @@
+-- Migration: Emulation Tests
+-- Version: 014
+-- Date: 2026-10-16
+-- Description: Adds adversary emulation test executions and the detections they are expected to trigger
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Adversary emulation test executions, e.g. Atomic Red Team tests
+CREATE TABLE IF NOT EXISTS emulation_tests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    technique_id TEXT NOT NULL,
+    test_name TEXT NOT NULL,
+    target_host TEXT NOT NULL,
+    executed_at TIMESTAMP NOT NULL,
+    window_minutes INTEGER NOT NULL DEFAULT 60, -- how long after execution events count
+    executed_by TEXT,
+    notes TEXT,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'validated', 'partial', 'missed')),
+    checked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (technique_id) REFERENCES mitre_techniques(id) ON DELETE CASCADE
+);
+
+-- Detections an emulation test is expected to trigger, and whether they did
+CREATE TABLE IF NOT EXISTS emulation_test_detections (
+    emulation_test_id INTEGER NOT NULL,
+    detection_id INTEGER NOT NULL,
+    detected BOOLEAN NOT NULL DEFAULT 0,
+    event_id INTEGER REFERENCES events(id) ON DELETE SET NULL, -- first event for the target host in the window
+    detected_at TIMESTAMP,
+    PRIMARY KEY (emulation_test_id, detection_id),
+    FOREIGN KEY (emulation_test_id) REFERENCES emulation_tests(id) ON DELETE CASCADE,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_emulation_tests_technique_id ON emulation_tests(technique_id, executed_at);
+
+COMMIT;
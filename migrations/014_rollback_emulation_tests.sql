@@ -0,0 +1,14 @@
+-- Rollback Migration: Remove Emulation Tests
+-- Version: 014
+-- Date: 2026-10-16
+-- Description: Drops the emulation test tables
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_emulation_tests_technique_id;
+DROP TABLE IF EXISTS emulation_test_detections;
+DROP TABLE IF EXISTS emulation_tests;
+
+COMMIT;
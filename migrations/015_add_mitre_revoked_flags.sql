@@ -0,0 +1,16 @@
+-- Migration: MITRE Revoked and Deprecated Flags
+-- Version: 015
+-- Date: 2026-10-16
+-- Description: Flags MITRE techniques revoked or deprecated by MITRE, as loaded from STIX bundles
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Revoked by MITRE, usually in favour of another technique
+ALTER TABLE mitre_techniques ADD COLUMN revoked BOOLEAN NOT NULL DEFAULT 0;
+
+-- Deprecated by MITRE
+ALTER TABLE mitre_techniques ADD COLUMN deprecated BOOLEAN NOT NULL DEFAULT 0;
+
+COMMIT;
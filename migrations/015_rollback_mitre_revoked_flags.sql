@@ -0,0 +1,14 @@
+-- Rollback Migration: Remove MITRE Revoked and Deprecated Flags
+-- Version: 015
+-- Date: 2026-10-16
+-- Description: Clears the revoked and deprecated flags of MITRE techniques
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- SQLite doesn't support dropping columns directly; clear the flags so the
+-- columns are ignored by older versions
+UPDATE mitre_techniques SET revoked = 0, deprecated = 0;
+
+COMMIT;
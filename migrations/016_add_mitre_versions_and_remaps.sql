@@ -0,0 +1,29 @@
+-- Migration: MITRE Versions and Remaps
+-- Version: 016
+-- Date: 2026-10-16
+-- Description: Records the ATT&CK version and replacement of techniques, and the remaps applied to detection mappings
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Technique ID replacing a revoked technique
+ALTER TABLE mitre_techniques ADD COLUMN revoked_by TEXT;
+
+-- ATT&CK release the technique was last imported from, e.g. 17.1
+ALTER TABLE mitre_techniques ADD COLUMN attack_version TEXT;
+
+-- Detection mappings moved off revoked techniques onto their replacements
+CREATE TABLE IF NOT EXISTS mitre_remaps (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    old_technique_id TEXT NOT NULL,
+    new_technique_id TEXT NOT NULL,
+    applied_by TEXT,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mitre_remaps_detection_id ON mitre_remaps(detection_id);
+
+COMMIT;
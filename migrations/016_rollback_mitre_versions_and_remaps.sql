@@ -0,0 +1,17 @@
+-- Rollback Migration: Remove MITRE Versions and Remaps
+-- Version: 016
+-- Date: 2026-10-16
+-- Description: Drops the remap history and clears technique versions and replacements
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_mitre_remaps_detection_id;
+DROP TABLE IF EXISTS mitre_remaps;
+
+-- SQLite doesn't support dropping columns directly; clear the columns so they
+-- are ignored by older versions
+UPDATE mitre_techniques SET revoked_by = NULL, attack_version = NULL;
+
+COMMIT;
@@ -0,0 +1,29 @@
+-- Migration: Priority Technique Lists
+-- Version: 017
+-- Date: 2026-10-16
+-- Description: Adds lists of priority techniques imported from ATT&CK Navigator layers
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Techniques to prioritize coverage of, imported from threat-intel ATT&CK Navigator layers
+CREATE TABLE IF NOT EXISTS priority_lists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    source TEXT, -- name of the layer the list was imported from
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS priority_list_techniques (
+    list_id INTEGER NOT NULL,
+    technique_id TEXT NOT NULL,
+    score REAL NOT NULL DEFAULT 0, -- layer score, e.g. number of groups using the technique
+    comment TEXT,
+    PRIMARY KEY (list_id, technique_id),
+    FOREIGN KEY (list_id) REFERENCES priority_lists(id) ON DELETE CASCADE,
+    FOREIGN KEY (technique_id) REFERENCES mitre_techniques(id) ON DELETE CASCADE
+);
+
+COMMIT;
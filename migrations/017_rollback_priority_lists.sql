@@ -0,0 +1,13 @@
+-- Rollback Migration: Remove Priority Technique Lists
+-- Version: 017
+-- Date: 2026-10-16
+-- Description: Drops the priority technique lists
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS priority_list_techniques;
+DROP TABLE IF EXISTS priority_lists;
+
+COMMIT;
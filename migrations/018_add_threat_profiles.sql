@@ -0,0 +1,46 @@
+-- Migration: Threat Groups and Profiles
+-- Version: 018
+-- Date: 2026-10-16
+-- Description: Adds threat groups with the techniques they use, and threat profiles of selected groups
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Adversary groups and the techniques they use, from ATT&CK intrusion sets or a local CSV
+CREATE TABLE IF NOT EXISTS threat_groups (
+    id TEXT PRIMARY KEY, -- e.g. G0016
+    name TEXT NOT NULL,
+    aliases TEXT, -- JSON array of aliases
+    description TEXT,
+    attack_version TEXT -- ATT&CK release the group was last imported from
+);
+
+CREATE TABLE IF NOT EXISTS threat_group_techniques (
+    group_id TEXT NOT NULL,
+    technique_id TEXT NOT NULL,
+    PRIMARY KEY (group_id, technique_id),
+    FOREIGN KEY (group_id) REFERENCES threat_groups(id) ON DELETE CASCADE,
+    FOREIGN KEY (technique_id) REFERENCES mitre_techniques(id) ON DELETE CASCADE
+);
+
+-- Threat profiles: the groups coverage is measured against
+CREATE TABLE IF NOT EXISTS threat_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS threat_profile_groups (
+    profile_id INTEGER NOT NULL,
+    group_id TEXT NOT NULL,
+    PRIMARY KEY (profile_id, group_id),
+    FOREIGN KEY (profile_id) REFERENCES threat_profiles(id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES threat_groups(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_threat_group_techniques_technique_id ON threat_group_techniques(technique_id);
+
+COMMIT;
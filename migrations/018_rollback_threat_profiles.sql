@@ -0,0 +1,16 @@
+-- Rollback Migration: Remove Threat Groups and Profiles
+-- Version: 018
+-- Date: 2026-10-16
+-- Description: Drops the threat group and threat profile tables
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_threat_group_techniques_technique_id;
+DROP TABLE IF EXISTS threat_profile_groups;
+DROP TABLE IF EXISTS threat_profiles;
+DROP TABLE IF EXISTS threat_group_techniques;
+DROP TABLE IF EXISTS threat_groups;
+
+COMMIT;
@@ -0,0 +1,25 @@
+-- Migration: Risk Threshold Policies
+-- Version: 019
+-- Date: 2026-10-16
+-- Description: Adds threshold policies that override the global risk threshold for matching entities
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Risk threshold policies (override the global threshold for matching entities)
+CREATE TABLE IF NOT EXISTS risk_thresholds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    entity_type TEXT, -- user, host, ip; NULL matches any type
+    value_pattern TEXT, -- glob matched against entity_value
+    tag TEXT, -- inventory tag the entity must carry
+    detection_class_id INTEGER REFERENCES detection_classes(id) ON DELETE CASCADE,
+    threshold INTEGER NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMIT;
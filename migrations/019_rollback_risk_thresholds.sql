@@ -0,0 +1,12 @@
+-- Rollback Migration: Remove Risk Threshold Policies
+-- Version: 019
+-- Date: 2026-10-16
+-- Description: Drops the risk_thresholds table
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS risk_thresholds;
+
+COMMIT;
@@ -0,0 +1,26 @@
+-- Migration: Risk Score History
+-- Version: 020
+-- Date: 2026-10-16
+-- Description: Adds risk_score_history to record changes to entity risk scores
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Risk score changes per entity, used to chart score trends
+CREATE TABLE IF NOT EXISTS risk_score_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity_id INTEGER NOT NULL,
+    recorded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    old_score INTEGER NOT NULL,
+    new_score INTEGER NOT NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('event', 'decay', 'false_positive', 'false_positive_removed', 'manual')),
+    event_id INTEGER, -- event that caused the change, if any
+    notes TEXT,
+    FOREIGN KEY (entity_id) REFERENCES risk_objects(id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_risk_score_history_entity ON risk_score_history(entity_id, recorded_at);
+
+COMMIT;
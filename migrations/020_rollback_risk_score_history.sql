@@ -0,0 +1,13 @@
+-- Rollback Migration: Remove Risk Score History
+-- Version: 020
+-- Date: 2026-10-16
+-- Description: Drops the risk_score_history table
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_risk_score_history_entity;
+DROP TABLE IF EXISTS risk_score_history;
+
+COMMIT;
@@ -0,0 +1,25 @@
+-- Migration: Asset and Identity Inventory
+-- Version: 021
+-- Date: 2026-10-16
+-- Description: Adds the inventory used to enrich risk objects with owner, criticality and tags
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+-- Asset and identity inventory used to enrich risk objects
+CREATE TABLE IF NOT EXISTS inventory (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity_type TEXT NOT NULL, -- user, host, ip
+    entity_value TEXT NOT NULL, -- exact value, or a CIDR range for ip
+    owner TEXT,
+    department TEXT,
+    business_unit TEXT,
+    criticality TEXT CHECK (criticality IN ('low', 'medium', 'high', 'critical')),
+    tags TEXT, -- JSON array of strings
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(entity_type, entity_value)
+);
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- Rollback Migration: Remove Asset and Identity Inventory
+-- Version: 021
+-- Date: 2026-10-16
+-- Description: Drops the inventory table
+
+PRAGMA foreign_keys = ON;
+
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS inventory;
+
+COMMIT;
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"riskmatrix/internal/risk"
+	validation "riskmatrix/pkg"
+	"riskmatrix/pkg/models"
+)
+
+// AlertRuleHandler handles HTTP requests for alert rule endpoints
+type AlertRuleHandler struct {
+	repo *risk.Repository
+}
+
+// NewAlertRuleHandler creates a new alert rule handler
+func NewAlertRuleHandler(repo *risk.Repository) *AlertRuleHandler {
+	return &AlertRuleHandler{repo: repo}
+}
+
+// ListAlertRules handles GET /api/risk/rules
+func (h *AlertRuleHandler) ListAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.repo.ListAlertRules()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving alert rules")
+		return
+	}
+
+	List(w, rules, 1, len(rules), len(rules))
+}
+
+// GetAlertRule handles GET /api/risk/rules/{id}
+func (h *AlertRuleHandler) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	rule, err := h.repo.GetAlertRule(id)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Alert rule not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, rule)
+}
+
+// CreateAlertRule handles POST /api/risk/rules
+func (h *AlertRuleHandler) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	// Rules are enabled unless the request says otherwise
+	rule := models.AlertRule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateAlertRule(&rule); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.CreateAlertRule(&rule); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error creating alert rule")
+		return
+	}
+
+	JSON(w, http.StatusCreated, rule)
+}
+
+// UpdateAlertRule handles PUT /api/risk/rules/{id}
+func (h *AlertRuleHandler) UpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	var rule models.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Ensure ID in URL matches ID in body
+	rule.ID = id
+
+	if err := validation.ValidateAlertRule(&rule); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.UpdateAlertRule(&rule); err != nil {
+		Error(w, r, http.StatusNotFound, "Alert rule not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, rule)
+}
+
+// DeleteAlertRule handles DELETE /api/risk/rules/{id}
+func (h *AlertRuleHandler) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	if err := h.repo.DeleteAlertRule(id); err != nil {
+		Error(w, r, http.StatusNotFound, "Alert rule not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"riskmatrix/internal/risk"
+	"riskmatrix/pkg/models"
+)
+
+func TestAlertRuleHandler_CRUD(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	handler := NewAlertRuleHandler(risk.NewRepository(db))
+
+	// Invalid payloads are rejected
+	body, _ := json.Marshal(models.AlertRule{Name: "bad", Type: models.AlertRuleTacticSequence, WindowMinutes: 60})
+	w := httptest.NewRecorder()
+	handler.CreateAlertRule(w, httptest.NewRequest("POST", "/api/risk/rules", bytes.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for empty sequence, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// Create
+	body, _ = json.Marshal(map[string]interface{}{"name": "Many detections", "type": "distinct_detections", "min_count": 3, "window_minutes": 240})
+	w = httptest.NewRecorder()
+	handler.CreateAlertRule(w, httptest.NewRequest("POST", "/api/risk/rules", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var created models.AlertRule
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !created.Enabled {
+		t.Error("Expected new rule to be enabled by default")
+	}
+	idStr := strconv.FormatInt(created.ID, 10)
+
+	// Update
+	created.Type = models.AlertRuleTacticSequence
+	created.Sequence = []string{"Initial Access", "Execution"}
+	body, _ = json.Marshal(created)
+	req := httptest.NewRequest("PUT", "/api/risk/rules/"+idStr, bytes.NewReader(body))
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	handler.UpdateAlertRule(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// Get
+	req = httptest.NewRequest("GET", "/api/risk/rules/"+idStr, nil)
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	handler.GetAlertRule(w, req)
+	var fetched models.AlertRule
+	json.NewDecoder(w.Body).Decode(&fetched)
+	if fetched.Type != models.AlertRuleTacticSequence || len(fetched.Sequence) != 2 {
+		t.Errorf("Expected updated tactic sequence rule, got %+v", fetched)
+	}
+
+	// Delete
+	req = httptest.NewRequest("DELETE", "/api/risk/rules/"+idStr, nil)
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	handler.DeleteAlertRule(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/risk/rules/"+idStr, nil)
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	handler.GetAlertRule(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d after delete, got %d", http.StatusNotFound, w.Code)
+	}
+}
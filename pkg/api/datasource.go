@@ -2,10 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"riskmatrix/internal/datasource"
+	"riskmatrix/internal/detection"
 	validation "riskmatrix/pkg"
 	"riskmatrix/pkg/models"
 )
@@ -13,6 +15,10 @@ import (
 // DataSourceHandler handles HTTP requests for data source endpoints
 type DataSourceHandler struct {
 	repo *datasource.Repository
+
+	// Used to resolve and submit events normalized from raw records
+	detectionRepo *detection.Repository
+	risk          *RiskHandler
 }
 
 // NewDataSourceHandler creates a new data source handler
@@ -74,6 +80,21 @@ func (h *DataSourceHandler) ListDataSources(w http.ResponseWriter, r *http.Reque
 	List(w, dataSources, 1, len(dataSources), len(dataSources))
 }
 
+// validateDataSource validates a data source and its field-mapping profile
+func validateDataSource(dataSource *models.DataSource) error {
+	if err := validation.ValidateDataSource(dataSource); err != nil {
+		return err
+	}
+
+	if dataSource.Mapping != nil {
+		if err := datasource.ValidateMapping(dataSource.Mapping); err != nil {
+			return fmt.Errorf("invalid mapping: %w", err)
+		}
+	}
+
+	return nil
+}
+
 // CreateDataSource handles POST /api/datasources
 func (h *DataSourceHandler) CreateDataSource(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
@@ -84,7 +105,7 @@ func (h *DataSourceHandler) CreateDataSource(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Validate payload
-	if err := validation.ValidateDataSource(&dataSource); err != nil {
+	if err := validateDataSource(&dataSource); err != nil {
 		Error(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -120,7 +141,7 @@ func (h *DataSourceHandler) UpdateDataSource(w http.ResponseWriter, r *http.Requ
 	dataSource.ID = id
 
 	// Validate payload
-	if err := validation.ValidateDataSource(&dataSource); err != nil {
+	if err := validateDataSource(&dataSource); err != nil {
 		Error(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
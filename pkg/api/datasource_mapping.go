@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"riskmatrix/internal/datasource"
+	"riskmatrix/pkg/models"
+)
+
+// maxRawEventsBody is the largest body accepted by the raw events endpoint
+const maxRawEventsBody = 10 * 1024 * 1024
+
+// MappingTestRequest is a sample payload to normalize with a data source's
+// mapping profile, or with the profile given instead
+type MappingTestRequest struct {
+	Sample  json.RawMessage           `json:"sample"`
+	Mapping *models.DataSourceMapping `json:"mapping,omitempty"`
+}
+
+// MappingTestResult is what a mapping profile makes of a sample payload
+type MappingTestResult struct {
+	Record *datasource.Record `json:"record,omitempty"`
+	Event  *models.Event      `json:"event,omitempty"`
+	Errors []string           `json:"errors"`
+}
+
+// TestMapping handles POST /api/datasources/{id}/mapping/test
+func (h *DataSourceHandler) TestMapping(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid data source ID")
+		return
+	}
+
+	// Parse request body
+	var req MappingTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Sample) == 0 {
+		Error(w, r, http.StatusBadRequest, "sample is required")
+		return
+	}
+
+	dataSource, err := h.repo.GetDataSource(id)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Data source not found")
+		return
+	}
+
+	// A mapping in the request is tried in place of the saved one
+	mapping := dataSource.Mapping
+	if req.Mapping != nil {
+		mapping = req.Mapping
+	}
+	if mapping == nil {
+		Error(w, r, http.StatusBadRequest, "Data source has no mapping")
+		return
+	}
+	if err := datasource.ValidateMapping(mapping); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// A string sample is the raw record itself, anything else is a JSON record
+	raw := string(req.Sample)
+	var text string
+	if err := json.Unmarshal(req.Sample, &text); err == nil {
+		raw = text
+	}
+
+	result := MappingTestResult{Errors: make([]string, 0)}
+	record, event, err := h.normalize(mapping, raw)
+	result.Record = record
+	result.Event = event
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	JSON(w, http.StatusOK, result)
+}
+
+// IngestRawEvents handles POST /api/datasources/{id}/events. The body holds
+// one raw record per line, each normalized into an event with the data
+// source's mapping profile.
+func (h *DataSourceHandler) IngestRawEvents(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid data source ID")
+		return
+	}
+
+	dataSource, err := h.repo.GetDataSource(id)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Data source not found")
+		return
+	}
+	if dataSource.Mapping == nil {
+		Error(w, r, http.StatusBadRequest, "Data source has no mapping")
+		return
+	}
+	if h.risk == nil {
+		Error(w, r, http.StatusServiceUnavailable, "Event ingestion is not available")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRawEventsBody))
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	records := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRawEventsBody)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			records = append(records, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(records) == 0 {
+		Error(w, r, http.StatusBadRequest, "No records in request body")
+		return
+	}
+
+	// Reject records that cannot be normalized before queueing the rest
+	results := make([]EventResult, len(records))
+	valid := make([]*models.Event, 0, len(records))
+	indexes := make([]int, 0, len(records))
+	for i, raw := range records {
+		results[i] = EventResult{Index: i}
+		_, event, err := h.normalize(dataSource.Mapping, raw)
+		if err != nil {
+			results[i].Status = EventRejected
+			results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, event)
+		indexes = append(indexes, i)
+	}
+
+	h.risk.writeEventResults(w, results, valid, indexes)
+}
+
+// normalize extracts a raw record's fields and builds the event they
+// describe, returning whatever it could extract along with the first problem
+// that keeps the record from being an event
+func (h *DataSourceHandler) normalize(mapping *models.DataSourceMapping, raw string) (*datasource.Record, *models.Event, error) {
+	record, err := datasource.Normalize(mapping, raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	event := &models.Event{
+		RawData:    raw,
+		RiskObject: record.RiskObject(mapping.EntityPriority),
+	}
+	if record.Timestamp != nil {
+		event.Timestamp = *record.Timestamp
+	}
+	if context, err := json.Marshal(record); err == nil {
+		event.Context = string(context)
+	}
+
+	if event.RiskObject == nil {
+		return record, event, fmt.Errorf("no user, host or ip extracted")
+	}
+	if record.DetectionName == "" {
+		return record, event, fmt.Errorf("no detection name extracted")
+	}
+	if h.detectionRepo == nil {
+		return record, event, fmt.Errorf("detections are not available")
+	}
+
+	detection, err := h.detectionRepo.GetDetectionByName(record.DetectionName)
+	if err != nil {
+		return record, event, fmt.Errorf("unknown detection: %s", record.DetectionName)
+	}
+	event.DetectionID = detection.ID
+	event.RiskPoints = detection.RiskPoints
+
+	return record, event, nil
+}
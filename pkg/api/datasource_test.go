@@ -10,6 +10,8 @@ import (
 	"testing"
 
 	"riskmatrix/internal/datasource"
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/risk"
 	"riskmatrix/pkg/database"
 	"riskmatrix/pkg/models"
 )
@@ -241,6 +243,17 @@ func TestDataSourceHandler_CreateDataSource(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectError:    true,
 		},
+		{
+			name: "Invalid mapping regex",
+			dataSource: models.DataSource{
+				Name: "Mapped DataSource",
+				Mapping: &models.DataSourceMapping{
+					User: &models.FieldExtractor{Regex: "("},
+				},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectError:    true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -607,3 +620,112 @@ func TestDataSourceHandler_CreateDataSource_DuplicateName(t *testing.T) {
 		t.Error("Expected creation to fail due to duplicate name, but it succeeded")
 	}
 }
+
+// setupMappingTestHandler creates a datasource handler that can submit
+// normalized events, with a data source mapping CloudTrail-style records
+func setupMappingTestHandler(t *testing.T) (*DataSourceHandler, *database.DB, *models.DataSource) {
+	handler, db := setupDataSourceTestHandler(t)
+	handler.detectionRepo = detection.NewRepository(db)
+	handler.risk = NewRiskHandler(risk.NewEngine(db, risk.DefaultConfig()), risk.NewRepository(db))
+
+	dataSource := &models.DataSource{
+		Name: "cloudtrail",
+		Mapping: &models.DataSourceMapping{
+			User:          &models.FieldExtractor{Path: "$.userIdentity.userName"},
+			IP:            &models.FieldExtractor{Path: "$.sourceIPAddress"},
+			Timestamp:     &models.FieldExtractor{Path: "$.eventTime"},
+			DetectionName: &models.FieldExtractor{Path: "$.alert"},
+		},
+	}
+	if err := handler.repo.CreateDataSource(dataSource); err != nil {
+		t.Fatalf("Failed to create data source: %v", err)
+	}
+	return handler, db, dataSource
+}
+
+func TestDataSourceHandler_TestMapping(t *testing.T) {
+	handler, db, dataSource := setupMappingTestHandler(t)
+	defer db.Close()
+	testDetection := createTestDetection(t, db)
+
+	post := func(body string) (*httptest.ResponseRecorder, MappingTestResult) {
+		id := strconv.FormatInt(dataSource.ID, 10)
+		req := httptest.NewRequest("POST", "/api/datasources/"+id+"/mapping/test", bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.TestMapping(w, req)
+
+		var result MappingTestResult
+		_ = json.NewDecoder(w.Body).Decode(&result)
+		return w, result
+	}
+
+	w, result := post(`{"sample": {"alert": "test detection", "userIdentity": {"userName": "alice"}, "eventTime": "2026-10-16T09:30:00Z"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("Expected no errors, got %v", result.Errors)
+	}
+	if result.Event == nil || result.Event.DetectionID != testDetection.ID || result.Event.RiskPoints != testDetection.RiskPoints {
+		t.Fatalf("Expected event for detection %d, got %+v", testDetection.ID, result.Event)
+	}
+	if result.Event.RiskObject.EntityType != models.EntityTypeUser || result.Event.RiskObject.EntityValue != "alice" {
+		t.Errorf("Expected user alice, got %+v", result.Event.RiskObject)
+	}
+
+	// A mapping in the request overrides the saved one, and problems are
+	// reported rather than failing the request
+	w, result = post(`{"sample": "src=10.0.0.9", "mapping": {"ip": {"regex": "src=(\\S+)"}}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if result.Record == nil || result.Record.IP != "10.0.0.9" || len(result.Errors) != 1 {
+		t.Errorf("Expected ip with a missing detection name error, got %+v", result)
+	}
+
+	if w, _ := post(`{"sample": "x", "mapping": {"ip": {"regex": "("}}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid mapping, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestDataSourceHandler_IngestRawEvents(t *testing.T) {
+	handler, db, dataSource := setupMappingTestHandler(t)
+	defer db.Close()
+	createTestDetection(t, db)
+
+	body := `{"alert": "Test Detection", "userIdentity": {"userName": "alice"}, "eventTime": "2026-10-16T09:30:00Z"}
+
+{"alert": "Unknown", "sourceIPAddress": "10.0.0.5"}
+not json`
+
+	id := strconv.FormatInt(dataSource.ID, 10)
+	req := httptest.NewRequest("POST", "/api/datasources/"+id+"/events", bytes.NewBufferString(body))
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler.IngestRawEvents(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Processed int           `json:"processed"`
+		Rejected  int           `json:"rejected"`
+		Results   []EventResult `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Processed != 1 || response.Rejected != 2 || len(response.Results) != 3 {
+		t.Fatalf("Unexpected response %+v", response)
+	}
+	if response.Results[0].Status != EventAccepted || response.Results[0].EventID == 0 {
+		t.Errorf("Expected first record accepted, got %+v", response.Results[0])
+	}
+	for _, result := range response.Results[1:] {
+		if result.Status != EventRejected || result.Error == "" {
+			t.Errorf("Expected record %d rejected, got %+v", result.Index, result)
+		}
+	}
+}
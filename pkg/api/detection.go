@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -11,12 +12,17 @@ import (
 
 // DetectionHandler handles HTTP requests for detection endpoints
 type DetectionHandler struct {
-	repo *detection.Repository
+	repo      *detection.Repository
+	lifecycle *detection.Lifecycle
 }
 
-// NewDetectionHandler creates a new detection handler
+// NewDetectionHandler creates a new detection handler with the default
+// lifecycle
 func NewDetectionHandler(repo *detection.Repository) *DetectionHandler {
-	return &DetectionHandler{repo: repo}
+	return &DetectionHandler{
+		repo:      repo,
+		lifecycle: detection.NewLifecycle(repo, detection.DefaultLifecycleConfig()),
+	}
 }
 
 // GetDetection handles GET /api/detections/{id}
@@ -84,6 +90,12 @@ func (h *DetectionHandler) CreateDetection(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// A new detection cannot start at a status that needs approval
+	if err := h.lifecycle.CheckUpdate(nil, &detection); err != nil {
+		lifecycleError(w, r, err, "Error creating detection")
+		return
+	}
+
 	// Create detection in repository
 	if err := h.repo.CreateDetection(&detection); err != nil {
 		Error(w, r, http.StatusInternalServerError, "Error creating detection")
@@ -109,6 +121,8 @@ func (h *DetectionHandler) UpdateDetection(w http.ResponseWriter, r *http.Reques
 		models.Detection
 		DataSourceIDs     []int64 `json:"data_source_ids,omitempty"`
 		MitreTechniqueIDs []string `json:"mitre_technique_ids,omitempty"`
+		ChangedBy         string   `json:"changed_by,omitempty"`
+		Reason            string   `json:"reason,omitempty"`
 	}
 
 	// Parse request body
@@ -120,8 +134,36 @@ func (h *DetectionHandler) UpdateDetection(w http.ResponseWriter, r *http.Reques
 	// Ensure ID in URL matches
 	updateRequest.Detection.ID = id
 
-	// Update detection in repository
-	if err := h.repo.UpdateDetection(&updateRequest.Detection); err != nil {
+	current, err := h.repo.GetDetection(id)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Detection not found")
+		return
+	}
+
+	// The update must follow the lifecycle, with the links it leaves the
+	// detection with
+	updated := updateRequest.Detection
+	updated.MitreTechniques = current.MitreTechniques
+	if updateRequest.MitreTechniqueIDs != nil {
+		updated.MitreTechniques = make([]models.MitreTechnique, len(updateRequest.MitreTechniqueIDs))
+		for i, techID := range updateRequest.MitreTechniqueIDs {
+			updated.MitreTechniques[i] = models.MitreTechnique{ID: techID}
+		}
+	}
+	updated.DataSources = current.DataSources
+	if updateRequest.DataSourceIDs != nil {
+		updated.DataSources = make([]models.DataSource, len(updateRequest.DataSourceIDs))
+		for i, dsID := range updateRequest.DataSourceIDs {
+			updated.DataSources[i] = models.DataSource{ID: dsID}
+		}
+	}
+	if err := h.lifecycle.CheckUpdate(current, &updated); err != nil {
+		lifecycleError(w, r, err, "Error updating detection")
+		return
+	}
+
+	// Update detection in repository, recording who changed it and why
+	if err := h.repo.UpdateDetectionAs(&updateRequest.Detection, updateRequest.ChangedBy, updateRequest.Reason); err != nil {
 		Error(w, r, http.StatusInternalServerError, "Error updating detection")
 		return
 	}
@@ -372,6 +414,25 @@ func (h *DetectionHandler) RemoveMitreTechnique(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	current, err := h.repo.GetDetection(id)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Detection not found")
+		return
+	}
+
+	// The detection must still meet the requirements of its status without
+	// the technique
+	remaining := *current
+	remaining.MitreTechniques = make([]models.MitreTechnique, 0, len(current.MitreTechniques))
+	for _, tech := range current.MitreTechniques {
+		if tech.ID != techniqueID {
+			remaining.MitreTechniques = append(remaining.MitreTechniques, tech)
+		}
+	}
+	if !h.checkRequirements(w, r, &remaining, "Error removing MITRE technique") {
+		return
+	}
+
 	// Remove technique from detection
 	if err := h.repo.RemoveMitreTechnique(id, techniqueID); err != nil {
 		Error(w, r, http.StatusInternalServerError, "Error removing MITRE technique")
@@ -428,6 +489,25 @@ func (h *DetectionHandler) RemoveDataSource(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	current, err := h.repo.GetDetection(id)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Detection not found")
+		return
+	}
+
+	// The detection must still meet the requirements of its status without
+	// the data source
+	remaining := *current
+	remaining.DataSources = make([]models.DataSource, 0, len(current.DataSources))
+	for _, ds := range current.DataSources {
+		if ds.ID != dataSourceID {
+			remaining.DataSources = append(remaining.DataSources, ds)
+		}
+	}
+	if !h.checkRequirements(w, r, &remaining, "Error removing data source") {
+		return
+	}
+
 	// Remove data source from detection
 	if err := h.repo.RemoveDataSource(id, dataSourceID); err != nil {
 		Error(w, r, http.StatusInternalServerError, "Error removing data source")
@@ -437,3 +517,19 @@ func (h *DetectionHandler) RemoveDataSource(w http.ResponseWriter, r *http.Reque
 	// Return success message
 	JSON(w, http.StatusOK, map[string]string{"message": "Data source removed successfully"})
 }
+
+// checkRequirements checks that a detection left without a removed link still
+// meets the requirements of its status, writing a conflict if it does not. It
+// reports whether the removal may go ahead.
+func (h *DetectionHandler) checkRequirements(w http.ResponseWriter, r *http.Request, remaining *models.Detection, message string) bool {
+	err := h.lifecycle.CheckRequirements(remaining)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, detection.ErrRequirementsNotMet):
+		Error(w, r, http.StatusConflict, err.Error())
+	default:
+		Error(w, r, http.StatusInternalServerError, message)
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/pkg/models"
+)
+
+// GetLifecycle handles GET /api/detections/lifecycle, returning the allowed
+// transitions, the fields each status requires and the statuses that need
+// approval
+func (h *DetectionHandler) GetLifecycle(w http.ResponseWriter, r *http.Request) {
+	JSON(w, http.StatusOK, h.lifecycle.Config())
+}
+
+// ListTransitions handles GET /api/detections/{id}/transitions, oldest first
+func (h *DetectionHandler) ListTransitions(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid detection ID")
+		return
+	}
+
+	if _, err := h.repo.GetDetection(id); err != nil {
+		Error(w, r, http.StatusNotFound, "Detection not found")
+		return
+	}
+
+	transitions, err := h.repo.ListTransitions(id)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving transitions")
+		return
+	}
+
+	JSON(w, http.StatusOK, transitions)
+}
+
+// TransitionDetection handles POST /api/detections/{id}/transitions. A move
+// to a status that needs approval creates an approval request and returns
+// 202 Accepted; any other allowed move is made straight away.
+func (h *DetectionHandler) TransitionDetection(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid detection ID")
+		return
+	}
+
+	var transitionRequest struct {
+		ToStatus models.DetectionStatus `json:"to_status"`
+		Actor    string                 `json:"actor"`
+		Comment  string                 `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&transitionRequest); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if transitionRequest.ToStatus == "" {
+		Error(w, r, http.StatusBadRequest, "to_status is required")
+		return
+	}
+	if strings.TrimSpace(transitionRequest.Actor) == "" {
+		Error(w, r, http.StatusBadRequest, "actor is required")
+		return
+	}
+
+	if _, err := h.repo.GetDetection(id); err != nil {
+		Error(w, r, http.StatusNotFound, "Detection not found")
+		return
+	}
+
+	if h.lifecycle.NeedsApproval(transitionRequest.ToStatus) {
+		approval, err := h.lifecycle.RequestApproval(id, transitionRequest.ToStatus, transitionRequest.Actor, transitionRequest.Comment)
+		if err != nil {
+			lifecycleError(w, r, err, "Error requesting approval")
+			return
+		}
+		JSON(w, http.StatusAccepted, approval)
+		return
+	}
+
+	updated, err := h.lifecycle.Transition(id, transitionRequest.ToStatus, transitionRequest.Actor, transitionRequest.Comment)
+	if err != nil {
+		lifecycleError(w, r, err, "Error transitioning detection")
+		return
+	}
+
+	JSON(w, http.StatusOK, updated)
+}
+
+// ListApprovals handles GET /api/detection-approvals, newest first. The
+// detection_id and status query parameters filter the requests.
+func (h *DetectionHandler) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	var detectionID int64
+	if idStr := r.URL.Query().Get("detection_id"); idStr != "" {
+		var err error
+		if detectionID, err = strconv.ParseInt(idStr, 10, 64); err != nil {
+			Error(w, r, http.StatusBadRequest, "Invalid detection_id parameter")
+			return
+		}
+	}
+
+	status := models.ApprovalStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", models.ApprovalPending, models.ApprovalApproved, models.ApprovalRejected:
+	default:
+		Error(w, r, http.StatusBadRequest, "Invalid status parameter")
+		return
+	}
+
+	approvals, err := h.repo.ListApprovals(detectionID, status)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving approvals")
+		return
+	}
+
+	JSON(w, http.StatusOK, approvals)
+}
+
+// GetApproval handles GET /api/detection-approvals/{id}
+func (h *DetectionHandler) GetApproval(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid approval ID")
+		return
+	}
+
+	approval, err := h.repo.GetApproval(id)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Approval not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, approval)
+}
+
+// ApproveApproval handles POST /api/detection-approvals/{id}/approve
+func (h *DetectionHandler) ApproveApproval(w http.ResponseWriter, r *http.Request) {
+	h.reviewApproval(w, r, true)
+}
+
+// RejectApproval handles POST /api/detection-approvals/{id}/reject
+func (h *DetectionHandler) RejectApproval(w http.ResponseWriter, r *http.Request) {
+	h.reviewApproval(w, r, false)
+}
+
+// reviewApproval approves or rejects an approval request on behalf of the
+// reviewer in the request body
+func (h *DetectionHandler) reviewApproval(w http.ResponseWriter, r *http.Request, approve bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid approval ID")
+		return
+	}
+
+	var reviewRequest struct {
+		Reviewer string `json:"reviewer"`
+		Comment  string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reviewRequest); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(reviewRequest.Reviewer) == "" {
+		Error(w, r, http.StatusBadRequest, "reviewer is required")
+		return
+	}
+
+	if _, err := h.repo.GetApproval(id); err != nil {
+		Error(w, r, http.StatusNotFound, "Approval not found")
+		return
+	}
+
+	approval, err := h.lifecycle.Review(id, reviewRequest.Reviewer, approve, reviewRequest.Comment)
+	if err != nil {
+		lifecycleError(w, r, err, "Error reviewing approval")
+		return
+	}
+
+	JSON(w, http.StatusOK, approval)
+}
+
+// lifecycleError writes the response for an error from the detection
+// lifecycle, with message for errors that are not the client's
+func lifecycleError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, detection.ErrTransitionNotAllowed),
+		errors.Is(err, detection.ErrApprovalRequired),
+		errors.Is(err, detection.ErrApprovalNotPending):
+		Error(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, detection.ErrRequirementsNotMet):
+		Error(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, detection.ErrSelfApproval):
+		Error(w, r, http.StatusForbidden, err.Error())
+	default:
+		Error(w, r, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/pkg/models"
+)
+
+func TestDetectionHandler_Lifecycle(t *testing.T) {
+	handler, db := setupTestHandler(t)
+	defer db.Close()
+
+	det := createTestDetection(t, db)
+	id := strconv.FormatInt(det.ID, 10)
+	createTestMitreTechnique(t, db)
+	repo := detection.NewRepository(db)
+	det.Query = "index=win EventCode=4625"
+	if err := repo.UpdateDetection(det); err != nil {
+		t.Fatalf("Failed to update detection: %v", err)
+	}
+	if err := repo.AddMitreTechnique(det.ID, "T1059"); err != nil {
+		t.Fatalf("Failed to add technique: %v", err)
+	}
+
+	transition := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/detections/"+id+"/transitions", strings.NewReader(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.TransitionDetection(w, req)
+		return w
+	}
+
+	if w := transition(`{"to_status": "test"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d without an actor, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := transition(`{"to_status": "retired", "actor": "alice"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w := transition(`{"to_status": "test", "actor": "alice"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a disallowed transition, got %d", http.StatusConflict, w.Code)
+	}
+	for _, status := range []string{"draft", "test"} {
+		if w := transition(`{"to_status": "` + status + `", "actor": "alice"}`); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+
+	// Promotion to production creates an approval request
+	w := transition(`{"to_status": "production", "actor": "alice", "comment": "Tested in lab"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var approval models.DetectionApproval
+	if err := json.NewDecoder(w.Body).Decode(&approval); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if approval.Status != models.ApprovalPending || approval.FromStatus != models.StatusTest {
+		t.Errorf("Unexpected approval %+v", approval)
+	}
+	approvalID := strconv.FormatInt(approval.ID, 10)
+
+	req := httptest.NewRequest("GET", "/api/detection-approvals?status=pending", nil)
+	w = httptest.NewRecorder()
+	handler.ListApprovals(w, req)
+	var pending []models.DetectionApproval
+	if err := json.NewDecoder(w.Body).Decode(&pending); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != approval.ID {
+		t.Errorf("Expected the pending approval, got %+v", pending)
+	}
+
+	review := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/detection-approvals/"+approvalID+"/approve", strings.NewReader(body))
+		req.SetPathValue("id", approvalID)
+		w := httptest.NewRecorder()
+		handler.ApproveApproval(w, req)
+		return w
+	}
+	if w := review(`{"reviewer": "alice"}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for self approval, got %d", http.StatusForbidden, w.Code)
+	}
+	if w := review(`{"reviewer": "bob", "comment": "Approved"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w := review(`{"reviewer": "carol"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a reviewed approval, got %d", http.StatusConflict, w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/detections/"+id+"/transitions", nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.ListTransitions(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var transitions []models.DetectionTransition
+	if err := json.NewDecoder(w.Body).Decode(&transitions); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	last := transitions[len(transitions)-1]
+	if len(transitions) != 5 || last.ToStatus != models.StatusProduction || last.Actor != "alice" || last.ApprovedBy != "bob" {
+		t.Errorf("Unexpected transitions %+v", transitions)
+	}
+
+	// Updates are held to the requirements of production
+	body := `{"name": "Test Detection", "status": "production", "query": "index=win", "owner": "test-owner", "mitre_technique_ids": []}`
+	req = httptest.NewRequest("PUT", "/api/detections/"+id, strings.NewReader(body))
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.UpdateDetection(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "mitre_techniques") {
+		t.Errorf("Expected requirements error, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDetectionHandler_RemoveLinksKeepsRequirements(t *testing.T) {
+	handler, db := setupTestHandler(t)
+	defer db.Close()
+
+	config := detection.DefaultLifecycleConfig()
+	config.Requirements[models.StatusProduction] = append(config.Requirements[models.StatusProduction], "data_sources")
+	handler.lifecycle = detection.NewLifecycle(detection.NewRepository(db), config)
+
+	det := createTestDetection(t, db)
+	id := strconv.FormatInt(det.ID, 10)
+	createTestMitreTechnique(t, db)
+	if _, err := db.Exec("INSERT INTO mitre_techniques (id, name, description, tactic) VALUES (?, ?, ?, ?)",
+		"T1078", "Valid Accounts", "Test description", "Persistence"); err != nil {
+		t.Fatalf("Failed to create test MITRE technique: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO data_sources (id, name, description) VALUES (?, ?, ?)", 1, "Test DataSource", "Test description"); err != nil {
+		t.Fatalf("Failed to create test data source: %v", err)
+	}
+	repo := detection.NewRepository(db)
+	for _, techID := range []string{"T1059", "T1078"} {
+		if err := repo.AddMitreTechnique(det.ID, techID); err != nil {
+			t.Fatalf("Failed to add technique: %v", err)
+		}
+	}
+	if err := repo.AddDataSource(det.ID, 1); err != nil {
+		t.Fatalf("Failed to add data source: %v", err)
+	}
+	if _, err := db.Exec("UPDATE detections SET status = ?, query = ? WHERE id = ?", models.StatusProduction, "index=win", det.ID); err != nil {
+		t.Fatalf("Failed to promote detection: %v", err)
+	}
+
+	removeTechnique := func(techID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/api/detections/"+id+"/mitre/"+techID, nil)
+		req.SetPathValue("id", id)
+		req.SetPathValue("technique_id", techID)
+		w := httptest.NewRecorder()
+		handler.RemoveMitreTechnique(w, req)
+		return w
+	}
+
+	// A production detection keeps its last technique
+	if w := removeTechnique("T1059"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w := removeTechnique("T1078"); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "mitre_techniques") {
+		t.Errorf("Expected requirements conflict, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/detections/"+id+"/datasource/1", nil)
+	req.SetPathValue("id", id)
+	req.SetPathValue("datasource_id", "1")
+	w := httptest.NewRecorder()
+	handler.RemoveDataSource(w, req)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "data_sources") {
+		t.Errorf("Expected requirements conflict, got %d: %s", w.Code, w.Body.String())
+	}
+
+	current, err := repo.GetDetection(det.ID)
+	if err != nil {
+		t.Fatalf("Failed to get detection: %v", err)
+	}
+	if len(current.MitreTechniques) != 1 || len(current.DataSources) != 1 {
+		t.Errorf("Expected the last technique and data source to remain, got %d and %d",
+			len(current.MitreTechniques), len(current.DataSources))
+	}
+}
+
+func TestDetectionHandler_GetLifecycle(t *testing.T) {
+	handler, db := setupTestHandler(t)
+	defer db.Close()
+
+	req := httptest.NewRequest("GET", "/api/detections/lifecycle", nil)
+	w := httptest.NewRecorder()
+	handler.GetLifecycle(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var config detection.LifecycleConfig
+	if err := json.NewDecoder(w.Body).Decode(&config); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(config.ApprovalRequired) != 1 || config.ApprovalRequired[0] != models.StatusProduction {
+		t.Errorf("Expected production to need approval, got %+v", config)
+	}
+}
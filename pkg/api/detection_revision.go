@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"riskmatrix/internal/detection"
+)
+
+// ListRevisions handles GET /api/detections/{id}/revisions, newest first
+func (h *DetectionHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid detection ID")
+		return
+	}
+
+	if _, err := h.repo.GetDetection(id); err != nil {
+		Error(w, r, http.StatusNotFound, "Detection not found")
+		return
+	}
+
+	revisions, err := h.repo.ListRevisions(id)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving revisions")
+		return
+	}
+
+	JSON(w, http.StatusOK, revisions)
+}
+
+// GetRevision handles GET /api/detections/{id}/revisions/{revision}
+func (h *DetectionHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	id, revision, ok := parseRevisionPath(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.repo.GetRevision(id, revision)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Revision not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, result)
+}
+
+// DiffRevisions handles GET /api/detections/{id}/revisions/diff. The from and
+// to query parameters default to the latest revision and the one before it.
+func (h *DetectionHandler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid detection ID")
+		return
+	}
+
+	revisions, err := h.repo.ListRevisions(id)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving revisions")
+		return
+	}
+	if len(revisions) == 0 {
+		Error(w, r, http.StatusNotFound, "Detection has no revisions")
+		return
+	}
+
+	to := revisions[0].Revision
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		if to, err = strconv.Atoi(toStr); err != nil {
+			Error(w, r, http.StatusBadRequest, "Invalid to revision")
+			return
+		}
+	}
+	from := to - 1
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		if from, err = strconv.Atoi(fromStr); err != nil {
+			Error(w, r, http.StatusBadRequest, "Invalid from revision")
+			return
+		}
+	}
+
+	fromRevision, err := h.repo.GetRevision(id, from)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "From revision not found")
+		return
+	}
+	toRevision, err := h.repo.GetRevision(id, to)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "To revision not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]interface{}{
+		"detection_id": id,
+		"from":         fromRevision,
+		"to":           toRevision,
+		"changes":      detection.DiffRevisions(fromRevision, toRevision),
+	})
+}
+
+// RevertDetection handles POST /api/detections/{id}/revisions/{revision}/revert.
+// The optional body says who is reverting and why. Reverting cannot make a
+// status change the lifecycle does not allow.
+func (h *DetectionHandler) RevertDetection(w http.ResponseWriter, r *http.Request) {
+	id, revision, ok := parseRevisionPath(w, r)
+	if !ok {
+		return
+	}
+
+	var revertRequest struct {
+		ChangedBy string `json:"changed_by"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&revertRequest); err != nil && err != io.EOF {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if _, err := h.repo.GetRevision(id, revision); err != nil {
+		Error(w, r, http.StatusNotFound, "Revision not found")
+		return
+	}
+
+	reverted, err := h.lifecycle.Revert(id, revision, revertRequest.ChangedBy, revertRequest.Reason)
+	if err != nil {
+		lifecycleError(w, r, err, "Error reverting detection")
+		return
+	}
+
+	JSON(w, http.StatusOK, reverted)
+}
+
+// parseRevisionPath parses the detection ID and revision from the URL path,
+// writing an error response if either is invalid
+func parseRevisionPath(w http.ResponseWriter, r *http.Request) (int64, int, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid detection ID")
+		return 0, 0, false
+	}
+
+	revision, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid revision")
+		return 0, 0, false
+	}
+
+	return id, revision, true
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestDetectionHandler_Revisions(t *testing.T) {
+	handler, db := setupTestHandler(t)
+	defer db.Close()
+
+	det := createTestDetection(t, db)
+	id := strconv.FormatInt(det.ID, 10)
+
+	// An update through the API records who made it and why
+	body := `{"name": "Test Detection", "status": "draft", "severity": "high", "risk_points": 80, "changed_by": "alice", "reason": "Raise severity"}`
+	req := httptest.NewRequest("PUT", "/api/detections/"+id, strings.NewReader(body))
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler.UpdateDetection(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/detections/"+id+"/revisions", nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.ListRevisions(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var revisions []models.DetectionRevision
+	if err := json.NewDecoder(w.Body).Decode(&revisions); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].ChangedBy != "alice" || revisions[0].Reason != "Raise severity" {
+		t.Fatalf("Unexpected revisions %+v", revisions)
+	}
+
+	// The diff defaults to the latest revision against the one before it
+	req = httptest.NewRequest("GET", "/api/detections/"+id+"/revisions/diff", nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.DiffRevisions(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var diff struct {
+		Changes []models.DetectionChange `json:"changes"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&diff); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	fields := make([]string, 0, len(diff.Changes))
+	for _, change := range diff.Changes {
+		fields = append(fields, change.Field)
+	}
+	if strings.Join(fields, ",") != "description,severity,risk_points,playbook_link,owner,risk_object,testing_description" {
+		t.Errorf("Unexpected changed fields %v", fields)
+	}
+
+	req = httptest.NewRequest("POST", "/api/detections/"+id+"/revisions/1/revert", strings.NewReader(`{"changed_by": "bob"}`))
+	req.SetPathValue("id", id)
+	req.SetPathValue("revision", "1")
+	w = httptest.NewRecorder()
+	handler.RevertDetection(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var reverted models.Detection
+	if err := json.NewDecoder(w.Body).Decode(&reverted); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if reverted.Severity != models.SeverityMedium || reverted.RiskPoints != 50 || reverted.Owner != "test-owner" {
+		t.Errorf("Expected detection as of revision 1, got %+v", reverted)
+	}
+
+	tests := []struct {
+		name     string
+		revision string
+		query    string
+		handler  http.HandlerFunc
+		expected int
+	}{
+		{"get revision", "3", "", handler.GetRevision, http.StatusOK},
+		{"get missing revision", "9", "", handler.GetRevision, http.StatusNotFound},
+		{"get invalid revision", "x", "", handler.GetRevision, http.StatusBadRequest},
+		{"diff missing revision", "", "?from=1&to=9", handler.DiffRevisions, http.StatusNotFound},
+		{"diff invalid revision", "", "?from=x", handler.DiffRevisions, http.StatusBadRequest},
+		{"revert missing revision", "9", "", handler.RevertDetection, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/detections/"+id+"/revisions"+tt.query, nil)
+			req.SetPathValue("id", id)
+			req.SetPathValue("revision", tt.revision)
+			w := httptest.NewRecorder()
+			tt.handler(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"riskmatrix/internal/rulesync"
+)
+
+// maxSyncBody is the largest archive accepted by the detection sync endpoint
+const maxSyncBody = 50 * 1024 * 1024
+
+// DetectionSyncHandler handles HTTP requests for syncing detections from rule
+// files
+type DetectionSyncHandler struct {
+	repo *rulesync.Repository
+}
+
+// NewDetectionSyncHandler creates a new detection sync handler
+func NewDetectionSyncHandler(repo *rulesync.Repository) *DetectionSyncHandler {
+	return &DetectionSyncHandler{repo: repo}
+}
+
+// SyncDetections handles POST /api/detections/sync. The body is a tar
+// archive, optionally gzipped, of detection rule files. With dry_run=true the
+// plan is returned without changing anything.
+func (h *DetectionSyncHandler) SyncDetections(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if dryRunStr := r.URL.Query().Get("dry_run"); dryRunStr != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(dryRunStr); err != nil {
+			Error(w, r, http.StatusBadRequest, "Invalid dry_run value")
+			return
+		}
+	}
+
+	defs, err := rulesync.ReadArchive(http.MaxBytesReader(w, r.Body, maxSyncBody))
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var plan *rulesync.Plan
+	if dryRun {
+		plan, err = h.repo.Plan(defs)
+	} else {
+		plan, err = h.repo.Apply(defs)
+	}
+	if errors.Is(err, rulesync.ErrInvalidDefinitions) {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error syncing detections")
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]interface{}{
+		"dry_run": dryRun,
+		"plan":    plan,
+	})
+}
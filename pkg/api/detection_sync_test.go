@@ -0,0 +1,93 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/rulesync"
+)
+
+// syncArchive builds a tar archive of rule files
+func syncArchive(t *testing.T, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	archive := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := archive.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Failed to close archive: %v", err)
+	}
+	return &buf
+}
+
+func TestDetectionSyncHandler_SyncDetections(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	handler := NewDetectionSyncHandler(rulesync.NewRepository(db))
+	detectionRepo := detection.NewRepository(db)
+
+	files := map[string]string{
+		"failed_logons.yml": "external_id: 2c1d0e9f-8a7b-4c6d-9e5f-4a3b2c1d0e9f\nname: Failed Logons\nseverity: high\n",
+	}
+
+	var response struct {
+		DryRun bool          `json:"dry_run"`
+		Plan   rulesync.Plan `json:"plan"`
+	}
+
+	req := httptest.NewRequest("POST", "/api/detections/sync?dry_run=true", syncArchive(t, files))
+	w := httptest.NewRecorder()
+	handler.SyncDetections(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !response.DryRun || response.Plan.Created != 1 || response.Plan.Actions[0].Action != rulesync.ActionCreate {
+		t.Errorf("Unexpected dry run response %+v", response)
+	}
+	if count, _ := detectionRepo.GetDetectionCount(); count != 0 {
+		t.Errorf("Expected a dry run to create nothing, got %d detections", count)
+	}
+
+	req = httptest.NewRequest("POST", "/api/detections/sync", syncArchive(t, files))
+	w = httptest.NewRecorder()
+	handler.SyncDetections(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if count, _ := detectionRepo.GetDetectionCount(); count != 1 {
+		t.Errorf("Expected 1 detection, got %d", count)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		body  *bytes.Buffer
+	}{
+		{"invalid dry run", "?dry_run=maybe", syncArchive(t, files)},
+		{"not an archive", "", bytes.NewBufferString("external_id: x")},
+		{"invalid definition", "", syncArchive(t, map[string]string{"bad.yml": "external_id: not-a-uuid\nname: Bad\n"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/detections/sync"+tt.query, tt.body)
+			w := httptest.NewRecorder()
+			handler.SyncDetections(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+		})
+	}
+}
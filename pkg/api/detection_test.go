@@ -308,7 +308,7 @@ func TestDetectionHandler_UpdateDetection(t *testing.T) {
 			updatedData: models.Detection{
 				Name:        "Updated Detection",
 				Description: "Updated description",
-				Status:      models.StatusProduction,
+				Status:      models.StatusTest,
 				Severity:    models.SeverityHigh,
 				RiskPoints:  75,
 				Owner:       "updated-owner",
@@ -317,6 +317,27 @@ func TestDetectionHandler_UpdateDetection(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectError:    false,
 		},
+		{
+			name:        "Promotion needs approval",
+			detectionID: strconv.FormatInt(testDetection.ID, 10),
+			updatedData: models.Detection{
+				Name:     "Updated Detection",
+				Status:   models.StatusProduction,
+				Severity: models.SeverityHigh,
+			},
+			expectedStatus: http.StatusConflict,
+			expectError:    true,
+		},
+		{
+			name:        "Non-existent detection",
+			detectionID: "99999",
+			updatedData: models.Detection{
+				Name:   "Updated Detection",
+				Status: models.StatusDraft,
+			},
+			expectedStatus: http.StatusNotFound,
+			expectError:    true,
+		},
 		{
 			name:        "Invalid detection ID",
 			detectionID: "invalid",
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/detectiontest"
+	"riskmatrix/pkg/models"
+)
+
+// DetectionTestHandler handles HTTP requests for detection test case and test
+// run endpoints
+type DetectionTestHandler struct {
+	repo       *detectiontest.Repository
+	detections *detection.Repository
+}
+
+// NewDetectionTestHandler creates a new detection test handler
+func NewDetectionTestHandler(repo *detectiontest.Repository, detections *detection.Repository) *DetectionTestHandler {
+	return &DetectionTestHandler{repo: repo, detections: detections}
+}
+
+// parseDetectionID parses the detection ID from the URL path, writing an
+// error response if it is invalid or the detection does not exist
+func (h *DetectionTestHandler) parseDetectionID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid detection ID")
+		return 0, false
+	}
+
+	if _, err := h.detections.GetDetection(id); err != nil {
+		Error(w, r, http.StatusNotFound, "Detection not found")
+		return 0, false
+	}
+
+	return id, true
+}
+
+// ListTestCases handles GET /api/detections/{id}/tests
+func (h *DetectionTestHandler) ListTestCases(w http.ResponseWriter, r *http.Request) {
+	detectionID, ok := h.parseDetectionID(w, r)
+	if !ok {
+		return
+	}
+
+	testCases, err := h.repo.ListTestCases(detectionID)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving test cases")
+		return
+	}
+
+	JSON(w, http.StatusOK, testCases)
+}
+
+// GetTestCase handles GET /api/detections/{id}/tests/{test_id}
+func (h *DetectionTestHandler) GetTestCase(w http.ResponseWriter, r *http.Request) {
+	detectionID, ok := h.parseDetectionID(w, r)
+	if !ok {
+		return
+	}
+
+	testID, err := strconv.ParseInt(r.PathValue("test_id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid test case ID")
+		return
+	}
+
+	testCase, err := h.repo.GetTestCase(detectionID, testID)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Test case not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, testCase)
+}
+
+// CreateTestCase handles POST /api/detections/{id}/tests
+func (h *DetectionTestHandler) CreateTestCase(w http.ResponseWriter, r *http.Request) {
+	detectionID, ok := h.parseDetectionID(w, r)
+	if !ok {
+		return
+	}
+
+	var testCase models.DetectionTestCase
+	if err := json.NewDecoder(r.Body).Decode(&testCase); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	testCase.DetectionID = detectionID
+
+	if err := detectiontest.ValidateTestCase(&testCase); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.CreateTestCase(&testCase); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error creating test case")
+		return
+	}
+
+	JSON(w, http.StatusCreated, testCase)
+}
+
+// UpdateTestCase handles PUT /api/detections/{id}/tests/{test_id}
+func (h *DetectionTestHandler) UpdateTestCase(w http.ResponseWriter, r *http.Request) {
+	detectionID, ok := h.parseDetectionID(w, r)
+	if !ok {
+		return
+	}
+
+	testID, err := strconv.ParseInt(r.PathValue("test_id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid test case ID")
+		return
+	}
+
+	var testCase models.DetectionTestCase
+	if err := json.NewDecoder(r.Body).Decode(&testCase); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	testCase.ID = testID
+	testCase.DetectionID = detectionID
+
+	if err := detectiontest.ValidateTestCase(&testCase); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := h.repo.GetTestCase(detectionID, testID); err != nil {
+		Error(w, r, http.StatusNotFound, "Test case not found")
+		return
+	}
+
+	if err := h.repo.UpdateTestCase(&testCase); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error updating test case")
+		return
+	}
+
+	updated, err := h.repo.GetTestCase(detectionID, testID)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving updated test case")
+		return
+	}
+
+	JSON(w, http.StatusOK, updated)
+}
+
+// DeleteTestCase handles DELETE /api/detections/{id}/tests/{test_id}
+func (h *DetectionTestHandler) DeleteTestCase(w http.ResponseWriter, r *http.Request) {
+	detectionID, ok := h.parseDetectionID(w, r)
+	if !ok {
+		return
+	}
+
+	testID, err := strconv.ParseInt(r.PathValue("test_id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid test case ID")
+		return
+	}
+
+	if err := h.repo.DeleteTestCase(detectionID, testID); err != nil {
+		Error(w, r, http.StatusNotFound, "Test case not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunTests handles POST /api/detections/{id}/test-runs, running the
+// detection's test cases against its current query. The optional body says
+// who ran them. A query that cannot be evaluated is recorded as a run with
+// the error status.
+func (h *DetectionTestHandler) RunTests(w http.ResponseWriter, r *http.Request) {
+	detectionID, ok := h.parseDetectionID(w, r)
+	if !ok {
+		return
+	}
+
+	var runRequest struct {
+		RunBy string `json:"run_by"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&runRequest); err != nil && err != io.EOF {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	testCases, err := h.repo.ListTestCases(detectionID)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving test cases")
+		return
+	}
+	if len(testCases) == 0 {
+		Error(w, r, http.StatusBadRequest, "Detection has no test cases")
+		return
+	}
+
+	run, err := h.repo.Run(detectionID, runRequest.RunBy)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error running tests")
+		return
+	}
+
+	JSON(w, http.StatusCreated, run)
+}
+
+// ListTestRuns handles GET /api/detections/{id}/test-runs, newest first
+func (h *DetectionTestHandler) ListTestRuns(w http.ResponseWriter, r *http.Request) {
+	detectionID, ok := h.parseDetectionID(w, r)
+	if !ok {
+		return
+	}
+
+	runs, err := h.repo.ListRuns(detectionID)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving test runs")
+		return
+	}
+
+	JSON(w, http.StatusOK, runs)
+}
+
+// GetTestRun handles GET /api/detections/{id}/test-runs/{run_id}, with the
+// result of each test case
+func (h *DetectionTestHandler) GetTestRun(w http.ResponseWriter, r *http.Request) {
+	detectionID, ok := h.parseDetectionID(w, r)
+	if !ok {
+		return
+	}
+
+	runID, err := strconv.ParseInt(r.PathValue("run_id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid test run ID")
+		return
+	}
+
+	run, err := h.repo.GetRun(detectionID, runID)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Test run not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, run)
+}
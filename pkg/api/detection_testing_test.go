@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/detectiontest"
+	"riskmatrix/pkg/models"
+)
+
+func TestDetectionTestHandler(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	handler := NewDetectionTestHandler(detectiontest.NewRepository(db), detection.NewRepository(db))
+	det := createTestDetection(t, db)
+	det.Query = "EventCode=4625"
+	if err := detection.NewRepository(db).UpdateDetection(det); err != nil {
+		t.Fatalf("Failed to update detection: %v", err)
+	}
+	id := strconv.FormatInt(det.ID, 10)
+
+	request := func(method, path, body string, handle http.HandlerFunc, values map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetPathValue("id", id)
+		for key, value := range values {
+			req.SetPathValue(key, value)
+		}
+		w := httptest.NewRecorder()
+		handle(w, req)
+		return w
+	}
+
+	// Running without test cases is refused
+	if w := request("POST", "/api/detections/"+id+"/test-runs", "", handler.RunTests, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	body := `{"name": "Failed logon", "records": [{"EventCode": 4625, "host": "ws1"}], "expect_match": true,
+		"expected_entity": {"entity_type": "host", "entity_value": "ws2"}}`
+	w := request("POST", "/api/detections/"+id+"/tests", body, handler.CreateTestCase, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var testCase models.DetectionTestCase
+	if err := json.NewDecoder(w.Body).Decode(&testCase); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	testID := strconv.FormatInt(testCase.ID, 10)
+
+	if w := request("POST", "/api/detections/"+id+"/tests", `{"name": "Empty", "records": []}`, handler.CreateTestCase, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a test case without records, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = request("POST", "/api/detections/"+id+"/test-runs", `{"run_by": "alice"}`, handler.RunTests, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var run models.DetectionTestRun
+	if err := json.NewDecoder(w.Body).Decode(&run); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if run.Status != models.TestRunFailed || run.Failed != 1 {
+		t.Errorf("Expected the test to fail on the extracted host, got %+v", run)
+	}
+
+	// The test detection's risk object is a host, so the host is extracted
+	// rather than the user
+	body = `{"name": "Failed logon", "records": [{"EventCode": 4625, "user": "alice", "host": "ws1"}], "expect_match": true,
+		"expected_entity": {"entity_type": "host", "entity_value": "WS1"}}`
+	if w := request("PUT", "/api/detections/"+id+"/tests/"+testID, body, handler.UpdateTestCase, map[string]string{"test_id": testID}); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	w = request("POST", "/api/detections/"+id+"/test-runs", "", handler.RunTests, nil)
+	if err := json.NewDecoder(w.Body).Decode(&run); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if run.Status != models.TestRunPassed {
+		t.Errorf("Expected the run to pass, got %+v", run)
+	}
+
+	w = request("GET", "/api/detections/"+id+"/test-runs", "", handler.ListTestRuns, nil)
+	var runs []models.DetectionTestRun
+	if err := json.NewDecoder(w.Body).Decode(&runs); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != run.ID || runs[1].RunBy != "alice" {
+		t.Errorf("Unexpected test runs %+v", runs)
+	}
+
+	runID := strconv.FormatInt(run.ID, 10)
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		values   map[string]string
+		expected int
+	}{
+		{"get test case", handler.GetTestCase, map[string]string{"test_id": testID}, http.StatusOK},
+		{"get missing test case", handler.GetTestCase, map[string]string{"test_id": "999"}, http.StatusNotFound},
+		{"get test run", handler.GetTestRun, map[string]string{"run_id": runID}, http.StatusOK},
+		{"get invalid test run", handler.GetTestRun, map[string]string{"run_id": "x"}, http.StatusBadRequest},
+		{"delete test case", handler.DeleteTestCase, map[string]string{"test_id": testID}, http.StatusNoContent},
+		{"delete missing test case", handler.DeleteTestCase, map[string]string{"test_id": testID}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := request("GET", "/api/detections/"+id+"/tests", "", tt.handler, tt.values); w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"riskmatrix/internal/emulation"
+	validation "riskmatrix/pkg"
+	"riskmatrix/pkg/models"
+)
+
+// EmulationHandler handles HTTP requests for adversary emulation test endpoints
+type EmulationHandler struct {
+	repo *emulation.Repository
+}
+
+// NewEmulationHandler creates a new emulation test handler
+func NewEmulationHandler(repo *emulation.Repository) *EmulationHandler {
+	return &EmulationHandler{repo: repo}
+}
+
+// ListEmulationTests handles GET /api/emulation-tests?technique_id=
+func (h *EmulationHandler) ListEmulationTests(w http.ResponseWriter, r *http.Request) {
+	tests, err := h.repo.ListTests(r.URL.Query().Get("technique_id"))
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving emulation tests")
+		return
+	}
+
+	List(w, tests, 1, len(tests), len(tests))
+}
+
+// GetEmulationTest handles GET /api/emulation-tests/{id}
+func (h *EmulationHandler) GetEmulationTest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid emulation test ID")
+		return
+	}
+
+	test, err := h.repo.GetTest(id)
+	if err != nil {
+		emulationError(w, r, err, "Error retrieving emulation test")
+		return
+	}
+
+	JSON(w, http.StatusOK, test)
+}
+
+// CreateEmulationTest handles POST /api/emulation-tests, recording a test
+// execution and checking it against the events produced so far
+func (h *EmulationHandler) CreateEmulationTest(w http.ResponseWriter, r *http.Request) {
+	var test models.EmulationTest
+	if err := json.NewDecoder(r.Body).Decode(&test); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateEmulationTest(&test); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.CreateTest(&test); err != nil {
+		if errors.Is(err, emulation.ErrUnknownReference) {
+			Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		Error(w, r, http.StatusInternalServerError, "Error creating emulation test")
+		return
+	}
+
+	JSON(w, http.StatusCreated, test)
+}
+
+// CheckEmulationTest handles POST /api/emulation-tests/{id}/check, checking
+// a test again, for instance after late events were ingested
+func (h *EmulationHandler) CheckEmulationTest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid emulation test ID")
+		return
+	}
+
+	test, err := h.repo.CheckTest(id)
+	if err != nil {
+		emulationError(w, r, err, "Error checking emulation test")
+		return
+	}
+
+	JSON(w, http.StatusOK, test)
+}
+
+// DeleteEmulationTest handles DELETE /api/emulation-tests/{id}
+func (h *EmulationHandler) DeleteEmulationTest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid emulation test ID")
+		return
+	}
+
+	if err := h.repo.DeleteTest(id); err != nil {
+		emulationError(w, r, err, "Error deleting emulation test")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// emulationError writes the response for an emulation repository error
+func emulationError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if errors.Is(err, emulation.ErrNotFound) {
+		Error(w, r, http.StatusNotFound, "Emulation test not found")
+		return
+	}
+	Error(w, r, http.StatusInternalServerError, message)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/emulation"
+	"riskmatrix/pkg/models"
+)
+
+func TestEmulationHandler(t *testing.T) {
+	mitreHandler, db := setupMitreTestHandler(t)
+	defer db.Close()
+
+	repo := emulation.NewRepository(db)
+	handler := NewEmulationHandler(repo)
+	mitreHandler.emulation = repo
+
+	technique := createTestMitreTechnique(t, db)
+	det := createTestDetection(t, db)
+	det.Status = models.StatusProduction
+	if err := detection.NewRepository(db).UpdateDetection(det); err != nil {
+		t.Fatalf("Failed to update detection: %v", err)
+	}
+	if err := detection.NewRepository(db).AddMitreTechnique(det.ID, technique.ID); err != nil {
+		t.Fatalf("Failed to map technique: %v", err)
+	}
+
+	executedAt := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	if _, err := db.Exec(`INSERT INTO risk_objects (entity_type, entity_value) VALUES ('host', 'ws1')`); err != nil {
+		t.Fatalf("Failed to create risk object: %v", err)
+	}
+	if _, err := db.Exec(
+		`INSERT INTO events (detection_id, entity_id, timestamp, risk_points) VALUES (?, 1, ?, 50)`,
+		det.ID, executedAt.Add(10*time.Minute).Format(time.RFC3339),
+	); err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name: "Validated test",
+			body: fmt.Sprintf(`{"technique_id": "T1059", "test_name": "Atomic T1059-1", "target_host": "WS1",
+				"executed_at": %q, "expected_detections": [{"detection_id": %d}]}`, executedAt.Format(time.RFC3339), det.ID),
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "No expected detections",
+			body:           fmt.Sprintf(`{"technique_id": "T1059", "test_name": "Atomic T1059-1", "target_host": "ws1", "executed_at": %q}`, executedAt.Format(time.RFC3339)),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Unknown technique",
+			body: fmt.Sprintf(`{"technique_id": "T1003", "test_name": "Atomic T1003-1", "target_host": "ws1",
+				"executed_at": %q, "expected_detections": [{"detection_id": %d}]}`, executedAt.Format(time.RFC3339), det.ID),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	var created models.EmulationTest
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/emulation-tests", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.CreateEmulationTest(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusCreated {
+				if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+			}
+		})
+	}
+	if created.Status != models.EmulationValidated || len(created.ExpectedDetections) != 1 || !created.ExpectedDetections[0].Detected {
+		t.Fatalf("Expected a validated test, got %+v", created)
+	}
+
+	req := httptest.NewRequest("GET", "/api/emulation-tests/999", nil)
+	req.SetPathValue("id", "999")
+	w := httptest.NewRecorder()
+	handler.GetEmulationTest(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/emulation-tests/1/check", nil)
+	req.SetPathValue("id", strconv.FormatInt(created.ID, 10))
+	w = httptest.NewRecorder()
+	handler.CheckEmulationTest(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/mitre/coverage/validation", nil)
+	w = httptest.NewRecorder()
+	mitreHandler.GetValidatedCoverage(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response struct {
+		Techniques []models.TechniqueValidationCoverage `json:"techniques"`
+		Summary    map[models.TechniqueValidation]int   `json:"summary"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Techniques) != 1 || response.Techniques[0].Status != models.TechniqueValidated ||
+		response.Summary[models.TechniqueValidated] != 1 || response.Summary[models.TechniqueMappedOnly] != 0 {
+		t.Errorf("Unexpected validated coverage %+v", response)
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"riskmatrix/internal/inventory"
+	validation "riskmatrix/pkg"
+	"riskmatrix/pkg/models"
+)
+
+// InventoryHandler handles HTTP requests for asset and identity inventory endpoints
+type InventoryHandler struct {
+	repo *inventory.Repository
+}
+
+// NewInventoryHandler creates a new inventory handler
+func NewInventoryHandler(repo *inventory.Repository) *InventoryHandler {
+	return &InventoryHandler{repo: repo}
+}
+
+// ListInventoryEntries handles GET /api/inventory
+func (h *InventoryHandler) ListInventoryEntries(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.repo.ListInventoryEntries()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving inventory")
+		return
+	}
+
+	List(w, entries, 1, len(entries), len(entries))
+}
+
+// GetInventoryEntry handles GET /api/inventory/{id}
+func (h *InventoryHandler) GetInventoryEntry(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid inventory entry ID")
+		return
+	}
+
+	entry, err := h.repo.GetInventoryEntry(id)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Inventory entry not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, entry)
+}
+
+// MatchInventoryEntry handles GET /api/inventory/match?type=&value=
+func (h *InventoryHandler) MatchInventoryEntry(w http.ResponseWriter, r *http.Request) {
+	entityType := r.URL.Query().Get("type")
+	entityValue := r.URL.Query().Get("value")
+
+	if entityType == "" || entityValue == "" {
+		Error(w, r, http.StatusBadRequest, "Missing entity type or value")
+		return
+	}
+
+	entry, err := h.repo.MatchInventoryEntry(models.EntityType(entityType), entityValue)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error matching inventory")
+		return
+	}
+	if entry == nil {
+		Error(w, r, http.StatusNotFound, "No matching inventory entry")
+		return
+	}
+
+	JSON(w, http.StatusOK, entry)
+}
+
+// CreateInventoryEntry handles POST /api/inventory
+func (h *InventoryHandler) CreateInventoryEntry(w http.ResponseWriter, r *http.Request) {
+	var entry models.InventoryEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateInventoryEntry(&entry); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.CreateInventoryEntry(&entry); err != nil {
+		Error(w, r, http.StatusConflict, "Inventory entry already exists for this entity")
+		return
+	}
+
+	JSON(w, http.StatusCreated, entry)
+}
+
+// UpdateInventoryEntry handles PUT /api/inventory/{id}
+func (h *InventoryHandler) UpdateInventoryEntry(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid inventory entry ID")
+		return
+	}
+
+	var entry models.InventoryEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Ensure ID in URL matches ID in body
+	entry.ID = id
+
+	if err := validation.ValidateInventoryEntry(&entry); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.UpdateInventoryEntry(&entry); err != nil {
+		Error(w, r, http.StatusNotFound, "Inventory entry not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, entry)
+}
+
+// DeleteInventoryEntry handles DELETE /api/inventory/{id}
+func (h *InventoryHandler) DeleteInventoryEntry(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid inventory entry ID")
+		return
+	}
+
+	if err := h.repo.DeleteInventoryEntry(id); err != nil {
+		Error(w, r, http.StatusNotFound, "Inventory entry not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ImportInventory handles POST /api/inventory/import
+// Accepts a multipart upload in the "file" field or a raw body. The format is
+// taken from the format query parameter (csv or json), then the file
+// extension, then the Content-Type.
+func (h *InventoryHandler) ImportInventory(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	format := strings.ToLower(r.URL.Query().Get("format"))
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			Error(w, r, http.StatusBadRequest, "Missing file upload")
+			return
+		}
+		defer file.Close()
+
+		body = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	} else if format == "" {
+		if strings.Contains(r.Header.Get("Content-Type"), "csv") {
+			format = "csv"
+		} else {
+			format = "json"
+		}
+	}
+
+	var entries []*models.InventoryEntry
+	var err error
+	switch format {
+	case "csv":
+		entries, err = inventory.ParseCSV(body)
+	case "json":
+		entries, err = inventory.ParseJSON(body)
+	default:
+		Error(w, r, http.StatusBadRequest, "Unsupported import format, must be csv or json")
+		return
+	}
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for i, entry := range entries {
+		if err := validation.ValidateInventoryEntry(entry); err != nil {
+			Error(w, r, http.StatusBadRequest, fmt.Sprintf("entry %d: %s", i+1, err.Error()))
+			return
+		}
+	}
+
+	imported, err := h.repo.ImportInventoryEntries(entries)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error importing inventory")
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]int{"imported": imported})
+}
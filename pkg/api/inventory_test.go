@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"riskmatrix/internal/inventory"
+)
+
+func TestInventoryHandler_ImportInventory(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	handler := NewInventoryHandler(inventory.NewRepository(db))
+
+	// CSV upload as multipart form data
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	part, _ := form.CreateFormFile("file", "assets.csv")
+	part.Write([]byte("entity_type,entity_value,owner,criticality\nhost,dc-01,infra,critical\nip,10.0.0.0/24,netops,high\n"))
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/inventory/import", &buf)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	handler.ImportInventory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var result map[string]int
+	json.NewDecoder(w.Body).Decode(&result)
+	if result["imported"] != 2 {
+		t.Errorf("Expected 2 imported entries, got %d", result["imported"])
+	}
+
+	// Raw JSON body
+	req = httptest.NewRequest("POST", "/api/inventory/import", strings.NewReader(`[{"entity_type": "user", "entity_value": "ceo@example.com", "criticality": "critical", "tags": ["vip"]}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.ImportInventory(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Invalid entries reject the whole import
+	req = httptest.NewRequest("POST", "/api/inventory/import?format=json", strings.NewReader(`[{"entity_type": "ip", "entity_value": "not-an-ip"}]`))
+	w = httptest.NewRecorder()
+	handler.ImportInventory(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid entry, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// A null entry is rejected rather than validated
+	req = httptest.NewRequest("POST", "/api/inventory/import?format=json", strings.NewReader(`[null]`))
+	w = httptest.NewRecorder()
+	handler.ImportInventory(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for null entry, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// Match an address inside the imported range
+	req = httptest.NewRequest("GET", "/api/inventory/match?type=ip&value=10.0.0.42", nil)
+	w = httptest.NewRecorder()
+	handler.MatchInventoryEntry(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"owner":"netops"`) {
+		t.Errorf("Expected match on the netops range, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ListInventoryEntries(w, httptest.NewRequest("GET", "/api/inventory", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "ceo@example.com") {
+		t.Error("Expected imported JSON entry in inventory list")
+	}
+}
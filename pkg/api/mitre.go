@@ -2,8 +2,10 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
+	"riskmatrix/internal/emulation"
 	"riskmatrix/internal/mitre"
 	validation "riskmatrix/pkg"
 	"riskmatrix/pkg/models"
@@ -11,7 +13,8 @@ import (
 
 // MitreHandler handles HTTP requests for MITRE ATT&CK endpoints
 type MitreHandler struct {
-	repo *mitre.Repository
+	repo      *mitre.Repository
+	emulation *emulation.Repository
 }
 
 // NewMitreHandler creates a new MITRE handler
@@ -135,6 +138,10 @@ func (h *MitreHandler) DeleteMitreTechnique(w http.ResponseWriter, r *http.Reque
 
 	// Delete technique from repository
 	if err := h.repo.DeleteMitreTechnique(id); err != nil {
+		if errors.Is(err, mitre.ErrTechniqueInUse) {
+			Error(w, r, http.StatusConflict, "Technique is mapped to detections; remap them first")
+			return
+		}
 		Error(w, r, http.StatusInternalServerError, "Error deleting technique")
 		return
 	}
@@ -143,10 +150,16 @@ func (h *MitreHandler) DeleteMitreTechnique(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// GetCoverageByTactic handles GET /api/mitre/coverage
+// GetCoverageByTactic handles GET /api/mitre/coverage. The mode query
+// parameter (any, production or weighted) selects the detections counted.
 func (h *MitreHandler) GetCoverageByTactic(w http.ResponseWriter, r *http.Request) {
+	mode, ok := parseCoverageMode(w, r, models.CoverageAny)
+	if !ok {
+		return
+	}
+
 	// Get coverage by tactic from repository
-	coverage, err := h.repo.GetCoverageByTactic()
+	coverage, err := h.repo.CoverageByTactic(mode)
 	if err != nil {
 		Error(w, r, http.StatusInternalServerError, "Error retrieving coverage")
 		return
@@ -156,6 +169,38 @@ func (h *MitreHandler) GetCoverageByTactic(w http.ResponseWriter, r *http.Reques
 	JSON(w, http.StatusOK, coverage)
 }
 
+// GetCoverageTree handles GET /api/mitre/coverage/tree, giving coverage by
+// tactic, technique and sub-technique for the mode query parameter
+func (h *MitreHandler) GetCoverageTree(w http.ResponseWriter, r *http.Request) {
+	mode, ok := parseCoverageMode(w, r, models.CoverageAny)
+	if !ok {
+		return
+	}
+
+	tree, err := h.repo.CoverageTree(mode)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving coverage tree")
+		return
+	}
+
+	JSON(w, http.StatusOK, tree)
+}
+
+// parseCoverageMode reads the coverage mode from the query string, defaulting
+// to fallback, writing a 400 response when it is invalid
+func parseCoverageMode(w http.ResponseWriter, r *http.Request, fallback models.CoverageMode) (models.CoverageMode, bool) {
+	mode := models.CoverageMode(r.URL.Query().Get("mode"))
+	if mode == "" {
+		return fallback, true
+	}
+
+	if err := validation.ValidateCoverageMode(mode); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return mode, true
+}
+
 // GetDetectionsByTechnique handles GET /api/mitre/techniques/{id}/detections
 func (h *MitreHandler) GetDetectionsByTechnique(w http.ResponseWriter, r *http.Request) {
 	// Extract ID from URL path
@@ -188,3 +233,97 @@ func (h *MitreHandler) GetCoverageSummary(w http.ResponseWriter, r *http.Request
 	// Return summary as JSON
 	JSON(w, http.StatusOK, summary)
 }
+
+// GetValidatedCoverage handles GET /api/mitre/coverage/validation, giving
+// each technique's validated vs. mapped-only status from emulation tests
+func (h *MitreHandler) GetValidatedCoverage(w http.ResponseWriter, r *http.Request) {
+	if h.emulation == nil {
+		Error(w, r, http.StatusNotFound, "Emulation testing is not enabled")
+		return
+	}
+
+	techniques, err := h.emulation.Coverage()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving validated coverage")
+		return
+	}
+
+	summary := map[models.TechniqueValidation]int{
+		models.TechniqueValidated:  0,
+		models.TechniqueMappedOnly: 0,
+		models.TechniqueNotCovered: 0,
+	}
+	for _, technique := range techniques {
+		summary[technique.Status]++
+	}
+
+	JSON(w, http.StatusOK, map[string]interface{}{
+		"techniques": techniques,
+		"summary":    summary,
+	})
+}
+
+// ListRemaps handles GET /api/mitre/remaps, listing the detections mapped to
+// revoked or deprecated techniques with the technique replacing each
+func (h *MitreHandler) ListRemaps(w http.ResponseWriter, r *http.Request) {
+	remaps, err := h.repo.ProposeRemaps()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving remaps")
+		return
+	}
+
+	detections := make(map[int64]bool)
+	summary := map[string]int{
+		mitre.RemapRevoked:    0,
+		mitre.RemapDeprecated: 0,
+		"unresolved":          0,
+	}
+	for _, remap := range remaps {
+		detections[remap.DetectionID] = true
+		summary[remap.Reason]++
+		if remap.ReplacementID == "" {
+			summary["unresolved"]++
+		}
+	}
+	summary["detections"] = len(detections)
+
+	JSON(w, http.StatusOK, map[string]interface{}{
+		"remaps":  remaps,
+		"summary": summary,
+	})
+}
+
+// ApplyRemaps handles POST /api/mitre/remaps/apply, moving detections off
+// revoked techniques onto their replacements. The body may limit the remap
+// to some techniques; an empty body remaps every revoked technique.
+func (h *MitreHandler) ApplyRemaps(w http.ResponseWriter, r *http.Request) {
+	var applyRequest struct {
+		TechniqueIDs []string `json:"technique_ids"`
+		AppliedBy    string   `json:"applied_by"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&applyRequest); err != nil {
+			Error(w, r, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	applied, err := h.repo.ApplyRemaps(applyRequest.TechniqueIDs, applyRequest.AppliedBy)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error applying remaps")
+		return
+	}
+
+	List(w, applied, 1, len(applied), len(applied))
+}
+
+// ListAppliedRemaps handles GET /api/mitre/remaps/history
+func (h *MitreHandler) ListAppliedRemaps(w http.ResponseWriter, r *http.Request) {
+	remaps, err := h.repo.ListAppliedRemaps()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving remap history")
+		return
+	}
+
+	List(w, remaps, 1, len(remaps), len(remaps))
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"riskmatrix/internal/mitre"
+	validation "riskmatrix/pkg"
+	"riskmatrix/pkg/models"
+)
+
+// GetNavigatorLayer handles GET /api/mitre/navigator-layer, exporting
+// coverage as an ATT&CK Navigator layer. The status (comma-separated),
+// class_id, data_source and domain query parameters filter the detections
+// scored.
+func (h *MitreHandler) GetNavigatorLayer(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.LayerFilter{
+		DataSource: query.Get("data_source"),
+		Domain:     query.Get("domain"),
+	}
+	if statuses := query.Get("status"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			filter.Statuses = append(filter.Statuses, models.DetectionStatus(strings.TrimSpace(status)))
+		}
+	}
+	if classIDStr := query.Get("class_id"); classIDStr != "" {
+		classID, err := strconv.ParseInt(classIDStr, 10, 64)
+		if err != nil {
+			Error(w, r, http.StatusBadRequest, "Invalid class_id parameter")
+			return
+		}
+		filter.ClassID = &classID
+	}
+
+	if err := validation.ValidateLayerFilter(&filter); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	layer, err := h.repo.NavigatorLayer(filter)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error building Navigator layer")
+		return
+	}
+
+	JSON(w, http.StatusOK, layer)
+}
+
+// ListPriorityLists handles GET /api/mitre/priority-lists
+func (h *MitreHandler) ListPriorityLists(w http.ResponseWriter, r *http.Request) {
+	lists, err := h.repo.ListPriorityLists()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving priority lists")
+		return
+	}
+
+	List(w, lists, 1, len(lists), len(lists))
+}
+
+// ImportPriorityList handles POST /api/mitre/priority-lists, turning a
+// threat-intel Navigator layer into a priority list. Importing a list under
+// an existing name replaces its techniques.
+func (h *MitreHandler) ImportPriorityList(w http.ResponseWriter, r *http.Request) {
+	var importRequest struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		MinScore    float64                `json:"min_score"`
+		Layer       *models.NavigatorLayer `json:"layer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&importRequest); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if importRequest.Layer == nil {
+		Error(w, r, http.StatusBadRequest, "layer is required")
+		return
+	}
+	if err := validation.ValidateNavigatorLayer(importRequest.Layer); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The list is named after the layer unless a name is given
+	list := &models.PriorityList{
+		Name:        strings.TrimSpace(importRequest.Name),
+		Description: importRequest.Description,
+	}
+	if list.Name == "" {
+		list.Name = strings.TrimSpace(importRequest.Layer.Name)
+	}
+	if list.Name == "" {
+		Error(w, r, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	result, err := h.repo.ImportPriorityLayer(list, importRequest.Layer, importRequest.MinScore)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error importing priority list")
+		return
+	}
+
+	JSON(w, http.StatusCreated, result)
+}
+
+// GetPriorityList handles GET /api/mitre/priority-lists/{id}
+func (h *MitreHandler) GetPriorityList(w http.ResponseWriter, r *http.Request) {
+	id, ok := parsePriorityListID(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.repo.GetPriorityList(id)
+	if err != nil {
+		priorityListError(w, r, err, "Error retrieving priority list")
+		return
+	}
+
+	JSON(w, http.StatusOK, list)
+}
+
+// DeletePriorityList handles DELETE /api/mitre/priority-lists/{id}
+func (h *MitreHandler) DeletePriorityList(w http.ResponseWriter, r *http.Request) {
+	id, ok := parsePriorityListID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeletePriorityList(id); err != nil {
+		priorityListError(w, r, err, "Error deleting priority list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPriorityListCoverage handles GET /api/mitre/priority-lists/{id}/coverage,
+// comparing the list against production detections
+func (h *MitreHandler) GetPriorityListCoverage(w http.ResponseWriter, r *http.Request) {
+	id, ok := parsePriorityListID(w, r)
+	if !ok {
+		return
+	}
+
+	techniques, err := h.repo.PriorityListCoverage(id)
+	if err != nil {
+		priorityListError(w, r, err, "Error retrieving priority coverage")
+		return
+	}
+
+	covered := 0
+	for _, technique := range techniques {
+		if technique.Covered {
+			covered++
+		}
+	}
+	percent := 0.0
+	if len(techniques) > 0 {
+		percent = float64(covered) / float64(len(techniques)) * 100
+	}
+
+	JSON(w, http.StatusOK, map[string]interface{}{
+		"techniques": techniques,
+		"summary": map[string]interface{}{
+			"techniques":       len(techniques),
+			"covered":          covered,
+			"gaps":             len(techniques) - covered,
+			"coverage_percent": percent,
+		},
+	})
+}
+
+// parsePriorityListID reads the priority list ID from the URL path, writing
+// a 400 response when it is invalid
+func parsePriorityListID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid priority list ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// priorityListError maps priority list errors to HTTP responses
+func priorityListError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if errors.Is(err, mitre.ErrPriorityListNotFound) {
+		Error(w, r, http.StatusNotFound, "Priority list not found")
+		return
+	}
+	Error(w, r, http.StatusInternalServerError, message)
+}
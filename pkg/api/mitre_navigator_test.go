@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"riskmatrix/internal/mitre"
+	"riskmatrix/pkg/models"
+)
+
+func TestMitreHandler_NavigatorLayer(t *testing.T) {
+	handler, db := setupMitreTestHandler(t)
+	defer db.Close()
+
+	createTestMitreTechnique(t, db)
+	if _, err := db.Exec("INSERT INTO detections (id, name, status, severity, risk_points) VALUES (1, 'Encoded PowerShell', 'production', 'high', 40)"); err != nil {
+		t.Fatalf("Failed to create detection: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO detection_mitre_map (detection_id, mitre_id) VALUES (1, 'T1059')"); err != nil {
+		t.Fatalf("Failed to map detection: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		techniques     int
+	}{
+		{name: "All detections", query: "", expectedStatus: http.StatusOK, techniques: 1},
+		{name: "Status filter", query: "?status=test,draft", expectedStatus: http.StatusOK, techniques: 0},
+		{name: "Invalid status", query: "?status=live", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid class", query: "?class_id=abc", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid domain", query: "?domain=pre-attack", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetNavigatorLayer(w, httptest.NewRequest("GET", "/api/mitre/navigator-layer"+tt.query, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var layer models.NavigatorLayer
+			if err := json.NewDecoder(w.Body).Decode(&layer); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(layer.Techniques) != tt.techniques || layer.Domain != mitre.DefaultLayerDomain {
+				t.Errorf("Unexpected layer %+v", layer)
+			}
+		})
+	}
+}
+
+func TestMitreHandler_PriorityLists(t *testing.T) {
+	handler, db := setupMitreTestHandler(t)
+	defer db.Close()
+
+	createTestMitreTechnique(t, db)
+
+	// A layer from Navigator, scoring T1059 and an unknown technique
+	body := []byte(`{"layer": {"name": "FIN7", "domain": "enterprise-attack", "techniques": [
+		{"techniqueID": "T1059", "tactic": "execution", "score": 2, "comment": "FIN7"},
+		{"techniqueID": "T1204", "tactic": "execution", "score": 1}
+	]}}`)
+	w := httptest.NewRecorder()
+	handler.ImportPriorityList(w, httptest.NewRequest("POST", "/api/mitre/priority-lists", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var result mitre.PriorityImport
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.List.Name != "FIN7" || result.List.TechniqueCount != 1 || len(result.Unknown) != 1 {
+		t.Errorf("Unexpected import %+v", result)
+	}
+
+	// A layer with a malformed technique is refused
+	w = httptest.NewRecorder()
+	handler.ImportPriorityList(w, httptest.NewRequest("POST", "/api/mitre/priority-lists",
+		bytes.NewReader([]byte(`{"name": "x", "layer": {"techniques": [{"techniqueID": "1059"}]}}`))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	id := strconv.FormatInt(result.List.ID, 10)
+	req := httptest.NewRequest("GET", "/api/mitre/priority-lists/"+id+"/coverage", nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.GetPriorityListCoverage(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var coverage struct {
+		Techniques []*models.PriorityCoverage `json:"techniques"`
+		Summary    map[string]float64         `json:"summary"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&coverage); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(coverage.Techniques) != 1 || coverage.Techniques[0].Covered || coverage.Summary["gaps"] != 1 {
+		t.Errorf("Unexpected coverage %+v", coverage)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/mitre/priority-lists/"+id, nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.DeletePriorityList(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/mitre/priority-lists/"+id, nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.GetPriorityList(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
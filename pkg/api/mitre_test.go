@@ -428,6 +428,79 @@ func TestMitreHandler_GetCoverageByTactic(t *testing.T) {
 	}
 }
 
+func TestMitreHandler_GetCoverageTree(t *testing.T) {
+	handler, db := setupMitreTestHandler(t)
+	defer db.Close()
+
+	createTestMitreTechnique(t, db)
+	repo := mitre.NewRepository(db)
+	if err := repo.CreateMitreTechnique(&models.MitreTechnique{
+		ID: "T1059.001", Name: "PowerShell", Tactic: "Execution", IsSubTechnique: true, SubTechniqueOf: "T1059",
+	}); err != nil {
+		t.Fatalf("Failed to create sub-technique: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO detections (id, name, status, severity, risk_points) VALUES (1, 'Encoded PowerShell', 'draft', 'high', 40)"); err != nil {
+		t.Fatalf("Failed to create detection: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO detection_mitre_map (detection_id, mitre_id) VALUES (1, 'T1059.001')"); err != nil {
+		t.Fatalf("Failed to map detection: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		coverage       float64
+	}{
+		{name: "Default mode", query: "", expectedStatus: http.StatusOK, coverage: 1},
+		{name: "Production only", query: "?mode=production", expectedStatus: http.StatusOK, coverage: 0},
+		{name: "Weighted", query: "?mode=weighted", expectedStatus: http.StatusOK, coverage: 0.25},
+		{name: "Invalid mode", query: "?mode=best", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetCoverageTree(w, httptest.NewRequest("GET", "/api/mitre/coverage/tree"+tt.query, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var tree models.CoverageTree
+			if err := json.NewDecoder(w.Body).Decode(&tree); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(tree.Tactics) != 1 || tree.Tactics[0].TechniqueCount != 1 {
+				t.Fatalf("Unexpected tree %+v", tree)
+			}
+			parent := tree.Tactics[0].Techniques[0]
+			if parent.TechniqueID != "T1059" || len(parent.SubTechniques) != 1 || parent.Coverage != tt.coverage {
+				t.Errorf("Unexpected parent %+v", parent)
+			}
+		})
+	}
+
+	// The tactic map takes the same modes
+	w := httptest.NewRecorder()
+	handler.GetCoverageByTactic(w, httptest.NewRequest("GET", "/api/mitre/coverage?mode=production", nil))
+	var coverage map[string]float64
+	if err := json.NewDecoder(w.Body).Decode(&coverage); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(coverage) != 1 || coverage["Execution"] != 0 {
+		t.Errorf("Unexpected coverage %v", coverage)
+	}
+
+	w = httptest.NewRecorder()
+	handler.GetCoverageByTactic(w, httptest.NewRequest("GET", "/api/mitre/coverage?mode=best", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
 func TestMitreHandler_GetDetectionsByTechnique(t *testing.T) {
 	handler, db := setupMitreTestHandler(t)
 	defer db.Close()
@@ -504,3 +577,65 @@ func TestMitreHandler_UpdateMitreTechnique_InvalidJSON(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
 	}
 }
+
+func TestMitreHandler_Remaps(t *testing.T) {
+	handler, db := setupMitreTestHandler(t)
+	defer db.Close()
+
+	// A production detection is mapped to T1086, revoked in favour of T1059
+	createTestMitreTechnique(t, db)
+	revoked := &models.MitreTechnique{ID: "T1086", Name: "PowerShell", Tactic: "Execution", Revoked: true, RevokedBy: "T1059"}
+	if err := mitre.NewRepository(db).CreateMitreTechnique(revoked); err != nil {
+		t.Fatalf("Failed to create technique: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO detections (id, name, status, severity, risk_points) VALUES (1, 'Encoded PowerShell', 'production', 'high', 40)"); err != nil {
+		t.Fatalf("Failed to create detection: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO detection_mitre_map (detection_id, mitre_id) VALUES (1, 'T1086')"); err != nil {
+		t.Fatalf("Failed to map detection: %v", err)
+	}
+
+	// The revoked technique cannot be deleted while mapped
+	req := httptest.NewRequest("DELETE", "/api/mitre/techniques/T1086", nil)
+	req.SetPathValue("id", "T1086")
+	w := httptest.NewRecorder()
+	handler.DeleteMitreTechnique(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ListRemaps(w, httptest.NewRequest("GET", "/api/mitre/remaps", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var report struct {
+		Remaps  []*models.TechniqueRemap `json:"remaps"`
+		Summary map[string]int           `json:"summary"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(report.Remaps) != 1 || report.Remaps[0].ReplacementID != "T1059" || report.Summary["detections"] != 1 || report.Summary["revoked"] != 1 {
+		t.Errorf("Unexpected remap report %+v", report)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"technique_ids": []string{"T1086"}, "applied_by": "alice"})
+	w = httptest.NewRecorder()
+	handler.ApplyRemaps(w, httptest.NewRequest("POST", "/api/mitre/remaps/apply", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ListAppliedRemaps(w, httptest.NewRequest("GET", "/api/mitre/remaps/history", nil))
+	var history struct {
+		Items []*models.AppliedRemap `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(history.Items) != 1 || history.Items[0].NewTechniqueID != "T1059" || history.Items[0].AppliedBy != "alice" {
+		t.Errorf("Unexpected remap history %+v", history.Items)
+	}
+}
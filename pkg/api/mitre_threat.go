@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"riskmatrix/internal/mitre"
+	validation "riskmatrix/pkg"
+	"riskmatrix/pkg/models"
+)
+
+// maxGroupImportBody is the largest file accepted by the threat group
+// import, enough for the enterprise ATT&CK bundle
+const maxGroupImportBody = 100 * 1024 * 1024
+
+// ListThreatGroups handles GET /api/mitre/groups
+func (h *MitreHandler) ListThreatGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.repo.ListThreatGroups()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving threat groups")
+		return
+	}
+
+	List(w, groups, 1, len(groups), len(groups))
+}
+
+// GetThreatGroup handles GET /api/mitre/groups/{id}
+func (h *MitreHandler) GetThreatGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := h.repo.GetThreatGroup(r.PathValue("id"))
+	if err != nil {
+		threatError(w, r, err, "Error retrieving threat group")
+		return
+	}
+
+	JSON(w, http.StatusOK, group)
+}
+
+// ImportThreatGroups handles POST /api/mitre/groups/import. The body is a
+// CSV of group technique uses or an ATT&CK STIX bundle, whose intrusion sets
+// are imported; the format is taken from the format query parameter (csv or
+// stix), then the Content-Type. Techniques are not imported from bundles.
+func (h *MitreHandler) ImportThreatGroups(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		if strings.Contains(r.Header.Get("Content-Type"), "csv") {
+			format = "csv"
+		} else {
+			format = "stix"
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxGroupImportBody)
+	var groups []*models.ThreatGroup
+	version := ""
+	switch format {
+	case "csv":
+		var err error
+		if groups, err = mitre.ParseGroupsCSV(body); err != nil {
+			Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	case "stix":
+		bundle, err := mitre.ParseBundle(body)
+		if err != nil {
+			Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		groups, version = bundle.Groups, bundle.Version
+	default:
+		Error(w, r, http.StatusBadRequest, "Unsupported import format, must be csv or stix")
+		return
+	}
+
+	report, err := h.repo.ImportGroups(groups, version)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error importing threat groups")
+		return
+	}
+
+	JSON(w, http.StatusOK, report)
+}
+
+// ListThreatProfiles handles GET /api/mitre/threat-profiles
+func (h *MitreHandler) ListThreatProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.repo.ListThreatProfiles()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving threat profiles")
+		return
+	}
+
+	List(w, profiles, 1, len(profiles), len(profiles))
+}
+
+// CreateThreatProfile handles POST /api/mitre/threat-profiles
+func (h *MitreHandler) CreateThreatProfile(w http.ResponseWriter, r *http.Request) {
+	var profile models.ThreatProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	profile.ID = 0
+
+	if err := validation.ValidateThreatProfile(&profile); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.CreateThreatProfile(&profile); err != nil {
+		threatError(w, r, err, "Error creating threat profile")
+		return
+	}
+
+	JSON(w, http.StatusCreated, profile)
+}
+
+// GetThreatProfile handles GET /api/mitre/threat-profiles/{id}
+func (h *MitreHandler) GetThreatProfile(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseThreatProfileID(w, r)
+	if !ok {
+		return
+	}
+
+	profile, err := h.repo.GetThreatProfile(id)
+	if err != nil {
+		threatError(w, r, err, "Error retrieving threat profile")
+		return
+	}
+
+	JSON(w, http.StatusOK, profile)
+}
+
+// UpdateThreatProfile handles PUT /api/mitre/threat-profiles/{id}
+func (h *MitreHandler) UpdateThreatProfile(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseThreatProfileID(w, r)
+	if !ok {
+		return
+	}
+
+	var profile models.ThreatProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	profile.ID = id
+
+	if err := validation.ValidateThreatProfile(&profile); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.UpdateThreatProfile(&profile); err != nil {
+		threatError(w, r, err, "Error updating threat profile")
+		return
+	}
+
+	updated, err := h.repo.GetThreatProfile(id)
+	if err != nil {
+		threatError(w, r, err, "Error retrieving threat profile")
+		return
+	}
+
+	JSON(w, http.StatusOK, updated)
+}
+
+// DeleteThreatProfile handles DELETE /api/mitre/threat-profiles/{id}
+func (h *MitreHandler) DeleteThreatProfile(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseThreatProfileID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteThreatProfile(id); err != nil {
+		threatError(w, r, err, "Error deleting threat profile")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetThreatProfileCoverage handles GET /api/mitre/threat-profiles/{id}/coverage.
+// The mode query parameter selects the detections counted, production by
+// default, and the optional limit query parameter caps the ranked gap list.
+func (h *MitreHandler) GetThreatProfileCoverage(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseThreatProfileID(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 0 {
+			Error(w, r, http.StatusBadRequest, "Invalid limit parameter")
+			return
+		}
+	}
+
+	mode, ok := parseCoverageMode(w, r, models.CoverageProduction)
+	if !ok {
+		return
+	}
+
+	coverage, err := h.repo.ThreatProfileCoverage(id, mode)
+	if err != nil {
+		threatError(w, r, err, "Error retrieving threat profile coverage")
+		return
+	}
+	if limit > 0 && len(coverage.Gaps) > limit {
+		coverage.Gaps = coverage.Gaps[:limit]
+	}
+
+	JSON(w, http.StatusOK, coverage)
+}
+
+// parseThreatProfileID reads the threat profile ID from the URL path,
+// writing a 400 response when it is invalid
+func parseThreatProfileID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid threat profile ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// threatError maps threat group and profile errors to HTTP responses
+func threatError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, mitre.ErrThreatProfileNotFound):
+		Error(w, r, http.StatusNotFound, "Threat profile not found")
+	case errors.Is(err, mitre.ErrThreatGroupNotFound):
+		// An unknown group in a profile is the caller's mistake
+		if r.Method == http.MethodGet {
+			Error(w, r, http.StatusNotFound, "Threat group not found")
+		} else {
+			Error(w, r, http.StatusBadRequest, err.Error())
+		}
+	case errors.Is(err, mitre.ErrThreatProfileExists):
+		Error(w, r, http.StatusConflict, err.Error())
+	default:
+		Error(w, r, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"riskmatrix/pkg/models"
+)
+
+func TestMitreHandler_ThreatProfiles(t *testing.T) {
+	handler, db := setupMitreTestHandler(t)
+	defer db.Close()
+
+	createTestMitreTechnique(t, db)
+
+	csv := "group_id,group_name,technique_id\nG0016,APT29,T1059\nG0016,APT29,T1003\n"
+	req := httptest.NewRequest("POST", "/api/mitre/groups/import", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	handler.ImportThreatGroups(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var report struct {
+		Groups  int      `json:"groups"`
+		Unknown []string `json:"unknown"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if report.Groups != 1 || len(report.Unknown) != 1 {
+		t.Errorf("Unexpected import report %+v", report)
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Valid profile", body: `{"name": "Finance", "group_ids": ["G0016"]}`, expectedStatus: http.StatusCreated},
+		{name: "Duplicate name", body: `{"name": "Finance", "group_ids": ["G0016"]}`, expectedStatus: http.StatusConflict},
+		{name: "Unknown group", body: `{"name": "Retail", "group_ids": ["G9999"]}`, expectedStatus: http.StatusBadRequest},
+		{name: "No groups", body: `{"name": "Retail", "group_ids": []}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	var profile models.ThreatProfile
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.CreateThreatProfile(w, httptest.NewRequest("POST", "/api/mitre/threat-profiles", bytes.NewReader([]byte(tt.body))))
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusCreated {
+				if err := json.NewDecoder(w.Body).Decode(&profile); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+			}
+		})
+	}
+
+	id := strconv.FormatInt(profile.ID, 10)
+	req = httptest.NewRequest("GET", "/api/mitre/threat-profiles/"+id+"/coverage", nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.GetThreatProfileCoverage(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var coverage models.ThreatCoverage
+	if err := json.NewDecoder(w.Body).Decode(&coverage); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if coverage.Summary.Techniques != 1 || len(coverage.Gaps) != 1 || coverage.Gaps[0].TechniqueID != "T1059" {
+		t.Errorf("Unexpected coverage %+v", coverage)
+	}
+
+	req = httptest.NewRequest("GET", "/api/mitre/threat-profiles/"+id+"/coverage?mode=best", nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.GetThreatProfileCoverage(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid mode, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/mitre/threat-profiles/999/coverage", nil)
+	req.SetPathValue("id", "999")
+	w = httptest.NewRecorder()
+	handler.GetThreatProfileCoverage(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
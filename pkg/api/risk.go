@@ -2,11 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
 
+	"riskmatrix/internal/ingest"
 	"riskmatrix/internal/risk"
 	"riskmatrix/pkg/models"
 )
@@ -15,8 +18,25 @@ import (
 type RiskHandler struct {
 	engine *risk.Engine
 	repo   *risk.Repository
+	ingest *ingest.Pipeline
 }
 
+// EventResult is the outcome of one event submitted to the batch endpoint
+type EventResult struct {
+	Index   int    `json:"index"`
+	Status  string `json:"status"`
+	EventID int64  `json:"event_id,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Event result statuses
+const (
+	EventAccepted  = "accepted"
+	EventRejected  = "rejected"
+	EventThrottled = "throttled"
+	EventDuplicate = "duplicate"
+)
+
 // NewRiskHandler creates a new risk handler
 func NewRiskHandler(engine *risk.Engine, repo *risk.Repository) *RiskHandler {
 	return &RiskHandler{
@@ -34,6 +54,7 @@ func (h *RiskHandler) ProcessEvent(w http.ResponseWriter, r *http.Request) {
 		EntityType      string             `json:"entity_type,omitempty"`
 		EntityValue     string             `json:"entity_value,omitempty"`
 		RiskObject      *models.RiskObject `json:"risk_object,omitempty"`
+		ExternalID      string             `json:"external_id,omitempty"`
 		Timestamp       time.Time          `json:"timestamp,omitempty"`
 		RawData         string             `json:"raw_data,omitempty"`
 		Context         string             `json:"context,omitempty"`
@@ -50,6 +71,7 @@ func (h *RiskHandler) ProcessEvent(w http.ResponseWriter, r *http.Request) {
 	event := models.Event{
 		DetectionID:     requestData.DetectionID,
 		EntityID:        requestData.EntityID,
+		ExternalID:      requestData.ExternalID,
 		RawData:         requestData.RawData,
 		Context:         requestData.Context,
 		RiskPoints:      requestData.RiskPoints,
@@ -57,11 +79,6 @@ func (h *RiskHandler) ProcessEvent(w http.ResponseWriter, r *http.Request) {
 		Timestamp:       requestData.Timestamp,
 	}
 
-	// Set timestamp if not provided
-	if event.Timestamp.IsZero() {
-		event.Timestamp = time.Now()
-	}
-
 	// Handle RiskObject from different sources
 	if requestData.RiskObject != nil {
 		// If RiskObject is directly provided in the request
@@ -87,8 +104,22 @@ func (h *RiskHandler) ProcessEvent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process event
-	if err := h.engine.ProcessEvent(&event); err != nil {
-		Error(w, r, http.StatusInternalServerError, "Error processing event")
+	if err := h.submitEvents([]*models.Event{&event})[0]; err != nil {
+		switch {
+		case errors.Is(err, ingest.ErrQueueFull):
+			h.setRetryAfter(w)
+			Error(w, r, http.StatusTooManyRequests, "Event queue is full, retry later")
+		case errors.Is(err, ingest.ErrStopped):
+			Error(w, r, http.StatusServiceUnavailable, "Event ingestion is not running")
+		default:
+			Error(w, r, http.StatusInternalServerError, "Error processing event")
+		}
+		return
+	}
+
+	// A replay returns the event that was originally created
+	if event.Duplicate {
+		JSON(w, http.StatusOK, event)
 		return
 	}
 
@@ -105,22 +136,95 @@ func (h *RiskHandler) ProcessEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set timestamp for events if not provided
-	now := time.Now()
-	for _, event := range events {
-		if event.Timestamp.IsZero() {
-			event.Timestamp = now
+	// Reject events that cannot be processed before queueing the rest
+	results := make([]EventResult, len(events))
+	valid := make([]*models.Event, 0, len(events))
+	indexes := make([]int, 0, len(events))
+	for i, event := range events {
+		results[i] = EventResult{Index: i}
+		if event == nil || event.RiskObject == nil || event.RiskObject.EntityType == "" || event.RiskObject.EntityValue == "" {
+			results[i].Status = EventRejected
+			results[i].Error = "risk_object with entity_type and entity_value is required"
+			continue
 		}
+		valid = append(valid, event)
+		indexes = append(indexes, i)
 	}
 
-	// Process events
-	if err := h.engine.ProcessEvents(events); err != nil {
-		Error(w, r, http.StatusInternalServerError, "Error processing events")
-		return
+	h.writeEventResults(w, results, valid, indexes)
+}
+
+// writeEventResults submits the valid events of a batch, records their
+// outcomes in results at the given indexes and writes the batch response
+func (h *RiskHandler) writeEventResults(w http.ResponseWriter, results []EventResult, valid []*models.Event, indexes []int) {
+	processed, duplicates, throttled := 0, 0, 0
+	for n, err := range h.submitEvents(valid) {
+		result := &results[indexes[n]]
+		switch {
+		case err == nil && valid[n].Duplicate:
+			result.Status = EventDuplicate
+			result.EventID = valid[n].ID
+			duplicates++
+		case err == nil:
+			result.Status = EventAccepted
+			result.EventID = valid[n].ID
+			processed++
+		case errors.Is(err, ingest.ErrQueueFull), errors.Is(err, ingest.ErrStopped):
+			result.Status = EventThrottled
+			result.Error = err.Error()
+			throttled++
+		default:
+			result.Status = EventRejected
+			result.Error = err.Error()
+		}
+	}
+
+	// All accepted is created, none queued for lack of room is too many
+	// requests, and anything else, including replays, is a partial success
+	status := http.StatusOK
+	if processed == len(results) {
+		status = http.StatusCreated
+	} else if processed+duplicates == 0 && throttled > 0 {
+		status = http.StatusTooManyRequests
+	}
+	if throttled > 0 {
+		h.setRetryAfter(w)
+	}
+
+	JSON(w, status, map[string]interface{}{
+		"processed":  processed,
+		"duplicates": duplicates,
+		"rejected":   len(results) - processed - duplicates,
+		"results":    results,
+	})
+}
+
+// submitEvents queues events for ingestion and waits for each one's result.
+// Without an ingestion pipeline the events are processed directly as one
+// batch.
+func (h *RiskHandler) submitEvents(events []*models.Event) []error {
+	if h.ingest != nil {
+		return h.ingest.Submit(events)
 	}
 
-	// Return success with count
-	JSON(w, http.StatusCreated, map[string]int{"processed": len(events)})
+	results, err := h.engine.ProcessEventBatch(events)
+	if err != nil {
+		results = make([]error, len(events))
+		for i := range results {
+			results[i] = err
+		}
+	}
+	return results
+}
+
+// setRetryAfter tells the client how many seconds to wait before resubmitting
+// throttled events
+func (h *RiskHandler) setRetryAfter(w http.ResponseWriter) {
+	retryAfter := ingest.DefaultConfig().RetryAfter
+	if h.ingest != nil {
+		retryAfter = h.ingest.RetryAfter()
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
 }
 
 // GetRiskObject handles GET /api/risk/objects/{id}
@@ -140,6 +244,16 @@ func (h *RiskHandler) GetRiskObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.repo.EnrichRiskObjects(obj); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving inventory")
+		return
+	}
+
+	if obj.TacticCoverage, err = h.engine.TacticCoverage(obj.ID); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving tactic coverage")
+		return
+	}
+
 	// Return risk object as JSON
 	JSON(w, http.StatusOK, obj)
 }
@@ -162,6 +276,16 @@ func (h *RiskHandler) GetRiskObjectByEntity(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := h.repo.EnrichRiskObjects(obj); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving inventory")
+		return
+	}
+
+	if obj.TacticCoverage, err = h.engine.TacticCoverage(obj.ID); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving tactic coverage")
+		return
+	}
+
 	// Return risk object as JSON
 	JSON(w, http.StatusOK, obj)
 }
@@ -195,6 +319,11 @@ func (h *RiskHandler) ListRiskObjects(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.repo.EnrichRiskObjects(objects...); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving inventory")
+		return
+	}
+
 	// Apply limit if provided
 	if limitStr != "" {
 		limit, err := strconv.Atoi(limitStr)
@@ -410,6 +539,11 @@ func (h *RiskHandler) GetRiskAlert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if alert.TacticCoverage, err = h.repo.GetAlertTacticCoverage(alert.ID); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving tactic coverage")
+		return
+	}
+
 	// Return risk alert as JSON
 	JSON(w, http.StatusOK, alert)
 }
@@ -491,6 +625,11 @@ func (h *RiskHandler) GetHighRiskEntities(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if err := h.repo.EnrichRiskObjects(entities...); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving inventory")
+		return
+	}
+
 	// Return entities as JSON
 	JSON(w, http.StatusOK, entities)
 }
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// ListEntityGroups handles GET /api/risk/groups
+func (h *RiskHandler) ListEntityGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.repo.ListEntityGroups()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving entity groups")
+		return
+	}
+
+	List(w, groups, 1, len(groups), len(groups))
+}
+
+// GetEntityGroup handles GET /api/risk/groups/{id}
+func (h *RiskHandler) GetEntityGroup(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid entity group ID")
+		return
+	}
+
+	group, err := h.repo.GetEntityGroup(id)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Entity group not found")
+		return
+	}
+
+	if err := h.repo.EnrichRiskObjects(group.Members...); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving inventory")
+		return
+	}
+
+	JSON(w, http.StatusOK, group)
+}
+
+// GetRiskObjectGroup handles GET /api/risk/objects/{id}/group
+func (h *RiskHandler) GetRiskObjectGroup(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid risk object ID")
+		return
+	}
+
+	if _, err := h.repo.GetRiskObject(id); err != nil {
+		Error(w, r, http.StatusNotFound, "Risk object not found")
+		return
+	}
+
+	group, err := h.repo.GetEntityGroupByEntity(id)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving entity group")
+		return
+	}
+	if group == nil {
+		Error(w, r, http.StatusNotFound, "Risk object is not part of an entity group")
+		return
+	}
+
+	if err := h.repo.EnrichRiskObjects(group.Members...); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving inventory")
+		return
+	}
+
+	JSON(w, http.StatusOK, group)
+}
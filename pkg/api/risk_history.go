@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"riskmatrix/internal/risk"
+	"riskmatrix/pkg/models"
+)
+
+// scoreHistoryBuckets maps the bucket query parameter to a bucket size
+var scoreHistoryBuckets = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// maxScoreHistoryBuckets bounds the number of points a history request returns
+const maxScoreHistoryBuckets = 2000
+
+// ScoreHistoryResponse is the response for a risk object's score history
+type ScoreHistoryResponse struct {
+	EntityID int64                     `json:"entity_id"`
+	Bucket   string                    `json:"bucket"`
+	Since    time.Time                 `json:"since"`
+	Until    time.Time                 `json:"until"`
+	Points   []*models.RiskScorePoint  `json:"points"`
+	Changes  []*models.RiskScoreChange `json:"changes"`
+}
+
+// GetRiskObjectHistory handles GET /api/risk/objects/{id}/history
+// Query parameters: since, until (RFC3339, default the last 30 days) and
+// bucket (hour, day or week, default day)
+func (h *RiskHandler) GetRiskObjectHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid risk object ID")
+		return
+	}
+
+	if _, err := h.repo.GetRiskObject(id); err != nil {
+		if errors.Is(err, risk.ErrRiskObjectNotFound) {
+			Error(w, r, http.StatusNotFound, "Risk object not found")
+			return
+		}
+		Error(w, r, http.StatusInternalServerError, "Error retrieving risk object")
+		return
+	}
+
+	bucketName := r.URL.Query().Get("bucket")
+	if bucketName == "" {
+		bucketName = "day"
+	}
+	bucket, ok := scoreHistoryBuckets[bucketName]
+	if !ok {
+		Error(w, r, http.StatusBadRequest, "Invalid bucket, must be one of hour, day, week")
+		return
+	}
+
+	until := time.Now().UTC()
+	if untilStr := r.URL.Query().Get("until"); untilStr != "" {
+		if until, err = time.Parse(time.RFC3339, untilStr); err != nil {
+			Error(w, r, http.StatusBadRequest, "Invalid until, must be RFC3339")
+			return
+		}
+	}
+
+	since := until.Add(-30 * 24 * time.Hour)
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		if since, err = time.Parse(time.RFC3339, sinceStr); err != nil {
+			Error(w, r, http.StatusBadRequest, "Invalid since, must be RFC3339")
+			return
+		}
+	}
+
+	if !since.Before(until) {
+		Error(w, r, http.StatusBadRequest, "since must be before until")
+		return
+	}
+	if until.Sub(since)/bucket > maxScoreHistoryBuckets {
+		Error(w, r, http.StatusBadRequest, "Time range too large for bucket size")
+		return
+	}
+
+	initial, err := h.repo.GetScoreAt(id, since)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving risk score history")
+		return
+	}
+
+	changes, err := h.repo.ListScoreHistory(id, since, until)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving risk score history")
+		return
+	}
+
+	JSON(w, http.StatusOK, ScoreHistoryResponse{
+		EntityID: id,
+		Bucket:   bucketName,
+		Since:    since,
+		Until:    until,
+		Points:   risk.BucketScoreHistory(initial, changes, since, until, bucket),
+		Changes:  changes,
+	})
+}
+
+// SetRiskScore handles PUT /api/risk/objects/{id}/score
+func (h *RiskHandler) SetRiskScore(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid risk object ID")
+		return
+	}
+
+	var req struct {
+		Score *int   `json:"score"`
+		Notes string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Score == nil || *req.Score < 0 {
+		Error(w, r, http.StatusBadRequest, "score is required and must be non-negative")
+		return
+	}
+
+	obj, err := h.engine.SetRiskScore(id, *req.Score, req.Notes)
+	if err != nil {
+		if errors.Is(err, risk.ErrRiskObjectNotFound) {
+			Error(w, r, http.StatusNotFound, "Risk object not found")
+			return
+		}
+		Error(w, r, http.StatusInternalServerError, "Error setting risk score")
+		return
+	}
+
+	JSON(w, http.StatusOK, obj)
+}
@@ -3,12 +3,14 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
 	"time"
 
+	"riskmatrix/internal/ingest"
 	"riskmatrix/internal/risk"
 	"riskmatrix/pkg/database"
 	"riskmatrix/pkg/models"
@@ -216,6 +218,150 @@ func TestRiskHandler_ProcessEvents(t *testing.T) {
 	}
 }
 
+func TestRiskHandler_ProcessEvents_PartialResults(t *testing.T) {
+	handler, db := setupRiskTestHandler(t)
+	defer db.Close()
+
+	testDetection := createTestDetection(t, db)
+
+	// A valid event, one with no entity and one with an unknown detection
+	body := fmt.Sprintf(`[
+		{"detection_id": %d, "risk_object": {"entity_type": "user", "entity_value": "batch-user"}, "risk_points": 10},
+		{"detection_id": %d, "risk_points": 10},
+		{"detection_id": 9999, "risk_object": {"entity_type": "user", "entity_value": "batch-user"}, "risk_points": 10}
+	]`, testDetection.ID, testDetection.ID)
+
+	req := httptest.NewRequest("POST", "/api/events/batch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ProcessEvents(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response struct {
+		Processed int           `json:"processed"`
+		Rejected  int           `json:"rejected"`
+		Results   []EventResult `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Processed != 1 || response.Rejected != 2 {
+		t.Errorf("Expected 1 processed and 2 rejected, got %d and %d", response.Processed, response.Rejected)
+	}
+	if len(response.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(response.Results))
+	}
+	if response.Results[0].Status != EventAccepted || response.Results[0].EventID == 0 {
+		t.Errorf("Expected first event accepted with an ID, got %+v", response.Results[0])
+	}
+	for _, result := range response.Results[1:] {
+		if result.Status != EventRejected || result.Error == "" {
+			t.Errorf("Expected event %d rejected with an error, got %+v", result.Index, result)
+		}
+	}
+}
+
+// heldProcessor keeps events queued until released
+type heldProcessor struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *heldProcessor) ProcessEventBatch(events []*models.Event) ([]error, error) {
+	p.started <- struct{}{}
+	<-p.release
+	return make([]error, len(events)), nil
+}
+
+func TestRiskHandler_ProcessEvent_QueueFull(t *testing.T) {
+	handler, db := setupRiskTestHandler(t)
+	defer db.Close()
+
+	processor := &heldProcessor{started: make(chan struct{}, 2), release: make(chan struct{})}
+	handler.ingest = ingest.NewPipeline(processor, ingest.Config{QueueSize: 1, Workers: 1, BatchSize: 1, RetryAfter: 3 * time.Second})
+	handler.ingest.Start()
+	defer handler.ingest.Stop()
+
+	// One event holds the worker and another fills the queue
+	event := func() *models.Event {
+		return &models.Event{RiskObject: &models.RiskObject{EntityType: models.EntityTypeUser, EntityValue: "busy-user"}}
+	}
+	done := make(chan struct{}, 2)
+	go func() { handler.ingest.Submit([]*models.Event{event()}); done <- struct{}{} }()
+	<-processor.started
+	go func() { handler.ingest.Submit([]*models.Event{event()}); done <- struct{}{} }()
+	for handler.ingest.QueueDepth() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	body := `{"detection_id": 1, "entity_type": "user", "entity_value": "busy-user", "risk_points": 10}`
+	req := httptest.NewRequest("POST", "/api/events", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ProcessEvent(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "3" {
+		t.Errorf("Expected Retry-After 3, got '%s'", retryAfter)
+	}
+
+	close(processor.release)
+	<-done
+	<-done
+}
+
+func TestRiskHandler_ProcessEvent_Replay(t *testing.T) {
+	handler, db := setupRiskTestHandler(t)
+	defer db.Close()
+
+	testDetection := createTestDetection(t, db)
+	body := fmt.Sprintf(`{"detection_id": %d, "entity_type": "user", "entity_value": "replay-user",
+		"timestamp": "2026-10-16T09:30:00Z", "raw_data": "login failed", "risk_points": 10}`, testDetection.ID)
+
+	post := func() (int, models.Event) {
+		req := httptest.NewRequest("POST", "/api/events", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ProcessEvent(w, req)
+
+		var event models.Event
+		if err := json.NewDecoder(w.Body).Decode(&event); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, event
+	}
+
+	status, original := post()
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, status)
+	}
+
+	// The forwarder retries the same event
+	status, replay := post()
+	if status != http.StatusOK {
+		t.Errorf("Expected status %d for a replay, got %d", http.StatusOK, status)
+	}
+	if !replay.Duplicate || replay.ID != original.ID {
+		t.Errorf("Expected original event %d as a duplicate, got event %d (duplicate %v)", original.ID, replay.ID, replay.Duplicate)
+	}
+
+	obj, err := handler.repo.GetRiskObjectByEntity(models.EntityTypeUser, "replay-user")
+	if err != nil {
+		t.Fatalf("Failed to get risk object: %v", err)
+	}
+	if obj.CurrentScore != original.RiskPoints {
+		t.Errorf("Expected score %d after replay, got %d", original.RiskPoints, obj.CurrentScore)
+	}
+}
+
 func TestRiskHandler_GetRiskObject(t *testing.T) {
 	handler, db := setupRiskTestHandler(t)
 	defer db.Close()
@@ -890,3 +1036,165 @@ func TestRiskHandler_MarkEventAsFalsePositive_InvalidJSON(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
 	}
 }
+
+func TestRiskHandler_GetRiskObjectHistory(t *testing.T) {
+	handler, db := setupRiskTestHandler(t)
+	defer db.Close()
+
+	obj := createTestRiskObject(t, db)
+
+	// Record a manual change so there is history to bucket
+	setReq := httptest.NewRequest("PUT", "/api/risk/objects/1/score", bytes.NewBufferString(`{"score": 60, "notes": "escalated"}`))
+	setReq.SetPathValue("id", strconv.FormatInt(obj.ID, 10))
+	setW := httptest.NewRecorder()
+	handler.SetRiskScore(setW, setReq)
+	if setW.Code != http.StatusOK {
+		t.Fatalf("Expected status %d setting score, got %d", http.StatusOK, setW.Code)
+	}
+
+	tests := []struct {
+		name           string
+		objectID       string
+		query          string
+		expectedStatus int
+		expectedPoints int
+	}{
+		{"Hourly buckets", strconv.FormatInt(obj.ID, 10), "?bucket=hour&since=" + time.Now().Add(-3*time.Hour).UTC().Format(time.RFC3339), http.StatusOK, 4},
+		{"Default daily buckets", strconv.FormatInt(obj.ID, 10), "", http.StatusOK, 31},
+		{"Invalid bucket", strconv.FormatInt(obj.ID, 10), "?bucket=minute", http.StatusBadRequest, 0},
+		{"Invalid since", strconv.FormatInt(obj.ID, 10), "?since=yesterday", http.StatusBadRequest, 0},
+		{"Non-existent object", "999", "", http.StatusNotFound, 0},
+		{"Invalid ID", "invalid", "", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/risk/objects/"+tt.objectID+"/history"+tt.query, nil)
+			req.SetPathValue("id", tt.objectID)
+			w := httptest.NewRecorder()
+
+			handler.GetRiskObjectHistory(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var response ScoreHistoryResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if len(response.Points) != tt.expectedPoints {
+				t.Errorf("Expected %d points, got %d", tt.expectedPoints, len(response.Points))
+			}
+			last := response.Points[len(response.Points)-1]
+			if last.Score != 60 {
+				t.Errorf("Expected latest score 60, got %d", last.Score)
+			}
+			if len(response.Changes) != 1 || response.Changes[0].Reason != models.ScoreChangeManual {
+				t.Errorf("Expected one manual change, got %d changes", len(response.Changes))
+			}
+		})
+	}
+}
+
+func TestRiskHandler_SetRiskScore(t *testing.T) {
+	handler, db := setupRiskTestHandler(t)
+	defer db.Close()
+
+	obj := createTestRiskObject(t, db)
+	setScore := func(id string) int {
+		req := httptest.NewRequest("PUT", "/api/risk/objects/"+id+"/score", bytes.NewBufferString(`{"score": 10}`))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.SetRiskScore(w, req)
+		return w.Code
+	}
+
+	if code := setScore("999"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing object, got %d", http.StatusNotFound, code)
+	}
+
+	// Other failures are server errors, not a missing object
+	if _, err := db.Exec(`DROP TABLE risk_score_history`); err != nil {
+		t.Fatalf("Failed to drop score history: %v", err)
+	}
+	if code := setScore(strconv.FormatInt(obj.ID, 10)); code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d when the update fails, got %d", http.StatusInternalServerError, code)
+	}
+}
+
+func TestRiskHandler_EntityGroups(t *testing.T) {
+	handler, db := setupRiskTestHandler(t)
+	defer db.Close()
+
+	obj := createTestRiskObject(t, db)
+	result, err := db.Exec(`INSERT INTO risk_objects (entity_type, entity_value, current_score, last_seen) VALUES (?, ?, ?, ?)`,
+		models.EntityTypeHost, "ws-1", 30, time.Now().Format(time.RFC3339))
+	if err != nil {
+		t.Fatalf("Failed to create risk object: %v", err)
+	}
+	hostID, _ := result.LastInsertId()
+	ungrouped, err := db.Exec(`INSERT INTO risk_objects (entity_type, entity_value, current_score, last_seen) VALUES (?, ?, ?, ?)`,
+		models.EntityTypeIP, "10.0.0.5", 10, time.Now().Format(time.RFC3339))
+	if err != nil {
+		t.Fatalf("Failed to create risk object: %v", err)
+	}
+	ungroupedID, _ := ungrouped.LastInsertId()
+
+	if _, err := db.Exec(`INSERT INTO entity_groups (id) VALUES (1)`); err != nil {
+		t.Fatalf("Failed to create entity group: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO entity_group_members (entity_id, group_id) VALUES (?, 1), (?, 1)`, obj.ID, hostID); err != nil {
+		t.Fatalf("Failed to add entity group members: %v", err)
+	}
+
+	listReq := httptest.NewRequest("GET", "/api/risk/groups", nil)
+	listW := httptest.NewRecorder()
+	handler.ListEntityGroups(listW, listReq)
+	if listW.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, listW.Code)
+	}
+
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		id             string
+		expectedStatus int
+	}{
+		{"Get group", handler.GetEntityGroup, "1", http.StatusOK},
+		{"Non-existent group", handler.GetEntityGroup, "999", http.StatusNotFound},
+		{"Invalid group ID", handler.GetEntityGroup, "invalid", http.StatusBadRequest},
+		{"Group of risk object", handler.GetRiskObjectGroup, strconv.FormatInt(obj.ID, 10), http.StatusOK},
+		{"Ungrouped risk object", handler.GetRiskObjectGroup, strconv.FormatInt(ungroupedID, 10), http.StatusNotFound},
+		{"Non-existent risk object", handler.GetRiskObjectGroup, "999", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/risk/groups/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+			w := httptest.NewRecorder()
+
+			tt.handler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var group models.EntityGroup
+			if err := json.NewDecoder(w.Body).Decode(&group); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(group.Members) != 2 || group.Score != 55 {
+				t.Errorf("Expected 2 members scoring 55, got %d scoring %d", len(group.Members), group.Score)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"riskmatrix/internal/risk"
+	validation "riskmatrix/pkg"
+	"riskmatrix/pkg/models"
+)
+
+// RiskThresholdHandler handles HTTP requests for risk threshold policy endpoints
+type RiskThresholdHandler struct {
+	repo *risk.Repository
+}
+
+// NewRiskThresholdHandler creates a new risk threshold handler
+func NewRiskThresholdHandler(repo *risk.Repository) *RiskThresholdHandler {
+	return &RiskThresholdHandler{repo: repo}
+}
+
+// ListRiskThresholds handles GET /api/risk/thresholds
+func (h *RiskThresholdHandler) ListRiskThresholds(w http.ResponseWriter, r *http.Request) {
+	thresholds, err := h.repo.ListRiskThresholds()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving risk thresholds")
+		return
+	}
+
+	List(w, thresholds, 1, len(thresholds), len(thresholds))
+}
+
+// GetRiskThreshold handles GET /api/risk/thresholds/{id}
+func (h *RiskThresholdHandler) GetRiskThreshold(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid threshold ID")
+		return
+	}
+
+	threshold, err := h.repo.GetRiskThreshold(id)
+	if err != nil {
+		Error(w, r, http.StatusNotFound, "Risk threshold not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, threshold)
+}
+
+// CreateRiskThreshold handles POST /api/risk/thresholds
+func (h *RiskThresholdHandler) CreateRiskThreshold(w http.ResponseWriter, r *http.Request) {
+	// Policies are enabled unless the request says otherwise
+	threshold := models.RiskThreshold{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&threshold); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateRiskThreshold(&threshold); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.CreateRiskThreshold(&threshold); err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error creating risk threshold")
+		return
+	}
+
+	JSON(w, http.StatusCreated, threshold)
+}
+
+// UpdateRiskThreshold handles PUT /api/risk/thresholds/{id}
+func (h *RiskThresholdHandler) UpdateRiskThreshold(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid threshold ID")
+		return
+	}
+
+	var threshold models.RiskThreshold
+	if err := json.NewDecoder(r.Body).Decode(&threshold); err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Ensure ID in URL matches ID in body
+	threshold.ID = id
+
+	if err := validation.ValidateRiskThreshold(&threshold); err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.UpdateRiskThreshold(&threshold); err != nil {
+		Error(w, r, http.StatusNotFound, "Risk threshold not found")
+		return
+	}
+
+	JSON(w, http.StatusOK, threshold)
+}
+
+// DeleteRiskThreshold handles DELETE /api/risk/thresholds/{id}
+func (h *RiskThresholdHandler) DeleteRiskThreshold(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid threshold ID")
+		return
+	}
+
+	if err := h.repo.DeleteRiskThreshold(id); err != nil {
+		Error(w, r, http.StatusNotFound, "Risk threshold not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
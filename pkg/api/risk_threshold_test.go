@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"riskmatrix/internal/risk"
+	"riskmatrix/pkg/models"
+)
+
+func TestRiskThresholdHandler_CRUD(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	handler := NewRiskThresholdHandler(risk.NewRepository(db))
+
+	// Invalid payloads are rejected
+	body, _ := json.Marshal(models.RiskThreshold{Name: "bad", EntityType: "printer", Threshold: 10})
+	w := httptest.NewRecorder()
+	handler.CreateRiskThreshold(w, httptest.NewRequest("POST", "/api/risk/thresholds", bytes.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid entity type, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// Create
+	body, _ = json.Marshal(map[string]interface{}{"name": "Service accounts", "entity_type": "user", "value_pattern": "svc-*", "threshold": 120})
+	w = httptest.NewRecorder()
+	handler.CreateRiskThreshold(w, httptest.NewRequest("POST", "/api/risk/thresholds", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var created models.RiskThreshold
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !created.Enabled {
+		t.Error("Expected new threshold to be enabled by default")
+	}
+	idStr := strconv.FormatInt(created.ID, 10)
+
+	// Update
+	created.Threshold = 150
+	body, _ = json.Marshal(created)
+	req := httptest.NewRequest("PUT", "/api/risk/thresholds/"+idStr, bytes.NewReader(body))
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	handler.UpdateRiskThreshold(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// Get
+	req = httptest.NewRequest("GET", "/api/risk/thresholds/"+idStr, nil)
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	handler.GetRiskThreshold(w, req)
+	var fetched models.RiskThreshold
+	json.NewDecoder(w.Body).Decode(&fetched)
+	if fetched.Threshold != 150 {
+		t.Errorf("Expected threshold 150, got %d", fetched.Threshold)
+	}
+
+	// Delete
+	req = httptest.NewRequest("DELETE", "/api/risk/thresholds/"+idStr, nil)
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	handler.DeleteRiskThreshold(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/risk/thresholds/"+idStr, nil)
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	handler.GetRiskThreshold(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d after delete, got %d", http.StatusNotFound, w.Code)
+	}
+}
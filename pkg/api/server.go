@@ -9,21 +9,36 @@ import (
 
 	"riskmatrix/internal/datasource"
 	"riskmatrix/internal/detection"
+	"riskmatrix/internal/detectiontest"
+	"riskmatrix/internal/emulation"
+	"riskmatrix/internal/ingest"
+	"riskmatrix/internal/inventory"
 	"riskmatrix/internal/mitre"
 	"riskmatrix/internal/risk"
+	"riskmatrix/internal/rulesync"
+	"riskmatrix/internal/sigma"
 	"riskmatrix/pkg/cache"
 	"riskmatrix/pkg/database"
 	"riskmatrix/pkg/middleware"
+	"riskmatrix/pkg/models"
 )
 
+// emulationCheckInterval is how often pending emulation tests are checked
+const emulationCheckInterval = 5 * time.Minute
+
 // Server represents the API server
 type Server struct {
 	db             *database.DB
 	detectionRepo  *detection.Repository
+	lifecycle      *detection.Lifecycle
 	mitreRepo      *mitre.Repository
 	dataSourceRepo *datasource.Repository
 	riskRepo       *risk.Repository
 	riskEngine     *risk.Engine
+	emulationRepo  *emulation.Repository
+	ingest         *ingest.Pipeline
+	syslog         *ingest.SyslogListener
+	hec            *ingest.HECListener
 	router         *http.ServeMux
 	handler        http.Handler
 	cache          *cache.Cache
@@ -36,13 +51,122 @@ type appConfig struct {
 		Threshold          int     `json:"threshold"`
 		DecayFactor        float64 `json:"decay_factor"`
 		DecayIntervalHours int     `json:"decay_interval_hours"`
+		ScoreWindowHours   int     `json:"score_window_hours"`
+		AlertCooldownHours int     `json:"alert_cooldown_hours"`
+		DedupWindowMinutes *int    `json:"dedup_window_minutes"`
+		Thresholds         []struct {
+			Name             string `json:"name"`
+			EntityType       string `json:"entity_type"`
+			ValuePattern     string `json:"value_pattern"`
+			Tag              string `json:"tag"`
+			DetectionClassID *int64 `json:"detection_class_id"`
+			Threshold        int    `json:"threshold"`
+			Priority         int    `json:"priority"`
+		} `json:"thresholds"`
+		Modifiers struct {
+			Enabled         bool               `json:"enabled"`
+			Severity        map[string]float64 `json:"severity"`
+			Criticality     map[string]float64 `json:"criticality"`
+			ConfidenceField string             `json:"confidence_field"`
+			Entities        []struct {
+				Name         string  `json:"name"`
+				EntityType   string  `json:"entity_type"`
+				ValuePattern string  `json:"value_pattern"`
+				Tag          string  `json:"tag"`
+				Multiplier   float64 `json:"multiplier"`
+			} `json:"entities"`
+		} `json:"modifiers"`
+		Correlation struct {
+			Enabled        bool              `json:"enabled"`
+			Fields         map[string]string `json:"fields"`
+			GroupThreshold int               `json:"group_threshold"`
+			MaxGroupSize   int               `json:"max_group_size"`
+		} `json:"correlation"`
+		TacticDiversity struct {
+			Enabled     bool `json:"enabled"`
+			WindowHours int  `json:"window_hours"`
+			Limit       int  `json:"limit"`
+			Bonus       int  `json:"bonus"`
+			Alert       bool `json:"alert"`
+		} `json:"tactic_diversity"`
 	} `json:"risk_engine"`
+	Ingestion struct {
+		QueueSize         int `json:"queue_size"`
+		Workers           int `json:"workers"`
+		BatchSize         int `json:"batch_size"`
+		BatchWaitMs       int `json:"batch_wait_ms"`
+		RetryAfterSeconds int `json:"retry_after_seconds"`
+		Syslog            struct {
+			Enabled bool               `json:"enabled"`
+			UDPAddr string             `json:"udp_addr"`
+			TCPAddr string             `json:"tcp_addr"`
+			Mapping fieldMappingConfig `json:"mapping"`
+		} `json:"syslog"`
+		HEC struct {
+			Enabled bool               `json:"enabled"`
+			Addr    string             `json:"addr"`
+			Tokens  []string           `json:"tokens"`
+			Mapping fieldMappingConfig `json:"mapping"`
+		} `json:"hec"`
+	} `json:"ingestion"`
+	// Overrides of the default detection lifecycle; an explicit empty
+	// approval_required list turns approvals off
+	DetectionLifecycle struct {
+		Transitions      map[models.DetectionStatus][]models.DetectionStatus `json:"transitions"`
+		Requirements     map[models.DetectionStatus][]string                 `json:"requirements"`
+		ApprovalRequired *[]models.DetectionStatus                           `json:"approval_required"`
+	} `json:"detection_lifecycle"`
 	Security struct {
 		EnableCORS     bool     `json:"enable_cors"`
 		AllowedOrigins []string `json:"allowed_origins"`
 	} `json:"security"`
 }
 
+// fieldMappingConfig overrides the fields an ingestion listener reads; empty
+// values keep the listener's default
+type fieldMappingConfig struct {
+	DetectionID string `json:"detection_id"`
+	EntityType  string `json:"entity_type"`
+	EntityValue string `json:"entity_value"`
+	Entities    []struct {
+		Field string `json:"field"`
+		Type  string `json:"type"`
+	} `json:"entities"`
+	RiskPoints string `json:"risk_points"`
+	Timestamp  string `json:"timestamp"`
+	ExternalID string `json:"external_id"`
+}
+
+// apply returns mapping with the configured fields replaced
+func (c fieldMappingConfig) apply(mapping ingest.FieldMapping) ingest.FieldMapping {
+	for _, field := range []struct {
+		value  string
+		target *string
+	}{
+		{c.DetectionID, &mapping.DetectionID},
+		{c.EntityType, &mapping.EntityType},
+		{c.EntityValue, &mapping.EntityValue},
+		{c.RiskPoints, &mapping.RiskPoints},
+		{c.Timestamp, &mapping.Timestamp},
+		{c.ExternalID, &mapping.ExternalID},
+	} {
+		if field.value != "" {
+			*field.target = field.value
+		}
+	}
+
+	if len(c.Entities) > 0 {
+		mapping.Entities = make([]ingest.EntityField, 0, len(c.Entities))
+		for _, entity := range c.Entities {
+			mapping.Entities = append(mapping.Entities, ingest.EntityField{
+				Field: entity.Field,
+				Type:  models.EntityType(entity.Type),
+			})
+		}
+	}
+	return mapping
+}
+
 func loadAppConfig() appConfig {
 	var conf appConfig
 	// Try CONFIG_PATH first, then default location
@@ -58,6 +182,32 @@ func loadAppConfig() appConfig {
 	return conf
 }
 
+// lifecycleConfig returns the detection lifecycle from config, keeping the
+// defaults if it is invalid
+func (conf appConfig) lifecycleConfig() detection.LifecycleConfig {
+	lifecycleCfg := detection.DefaultLifecycleConfig()
+	if len(conf.DetectionLifecycle.Transitions) > 0 {
+		lifecycleCfg.Transitions = conf.DetectionLifecycle.Transitions
+	}
+	if conf.DetectionLifecycle.Requirements != nil {
+		lifecycleCfg.Requirements = conf.DetectionLifecycle.Requirements
+	}
+	if conf.DetectionLifecycle.ApprovalRequired != nil {
+		lifecycleCfg.ApprovalRequired = *conf.DetectionLifecycle.ApprovalRequired
+	}
+	if err := lifecycleCfg.Validate(); err != nil {
+		log.Printf("Invalid detection lifecycle config, using defaults: %v", err)
+		lifecycleCfg = detection.DefaultLifecycleConfig()
+	}
+	return lifecycleCfg
+}
+
+// LoadLifecycleConfig returns the detection lifecycle configured for the
+// server, for commands that change detections outside it
+func LoadLifecycleConfig() detection.LifecycleConfig {
+	return loadAppConfig().lifecycleConfig()
+}
+
 // NewServer creates a new API server
 func NewServer(db *database.DB) *Server {
 	// Create repositories
@@ -80,8 +230,98 @@ func NewServer(db *database.DB) *Server {
 	if conf.RiskEngine.DecayIntervalHours > 0 {
 		riskCfg.DecayInterval = time.Duration(conf.RiskEngine.DecayIntervalHours) * time.Hour
 	}
+	if conf.RiskEngine.ScoreWindowHours > 0 {
+		riskCfg.ScoreWindow = time.Duration(conf.RiskEngine.ScoreWindowHours) * time.Hour
+	}
+	if conf.RiskEngine.AlertCooldownHours > 0 {
+		riskCfg.AlertCooldown = time.Duration(conf.RiskEngine.AlertCooldownHours) * time.Hour
+	}
+	// An explicit 0 turns event deduplication off
+	if conf.RiskEngine.DedupWindowMinutes != nil {
+		riskCfg.DedupWindow = time.Duration(*conf.RiskEngine.DedupWindowMinutes) * time.Minute
+	}
+	for _, t := range conf.RiskEngine.Thresholds {
+		riskCfg.Thresholds = append(riskCfg.Thresholds, models.RiskThreshold{
+			Name:             t.Name,
+			EntityType:       models.EntityType(t.EntityType),
+			ValuePattern:     t.ValuePattern,
+			Tag:              t.Tag,
+			DetectionClassID: t.DetectionClassID,
+			Threshold:        t.Threshold,
+			Priority:         t.Priority,
+			Enabled:          true,
+		})
+	}
+	riskCfg.Modifiers.Enabled = conf.RiskEngine.Modifiers.Enabled
+	riskCfg.Modifiers.ConfidenceField = conf.RiskEngine.Modifiers.ConfidenceField
+	if len(conf.RiskEngine.Modifiers.Severity) > 0 {
+		riskCfg.Modifiers.Severity = make(map[models.Severity]float64)
+		for severity, multiplier := range conf.RiskEngine.Modifiers.Severity {
+			riskCfg.Modifiers.Severity[models.Severity(severity)] = multiplier
+		}
+	}
+	if len(conf.RiskEngine.Modifiers.Criticality) > 0 {
+		riskCfg.Modifiers.Criticality = make(map[models.Criticality]float64)
+		for criticality, multiplier := range conf.RiskEngine.Modifiers.Criticality {
+			riskCfg.Modifiers.Criticality[models.Criticality(criticality)] = multiplier
+		}
+	}
+	for _, m := range conf.RiskEngine.Modifiers.Entities {
+		riskCfg.Modifiers.Entities = append(riskCfg.Modifiers.Entities, risk.EntityModifier{
+			Name:         m.Name,
+			EntityType:   models.EntityType(m.EntityType),
+			ValuePattern: m.ValuePattern,
+			Tag:          m.Tag,
+			Multiplier:   m.Multiplier,
+		})
+	}
+	riskCfg.Correlation.Enabled = conf.RiskEngine.Correlation.Enabled
+	riskCfg.Correlation.GroupThreshold = conf.RiskEngine.Correlation.GroupThreshold
+	riskCfg.Correlation.MaxGroupSize = conf.RiskEngine.Correlation.MaxGroupSize
+	if len(conf.RiskEngine.Correlation.Fields) > 0 {
+		riskCfg.Correlation.Fields = make(map[string]models.EntityType)
+		for field, entityType := range conf.RiskEngine.Correlation.Fields {
+			riskCfg.Correlation.Fields[field] = models.EntityType(entityType)
+		}
+	}
+	riskCfg.TacticDiversity = risk.TacticDiversityConfig{
+		Enabled: conf.RiskEngine.TacticDiversity.Enabled,
+		Window:  time.Duration(conf.RiskEngine.TacticDiversity.WindowHours) * time.Hour,
+		Limit:   conf.RiskEngine.TacticDiversity.Limit,
+		Bonus:   conf.RiskEngine.TacticDiversity.Bonus,
+		Alert:   conf.RiskEngine.TacticDiversity.Alert,
+	}
 	riskEngine := risk.NewEngine(db, riskCfg)
 
+	// Event ingestion queue; zero values use the pipeline defaults
+	ingestPipeline := ingest.NewPipeline(riskEngine, ingest.Config{
+		QueueSize:  conf.Ingestion.QueueSize,
+		Workers:    conf.Ingestion.Workers,
+		BatchSize:  conf.Ingestion.BatchSize,
+		BatchWait:  time.Duration(conf.Ingestion.BatchWaitMs) * time.Millisecond,
+		RetryAfter: time.Duration(conf.Ingestion.RetryAfterSeconds) * time.Second,
+	})
+
+	// Optional listeners for syslog/CEF and Splunk HEC senders
+	var syslogListener *ingest.SyslogListener
+	if conf.Ingestion.Syslog.Enabled {
+		syslogListener = ingest.NewSyslogListener(ingestPipeline, ingest.SyslogConfig{
+			UDPAddr: conf.Ingestion.Syslog.UDPAddr,
+			TCPAddr: conf.Ingestion.Syslog.TCPAddr,
+			Mapping: conf.Ingestion.Syslog.Mapping.apply(ingest.DefaultSyslogMapping()),
+		})
+	}
+	var hecListener *ingest.HECListener
+	if conf.Ingestion.HEC.Enabled {
+		hecListener = ingest.NewHECListener(ingestPipeline, ingest.HECConfig{
+			Addr:    conf.Ingestion.HEC.Addr,
+			Tokens:  conf.Ingestion.HEC.Tokens,
+			Mapping: conf.Ingestion.HEC.Mapping.apply(ingest.DefaultHECMapping()),
+		})
+	}
+
+	lifecycle := detection.NewLifecycle(detectionRepo, conf.lifecycleConfig())
+
 	// Create cache with 5 minute TTL
 	apiCache := cache.New(5 * time.Minute)
 
@@ -92,10 +332,15 @@ func NewServer(db *database.DB) *Server {
 	server := &Server{
 		db:             db,
 		detectionRepo:  detectionRepo,
+		lifecycle:      lifecycle,
 		mitreRepo:      mitreRepo,
 		dataSourceRepo: dataSourceRepo,
 		riskRepo:       riskRepo,
 		riskEngine:     riskEngine,
+		emulationRepo:  emulation.NewRepository(db),
+		ingest:         ingestPipeline,
+		syslog:         syslogListener,
+		hec:            hecListener,
 		router:         http.NewServeMux(),
 		cache:          apiCache,
 		preparedStmts:  preparedStmts,
@@ -114,10 +359,26 @@ func NewServer(db *database.DB) *Server {
 func (s *Server) setupRoutes() {
 	// Create handlers
 	detectionHandler := NewDetectionHandler(s.detectionRepo)
+	detectionHandler.lifecycle = s.lifecycle
 	detectionClassHandler := NewDetectionClassHandler(s.detectionRepo)
 	mitreHandler := NewMitreHandler(s.mitreRepo)
 	dataSourceHandler := NewDataSourceHandler(s.dataSourceRepo)
 	riskHandler := NewRiskHandler(s.riskEngine, s.riskRepo)
+	riskHandler.ingest = s.ingest
+	dataSourceHandler.detectionRepo = s.detectionRepo
+	dataSourceHandler.risk = riskHandler
+	riskThresholdHandler := NewRiskThresholdHandler(s.riskRepo)
+	alertRuleHandler := NewAlertRuleHandler(s.riskRepo)
+	inventoryHandler := NewInventoryHandler(inventory.NewRepository(s.db))
+	emulationHandler := NewEmulationHandler(s.emulationRepo)
+	mitreHandler.emulation = s.emulationRepo
+	sigmaRepo := sigma.NewRepository(s.db)
+	sigmaRepo.SetLifecycle(s.lifecycle)
+	sigmaHandler := NewSigmaHandler(sigmaRepo, s.detectionRepo)
+	syncRepo := rulesync.NewRepository(s.db)
+	syncRepo.SetLifecycle(s.lifecycle)
+	detectionSyncHandler := NewDetectionSyncHandler(syncRepo)
+	detectionTestHandler := NewDetectionTestHandler(detectiontest.NewRepository(s.db), s.detectionRepo)
 
 	// Static files
 	s.router.Handle("/", http.FileServer(http.Dir("web/static")))
@@ -132,10 +393,29 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("POST /api/detections", detectionHandler.CreateDetection)
 	s.router.HandleFunc("GET /api/detections/count", detectionHandler.GetDetectionCount)
 	s.router.HandleFunc("GET /api/detections/count/status", detectionHandler.GetDetectionCountByStatus)
+	s.router.HandleFunc("GET /api/detections/sigma", sigmaHandler.ExportRules)
+	s.router.HandleFunc("POST /api/detections/sigma", sigmaHandler.ImportRules)
+	s.router.HandleFunc("POST /api/detections/sync", detectionSyncHandler.SyncDetections)
+	s.router.HandleFunc("GET /api/detections/lifecycle", detectionHandler.GetLifecycle)
 	s.router.HandleFunc("GET /api/detections/{id}", detectionHandler.GetDetection)
 	s.router.HandleFunc("PUT /api/detections/{id}", detectionHandler.UpdateDetection)
 	s.router.HandleFunc("DELETE /api/detections/{id}", detectionHandler.DeleteDetection)
 	s.router.HandleFunc("GET /api/detections/{id}/fp-rate", detectionHandler.GetFalsePositiveRate)
+	s.router.HandleFunc("GET /api/detections/{id}/sigma", sigmaHandler.ExportRule)
+	s.router.HandleFunc("GET /api/detections/{id}/revisions", detectionHandler.ListRevisions)
+	s.router.HandleFunc("GET /api/detections/{id}/revisions/diff", detectionHandler.DiffRevisions)
+	s.router.HandleFunc("GET /api/detections/{id}/revisions/{revision}", detectionHandler.GetRevision)
+	s.router.HandleFunc("POST /api/detections/{id}/revisions/{revision}/revert", detectionHandler.RevertDetection)
+	s.router.HandleFunc("GET /api/detections/{id}/transitions", detectionHandler.ListTransitions)
+	s.router.HandleFunc("POST /api/detections/{id}/transitions", detectionHandler.TransitionDetection)
+	s.router.HandleFunc("GET /api/detections/{id}/tests", detectionTestHandler.ListTestCases)
+	s.router.HandleFunc("POST /api/detections/{id}/tests", detectionTestHandler.CreateTestCase)
+	s.router.HandleFunc("GET /api/detections/{id}/tests/{test_id}", detectionTestHandler.GetTestCase)
+	s.router.HandleFunc("PUT /api/detections/{id}/tests/{test_id}", detectionTestHandler.UpdateTestCase)
+	s.router.HandleFunc("DELETE /api/detections/{id}/tests/{test_id}", detectionTestHandler.DeleteTestCase)
+	s.router.HandleFunc("GET /api/detections/{id}/test-runs", detectionTestHandler.ListTestRuns)
+	s.router.HandleFunc("POST /api/detections/{id}/test-runs", detectionTestHandler.RunTests)
+	s.router.HandleFunc("GET /api/detections/{id}/test-runs/{run_id}", detectionTestHandler.GetTestRun)
 	s.router.HandleFunc("GET /api/detections/{id}/events/count/30days", detectionHandler.GetEventCountLast30Days)
 	s.router.HandleFunc("GET /api/detections/{id}/false-positives/count/30days", detectionHandler.GetFalsePositivesLast30Days)
 	s.router.HandleFunc("POST /api/detections/{id}/mitre/{technique_id}", detectionHandler.AddMitreTechnique)
@@ -143,6 +423,12 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("POST /api/detections/{id}/datasource/{datasource_id}", detectionHandler.AddDataSource)
 	s.router.HandleFunc("DELETE /api/detections/{id}/datasource/{datasource_id}", detectionHandler.RemoveDataSource)
 
+	// API routes - Detection Approvals
+	s.router.HandleFunc("GET /api/detection-approvals", detectionHandler.ListApprovals)
+	s.router.HandleFunc("GET /api/detection-approvals/{id}", detectionHandler.GetApproval)
+	s.router.HandleFunc("POST /api/detection-approvals/{id}/approve", detectionHandler.ApproveApproval)
+	s.router.HandleFunc("POST /api/detection-approvals/{id}/reject", detectionHandler.RejectApproval)
+
 	// API routes - Detection Classes
 	s.router.HandleFunc("GET /api/detection-classes", detectionClassHandler.ListDetectionClasses)
 	s.router.HandleFunc("POST /api/detection-classes", detectionClassHandler.CreateDetectionClass)
@@ -160,6 +446,33 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("GET /api/mitre/techniques/{id}/detections", mitreHandler.GetDetectionsByTechnique)
 	s.router.HandleFunc("GET /api/mitre/coverage", mitreHandler.GetCoverageByTactic)
 	s.router.HandleFunc("GET /api/mitre/coverage/summary", mitreHandler.GetCoverageSummary)
+	s.router.HandleFunc("GET /api/mitre/coverage/tree", mitreHandler.GetCoverageTree)
+	s.router.HandleFunc("GET /api/mitre/coverage/validation", mitreHandler.GetValidatedCoverage)
+	s.router.HandleFunc("GET /api/mitre/remaps", mitreHandler.ListRemaps)
+	s.router.HandleFunc("POST /api/mitre/remaps/apply", mitreHandler.ApplyRemaps)
+	s.router.HandleFunc("GET /api/mitre/remaps/history", mitreHandler.ListAppliedRemaps)
+	s.router.HandleFunc("GET /api/mitre/navigator-layer", mitreHandler.GetNavigatorLayer)
+	s.router.HandleFunc("GET /api/mitre/priority-lists", mitreHandler.ListPriorityLists)
+	s.router.HandleFunc("POST /api/mitre/priority-lists", mitreHandler.ImportPriorityList)
+	s.router.HandleFunc("GET /api/mitre/priority-lists/{id}", mitreHandler.GetPriorityList)
+	s.router.HandleFunc("DELETE /api/mitre/priority-lists/{id}", mitreHandler.DeletePriorityList)
+	s.router.HandleFunc("GET /api/mitre/priority-lists/{id}/coverage", mitreHandler.GetPriorityListCoverage)
+	s.router.HandleFunc("GET /api/mitre/groups", mitreHandler.ListThreatGroups)
+	s.router.HandleFunc("POST /api/mitre/groups/import", mitreHandler.ImportThreatGroups)
+	s.router.HandleFunc("GET /api/mitre/groups/{id}", mitreHandler.GetThreatGroup)
+	s.router.HandleFunc("GET /api/mitre/threat-profiles", mitreHandler.ListThreatProfiles)
+	s.router.HandleFunc("POST /api/mitre/threat-profiles", mitreHandler.CreateThreatProfile)
+	s.router.HandleFunc("GET /api/mitre/threat-profiles/{id}", mitreHandler.GetThreatProfile)
+	s.router.HandleFunc("PUT /api/mitre/threat-profiles/{id}", mitreHandler.UpdateThreatProfile)
+	s.router.HandleFunc("DELETE /api/mitre/threat-profiles/{id}", mitreHandler.DeleteThreatProfile)
+	s.router.HandleFunc("GET /api/mitre/threat-profiles/{id}/coverage", mitreHandler.GetThreatProfileCoverage)
+
+	// API routes - Adversary emulation tests
+	s.router.HandleFunc("GET /api/emulation-tests", emulationHandler.ListEmulationTests)
+	s.router.HandleFunc("POST /api/emulation-tests", emulationHandler.CreateEmulationTest)
+	s.router.HandleFunc("GET /api/emulation-tests/{id}", emulationHandler.GetEmulationTest)
+	s.router.HandleFunc("DELETE /api/emulation-tests/{id}", emulationHandler.DeleteEmulationTest)
+	s.router.HandleFunc("POST /api/emulation-tests/{id}/check", emulationHandler.CheckEmulationTest)
 
 	// API routes - Data Sources
 	s.router.HandleFunc("GET /api/datasources", dataSourceHandler.ListDataSources)
@@ -171,6 +484,8 @@ func (s *Server) setupRoutes() {
 	// Routes with {id} and additional path segments
 	s.router.HandleFunc("GET /api/datasources/{id}/detections", dataSourceHandler.GetDetectionsByDataSource)
 	s.router.HandleFunc("GET /api/datasources/{id}/techniques", dataSourceHandler.GetMitreTechniquesByDataSource)
+	s.router.HandleFunc("POST /api/datasources/{id}/mapping/test", dataSourceHandler.TestMapping)
+	s.router.HandleFunc("POST /api/datasources/{id}/events", dataSourceHandler.IngestRawEvents)
 	// Base {id} routes
 	s.router.HandleFunc("GET /api/datasources/{id}", dataSourceHandler.GetDataSource)
 	s.router.HandleFunc("PUT /api/datasources/{id}", dataSourceHandler.UpdateDataSource)
@@ -186,6 +501,9 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("DELETE /api/events/{id}/false-positive", riskHandler.UnmarkEventAsFalsePositive)
 	s.router.HandleFunc("GET /api/risk/objects", riskHandler.ListRiskObjects)
 	s.router.HandleFunc("GET /api/risk/objects/{id}", riskHandler.GetRiskObject)
+	s.router.HandleFunc("GET /api/risk/objects/{id}/history", riskHandler.GetRiskObjectHistory)
+	s.router.HandleFunc("PUT /api/risk/objects/{id}/score", riskHandler.SetRiskScore)
+	s.router.HandleFunc("GET /api/risk/objects/{id}/group", riskHandler.GetRiskObjectGroup)
 	s.router.HandleFunc("GET /api/risk/objects/entity", riskHandler.GetRiskObjectByEntity)
 	s.router.HandleFunc("GET /api/risk/alerts", riskHandler.ListRiskAlerts)
 	s.router.HandleFunc("GET /api/risk/alerts/{id}", riskHandler.GetRiskAlert)
@@ -193,6 +511,31 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("GET /api/risk/alerts/{id}/events", riskHandler.GetEventsForAlert)
 	s.router.HandleFunc("POST /api/risk/decay", riskHandler.DecayRiskScores)
 	s.router.HandleFunc("GET /api/risk/high", riskHandler.GetHighRiskEntities)
+	s.router.HandleFunc("GET /api/risk/groups", riskHandler.ListEntityGroups)
+	s.router.HandleFunc("GET /api/risk/groups/{id}", riskHandler.GetEntityGroup)
+
+	// API routes - Risk threshold policies
+	s.router.HandleFunc("GET /api/risk/thresholds", riskThresholdHandler.ListRiskThresholds)
+	s.router.HandleFunc("POST /api/risk/thresholds", riskThresholdHandler.CreateRiskThreshold)
+	s.router.HandleFunc("GET /api/risk/thresholds/{id}", riskThresholdHandler.GetRiskThreshold)
+	s.router.HandleFunc("PUT /api/risk/thresholds/{id}", riskThresholdHandler.UpdateRiskThreshold)
+	s.router.HandleFunc("DELETE /api/risk/thresholds/{id}", riskThresholdHandler.DeleteRiskThreshold)
+
+	// API routes - Alert rules
+	s.router.HandleFunc("GET /api/risk/rules", alertRuleHandler.ListAlertRules)
+	s.router.HandleFunc("POST /api/risk/rules", alertRuleHandler.CreateAlertRule)
+	s.router.HandleFunc("GET /api/risk/rules/{id}", alertRuleHandler.GetAlertRule)
+	s.router.HandleFunc("PUT /api/risk/rules/{id}", alertRuleHandler.UpdateAlertRule)
+	s.router.HandleFunc("DELETE /api/risk/rules/{id}", alertRuleHandler.DeleteAlertRule)
+
+	// API routes - Asset and identity inventory
+	s.router.HandleFunc("GET /api/inventory", inventoryHandler.ListInventoryEntries)
+	s.router.HandleFunc("POST /api/inventory", inventoryHandler.CreateInventoryEntry)
+	s.router.HandleFunc("POST /api/inventory/import", inventoryHandler.ImportInventory)
+	s.router.HandleFunc("GET /api/inventory/match", inventoryHandler.MatchInventoryEntry)
+	s.router.HandleFunc("GET /api/inventory/{id}", inventoryHandler.GetInventoryEntry)
+	s.router.HandleFunc("PUT /api/inventory/{id}", inventoryHandler.UpdateInventoryEntry)
+	s.router.HandleFunc("DELETE /api/inventory/{id}", inventoryHandler.DeleteInventoryEntry)
 }
 
 // setupMiddleware sets up the middleware chain
@@ -280,9 +623,58 @@ func (s *Server) Start(addr string) error {
 	return srv.ListenAndServe()
 }
 
+// StartIngestion starts the event ingestion workers and any enabled syslog
+// and HEC listeners. The returned function stops the listeners and waits for
+// the queued events to be processed.
+func (s *Server) StartIngestion() (func(), error) {
+	s.ingest.Start()
+
+	stop := func() {
+		if s.syslog != nil {
+			s.syslog.Stop()
+		}
+		if s.hec != nil {
+			s.hec.Stop()
+		}
+		s.ingest.Stop()
+	}
+
+	if s.syslog != nil {
+		if err := s.syslog.Start(); err != nil {
+			s.syslog = nil
+			stop()
+			return nil, err
+		}
+		if addr := s.syslog.UDPAddr(); addr != nil {
+			log.Printf("Syslog listener started on udp %s", addr)
+		}
+		if addr := s.syslog.TCPAddr(); addr != nil {
+			log.Printf("Syslog listener started on tcp %s", addr)
+		}
+	}
+	if s.hec != nil {
+		if err := s.hec.Start(); err != nil {
+			s.hec = nil
+			stop()
+			return nil, err
+		}
+		log.Printf("HEC listener started on %s", s.hec.Addr())
+	}
+
+	return stop, nil
+}
+
 // StartRiskDecayProcess starts the background process to decay risk scores
 func (s *Server) StartRiskDecayProcess() chan struct{} {
 	stop := make(chan struct{})
 	go s.riskEngine.StartDecayProcess(stop)
 	return stop
 }
+
+// StartEmulationCheckProcess starts the background process that checks
+// pending emulation tests
+func (s *Server) StartEmulationCheckProcess() chan struct{} {
+	stop := make(chan struct{})
+	go s.emulationRepo.StartCheckProcess(emulationCheckInterval, stop)
+	return stop
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/sigma"
+)
+
+// maxSigmaBody is the largest body accepted by the Sigma import endpoint
+const maxSigmaBody = 10 * 1024 * 1024
+
+// SigmaHandler handles HTTP requests for importing and exporting detections
+// as Sigma rules
+type SigmaHandler struct {
+	repo          *sigma.Repository
+	detectionRepo *detection.Repository
+}
+
+// NewSigmaHandler creates a new Sigma handler
+func NewSigmaHandler(repo *sigma.Repository, detectionRepo *detection.Repository) *SigmaHandler {
+	return &SigmaHandler{repo: repo, detectionRepo: detectionRepo}
+}
+
+// ImportRules handles POST /api/detections/sigma. The body holds one or more
+// Sigma rules as YAML documents.
+func (h *SigmaHandler) ImportRules(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSigmaBody))
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rules, err := sigma.Parse(data)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results := h.repo.Import(rules)
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.Action]++
+	}
+
+	JSON(w, http.StatusOK, map[string]interface{}{
+		"created": counts[sigma.ImportCreated],
+		"updated": counts[sigma.ImportUpdated],
+		"failed":  counts[sigma.ImportFailed],
+		"results": results,
+	})
+}
+
+// ExportRule handles GET /api/detections/{id}/sigma
+func (h *SigmaHandler) ExportRule(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		Error(w, r, http.StatusBadRequest, "Invalid detection ID")
+		return
+	}
+
+	// Make sure the detection exists before exporting it
+	if _, err := h.detectionRepo.GetDetection(id); err != nil {
+		Error(w, r, http.StatusNotFound, "Detection not found")
+		return
+	}
+
+	rule, err := h.repo.Export(id)
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error exporting detection")
+		return
+	}
+	data, err := rule.Render()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error exporting detection")
+		return
+	}
+
+	writeYAML(w, data)
+}
+
+// ExportRules handles GET /api/detections/sigma, exporting every detection
+// as one YAML document each
+func (h *SigmaHandler) ExportRules(w http.ResponseWriter, r *http.Request) {
+	detections, err := h.detectionRepo.ListDetections()
+	if err != nil {
+		Error(w, r, http.StatusInternalServerError, "Error retrieving detections")
+		return
+	}
+
+	data := make([]byte, 0)
+	for i, det := range detections {
+		rule, err := h.repo.Export(det.ID)
+		if err != nil {
+			Error(w, r, http.StatusInternalServerError, "Error exporting detections")
+			return
+		}
+		document, err := rule.Render()
+		if err != nil {
+			Error(w, r, http.StatusInternalServerError, "Error exporting detections")
+			return
+		}
+
+		if i > 0 {
+			data = append(data, "---\n"...)
+		}
+		data = append(data, document...)
+	}
+
+	writeYAML(w, data)
+}
+
+// writeYAML writes a YAML response
+func writeYAML(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
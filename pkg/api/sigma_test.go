@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"riskmatrix/internal/detection"
+	"riskmatrix/internal/sigma"
+	"riskmatrix/pkg/database"
+)
+
+// setupSigmaTestHandler creates a Sigma handler with test database
+func setupSigmaTestHandler(t *testing.T) (*SigmaHandler, *database.DB) {
+	db := setupTestDB(t)
+	handler := NewSigmaHandler(sigma.NewRepository(db), detection.NewRepository(db))
+	return handler, db
+}
+
+func TestSigmaHandler_ImportAndExport(t *testing.T) {
+	handler, db := setupSigmaTestHandler(t)
+	defer db.Close()
+
+	rules := `title: Failed Logons
+id: 0f6a1b2c-3d4e-5f60-7182-93a4b5c6d7e8
+status: experimental
+logsource:
+    product: windows
+    service: security
+detection:
+    selection:
+        EventID: 4625
+    condition: selection
+level: high
+---
+title: ""
+detection:
+    condition: x
+`
+
+	req := httptest.NewRequest("POST", "/api/detections/sigma", strings.NewReader(rules))
+	w := httptest.NewRecorder()
+	handler.ImportRules(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a rule without a title, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	rules = strings.Split(rules, "---")[0]
+	req = httptest.NewRequest("POST", "/api/detections/sigma", strings.NewReader(rules))
+	w = httptest.NewRecorder()
+	handler.ImportRules(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Created int                  `json:"created"`
+		Results []sigma.ImportResult `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Created != 1 || len(response.Results) != 1 || response.Results[0].DetectionID == 0 {
+		t.Fatalf("Unexpected response %+v", response)
+	}
+
+	id := strconv.FormatInt(response.Results[0].DetectionID, 10)
+	req = httptest.NewRequest("GET", "/api/detections/"+id+"/sigma", nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.ExportRule(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/yaml" {
+		t.Errorf("Expected YAML, got %s", contentType)
+	}
+	for _, expected := range []string{"title: Failed Logons", "service: security", "EventID: 4625", "level: high"} {
+		if !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("Expected export to contain %q:\n%s", expected, w.Body.String())
+		}
+	}
+
+	// Every detection is exported, including those created in the catalog
+	createTestDetection(t, db)
+	req = httptest.NewRequest("GET", "/api/detections/sigma", nil)
+	w = httptest.NewRecorder()
+	handler.ExportRules(w, req)
+	exported, err := sigma.Parse(bytes.TrimSpace(w.Body.Bytes()))
+	if err != nil || len(exported) != 2 {
+		t.Fatalf("Expected 2 exported rules, got %d: %v", len(exported), err)
+	}
+
+	req = httptest.NewRequest("GET", "/api/detections/999/sigma", nil)
+	req.SetPathValue("id", "999")
+	w = httptest.NewRecorder()
+	handler.ExportRule(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
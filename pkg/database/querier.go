@@ -0,0 +1,20 @@
+package database
+
+import "database/sql"
+
+// RowScanner is satisfied by both *sql.Row and *sql.Rows
+type RowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Queryer runs queries on the database or within a transaction; it is
+// satisfied by both *DB and *sql.Tx
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Execer runs statements on the database or within a transaction
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
@@ -18,6 +18,7 @@ CREATE TABLE IF NOT EXISTS detections (
     event_count_last_30_days INTEGER NOT NULL DEFAULT 0,
     false_positives_last_30_days INTEGER NOT NULL DEFAULT 0,
     class_id INTEGER REFERENCES detection_classes(id),
+    external_id TEXT UNIQUE, -- stable UUID used to sync detections from rule files
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
@@ -35,7 +36,11 @@ CREATE TABLE IF NOT EXISTS mitre_techniques (
     platforms TEXT, -- JSON array of affected platforms
     data_sources TEXT, -- JSON array of data sources
     is_sub_technique BOOLEAN NOT NULL DEFAULT 0,
-    sub_technique_of TEXT -- Parent technique ID if this is a sub-technique
+    sub_technique_of TEXT, -- Parent technique ID if this is a sub-technique
+    revoked BOOLEAN NOT NULL DEFAULT 0, -- Revoked by MITRE, usually in favour of another technique
+    deprecated BOOLEAN NOT NULL DEFAULT 0, -- Deprecated by MITRE
+    revoked_by TEXT, -- Technique ID replacing a revoked technique
+    attack_version TEXT -- ATT&CK release the technique was last imported from, e.g. 17.1
 );
 
 -- Detection to MITRE Technique mapping
@@ -52,7 +57,8 @@ CREATE TABLE IF NOT EXISTS data_sources (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL UNIQUE,
     description TEXT,
-    log_format TEXT
+    log_format TEXT,
+    mapping TEXT -- JSON field-mapping profile for normalizing raw records
 );
 
 -- Detection to Data Source mapping
@@ -84,6 +90,7 @@ CREATE TABLE IF NOT EXISTS events (
     context TEXT, -- JSON field for detection context information
     risk_points INTEGER NOT NULL DEFAULT 0,
     is_false_positive BOOLEAN NOT NULL DEFAULT 0,
+    risk_breakdown TEXT, -- JSON explanation of how risk_points was calculated
     FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE,
     FOREIGN KEY (entity_id) REFERENCES risk_objects(id) ON DELETE CASCADE
 );
@@ -97,7 +104,22 @@ CREATE TABLE IF NOT EXISTS risk_alerts (
     status TEXT NOT NULL DEFAULT 'New' CHECK (status IN ('New', 'Triage', 'Investigation', 'On Hold', 'Incident', 'Closed')),
     notes TEXT,
     owner TEXT,
-    FOREIGN KEY (entity_id) REFERENCES risk_objects(id) ON DELETE CASCADE
+    closed_at TIMESTAMP, -- set when status becomes Closed
+    group_id INTEGER, -- set for alerts raised on a correlated entity group
+    rule_id INTEGER, -- set for alerts raised by an alert rule
+    FOREIGN KEY (entity_id) REFERENCES risk_objects(id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES entity_groups(id) ON DELETE SET NULL,
+    FOREIGN KEY (rule_id) REFERENCES alert_rules(id) ON DELETE SET NULL
+);
+
+-- Events that contributed to a risk alert
+CREATE TABLE IF NOT EXISTS risk_alert_events (
+    alert_id INTEGER NOT NULL,
+    event_id INTEGER NOT NULL,
+    risk_points INTEGER NOT NULL DEFAULT 0, -- points the event contributed to the alert score
+    PRIMARY KEY (alert_id, event_id),
+    FOREIGN KEY (alert_id) REFERENCES risk_alerts(id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
 );
 
 -- False Positives
@@ -123,6 +145,289 @@ CREATE TABLE IF NOT EXISTS detection_classes (
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Risk threshold policies (override the global threshold for matching entities)
+CREATE TABLE IF NOT EXISTS risk_thresholds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    entity_type TEXT, -- user, host, ip; NULL matches any type
+    value_pattern TEXT, -- glob matched against entity_value
+    tag TEXT, -- inventory tag the entity must carry
+    detection_class_id INTEGER REFERENCES detection_classes(id) ON DELETE CASCADE,
+    threshold INTEGER NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Risk score changes per entity, used to chart score trends
+CREATE TABLE IF NOT EXISTS risk_score_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity_id INTEGER NOT NULL,
+    recorded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    old_score INTEGER NOT NULL,
+    new_score INTEGER NOT NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('event', 'decay', 'false_positive', 'false_positive_removed', 'manual')),
+    event_id INTEGER, -- event that caused the change, if any
+    notes TEXT,
+    FOREIGN KEY (entity_id) REFERENCES risk_objects(id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE SET NULL
+);
+
+-- Asset and identity inventory used to enrich risk objects
+CREATE TABLE IF NOT EXISTS inventory (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity_type TEXT NOT NULL, -- user, host, ip
+    entity_value TEXT NOT NULL, -- exact value, or a CIDR range for ip
+    owner TEXT,
+    department TEXT,
+    business_unit TEXT,
+    criticality TEXT CHECK (criticality IN ('low', 'medium', 'high', 'critical')),
+    tags TEXT, -- JSON array of strings
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(entity_type, entity_value)
+);
+
+-- Groups of entities correlated by appearing together in event context
+CREATE TABLE IF NOT EXISTS entity_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Entity group membership; an entity belongs to at most one group
+CREATE TABLE IF NOT EXISTS entity_group_members (
+    entity_id INTEGER PRIMARY KEY,
+    group_id INTEGER NOT NULL,
+    linked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (entity_id) REFERENCES risk_objects(id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES entity_groups(id) ON DELETE CASCADE
+);
+
+-- Alert rules evaluated against an entity's recent events alongside the score threshold
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT,
+    rule_type TEXT NOT NULL CHECK (rule_type IN ('distinct_detections', 'distinct_tactics', 'tactic_sequence')),
+    entity_type TEXT, -- user, host, ip; NULL matches any type
+    min_count INTEGER NOT NULL DEFAULT 0, -- distinct detections or tactics required
+    sequence TEXT, -- JSON array of tactics that must occur in order
+    window_minutes INTEGER NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Idempotency keys of ingested events, so replayed events are not scored twice
+CREATE TABLE IF NOT EXISTS event_dedup_keys (
+    dedup_key TEXT PRIMARY KEY, -- client external_id, or a hash of detection, entity, timestamp and raw_data
+    event_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+-- Sigma rules detections were imported from, kept so exports carry the rule
+-- fields the catalog does not model
+CREATE TABLE IF NOT EXISTS detection_sigma_rules (
+    detection_id INTEGER PRIMARY KEY,
+    rule_id TEXT UNIQUE, -- the rule's id, used to match re-imports
+    rule TEXT NOT NULL, -- the rule as imported, in YAML
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Detection revisions, a snapshot of a detection after each change along with
+-- who made it and why
+CREATE TABLE IF NOT EXISTS detection_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL, -- 1 for the first revision of each detection
+    name TEXT NOT NULL,
+    description TEXT,
+    query TEXT,
+    status TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    risk_points INTEGER NOT NULL DEFAULT 0,
+    playbook_link TEXT,
+    owner TEXT,
+    risk_object TEXT,
+    testing_description TEXT,
+    class_id INTEGER,
+    external_id TEXT,
+    changed_by TEXT,
+    reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (detection_id, revision),
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Detection status transitions, recorded for every status change
+CREATE TABLE IF NOT EXISTS detection_transitions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    from_status TEXT, -- NULL when the detection was created
+    to_status TEXT NOT NULL,
+    actor TEXT,
+    comment TEXT,
+    approval_id INTEGER REFERENCES detection_approvals(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Requests to move a detection to a status that needs a reviewer's approval
+CREATE TABLE IF NOT EXISTS detection_approvals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    requested_by TEXT NOT NULL,
+    comment TEXT,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reviewer TEXT,
+    review_comment TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Sample log records with the result a detection should give for them
+CREATE TABLE IF NOT EXISTS detection_test_cases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    records TEXT NOT NULL, -- JSON array of log records
+    expect_match BOOLEAN NOT NULL DEFAULT 1,
+    expected_entity_type TEXT CHECK (expected_entity_type IN ('user', 'host', 'ip')),
+    expected_entity_value TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Runs of a detection's test cases against its query
+CREATE TABLE IF NOT EXISTS detection_test_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    query TEXT NOT NULL, -- the query as it was when tested
+    language TEXT,
+    status TEXT NOT NULL CHECK (status IN ('passed', 'failed', 'error')),
+    passed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    run_by TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Outcome of each test case in a run; kept when the test case is deleted
+CREATE TABLE IF NOT EXISTS detection_test_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL,
+    test_case_id INTEGER REFERENCES detection_test_cases(id) ON DELETE SET NULL,
+    test_case_name TEXT NOT NULL,
+    passed BOOLEAN NOT NULL,
+    matched BOOLEAN NOT NULL,
+    entity_type TEXT,
+    entity_value TEXT,
+    message TEXT,
+    FOREIGN KEY (run_id) REFERENCES detection_test_runs(id) ON DELETE CASCADE
+);
+
+-- Adversary emulation test executions, e.g. Atomic Red Team tests
+CREATE TABLE IF NOT EXISTS emulation_tests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    technique_id TEXT NOT NULL,
+    test_name TEXT NOT NULL,
+    target_host TEXT NOT NULL,
+    executed_at TIMESTAMP NOT NULL,
+    window_minutes INTEGER NOT NULL DEFAULT 60, -- how long after execution events count
+    executed_by TEXT,
+    notes TEXT,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'validated', 'partial', 'missed')),
+    checked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (technique_id) REFERENCES mitre_techniques(id) ON DELETE CASCADE
+);
+
+-- Detections an emulation test is expected to trigger, and whether they did
+CREATE TABLE IF NOT EXISTS emulation_test_detections (
+    emulation_test_id INTEGER NOT NULL,
+    detection_id INTEGER NOT NULL,
+    detected BOOLEAN NOT NULL DEFAULT 0,
+    event_id INTEGER REFERENCES events(id) ON DELETE SET NULL, -- first event for the target host in the window
+    detected_at TIMESTAMP,
+    PRIMARY KEY (emulation_test_id, detection_id),
+    FOREIGN KEY (emulation_test_id) REFERENCES emulation_tests(id) ON DELETE CASCADE,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Detection mappings moved off revoked techniques onto their replacements
+CREATE TABLE IF NOT EXISTS mitre_remaps (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detection_id INTEGER NOT NULL,
+    old_technique_id TEXT NOT NULL,
+    new_technique_id TEXT NOT NULL,
+    applied_by TEXT,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (detection_id) REFERENCES detections(id) ON DELETE CASCADE
+);
+
+-- Techniques to prioritize coverage of, imported from threat-intel ATT&CK Navigator layers
+CREATE TABLE IF NOT EXISTS priority_lists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    source TEXT, -- name of the layer the list was imported from
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS priority_list_techniques (
+    list_id INTEGER NOT NULL,
+    technique_id TEXT NOT NULL,
+    score REAL NOT NULL DEFAULT 0, -- layer score, e.g. number of groups using the technique
+    comment TEXT,
+    PRIMARY KEY (list_id, technique_id),
+    FOREIGN KEY (list_id) REFERENCES priority_lists(id) ON DELETE CASCADE,
+    FOREIGN KEY (technique_id) REFERENCES mitre_techniques(id) ON DELETE CASCADE
+);
+
+-- Adversary groups and the techniques they use, from ATT&CK intrusion sets or a local CSV
+CREATE TABLE IF NOT EXISTS threat_groups (
+    id TEXT PRIMARY KEY, -- e.g. G0016
+    name TEXT NOT NULL,
+    aliases TEXT, -- JSON array of aliases
+    description TEXT,
+    attack_version TEXT -- ATT&CK release the group was last imported from
+);
+
+CREATE TABLE IF NOT EXISTS threat_group_techniques (
+    group_id TEXT NOT NULL,
+    technique_id TEXT NOT NULL,
+    PRIMARY KEY (group_id, technique_id),
+    FOREIGN KEY (group_id) REFERENCES threat_groups(id) ON DELETE CASCADE,
+    FOREIGN KEY (technique_id) REFERENCES mitre_techniques(id) ON DELETE CASCADE
+);
+
+-- Threat profiles: the groups coverage is measured against
+CREATE TABLE IF NOT EXISTS threat_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS threat_profile_groups (
+    profile_id INTEGER NOT NULL,
+    group_id TEXT NOT NULL,
+    PRIMARY KEY (profile_id, group_id),
+    FOREIGN KEY (profile_id) REFERENCES threat_profiles(id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES threat_groups(id) ON DELETE CASCADE
+);
+
 -- Indexes for performance
 CREATE INDEX IF NOT EXISTS idx_detections_status ON detections(status);
 CREATE INDEX IF NOT EXISTS idx_events_detection_id ON events(detection_id);
@@ -130,4 +435,16 @@ CREATE INDEX IF NOT EXISTS idx_events_entity_id ON events(entity_id);
 CREATE INDEX IF NOT EXISTS idx_events_timestamp ON events(timestamp);
 CREATE INDEX IF NOT EXISTS idx_risk_objects_entity ON risk_objects(entity_type, entity_value);
 CREATE INDEX IF NOT EXISTS idx_risk_alerts_entity_id ON risk_alerts(entity_id);
-CREATE INDEX IF NOT EXISTS idx_false_positives_event_id ON false_positives(event_id);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_risk_alert_events_event_id ON risk_alert_events(event_id);
+CREATE INDEX IF NOT EXISTS idx_false_positives_event_id ON false_positives(event_id);
+CREATE INDEX IF NOT EXISTS idx_risk_score_history_entity ON risk_score_history(entity_id, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_entity_group_members_group_id ON entity_group_members(group_id);
+CREATE INDEX IF NOT EXISTS idx_event_dedup_keys_created_at ON event_dedup_keys(created_at);
+CREATE INDEX IF NOT EXISTS idx_detection_transitions_detection_id ON detection_transitions(detection_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_detection_approvals_pending ON detection_approvals(detection_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_detection_test_cases_detection_id ON detection_test_cases(detection_id);
+CREATE INDEX IF NOT EXISTS idx_detection_test_runs_detection_id ON detection_test_runs(detection_id);
+CREATE INDEX IF NOT EXISTS idx_detection_test_results_run_id ON detection_test_results(run_id);
+CREATE INDEX IF NOT EXISTS idx_emulation_tests_technique_id ON emulation_tests(technique_id, executed_at);
+CREATE INDEX IF NOT EXISTS idx_mitre_remaps_detection_id ON mitre_remaps(detection_id);
+CREATE INDEX IF NOT EXISTS idx_threat_group_techniques_technique_id ON threat_group_techniques(technique_id);
\ No newline at end of file
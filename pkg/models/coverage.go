@@ -0,0 +1,44 @@
+package models
+
+// CoverageMode selects which detections count towards technique coverage
+type CoverageMode string
+
+const (
+	// CoverageAny counts every detection that is not retired
+	CoverageAny CoverageMode = "any"
+	// CoverageProduction counts only production detections
+	CoverageProduction CoverageMode = "production"
+	// CoverageWeighted scores a technique by the quality of its best
+	// detection, from its status and false positive rate
+	CoverageWeighted CoverageMode = "weighted"
+)
+
+// TechniqueCoverageNode is a technique in the coverage tree. Scores run from
+// 0 to 1; a parent technique's coverage is its own score or the average of
+// its sub-techniques', whichever is higher.
+type TechniqueCoverageNode struct {
+	TechniqueID   string                   `json:"technique_id"`
+	Name          string                   `json:"name"`
+	Detections    int                      `json:"detections"` // detections counted under the mode
+	Score         float64                  `json:"score"`      // from the technique's own detections
+	Coverage      float64                  `json:"coverage"`   // including sub-techniques
+	Partial       bool                     `json:"partial"`    // some, but not full, coverage
+	SubTechniques []*TechniqueCoverageNode `json:"sub_techniques,omitempty"`
+}
+
+// TacticCoverageNode is a tactic in the coverage tree with the top-level
+// techniques under it. A technique is listed under each of its tactics.
+type TacticCoverageNode struct {
+	Tactic          string                   `json:"tactic"`
+	TechniqueCount  int                      `json:"technique_count"`
+	Covered         int                      `json:"covered"` // techniques fully covered
+	Partial         int                      `json:"partial"` // techniques partly covered
+	CoveragePercent float64                  `json:"coverage_percent"`
+	Techniques      []*TechniqueCoverageNode `json:"techniques"`
+}
+
+// CoverageTree is coverage by tactic, technique and sub-technique
+type CoverageTree struct {
+	Mode    CoverageMode          `json:"mode"`
+	Tactics []*TacticCoverageNode `json:"tactics"`
+}
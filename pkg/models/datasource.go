@@ -6,6 +6,36 @@ type DataSource struct {
 	Name        string `json:"name"` // e.g. sysmon, cloudtrail
 	Description string `json:"description,omitempty"`
 	LogFormat   string `json:"log_format,omitempty"`
+
+	// How raw records from this source are normalized into events
+	Mapping *DataSourceMapping `json:"mapping,omitempty"`
+}
+
+// FieldExtractor extracts a value from a raw record. Path is a JSONPath into
+// a JSON record, e.g. $.userIdentity.userName. Regex is applied to the value
+// at Path, or to the whole record when there is no path, and its "value"
+// named group, or else its first group, is the value extracted.
+type FieldExtractor struct {
+	Path  string `json:"path,omitempty"`
+	Regex string `json:"regex,omitempty"`
+}
+
+// DataSourceMapping is a profile of extractors that normalizes a data
+// source's raw records into events
+type DataSourceMapping struct {
+	User          *FieldExtractor `json:"user,omitempty"`
+	Host          *FieldExtractor `json:"host,omitempty"`
+	IP            *FieldExtractor `json:"ip,omitempty"`
+	Timestamp     *FieldExtractor `json:"timestamp,omitempty"`
+	DetectionName *FieldExtractor `json:"detection_name,omitempty"`
+
+	// Go time layout of extracted timestamps; RFC 3339, the CEF receipt
+	// time format and Unix seconds or milliseconds are accepted without one
+	TimestampFormat string `json:"timestamp_format,omitempty"`
+
+	// Entity types tried in order for the event's risk object (default
+	// user, host, ip)
+	EntityPriority []EntityType `json:"entity_priority,omitempty"`
 }
 
 // DataSourceRepository defines the interface for data source data access
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -63,6 +64,7 @@ type Detection struct {
 	EventCountLast30Days     int             `json:"event_count_last_30_days"`
 	FalsePositivesLast30Days int             `json:"false_positives_last_30_days"`
 	ClassID                  *int64          `json:"class_id,omitempty"`
+	ExternalID               string          `json:"external_id,omitempty"` // Stable UUID used to sync detections from rule files
 	CreatedAt                time.Time       `json:"created_at"`
 	UpdatedAt                time.Time       `json:"updated_at"`
 
@@ -72,6 +74,132 @@ type Detection struct {
 	DataSources     []DataSource     `json:"data_sources,omitempty"`
 }
 
+// DetectionRevision is an immutable snapshot of a detection after a change,
+// recording who made the change and why
+type DetectionRevision struct {
+	ID                 int64           `json:"id"`
+	DetectionID        int64           `json:"detection_id"`
+	Revision           int             `json:"revision"`
+	Name               string          `json:"name"`
+	Description        string          `json:"description"`
+	Query              string          `json:"query,omitempty"`
+	Status             DetectionStatus `json:"status"`
+	Severity           Severity        `json:"severity"`
+	RiskPoints         int             `json:"risk_points"`
+	PlaybookLink       string          `json:"playbook_link,omitempty"`
+	Owner              string          `json:"owner,omitempty"`
+	RiskObject         RiskObjectType  `json:"risk_object,omitempty"`
+	TestingDescription string          `json:"testing_description,omitempty"`
+	ClassID            *int64          `json:"class_id,omitempty"`
+	ExternalID         string          `json:"external_id,omitempty"`
+	ChangedBy          string          `json:"changed_by,omitempty"`
+	Reason             string          `json:"reason,omitempty"`
+	CreatedAt          time.Time       `json:"created_at"`
+}
+
+// DetectionChange is a field that differs between two detection revisions
+type DetectionChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// DetectionTransition records a change of a detection's status
+type DetectionTransition struct {
+	ID          int64           `json:"id"`
+	DetectionID int64           `json:"detection_id"`
+	FromStatus  DetectionStatus `json:"from_status,omitempty"` // empty when the detection was created
+	ToStatus    DetectionStatus `json:"to_status"`
+	Actor       string          `json:"actor,omitempty"`
+	Comment     string          `json:"comment,omitempty"`
+	ApprovalID  *int64          `json:"approval_id,omitempty"`
+	ApprovedBy  string          `json:"approved_by,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// ApprovalStatus represents the state of a detection approval request
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+)
+
+// DetectionApproval is a request to move a detection to a status that needs
+// a reviewer's approval, such as production
+type DetectionApproval struct {
+	ID            int64           `json:"id"`
+	DetectionID   int64           `json:"detection_id"`
+	FromStatus    DetectionStatus `json:"from_status"`
+	ToStatus      DetectionStatus `json:"to_status"`
+	RequestedBy   string          `json:"requested_by"`
+	Comment       string          `json:"comment,omitempty"`
+	Status        ApprovalStatus  `json:"status"`
+	Reviewer      string          `json:"reviewer,omitempty"`
+	ReviewComment string          `json:"review_comment,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ReviewedAt    *time.Time      `json:"reviewed_at,omitempty"`
+}
+
+// ExpectedEntity is the entity a detection test case expects risk to be
+// attributed to
+type ExpectedEntity struct {
+	EntityType  EntityType `json:"entity_type"`
+	EntityValue string     `json:"entity_value"`
+}
+
+// DetectionTestCase is a set of sample log records and the result a
+// detection's query should give for them
+type DetectionTestCase struct {
+	ID             int64             `json:"id"`
+	DetectionID    int64             `json:"detection_id"`
+	Name           string            `json:"name"`
+	Description    string            `json:"description,omitempty"`
+	Records        []json.RawMessage `json:"records"`                   // JSON log records
+	ExpectMatch    bool              `json:"expect_match"`              // whether any record should match
+	ExpectedEntity *ExpectedEntity   `json:"expected_entity,omitempty"` // entity extracted from the first match
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// TestRunStatus represents the outcome of a detection test run
+type TestRunStatus string
+
+const (
+	TestRunPassed TestRunStatus = "passed"
+	TestRunFailed TestRunStatus = "failed"
+	TestRunError  TestRunStatus = "error" // the query could not be evaluated
+)
+
+// DetectionTestRun records a run of a detection's test cases against its
+// query
+type DetectionTestRun struct {
+	ID          int64                  `json:"id"`
+	DetectionID int64                  `json:"detection_id"`
+	Query       string                 `json:"query"`
+	Language    string                 `json:"language,omitempty"`
+	Status      TestRunStatus          `json:"status"`
+	Passed      int                    `json:"passed"`
+	Failed      int                    `json:"failed"`
+	Error       string                 `json:"error,omitempty"`
+	RunBy       string                 `json:"run_by,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	Results     []*DetectionTestResult `json:"results,omitempty"`
+}
+
+// DetectionTestResult is the outcome of one test case in a run
+type DetectionTestResult struct {
+	ID           int64           `json:"id"`
+	RunID        int64           `json:"run_id"`
+	TestCaseID   *int64          `json:"test_case_id,omitempty"` // nil once the test case is deleted
+	TestCaseName string          `json:"test_case_name"`
+	Passed       bool            `json:"passed"`
+	Matched      bool            `json:"matched"`
+	Entity       *ExpectedEntity `json:"entity,omitempty"` // entity extracted from the first match
+	Message      string          `json:"message,omitempty"`
+}
+
 // DetectionRepository defines the interface for detection data access
 type DetectionRepository interface {
 	// Basic CRUD operations
@@ -82,6 +210,11 @@ type DetectionRepository interface {
 	UpdateDetection(detection *Detection) error
 	DeleteDetection(id int64) error
 
+	// Revision operations
+	ListRevisions(detectionID int64) ([]*DetectionRevision, error)
+	GetRevision(detectionID int64, revision int) (*DetectionRevision, error)
+	RevertDetection(detectionID int64, revision int, changedBy, reason string) (*Detection, error)
+
 	// Relationship operations
 	AddMitreTechnique(detectionID int64, mitreID string) error
 	RemoveMitreTechnique(detectionID int64, mitreID string) error
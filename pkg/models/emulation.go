@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// EmulationStatus is the outcome of checking an emulation test for the
+// detections it was expected to trigger
+type EmulationStatus string
+
+const (
+	EmulationPending   EmulationStatus = "pending"   // detection window still open
+	EmulationValidated EmulationStatus = "validated" // every expected detection fired
+	EmulationPartial   EmulationStatus = "partial"   // some expected detections fired
+	EmulationMissed    EmulationStatus = "missed"    // no expected detection fired
+)
+
+// EmulationTest records one execution of an adversary emulation test, such as
+// an Atomic Red Team test, against a host
+type EmulationTest struct {
+	ID                 int64                 `json:"id"`
+	TechniqueID        string                `json:"technique_id"`
+	TestName           string                `json:"test_name"`
+	TargetHost         string                `json:"target_host"`
+	ExecutedAt         time.Time             `json:"executed_at"`
+	WindowMinutes      int                   `json:"window_minutes"` // how long after execution events count
+	ExecutedBy         string                `json:"executed_by,omitempty"`
+	Notes              string                `json:"notes,omitempty"`
+	Status             EmulationStatus       `json:"status"`
+	CheckedAt          *time.Time            `json:"checked_at,omitempty"`
+	CreatedAt          time.Time             `json:"created_at"`
+	ExpectedDetections []*EmulationDetection `json:"expected_detections"`
+}
+
+// WindowEnd returns the end of the window in which events count towards the test
+func (t *EmulationTest) WindowEnd() time.Time {
+	return t.ExecutedAt.Add(time.Duration(t.WindowMinutes) * time.Minute)
+}
+
+// EmulationDetection is a detection an emulation test is expected to trigger,
+// with the first event it produced for the target host in the window
+type EmulationDetection struct {
+	DetectionID   int64      `json:"detection_id"`
+	DetectionName string     `json:"detection_name,omitempty"`
+	Detected      bool       `json:"detected"`
+	EventID       *int64     `json:"event_id,omitempty"`
+	DetectedAt    *time.Time `json:"detected_at,omitempty"`
+}
+
+// TechniqueValidation is the validated coverage status of a MITRE technique
+type TechniqueValidation string
+
+const (
+	TechniqueValidated  TechniqueValidation = "validated"   // latest emulation test triggered every expected detection
+	TechniqueMappedOnly TechniqueValidation = "mapped_only" // production detections mapped, but not validated
+	TechniqueNotCovered TechniqueValidation = "not_covered" // no production detections mapped
+)
+
+// TechniqueValidationCoverage is a technique's coverage status, with the
+// latest completed emulation test it is based on
+type TechniqueValidationCoverage struct {
+	TechniqueID    string              `json:"technique_id"`
+	Name           string              `json:"name"`
+	Tactic         string              `json:"tactic"`
+	Status         TechniqueValidation `json:"status"`
+	Detections     int                 `json:"detections"` // production detections mapped to the technique
+	LastTestID     *int64              `json:"last_test_id,omitempty"`
+	LastTestStatus EmulationStatus     `json:"last_test_status,omitempty"`
+	LastExecutedAt *time.Time          `json:"last_executed_at,omitempty"`
+}
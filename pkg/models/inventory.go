@@ -0,0 +1,56 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Criticality represents how important an asset or identity is to the business
+type Criticality string
+
+const (
+	CriticalityLow      Criticality = "low"
+	CriticalityMedium   Criticality = "medium"
+	CriticalityHigh     Criticality = "high"
+	CriticalityCritical Criticality = "critical"
+)
+
+// InventoryEntry describes a known asset or identity. For ip entities the
+// value may be a CIDR range covering many addresses.
+type InventoryEntry struct {
+	ID           int64       `json:"id"`
+	EntityType   EntityType  `json:"entity_type"`  // user, host, ip
+	EntityValue  string      `json:"entity_value"` // exact value, or a CIDR range for ip
+	Owner        string      `json:"owner,omitempty"`
+	Department   string      `json:"department,omitempty"`
+	BusinessUnit string      `json:"business_unit,omitempty"`
+	Criticality  Criticality `json:"criticality,omitempty"`
+	Tags         []string    `json:"tags,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// HasTag reports whether the entry carries tag, ignoring case
+func (e *InventoryEntry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// InventoryRepository defines the interface for asset and identity inventory data access
+type InventoryRepository interface {
+	GetInventoryEntry(id int64) (*InventoryEntry, error)
+	ListInventoryEntries() ([]*InventoryEntry, error)
+	CreateInventoryEntry(entry *InventoryEntry) error
+	UpdateInventoryEntry(entry *InventoryEntry) error
+	DeleteInventoryEntry(id int64) error
+
+	// ImportInventoryEntries creates or updates entries keyed on entity type and value
+	ImportInventoryEntries(entries []*InventoryEntry) (int, error)
+
+	// MatchInventoryEntry finds the entry describing an entity, if any
+	MatchInventoryEntry(entityType EntityType, entityValue string) (*InventoryEntry, error)
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // MitreTechnique represents a MITRE ATT&CK technique
 type MitreTechnique struct {
 	ID             string   `json:"id"` // e.g. T1059.001
@@ -14,6 +16,34 @@ type MitreTechnique struct {
 	DataSources    []string `json:"data_sources,omitempty"`     // Data sources useful for detection
 	IsSubTechnique bool     `json:"is_sub_technique"`           // Whether this is a sub-technique
 	SubTechniqueOf string   `json:"sub_technique_of,omitempty"` // Parent technique ID if this is a sub-technique
+	Revoked        bool     `json:"revoked"`                    // Revoked by MITRE, usually in favour of another technique
+	Deprecated     bool     `json:"deprecated"`                 // Deprecated by MITRE
+	RevokedBy      string   `json:"revoked_by,omitempty"`       // Technique ID replacing a revoked technique
+	AttackVersion  string   `json:"attack_version,omitempty"`   // ATT&CK release the technique was last imported from
+}
+
+// TechniqueRemap is a detection mapped to a revoked or deprecated technique,
+// with the technique that replaces it, if any
+type TechniqueRemap struct {
+	DetectionID     int64           `json:"detection_id"`
+	DetectionName   string          `json:"detection_name"`
+	DetectionStatus DetectionStatus `json:"detection_status"`
+	TechniqueID     string          `json:"technique_id"`
+	TechniqueName   string          `json:"technique_name"`
+	Reason          string          `json:"reason"`                     // revoked or deprecated
+	ReplacementID   string          `json:"replacement_id,omitempty"`   // empty when the mapping needs a manual remap
+	ReplacementName string          `json:"replacement_name,omitempty"` // name of the replacing technique
+}
+
+// AppliedRemap records a detection mapping moved from a revoked technique to
+// its replacement
+type AppliedRemap struct {
+	ID             int64     `json:"id"`
+	DetectionID    int64     `json:"detection_id"`
+	OldTechniqueID string    `json:"old_technique_id"`
+	NewTechniqueID string    `json:"new_technique_id"`
+	AppliedBy      string    `json:"applied_by,omitempty"`
+	AppliedAt      time.Time `json:"applied_at"`
 }
 
 // MitreRepository defines the interface for MITRE technique data access
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// NavigatorLayer is an ATT&CK Navigator layer, the JSON format coverage and
+// threat intelligence are shared in. Only the fields used here are kept;
+// Navigator fills in defaults for the rest.
+type NavigatorLayer struct {
+	Name        string                 `json:"name"`
+	Versions    *NavigatorVersions     `json:"versions,omitempty"`
+	Domain      string                 `json:"domain"`
+	Description string                 `json:"description,omitempty"`
+	Techniques  []*NavigatorTechnique  `json:"techniques"`
+	Gradient    *NavigatorGradient     `json:"gradient,omitempty"`
+	LegendItems []*NavigatorLegendItem `json:"legendItems,omitempty"`
+}
+
+// LayerFilter selects the detections a Navigator layer is scored from
+type LayerFilter struct {
+	Statuses   []DetectionStatus // every status but retired when empty
+	ClassID    *int64
+	DataSource string // data source name
+	Domain     string // Navigator domain, enterprise-attack when empty
+}
+
+// NavigatorVersions are the ATT&CK, Navigator and layer format versions of a
+// layer
+type NavigatorVersions struct {
+	Attack    string `json:"attack,omitempty"`
+	Navigator string `json:"navigator,omitempty"`
+	Layer     string `json:"layer,omitempty"`
+}
+
+// NavigatorTechnique is the annotation of a technique in a layer. Score and
+// Enabled are optional in layers, so they are pointers.
+type NavigatorTechnique struct {
+	TechniqueID       string               `json:"techniqueID"`
+	Tactic            string               `json:"tactic,omitempty"` // tactic shortname; empty annotates the technique under all its tactics
+	Score             *float64             `json:"score,omitempty"`
+	Color             string               `json:"color,omitempty"`
+	Comment           string               `json:"comment,omitempty"`
+	Enabled           *bool                `json:"enabled,omitempty"`
+	Metadata          []*NavigatorMetadata `json:"metadata,omitempty"`
+	ShowSubtechniques bool                 `json:"showSubtechniques,omitempty"`
+}
+
+// NavigatorMetadata is a name/value pair shown with a technique
+type NavigatorMetadata struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// NavigatorGradient colors techniques by score
+type NavigatorGradient struct {
+	Colors   []string `json:"colors"`
+	MinValue float64  `json:"minValue"`
+	MaxValue float64  `json:"maxValue"`
+}
+
+// NavigatorLegendItem explains a color of a layer
+type NavigatorLegendItem struct {
+	Label string `json:"label"`
+	Color string `json:"color"`
+}
+
+// PriorityList is a list of techniques to prioritize coverage of, such as
+// the techniques a threat-intel layer marks as used by relevant adversaries
+type PriorityList struct {
+	ID             int64                `json:"id"`
+	Name           string               `json:"name"`
+	Description    string               `json:"description,omitempty"`
+	Source         string               `json:"source,omitempty"` // name of the layer the list was imported from
+	TechniqueCount int                  `json:"technique_count"`
+	CreatedAt      time.Time            `json:"created_at"`
+	Techniques     []*PriorityTechnique `json:"techniques,omitempty"`
+}
+
+// PriorityTechnique is a technique of a priority list, with the score the
+// threat-intel layer gave it
+type PriorityTechnique struct {
+	TechniqueID string  `json:"technique_id"`
+	Score       float64 `json:"score"`
+	Comment     string  `json:"comment,omitempty"`
+}
+
+// PriorityCoverage is the coverage of a priority technique by production
+// detections
+type PriorityCoverage struct {
+	TechniqueID          string  `json:"technique_id"`
+	Name                 string  `json:"name"`
+	Tactic               string  `json:"tactic"`
+	Priority             float64 `json:"priority"` // score from the priority list
+	ProductionDetections int     `json:"production_detections"`
+	Covered              bool    `json:"covered"`
+}
@@ -32,6 +32,20 @@ type RiskObject struct {
 	EntityValue  string     `json:"entity_value"`
 	CurrentScore int        `json:"current_score"`
 	LastSeen     time.Time  `json:"last_seen"`
+
+	// Asset or identity details from the inventory, when known
+	Inventory *InventoryEntry `json:"inventory,omitempty"`
+
+	// MITRE tactics and techniques hit by recent events, set on detail responses
+	TacticCoverage *TacticCoverage `json:"tactic_coverage,omitempty"`
+}
+
+// TacticCoverage lists the distinct MITRE tactics and techniques hit by a set
+// of events
+type TacticCoverage struct {
+	Tactics    []string   `json:"tactics"`
+	Techniques []string   `json:"techniques"`
+	Since      *time.Time `json:"since,omitempty"` // start of the window, when counted over one
 }
 
 // Event represents a detection trigger
@@ -44,6 +58,15 @@ type Event struct {
 	Context         string    `json:"context,omitempty"` // JSON field for detection context information
 	RiskPoints      int       `json:"risk_points"`
 	IsFalsePositive bool      `json:"is_false_positive"`
+	Contribution    int       `json:"contribution,omitempty"` // Points contributed to an alert, set when listed for one
+
+	// Client-supplied idempotency key; a replay within the dedup window
+	// returns the original event with Duplicate set instead of scoring again
+	ExternalID string `json:"external_id,omitempty"`
+	Duplicate  bool   `json:"duplicate,omitempty"`
+
+	// How RiskPoints was calculated when risk modifiers are enabled
+	RiskBreakdown *RiskBreakdown `json:"risk_breakdown,omitempty"`
 
 	// Relationships (for convenience)
 	Detection  *Detection  `json:"detection,omitempty"`
@@ -59,12 +82,121 @@ type RiskAlert struct {
 	Status      AlertStatus `json:"status"`
 	Notes       string      `json:"notes,omitempty"`
 	Owner       string      `json:"owner,omitempty"`
+	ClosedAt    *time.Time  `json:"closed_at,omitempty"` // Set when the status becomes Closed
+	GroupID     *int64      `json:"group_id,omitempty"`  // Set when raised on an entity group
+	RuleID      *int64      `json:"rule_id,omitempty"`   // Set when raised by an alert rule
+
+	// MITRE tactics and techniques of the contributing events, set on detail responses
+	TacticCoverage *TacticCoverage `json:"tactic_coverage,omitempty"`
 
 	// Relationships (for convenience)
 	RiskObject *RiskObject `json:"risk_object,omitempty"`
 	Events     []*Event    `json:"events,omitempty"` // Contributing events
 }
 
+// EntityGroup is a set of entities correlated by appearing together in event
+// context, scored and alerted on as a single incident entity
+type EntityGroup struct {
+	ID        int64         `json:"id"`
+	Score     int           `json:"score"` // Sum of the members' current scores
+	Members   []*RiskObject `json:"members"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// RiskThreshold is a threshold policy that overrides the global risk threshold
+// for matching entities. Empty criteria match anything; when several policies
+// match, the highest priority wins, then the most specific.
+type RiskThreshold struct {
+	ID               int64      `json:"id"`
+	Name             string     `json:"name"`
+	EntityType       EntityType `json:"entity_type,omitempty"`        // user, host, ip
+	ValuePattern     string     `json:"value_pattern,omitempty"`      // glob matched against entity_value, e.g. dc-*
+	Tag              string     `json:"tag,omitempty"`                // inventory tag the entity must carry, e.g. privileged
+	DetectionClassID *int64     `json:"detection_class_id,omitempty"` // class of the triggering detection
+	Threshold        int        `json:"threshold"`
+	Priority         int        `json:"priority"`
+	Enabled          bool       `json:"enabled"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// AlertRuleType is the condition an alert rule checks
+type AlertRuleType string
+
+const (
+	// At least MinCount distinct detections fired on the entity
+	AlertRuleDistinctDetections AlertRuleType = "distinct_detections"
+	// The entity's detections span at least MinCount distinct MITRE tactics
+	AlertRuleDistinctTactics AlertRuleType = "distinct_tactics"
+	// The entity's detections hit the tactics in Sequence in order
+	AlertRuleTacticSequence AlertRuleType = "tactic_sequence"
+)
+
+// AlertRule raises a risk alert when an entity's events within a window meet
+// a condition other than the score threshold
+type AlertRule struct {
+	ID            int64         `json:"id"`
+	Name          string        `json:"name"`
+	Description   string        `json:"description,omitempty"`
+	Type          AlertRuleType `json:"type"`
+	EntityType    EntityType    `json:"entity_type,omitempty"` // user, host, ip; empty matches any
+	MinCount      int           `json:"min_count,omitempty"`   // distinct detections or tactics required
+	Sequence      []string      `json:"sequence,omitempty"`    // tactics in order, e.g. Initial Access, Execution
+	WindowMinutes int           `json:"window_minutes"`
+	Enabled       bool          `json:"enabled"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// RiskBreakdown explains how an event's risk points were calculated from the
+// detection's base points
+type RiskBreakdown struct {
+	BasePoints      int            `json:"base_points"`
+	Modifiers       []RiskModifier `json:"modifiers"`
+	BonusPoints     int            `json:"bonus_points,omitempty"` // added for kill-chain breadth
+	EffectivePoints int            `json:"effective_points"`
+}
+
+// RiskModifier is one multiplier applied to an event's base points
+type RiskModifier struct {
+	Type       string  `json:"type"` // severity, criticality, entity or confidence
+	Name       string  `json:"name"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// ScoreChangeReason describes why a risk object's score changed
+type ScoreChangeReason string
+
+const (
+	ScoreChangeEvent                ScoreChangeReason = "event"
+	ScoreChangeDecay                ScoreChangeReason = "decay"
+	ScoreChangeFalsePositive        ScoreChangeReason = "false_positive"
+	ScoreChangeFalsePositiveRemoved ScoreChangeReason = "false_positive_removed"
+	ScoreChangeManual               ScoreChangeReason = "manual"
+)
+
+// RiskScoreChange records one change to a risk object's score
+type RiskScoreChange struct {
+	ID         int64             `json:"id"`
+	EntityID   int64             `json:"entity_id"`
+	RecordedAt time.Time         `json:"recorded_at"`
+	OldScore   int               `json:"old_score"`
+	NewScore   int               `json:"new_score"`
+	Reason     ScoreChangeReason `json:"reason"`
+	EventID    *int64            `json:"event_id,omitempty"`
+	Notes      string            `json:"notes,omitempty"`
+}
+
+// RiskScorePoint summarises a risk object's score over one time bucket
+type RiskScorePoint struct {
+	Time    time.Time `json:"time"`    // start of the bucket
+	Score   int       `json:"score"`   // score at the end of the bucket
+	Min     int       `json:"min"`     // lowest score held during the bucket
+	Max     int       `json:"max"`     // highest score held during the bucket
+	Changes int       `json:"changes"` // number of score changes in the bucket
+}
+
 // FalsePositive represents an analyst-logged false positive
 type FalsePositive struct {
 	ID          int64     `json:"id"`
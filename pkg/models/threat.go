@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// ThreatGroup is an adversary group, such as an ATT&CK intrusion set, with
+// the techniques it is known to use
+type ThreatGroup struct {
+	ID             string   `json:"id"` // e.g. G0016
+	Name           string   `json:"name"`
+	Aliases        []string `json:"aliases,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	AttackVersion  string   `json:"attack_version,omitempty"` // ATT&CK release the group was last imported from
+	TechniqueCount int      `json:"technique_count"`
+	Techniques     []string `json:"techniques,omitempty"` // IDs of the techniques the group uses
+}
+
+// ThreatProfile is a set of threat groups coverage is measured against,
+// such as the groups known to target an industry
+type ThreatProfile struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	GroupIDs    []string  `json:"group_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ThreatTechniqueCoverage is the coverage of a technique used by the groups
+// of a threat profile, scored as in the coverage tree
+type ThreatTechniqueCoverage struct {
+	TechniqueID string   `json:"technique_id"`
+	Name        string   `json:"name"`
+	Tactics     []string `json:"tactics"`
+	Groups      []string `json:"groups"` // names of the profile's groups using the technique
+	GroupCount  int      `json:"group_count"`
+	Detections  int      `json:"detections"` // detections counted under the mode
+	Coverage    float64  `json:"coverage"`   // from 0 to 1, including sub-techniques
+	Covered     bool     `json:"covered"`    // fully covered
+	Partial     bool     `json:"partial"`    // some, but not full, coverage
+}
+
+// ThreatCoverageSummary totals the coverage of a threat profile. The
+// weighted percentage counts each technique once per group using it.
+type ThreatCoverageSummary struct {
+	Techniques              int     `json:"techniques"`
+	Covered                 int     `json:"covered"`
+	Partial                 int     `json:"partial"`
+	Gaps                    int     `json:"gaps"`
+	CoveragePercent         float64 `json:"coverage_percent"`
+	WeightedCoveragePercent float64 `json:"weighted_coverage_percent"`
+}
+
+// ThreatCoverage is the coverage of the techniques a threat profile's
+// groups use, with the ones not fully covered ranked by how many groups use
+// them
+type ThreatCoverage struct {
+	Profile    *ThreatProfile             `json:"profile"`
+	Mode       CoverageMode               `json:"mode"`
+	Techniques []*ThreatTechniqueCoverage `json:"techniques"`
+	Gaps       []*ThreatTechniqueCoverage `json:"gaps"`
+	ByTactic   map[string]float64         `json:"by_tactic"` // coverage percentage of the profile's techniques per tactic
+	Summary    ThreatCoverageSummary      `json:"summary"`
+}
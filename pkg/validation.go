@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/mail"
 	"net/url"
+	"path"
 	"regexp"
 	"strings"
 
@@ -26,6 +27,9 @@ var (
 	// MITRE technique ID pattern: T followed by 4 digits, optionally .XXX for sub-techniques
 	mitreIDPattern = regexp.MustCompile(`^T\d{4}(\.\d{3})?$`)
 
+	// UUID pattern for detection external IDs, in any version and case
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 	// Valid MITRE domains
 	validDomains = map[string]bool{
 		"Enterprise": true,
@@ -33,6 +37,13 @@ var (
 		"ICS":        true,
 	}
 
+	// Valid ATT&CK Navigator layer domains
+	validLayerDomains = map[string]bool{
+		"enterprise-attack": true,
+		"mobile-attack":     true,
+		"ics-attack":        true,
+	}
+
 	// Valid log formats
 	validLogFormats = map[string]bool{
 		"JSON":   true,
@@ -97,6 +108,11 @@ func ValidateDetection(detection *models.Detection) error {
 		return fmt.Errorf("invalid risk object type: %s", detection.RiskObject)
 	}
 
+	// Validate external ID if provided
+	if detection.ExternalID != "" && !uuidPattern.MatchString(detection.ExternalID) {
+		return fmt.Errorf("invalid external ID, must be a UUID: %s", detection.ExternalID)
+	}
+
 	// Additional validation for production detections
 	if detection.Status == models.StatusProduction {
 		if detection.Query == "" {
@@ -248,6 +264,199 @@ func ValidateDataSource(dataSource *models.DataSource) error {
 	return nil
 }
 
+// ValidateRiskThreshold validates a risk threshold policy
+func ValidateRiskThreshold(threshold *models.RiskThreshold) error {
+	if threshold.Name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+
+	if threshold.Threshold <= 0 {
+		return fmt.Errorf("threshold must be positive")
+	}
+
+	if threshold.EntityType != "" && !isValidEntityType(threshold.EntityType) {
+		return fmt.Errorf("invalid entity type: %s", threshold.EntityType)
+	}
+
+	if threshold.ValuePattern != "" {
+		if _, err := path.Match(threshold.ValuePattern, ""); err != nil {
+			return fmt.Errorf("invalid value pattern: %s", threshold.ValuePattern)
+		}
+	}
+
+	return nil
+}
+
+// ValidateAlertRule validates an alert rule
+func ValidateAlertRule(rule *models.AlertRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+
+	if rule.EntityType != "" && !isValidEntityType(rule.EntityType) {
+		return fmt.Errorf("invalid entity type: %s", rule.EntityType)
+	}
+
+	if rule.WindowMinutes <= 0 {
+		return fmt.Errorf("window_minutes must be positive")
+	}
+
+	switch rule.Type {
+	case models.AlertRuleDistinctDetections, models.AlertRuleDistinctTactics:
+		if rule.MinCount <= 0 {
+			return fmt.Errorf("min_count must be positive")
+		}
+	case models.AlertRuleTacticSequence:
+		if len(rule.Sequence) == 0 {
+			return fmt.Errorf("sequence cannot be empty")
+		}
+		for _, tactic := range rule.Sequence {
+			if strings.TrimSpace(tactic) == "" {
+				return fmt.Errorf("sequence cannot contain empty tactics")
+			}
+		}
+	default:
+		return fmt.Errorf("invalid rule type: %s", rule.Type)
+	}
+
+	return nil
+}
+
+// ValidateInventoryEntry validates an inventory entry model
+func ValidateInventoryEntry(entry *models.InventoryEntry) error {
+	entityType := models.EntityType(strings.ToLower(string(entry.EntityType)))
+	if !isValidEntityType(entityType) {
+		return fmt.Errorf("invalid entity type: %s", entry.EntityType)
+	}
+
+	if strings.TrimSpace(entry.EntityValue) == "" {
+		return fmt.Errorf("entity value cannot be empty")
+	}
+
+	// IP entries may cover a whole range
+	if entityType == models.EntityTypeIP && !isValidIPAddress(entry.EntityValue) {
+		if _, _, err := net.ParseCIDR(entry.EntityValue); err != nil {
+			return fmt.Errorf("invalid IP address or CIDR range: %s", entry.EntityValue)
+		}
+	}
+
+	if entry.Criticality != "" && !isValidCriticality(entry.Criticality) {
+		return fmt.Errorf("invalid criticality: %s", entry.Criticality)
+	}
+
+	return nil
+}
+
+// ValidateEmulationTest validates an emulation test execution. A zero window
+// is allowed and means the default window.
+func ValidateEmulationTest(test *models.EmulationTest) error {
+	if !mitreIDPattern.MatchString(test.TechniqueID) {
+		return fmt.Errorf("invalid MITRE technique ID format: %s", test.TechniqueID)
+	}
+
+	if strings.TrimSpace(test.TestName) == "" {
+		return fmt.Errorf("test name cannot be empty")
+	}
+
+	if !isValidHostname(test.TargetHost) && !isValidIPAddress(test.TargetHost) {
+		return fmt.Errorf("invalid target host: %s", test.TargetHost)
+	}
+
+	if test.ExecutedAt.IsZero() {
+		return fmt.Errorf("execution time is required")
+	}
+
+	// A week is long enough for any batch of delayed events
+	if test.WindowMinutes < 0 || test.WindowMinutes > 7*24*60 {
+		return fmt.Errorf("window must be between 0 and 10080 minutes")
+	}
+
+	if len(test.ExpectedDetections) == 0 {
+		return fmt.Errorf("at least one expected detection is required")
+	}
+	seen := make(map[int64]bool)
+	for _, expected := range test.ExpectedDetections {
+		if expected == nil || expected.DetectionID <= 0 {
+			return fmt.Errorf("expected detections need a detection ID")
+		}
+		if seen[expected.DetectionID] {
+			return fmt.Errorf("duplicate expected detection: %d", expected.DetectionID)
+		}
+		seen[expected.DetectionID] = true
+	}
+
+	return nil
+}
+
+// ValidateLayerFilter validates the detection filter of a Navigator layer
+func ValidateLayerFilter(filter *models.LayerFilter) error {
+	for _, status := range filter.Statuses {
+		if !isValidDetectionStatus(status) {
+			return fmt.Errorf("invalid status: %s", status)
+		}
+	}
+
+	if filter.Domain != "" && !validLayerDomains[filter.Domain] {
+		return fmt.Errorf("invalid domain: %s", filter.Domain)
+	}
+
+	return nil
+}
+
+// ValidateCoverageMode validates a technique coverage mode
+func ValidateCoverageMode(mode models.CoverageMode) error {
+	switch mode {
+	case models.CoverageAny, models.CoverageProduction, models.CoverageWeighted:
+		return nil
+	default:
+		return fmt.Errorf("invalid coverage mode: %s, must be any, production or weighted", mode)
+	}
+}
+
+// ValidateNavigatorLayer validates an ATT&CK Navigator layer imported as a
+// priority list
+func ValidateNavigatorLayer(layer *models.NavigatorLayer) error {
+	if len(layer.Techniques) == 0 {
+		return fmt.Errorf("layer has no techniques")
+	}
+
+	for _, technique := range layer.Techniques {
+		if technique == nil {
+			return fmt.Errorf("layer has an empty technique")
+		}
+		if !mitreIDPattern.MatchString(technique.TechniqueID) {
+			return fmt.Errorf("invalid MITRE technique ID format: %s", technique.TechniqueID)
+		}
+	}
+
+	return nil
+}
+
+// ValidateThreatProfile validates a threat profile
+func ValidateThreatProfile(profile *models.ThreatProfile) error {
+	if strings.TrimSpace(profile.Name) == "" {
+		return fmt.Errorf("profile name cannot be empty")
+	}
+	if len(profile.Name) > MaxDetectionNameLength {
+		return fmt.Errorf("profile name too long (max %d characters)", MaxDetectionNameLength)
+	}
+
+	if len(profile.Description) > MaxDescriptionLength {
+		return fmt.Errorf("description too long (max %d characters)", MaxDescriptionLength)
+	}
+
+	if len(profile.GroupIDs) == 0 {
+		return fmt.Errorf("at least one threat group is required")
+	}
+	for _, groupID := range profile.GroupIDs {
+		if strings.TrimSpace(groupID) == "" {
+			return fmt.Errorf("threat group IDs cannot be empty")
+		}
+	}
+
+	return nil
+}
+
 // ValidateRiskAlert validates a risk alert model
 func ValidateRiskAlert(alert *models.RiskAlert) error {
 	if alert.EntityID <= 0 {
@@ -337,6 +546,15 @@ func isValidEntityType(entityType models.EntityType) bool {
 	}
 }
 
+func isValidCriticality(criticality models.Criticality) bool {
+	switch criticality {
+	case models.CriticalityLow, models.CriticalityMedium, models.CriticalityHigh, models.CriticalityCritical:
+		return true
+	default:
+		return false
+	}
+}
+
 func isValidAlertStatus(status models.AlertStatus) bool {
 	switch status {
 	case models.AlertStatusNew, models.AlertStatusTriage, models.AlertStatusInvestigation,
@@ -11,6 +11,10 @@ class RiskObjectDetailAPI {
     static async fetchDetections() {
         return await APIUtils.fetchAPI('/api/detections');
     }
+
+    static async fetchScoreHistory(id, bucket) {
+        return await APIUtils.fetchAPI(`/api/risk/objects/${id}/history?bucket=${bucket}`);
+    }
 }
 
 // Alpine.js risk object detail data function
@@ -20,6 +24,8 @@ function riskObjectDetailData() {
         events: [],
         detections: {},
         riskAlerts: [],
+        scoreHistory: null,
+        historyBucket: 'day',
         loading: true,
         objectId: null,
         chart: null,
@@ -44,6 +50,7 @@ function riskObjectDetailData() {
                 
                 await Promise.all([
                     this.fetchEvents(),
+                    this.fetchScoreHistory(),
                     this.fetchDetections(),
                     this.fetchRiskAlerts()
                 ]);
@@ -87,6 +94,36 @@ function riskObjectDetailData() {
             }
         },
         
+        async fetchScoreHistory() {
+            try {
+                this.scoreHistory = await RiskObjectDetailAPI.fetchScoreHistory(this.objectId, this.historyBucket);
+            } catch (error) {
+                console.error('Error fetching score history:', error);
+                this.scoreHistory = null;
+            }
+        },
+        
+        async changeHistoryBucket(bucket) {
+            this.historyBucket = bucket;
+            await this.fetchScoreHistory();
+            this.resetChart();
+        },
+        
+        getChartHistory() {
+            // Prefer the recorded score history, which includes decay and
+            // false positive adjustments, over a reconstruction from events
+            const points = this.scoreHistory?.points || [];
+            if (points.some(p => p.changes > 0 || p.score > 0)) {
+                return points.map(p => ({
+                    timestamp: new Date(p.time),
+                    score: p.score,
+                    min: p.min,
+                    max: p.max
+                }));
+            }
+            return this.calculateRiskScoreHistory().reverse();
+        },
+        
         async fetchDetections() {
             try {
                 const detectionsResp = await RiskObjectDetailAPI.fetchDetections();
@@ -260,7 +297,7 @@ function riskObjectDetailData() {
                 this.chart = null;
             }
             
-            let history = this.calculateRiskScoreHistory().reverse(); // Show chronological order for chart
+            let history = this.getChartHistory(); // Chronological order for chart
             console.log('History for chart:', history);
             
             // If no history but we have a current score, create a minimal chart with current score